@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/config"
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+// cacheDir is where the response cache lives
+func cacheDir(cfg *config.Config) string {
+	if cfg.AI.Cache.Dir != "" {
+		return cfg.AI.Cache.Dir
+	}
+	return filepath.Join(cfg.Paths.OutputDir, "cache")
+}
+
+// responseCache opens the response cache configured under ai.cache
+func responseCache(cfg *config.Config) *agent.ResponseCache {
+	ttl := cfg.AI.Cache.TTL
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+	return agent.NewResponseCache(storage.NewFileSystem(cacheDir(cfg)), ttl).
+		WithLimits(cfg.AI.Cache.MaxEntries, int64(cfg.AI.Cache.MaxSizeMB)<<20)
+}
+
+func runCache(ctx context.Context, args []string) error {
+	usage := "Usage: orc cache stats | clear | prune"
+	if len(args) != 1 {
+		fmt.Println(usage)
+		return fmt.Errorf("cache requires a subcommand")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	cache := responseCache(cfg)
+
+	switch args[0] {
+	case "stats":
+		stats, err := cache.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Response cache: %s\n", cacheDir(cfg))
+		if !cfg.AI.Cache.Enabled {
+			fmt.Println("  (disabled; set ai.cache.enabled to use it)")
+		}
+		fmt.Printf("  %d entries, %.1f MB", stats.Entries, float64(stats.Bytes)/(1<<20))
+		if cfg.AI.Cache.MaxEntries > 0 || cfg.AI.Cache.MaxSizeMB > 0 {
+			fmt.Printf(" (limits: %d entries, %d MB)", cfg.AI.Cache.MaxEntries, cfg.AI.Cache.MaxSizeMB)
+		}
+		fmt.Println()
+		if stats.Entries == 0 {
+			return nil
+		}
+		fmt.Printf("  %d expired, %d unreadable\n", stats.Expired, stats.Unreadable)
+		fmt.Printf("  oldest %s, newest %s\n", stats.Oldest.Format("2006-01-02 15:04"), stats.Newest.Format("2006-01-02 15:04"))
+
+		models := make([]string, 0, len(stats.ByModel))
+		for model := range stats.ByModel {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for _, model := range models {
+			fmt.Printf("  %-32s %d\n", model, stats.ByModel[model])
+		}
+
+	case "clear":
+		removed, err := cache.Clear(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %d cached responses\n", removed)
+
+	case "prune":
+		removed, err := cache.Prune(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Pruned %d expired or evicted responses\n", removed)
+
+	default:
+		fmt.Println(usage)
+		return fmt.Errorf("unknown cache subcommand %q", args[0])
+	}
+	return nil
+}
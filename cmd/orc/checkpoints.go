@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+func runCheckpoints(ctx context.Context, opts globalOptions, args []string) error {
+	flags := flag.NewFlagSet("checkpoints", flag.ExitOnError)
+	phase := flags.String("phase", "", "only show checkpoints taken after this phase")
+	flags.Usage = func() {
+		fmt.Println("Usage: orc checkpoints [-phase <name>] <session-id>")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("checkpoints requires exactly one session ID")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		return err
+	}
+	store, info, err := a.openSession(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	checkpoints := core.NewCheckpointManager(store)
+	var history []*core.Checkpoint
+	if *phase != "" {
+		history, err = checkpoints.ListByPhase(ctx, info.ID, *phase)
+	} else {
+		history, err = checkpoints.History(ctx, info.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		fmt.Printf("No checkpoints for session %s\n", info.ID)
+		return nil
+	}
+
+	fmt.Printf("Checkpoints for %s session %s:\n", info.Plugin, info.ID)
+	for _, chk := range history {
+		note := ""
+		switch {
+		case chk.ForkedFrom != "":
+			note = fmt.Sprintf("  (forked from %s@%s)", chk.ForkedFrom, chk.ParentID)
+		case chk.ParentID != "":
+			note = fmt.Sprintf("  (restored from %s)", chk.ParentID)
+		}
+		fmt.Printf("  %s  %s  after phase %d: %s%s\n",
+			chk.CheckpointID, chk.Timestamp.Format("2006-01-02 15:04:05"), chk.PhaseIndex, chk.PhaseName, note)
+	}
+	fmt.Printf("\nRewind with: orc resume -from <checkpoint> %s\n", info.ID)
+	fmt.Printf("Branch with: orc fork %s <checkpoint>\n", info.ID)
+	return nil
+}
+
+func runFork(ctx context.Context, opts globalOptions, args []string) error {
+	flags := flag.NewFlagSet("fork", flag.ExitOnError)
+	pipeline := flags.String("pipeline", "", "run the fork with a different pipeline")
+	vars := varsFlag{}
+	flags.Var(vars, "set", "set a pipeline variable for the fork (key=value, repeatable)")
+	flags.Usage = func() {
+		fmt.Println("Usage: orc fork [-pipeline <name|file>] [-set key=value] <session-id> <checkpoint>")
+		fmt.Println("Starts a new session from a checkpoint of an existing one; see 'orc checkpoints'.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("fork requires a session ID and a checkpoint")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		return err
+	}
+	source, info, err := a.openSession(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// Fork into a scratch directory first; the session directory is named after the new ID
+	scratch, err := os.MkdirTemp(a.sessionsDir(), ".fork-")
+	if err != nil {
+		return fmt.Errorf("creating session directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	forkID, err := core.NewCheckpointManager(source).ForkInto(ctx, info.ID, flags.Arg(1), core.NewCheckpointManager(storage.NewFileSystem(scratch)))
+	if err != nil {
+		return err
+	}
+	forkDir := storage.CreateSessionPath(a.cfg.Paths.OutputDir, forkID, info.Request, storage.SessionUUID)
+	if err := copySessionFiles(source.BaseDir(), scratch); err != nil {
+		return fmt.Errorf("copying session output: %w", err)
+	}
+	if err := os.Rename(scratch, forkDir); err != nil {
+		return fmt.Errorf("creating session directory: %w", err)
+	}
+	store := a.sessionStore(forkDir, forkID)
+	lock, err := lockSession(store)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if cached, ok := store.(*storage.Cached); ok {
+		if _, err := cached.Push(ctx); err != nil {
+			return fmt.Errorf("uploading forked session: %w", err)
+		}
+	}
+
+	chk, err := core.NewCheckpointManager(store).Load(ctx, forkID)
+	if err != nil {
+		return err
+	}
+
+	parentID := info.ID
+	info.ID = forkID
+	info.ForkedFrom = parentID
+	info.CreatedAt = time.Now()
+	if *pipeline != "" {
+		if _, info.Pipeline, err = loadPipeline(*pipeline); err != nil {
+			return err
+		}
+	} else if info.Pipeline == "" && len(vars) > 0 {
+		info.Pipeline = info.Plugin
+	}
+	if len(vars) > 0 {
+		if info.Vars == nil {
+			info.Vars = make(map[string]string, len(vars))
+		}
+		for k, v := range vars {
+			info.Vars[k] = v
+		}
+	}
+	if err := saveSessionInfo(ctx, store, info); err != nil {
+		return err
+	}
+	if err := a.initPlugins(ctx, store); err != nil {
+		return fmt.Errorf("initializing plugins: %w", err)
+	}
+
+	fmt.Printf("🌱 Forked session %s from %s after phase %q\n", forkID, parentID, chk.PhaseName)
+	return a.runSession(ctx, store, info, chk.PhaseIndex)
+}
+
+// openSession resolves a session ID or prefix to its storage and recorded info. With
+// a remote backend, sessions missing locally are looked up there, and the local copy
+// is brought up to date before use.
+func (a *app) openSession(ctx context.Context, id string) (sessionStore, sessionInfo, error) {
+	sessionDir, err := findSessionDir(a.sessionsDir(), id)
+	if err != nil && a.remote == nil {
+		return nil, sessionInfo{}, err
+	}
+	if err != nil {
+		remoteID, findErr := a.findRemoteSession(ctx, id)
+		if findErr != nil {
+			return nil, sessionInfo{}, findErr
+		}
+		sessionDir = storage.CreateSessionPath(a.cfg.Paths.OutputDir, remoteID, "", storage.SessionUUID)
+	}
+	store := a.sessionStore(sessionDir, filepath.Base(sessionDir))
+	if cached, ok := store.(*storage.Cached); ok {
+		if _, err := cached.Pull(ctx); err != nil {
+			return nil, sessionInfo{}, fmt.Errorf("downloading session: %w", err)
+		}
+	}
+
+	info, err := loadSessionInfo(ctx, store)
+	if err != nil {
+		return nil, sessionInfo{}, err
+	}
+	return store, info, nil
+}
+
+// copySessionFiles copies the output a session's phases saved, which later phases
+// may read back, leaving out its checkpoints and session record
+func copySessionFiles(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "checkpoints" || rel == sessionFile || rel == storage.LockFile {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+}
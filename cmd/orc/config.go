@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/dotcommander/orc/internal/config"
+)
+
+// apiKeyPattern masks literal API keys when printing the config file
+var apiKeyPattern = regexp.MustCompile(`(?m)^(\s*api_key:\s*)([^\s$#][^\s#]*)`)
+
+func runConfig(args []string) error {
+	usage := "Usage: orc config get <key> | set <key> <value> | list | path"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return fmt.Errorf("config requires a subcommand")
+	}
+
+	path := config.ConfigPath()
+
+	switch args[0] {
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: orc config get <key>")
+		}
+		value, err := config.GetValue(path, args[1])
+		if err != nil {
+			return err
+		}
+		if args[1] == "ai.api_key" {
+			value = maskSecret(value)
+		}
+		fmt.Println(value)
+
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: orc config set <key> <value>")
+		}
+		if err := config.SetValue(path, args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s updated in %s\n", args[1], path)
+
+	case "list":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+		fmt.Printf("# %s\n", path)
+		fmt.Print(apiKeyPattern.ReplaceAllStringFunc(string(data), func(line string) string {
+			m := apiKeyPattern.FindStringSubmatch(line)
+			return m[1] + maskSecret(m[2])
+		}))
+
+	case "path":
+		fmt.Println(path)
+
+	default:
+		fmt.Println(usage)
+		return fmt.Errorf("unknown config subcommand: %s", args[0])
+	}
+
+	return nil
+}
+
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return "********"
+	}
+	return s[:4] + "..." + s[len(s)-4:]
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+func runCreate(ctx context.Context, opts globalOptions, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	pipeline := flags.String("pipeline", "", "pipeline to run: a YAML file, a name in "+pipelinesDir()+", or a built-in")
+	vars := varsFlag{}
+	flags.Var(vars, "set", "set a pipeline variable (key=value, repeatable)")
+	flags.Usage = func() {
+		fmt.Println(`Usage: orc create [-pipeline <name|file>] [-set key=value] <plugin> "<request>"`)
+		fmt.Println(`Example: orc create fiction "Write a sci-fi thriller about AI consciousness"`)
+		fmt.Println(`Example: orc create -pipeline fiction-outline fiction "Write a sci-fi thriller"`)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("create requires a plugin name and a request")
+	}
+	pluginName := flags.Arg(0)
+	request := strings.TrimSpace(strings.Join(flags.Args()[1:], " "))
+
+	a, err := newApp(opts)
+	if err != nil {
+		return err
+	}
+
+	sessionID := uuid.New().String()
+	store := a.sessionStore(storage.CreateSessionPath(a.cfg.Paths.OutputDir, sessionID, request, storage.SessionUUID), sessionID)
+	lock, err := lockSession(store)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if err := a.initPlugins(ctx, store); err != nil {
+		return fmt.Errorf("initializing plugins: %w", err)
+	}
+
+	p, err := a.integrator.GetDomainRegistry().Get(pluginName)
+	if err != nil {
+		return fmt.Errorf("%w (available: %s)", err, strings.Join(a.integrator.GetDomainRegistry().List(), ", "))
+	}
+	if err := p.ValidateRequest(request); err != nil {
+		return fmt.Errorf("invalid request for %s: %w", pluginName, err)
+	}
+
+	info := sessionInfo{
+		ID:        sessionID,
+		Plugin:    pluginName,
+		Request:   request,
+		CreatedAt: time.Now(),
+	}
+	if *pipeline == "" && len(vars) > 0 {
+		*pipeline = pluginName // Variables apply to the plugin's built-in pipeline
+	}
+	if *pipeline != "" {
+		if _, info.Pipeline, err = loadPipeline(*pipeline); err != nil {
+			return err
+		}
+		if len(vars) > 0 {
+			info.Vars = vars
+		}
+		if _, err := withPipeline(p, info); err != nil {
+			return err
+		}
+	}
+	if err := saveSessionInfo(ctx, store, info); err != nil {
+		return err
+	}
+
+	fmt.Printf("🚀 Starting %s session %s\n", pluginName, sessionID)
+	return a.runSession(ctx, store, info, 0)
+}
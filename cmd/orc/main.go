@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/config"
+	"github.com/dotcommander/orc/internal/core"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/internal/plugin"
+)
+
+// Set at build time via -ldflags (see Makefile)
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// globalOptions are flags accepted before the subcommand
+type globalOptions struct {
+	configPath string
+	outputDir  string
+	verbose    bool
+	recordPath string
+	replayPath string
+}
+
+func main() {
+	opts := globalOptions{}
+	flags := flag.NewFlagSet("orc", flag.ExitOnError)
+	flags.StringVar(&opts.configPath, "config", "", "path to config file (default $XDG_CONFIG_HOME/orchestrator/config.yaml)")
+	flags.StringVar(&opts.outputDir, "output", "", "override the output directory")
+	flags.BoolVar(&opts.verbose, "verbose", false, "enable debug logging")
+	flags.StringVar(&opts.recordPath, "record", "", "record AI requests and responses to a cassette file")
+	flags.StringVar(&opts.replayPath, "replay", "", "serve AI responses from a cassette file instead of the network")
+	showVersion := flags.Bool("version", false, "print version and exit")
+	flags.Usage = func() { printUsage(flags) }
+	flags.Parse(os.Args[1:])
+
+	if *showVersion {
+		fmt.Printf("orc %s (commit %s, built %s)\n", Version, Commit, BuildTime)
+		return
+	}
+
+	args := flags.Args()
+	if len(args) == 0 {
+		printUsage(flags)
+		os.Exit(1)
+	}
+
+	if opts.recordPath != "" && opts.replayPath != "" {
+		fmt.Fprintln(os.Stderr, "Error: -record and -replay cannot be used together")
+		os.Exit(1)
+	}
+
+	if opts.configPath != "" {
+		os.Setenv("ORCHESTRATOR_CONFIG", opts.configPath)
+	}
+
+	level := slog.LevelInfo
+	if opts.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = runCreate(ctx, opts, args[1:])
+	case "resume":
+		err = runResume(ctx, opts, args[1:])
+	case "checkpoints":
+		err = runCheckpoints(ctx, opts, args[1:])
+	case "fork":
+		err = runFork(ctx, opts, args[1:])
+	case "config":
+		err = runConfig(args[1:])
+	case "plugins":
+		err = runPlugins(ctx, opts, args[1:])
+	case "cache":
+		err = runCache(ctx, args[1:])
+	case "version":
+		fmt.Printf("orc %s (commit %s, built %s)\n", Version, Commit, BuildTime)
+	case "help":
+		printUsage(flags)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+		printUsage(flags)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printUsage(flags *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, `Usage: orc [flags] <command> [arguments]
+
+Commands:
+  create <plugin> "<request>"   Generate content with a plugin (e.g. fiction, code)
+  resume <session-id>           Continue a session from its last checkpoint
+  checkpoints <session-id>      List a session's checkpoint history
+  fork <session-id> <checkpoint>
+                                Start a new session from an earlier checkpoint
+  config get|set|list|path      Inspect or change configuration
+  plugins                       List available plugins
+  plugins deps [-path dir]      Show plugin load order and dependency problems
+  cache stats|clear|prune       Inspect or empty the response cache
+  version                       Print version information
+
+Flags:
+`)
+	flags.PrintDefaults()
+}
+
+// app bundles the components shared by every command that talks to the AI
+type app struct {
+	cfg        *config.Config
+	client     agent.AIClient
+	integrator *plugin.PluginIntegrator
+	logger     *slog.Logger
+	// remote holds sessions when storage.backend is s3 or sqlite; nil keeps them
+	// only locally
+	remote remoteBackend
+}
+
+// newApp loads configuration and wires the AI client and plugin integrator
+func newApp(opts globalOptions) (*app, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if opts.outputDir != "" {
+		cfg.Paths.OutputDir = opts.outputDir
+	}
+
+	logger := slog.Default()
+	clientOpts := []agent.Option{
+		agent.WithAPIConfig(cfg.AI.BaseURL, cfg.AI.Model),
+		agent.WithTimeout(time.Duration(cfg.AI.Timeout) * time.Second),
+		agent.WithRetry(cfg.Limits.MaxRetries),
+		agent.WithRateLimit(cfg.Limits.RateLimit.RequestsPerMinute, cfg.Limits.RateLimit.BurstSize),
+		agent.WithLogger(logger.With("component", "ai_client")),
+		agent.WithGenerationOptions(generationOptions(cfg.AI.GenerationConfig)),
+		agent.WithContextWindow(cfg.AI.ContextWindow),
+		agent.WithUsageHook(func(ctx context.Context, u agent.Usage) {
+			if u.Batch {
+				core.RecordBatchUsage(ctx, agent.RoleFromContext(ctx), u.Model, u.InputTokens, u.OutputTokens)
+				return
+			}
+			core.RecordUsage(ctx, agent.RoleFromContext(ctx), u.Model, u.InputTokens, u.OutputTokens)
+		}),
+	}
+	if cfg.AI.Provider != "" {
+		provider, err := agent.GetProvider(cfg.AI.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("ai.provider: %w", err)
+		}
+		clientOpts = append(clientOpts, agent.WithProvider(provider))
+	}
+	base := agent.NewClient(cfg.AI.APIKey, clientOpts...)
+	var client agent.AIClient = base
+	if len(cfg.AI.Models) > 0 || len(cfg.AI.FallbackModels) > 0 {
+		client = agent.NewModelRouter(base, cfg.AI.Models, cfg.AI.FallbackModels)
+	}
+
+	interceptors, err := buildInterceptors(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := remoteStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recording sits outside the chain so cassettes hold what callers saw; replay
+	// stands in for the network, inside it
+	switch {
+	case opts.replayPath != "":
+		replay, err := agent.NewReplayClient(opts.replayPath)
+		if err != nil {
+			return nil, err
+		}
+		client = intercept(replay.WithModels(cfg.AI.Models, base.Model()), interceptors)
+	case opts.recordPath != "":
+		if client, err = agent.NewRecordingClient(intercept(client, interceptors), opts.recordPath); err != nil {
+			return nil, err
+		}
+	default:
+		client = intercept(client, interceptors)
+	}
+
+	integrator := plugin.NewPluginIntegrator(cfg, logger.With("component", "plugins"))
+	integrator.SetHostVersion(Version)
+	integrator.SetConcurrencyLimit(base.Limiter())
+
+	return &app{
+		cfg:        cfg,
+		client:     client,
+		integrator: integrator,
+		logger:     logger,
+		remote:     remote,
+	}, nil
+}
+
+// promptsDir is the directory holding the prompt templates used by the agent factory
+func (a *app) promptsDir() string {
+	return filepath.Dir(a.cfg.Paths.Prompts.Orchestrator)
+}
+
+// sessionsDir is where per-session output and checkpoints live
+func (a *app) sessionsDir() string {
+	return filepath.Join(a.cfg.Paths.OutputDir, "sessions")
+}
+
+// initPlugins registers the built-in plugins against storage rooted at the session
+// directory, then loads the external plugins found under plugins.discovery_paths
+func (a *app) initPlugins(ctx context.Context, store sessionStore) error {
+	domainAgent := agent.New(a.client, "")
+	if err := a.integrator.InitializeBuiltinPlugins(domainAgent, store, a.promptsDir(), a.client); err != nil {
+		return err
+	}
+	if _, err := a.integrator.DiscoverExternalPlugins(ctx); err != nil {
+		return err
+	}
+
+	if len(a.cfg.AI.Generation) == 0 {
+		return nil
+	}
+	roleOptions := make(map[string]agent.GenerationOptions, len(a.cfg.AI.Generation))
+	for role, gen := range a.cfg.AI.Generation {
+		roleOptions[role] = generationOptions(gen)
+	}
+	for _, p := range a.integrator.GetDomainRegistry().GetPlugins() {
+		if host, ok := p.(domainPlugin.PipelineHost); ok {
+			host.PhaseEnv().Agents.WithRoleOptions(roleOptions)
+		}
+	}
+	return nil
+}
+
+// buildInterceptors creates the interceptor chain configured under ai.interceptors.
+// An enabled ai.cache goes outermost unless the chain places it explicitly.
+func buildInterceptors(cfg *config.Config, logger *slog.Logger) ([]agent.Interceptor, error) {
+	var specs []agent.InterceptorSpec
+	listed := false
+	for _, ic := range cfg.AI.Interceptors {
+		specs = append(specs, agent.InterceptorSpec{Name: ic.Name, Options: ic.Options})
+		listed = listed || ic.Name == "cache"
+	}
+	if cfg.AI.Cache.Enabled && !listed {
+		cache := agent.InterceptorSpec{Name: "cache", Options: agent.InterceptorOptions{"phases": cfg.AI.Cache.Phases}}
+		specs = append([]agent.InterceptorSpec{cache}, specs...)
+	}
+
+	interceptors, err := agent.BuildInterceptors(specs, agent.InterceptorEnv{
+		Cache:       responseCache(cfg),
+		DumpDir:     filepath.Join(cfg.Paths.OutputDir, "dumps"),
+		CheckBudget: core.CheckUsageBudget,
+		Logger:      logger.With("component", "interceptors"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ai.interceptors: %w", err)
+	}
+	return interceptors, nil
+}
+
+// intercept wraps client in the interceptor chain, if one is configured
+func intercept(client agent.AIClient, interceptors []agent.Interceptor) agent.AIClient {
+	if len(interceptors) == 0 {
+		return client
+	}
+	return agent.NewInterceptedClient(client, interceptors...)
+}
+
+// generationOptions converts configured sampling parameters for the agent package
+func generationOptions(gen config.GenerationConfig) agent.GenerationOptions {
+	return agent.GenerationOptions{
+		Temperature: gen.Temperature,
+		MaxTokens:   gen.MaxTokens,
+		TopP:        gen.TopP,
+		Stop:        gen.Stop,
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dotcommander/orc/internal/config"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+)
+
+// pipelinesDir holds user pipeline definitions, next to the config file
+func pipelinesDir() string {
+	return filepath.Join(filepath.Dir(config.ConfigPath()), "pipelines")
+}
+
+// loadPipeline resolves ref as a YAML file, then as a name in the pipelines directory,
+// then as a built-in pipeline. It returns the definition and the reference to record
+// in the session so resume finds the same pipeline.
+func loadPipeline(ref string) (*domainPlugin.PipelineDefinition, string, error) {
+	if strings.HasSuffix(ref, ".yaml") || strings.HasSuffix(ref, ".yml") || strings.ContainsRune(ref, filepath.Separator) {
+		abs, err := filepath.Abs(ref)
+		if err != nil {
+			return nil, "", err
+		}
+		def, err := domainPlugin.LoadPipeline(abs)
+		return def, abs, err
+	}
+
+	userFile := filepath.Join(pipelinesDir(), ref+".yaml")
+	if _, err := os.Stat(userFile); err == nil {
+		def, err := domainPlugin.LoadPipeline(userFile)
+		return def, userFile, err
+	}
+
+	def, err := domainPlugin.BuiltinPipeline(ref)
+	return def, ref, err
+}
+
+// listPipelines returns the built-in pipeline names followed by any in the pipelines
+// directory
+func listPipelines() []string {
+	names := domainPlugin.ListBuiltinPipelines()
+	files, _ := filepath.Glob(filepath.Join(pipelinesDir(), "*.yaml"))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPipeline swaps the plugin's phases for those of the session's pipeline, if it
+// has one
+func withPipeline(p domainPlugin.DomainPlugin, info sessionInfo) (domainPlugin.DomainPlugin, error) {
+	if info.Pipeline == "" {
+		return p, nil
+	}
+
+	def, _, err := loadPipeline(info.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Vars) > 0 && def.Vars == nil {
+		def.Vars = make(map[string]string, len(info.Vars))
+	}
+	for k, v := range info.Vars {
+		def.Vars[k] = v
+	}
+
+	pp, err := domainPlugin.NewPipelinePlugin(p, def)
+	if err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// varsFlag collects repeated -set key=value flags
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for k, val := range v {
+		pairs = append(pairs, k+"="+val)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	v[strings.TrimSpace(key)] = value
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dotcommander/orc/internal/config"
+	"github.com/dotcommander/orc/internal/storage"
+	"github.com/dotcommander/orc/pkg/plugin/deps"
+)
+
+func runPlugins(ctx context.Context, opts globalOptions, args []string) error {
+	if len(args) > 0 && args[0] == "deps" {
+		return runPluginDeps(args[1:])
+	}
+
+	flags := flag.NewFlagSet("plugins", flag.ExitOnError)
+	flags.Parse(args)
+
+	a, err := newApp(opts)
+	if err != nil {
+		return err
+	}
+	if err := a.initPlugins(ctx, storage.NewFileSystem(a.cfg.Paths.OutputDir)); err != nil {
+		return fmt.Errorf("initializing plugins: %w", err)
+	}
+
+	registry := a.integrator.GetDomainRegistry()
+	names := registry.List()
+	sort.Strings(names)
+
+	fmt.Println("Plugins:")
+	for _, name := range names {
+		p, err := registry.Get(name)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("  %-10s %s\n", name, p.Description())
+		for i, phase := range p.GetPhases() {
+			fmt.Printf("  %-10s   %d. %s\n", "", i+1, phase.Name())
+		}
+	}
+
+	fmt.Println("\nPipelines (orc create -pipeline <name>):")
+	for _, name := range listPipelines() {
+		fmt.Printf("  %s\n", name)
+	}
+
+	result, err := a.integrator.DiscoverExternalPlugins(ctx)
+	if err != nil {
+		return fmt.Errorf("discovering external plugins: %w", err)
+	}
+
+	fmt.Println("\nExternal plugins:")
+	if len(result.ExternalPlugins) == 0 {
+		fmt.Println("  (none found)")
+	}
+	for _, ext := range result.ExternalPlugins {
+		status := "compatible"
+		if !ext.Compatible {
+			status = "incompatible: " + ext.Error
+		}
+		fmt.Printf("  %-10s %s [%s]\n", ext.Name, ext.Path, status)
+	}
+	for _, perr := range result.Errors {
+		fmt.Printf("  error: %s %s: %s\n", perr.Plugin, perr.Path, perr.Error)
+	}
+
+	return nil
+}
+
+// runPluginDeps shows the order plugin manifests load in and why the plugins that
+// can't load are left out
+func runPluginDeps(args []string) error {
+	flags := flag.NewFlagSet("plugins deps", flag.ExitOnError)
+	path := flags.String("path", "", "scan this directory instead of plugins.discovery_paths")
+	flags.Parse(args)
+
+	paths := []string{*path}
+	if *path == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		paths = cfg.Plugins.DiscoveryPaths
+	}
+
+	plugins, errs := deps.Scan(paths)
+	graph := deps.Resolve(plugins, Version)
+
+	fmt.Println("Load order:")
+	if len(graph.Order) == 0 {
+		fmt.Println("  (none)")
+	}
+	for i, name := range graph.Order {
+		p, _ := graph.Plugin(name)
+		fmt.Printf("  %d. %s %s\n", i+1, name, p.Version)
+		var needs []string
+		for _, r := range graph.Requires(name) {
+			dep, _ := graph.Plugin(r.Name)
+			needs = append(needs, fmt.Sprintf("%s (%s)", r, dep.Version))
+		}
+		if len(needs) > 0 {
+			fmt.Printf("       needs %s\n", strings.Join(needs, ", "))
+		}
+	}
+
+	if len(graph.Problems) > 0 {
+		names := make([]string, 0, len(graph.Problems))
+		for name := range graph.Problems {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println("\nCan't load:")
+		for _, name := range names {
+			fmt.Printf("  %v\n", graph.Problems[name])
+		}
+	}
+	if len(errs) > 0 {
+		fmt.Println("\nUnreadable manifests:")
+		for _, err := range errs {
+			fmt.Printf("  %v\n", err)
+		}
+	}
+
+	if len(graph.Problems) > 0 {
+		return fmt.Errorf("%d of %d plugins can't load", len(graph.Problems), len(graph.Order)+len(graph.Problems))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/dotcommander/orc/internal/core"
+)
+
+func runResume(ctx context.Context, opts globalOptions, args []string) error {
+	flags := flag.NewFlagSet("resume", flag.ExitOnError)
+	from := flags.String("from", "", "rewind to this checkpoint before resuming (see 'orc checkpoints')")
+	flags.Usage = func() {
+		fmt.Println("Usage: orc resume [-from <checkpoint>] <session-id>")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("resume requires exactly one session ID")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		return err
+	}
+
+	store, info, err := a.openSession(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	lock, err := lockSession(store)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if err := a.initPlugins(ctx, store); err != nil {
+		return fmt.Errorf("initializing plugins: %w", err)
+	}
+
+	checkpoints := core.NewCheckpointManager(store)
+	if *from != "" {
+		chk, err := checkpoints.RestoreTo(ctx, info.ID, *from)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("⏪ Rewound session %s to checkpoint %s\n", info.ID, *from)
+		return a.runSession(ctx, store, info, chk.PhaseIndex)
+	}
+
+	startPhase := 0
+	if chk, err := checkpoints.Load(ctx, info.ID); err == nil {
+		startPhase = chk.PhaseIndex
+		if err := checkpoints.MarkAsResumed(ctx, info.ID); err != nil {
+			a.logger.Warn("failed to mark checkpoint as resumed", "error", err)
+		}
+		fmt.Printf("🔄 Resuming %s session %s after phase %q\n", info.Plugin, info.ID, chk.PhaseName)
+	} else {
+		fmt.Printf("🔄 No checkpoint found for session %s, restarting from the first phase\n", info.ID)
+	}
+
+	return a.runSession(ctx, store, info, startPhase)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotcommander/orc/internal/core"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+// sessionFile records what a session was started with so it can be resumed
+const sessionFile = "session.json"
+
+type sessionInfo struct {
+	ID         string            `json:"id"`
+	Plugin     string            `json:"plugin"`
+	Request    string            `json:"request"`
+	Pipeline   string            `json:"pipeline,omitempty"`    // Pipeline file or built-in name
+	Vars       map[string]string `json:"vars,omitempty"`        // Overrides for the pipeline's vars
+	ForkedFrom string            `json:"forked_from,omitempty"` // Session this one was forked from
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func saveSessionInfo(ctx context.Context, store sessionStore, info sessionInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling session info: %w", err)
+	}
+	return store.Save(ctx, sessionFile, data)
+}
+
+func loadSessionInfo(ctx context.Context, store sessionStore) (sessionInfo, error) {
+	var info sessionInfo
+	data, err := store.Load(ctx, sessionFile)
+	if err != nil {
+		return info, fmt.Errorf("loading session info: %w", err)
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("parsing session info: %w", err)
+	}
+	return info, nil
+}
+
+// lockSession takes the session's lock so no other orc process writes to it while
+// this one runs
+func lockSession(store sessionStore) (*storage.Lock, error) {
+	lock, err := storage.LockDir(store.BaseDir(), storage.DefaultStaleLock)
+	if errors.Is(err, storage.ErrLocked) {
+		return nil, fmt.Errorf("session is already running elsewhere: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking session: %w", err)
+	}
+	return lock, nil
+}
+
+// findSessionDir resolves a full session ID or a unique prefix of one
+func findSessionDir(sessionsDir, id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\*?[`) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+
+	exact := filepath.Join(sessionsDir, id)
+	if info, err := os.Stat(exact); err == nil && info.IsDir() {
+		return exact, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(sessionsDir, id+"*"))
+	if err != nil {
+		return "", fmt.Errorf("searching sessions: %w", err)
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no session matching %q in %s", id, sessionsDir)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("session ID %q is ambiguous (%d matches)", id, len(matches))
+	}
+}
+
+// runSession drives the plugin's phases through the core orchestrator starting at startPhase
+func (a *app) runSession(ctx context.Context, store sessionStore, info sessionInfo, startPhase int) error {
+	p, err := a.integrator.GetDomainRegistry().Get(info.Plugin)
+	if err != nil {
+		return fmt.Errorf("%w (available: %s)", err, strings.Join(a.integrator.GetDomainRegistry().List(), ", "))
+	}
+
+	p, err = withPipeline(p, info)
+	if err != nil {
+		return err
+	}
+	schedule := domainPlugin.PhaseSchedule(p)
+
+	phases := domainPlugin.CorePhases(p)
+	if startPhase >= len(phases) {
+		fmt.Printf("Session %s already completed all %d phases\n", info.ID, len(phases))
+		return nil
+	}
+
+	orch := core.New(phases, store, core.WithConfig(core.OrchestratorConfig{
+		CheckpointingEnabled: true,
+		MaxRetries:           max(a.cfg.Limits.MaxRetries, 1),
+		PerformanceEnabled:   false, // the optimized linear runner skips checkpoints for plugins of one or two phases
+		Schedule:             schedule,
+		Budget: core.Budget{
+			MaxTokens:  a.cfg.Limits.Budget.MaxTokens,
+			MaxCostUSD: a.cfg.Limits.Budget.MaxCostUSD,
+		},
+	})).WithSessionID(info.ID)
+
+	if a.cfg.Limits.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.cfg.Limits.TotalTimeout)
+		defer cancel()
+	}
+
+	// Pipelines and plugin manifests may declare dependencies and parallel phases,
+	// which only the graph runner honours
+	run := orch.RunWithResume
+	if len(schedule) > 0 {
+		run = orch.RunGraphWithResume
+	}
+	if err := run(ctx, info.Request, startPhase); err != nil {
+		if errors.Is(err, core.ErrBudgetExceeded) {
+			fmt.Fprintf(os.Stderr, "\n%s", orch.Usage().Report())
+			fmt.Fprintf(os.Stderr, "Raise limits.budget with 'orc config set' before resuming.\n")
+		}
+		fmt.Fprintf(os.Stderr, "\nSession %s stopped. Resume with: orc resume %s\n", info.ID, info.ID)
+		return err
+	}
+
+	spec := p.GetOutputSpec()
+	fmt.Printf("\n✅ Session %s complete\n", info.ID)
+	fmt.Printf("Output: %s\n", store.BaseDir())
+	if spec.PrimaryOutput != "" {
+		fmt.Printf("Primary output: %s\n", filepath.Join(store.BaseDir(), spec.PrimaryOutput))
+	}
+	tokens, cost := orch.Usage().Totals()
+	fmt.Printf("Usage: %d tokens, est. $%.4f\n", tokens, cost)
+	a.logger.Debug("validation report", "report", orch.GetValidationReport())
+
+	return nil
+}
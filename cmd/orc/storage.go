@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotcommander/orc/internal/config"
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+// sessionStore is the storage a session runs against. Its files are always
+// available locally under BaseDir, even when a remote backend holds the originals.
+type sessionStore interface {
+	storage.Storage
+	BaseDir() string
+}
+
+// remoteBackend holds every session's originals, each under its own root
+type remoteBackend interface {
+	session(sessionID string) storage.Storage
+	FindSessions(ctx context.Context, idPrefix string) ([]string, error)
+}
+
+type s3Backend struct{ *storage.S3 }
+
+func (b s3Backend) session(sessionID string) storage.Storage { return b.Session(sessionID) }
+
+type sqliteBackend struct{ *storage.SQLite }
+
+func (b sqliteBackend) session(sessionID string) storage.Storage { return b.Session(sessionID) }
+
+// remoteStorage opens the backend configured under storage, or returns nil when
+// sessions are kept only on the local filesystem
+func remoteStorage(cfg *config.Config) (remoteBackend, error) {
+	switch cfg.Storage.Backend {
+	case "s3":
+		return s3Storage(cfg.Storage.S3)
+	case "sqlite":
+		path := cfg.Storage.SQLite.Path
+		if path == "" {
+			path = filepath.Join(cfg.Paths.OutputDir, "orc.db")
+		}
+		db, err := storage.OpenSQLite(path)
+		if err != nil {
+			return nil, fmt.Errorf("storage.sqlite: %w", err)
+		}
+		return sqliteBackend{db}, nil
+	}
+	return nil, nil
+}
+
+// s3Storage opens the bucket configured under storage.s3
+func s3Storage(s3 config.S3Config) (remoteBackend, error) {
+	env := func(value, name string) string {
+		if value != "" {
+			return value
+		}
+		return os.Getenv(name)
+	}
+
+	prefix := s3.Prefix
+	if prefix == "" {
+		prefix = "orc/sessions/" + storage.SessionPlaceholder
+	}
+	remote, err := storage.NewS3(storage.S3Options{
+		Endpoint:     s3.Endpoint,
+		Region:       env(s3.Region, "AWS_REGION"),
+		Bucket:       s3.Bucket,
+		AccessKey:    env(s3.AccessKey, "AWS_ACCESS_KEY_ID"),
+		SecretKey:    env(s3.SecretKey, "AWS_SECRET_ACCESS_KEY"),
+		SessionToken: env(s3.SessionToken, "AWS_SESSION_TOKEN"),
+		PathStyle:    s3.PathStyle,
+	}, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("storage.s3: %w", err)
+	}
+	return s3Backend{remote}, nil
+}
+
+// sessionStore returns the storage for a session kept in dir, writing through to
+// the remote backend when one is configured
+func (a *app) sessionStore(dir, sessionID string) sessionStore {
+	local := storage.NewFileSystem(dir)
+	if a.remote == nil {
+		return local
+	}
+	return storage.NewCached(a.remote.session(sessionID), local)
+}
+
+// findRemoteSession resolves a session ID or unique prefix against the remote backend
+func (a *app) findRemoteSession(ctx context.Context, id string) (string, error) {
+	ids, err := a.remote.FindSessions(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("searching remote sessions: %w", err)
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no session matching %q in %s or remote storage", id, a.sessionsDir())
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("session ID %q is ambiguous (%s)", id, strings.Join(ids, ", "))
+	}
+}
@@ -26,6 +26,9 @@ type Agent struct {
 	client       AIClient
 	promptPath   string
 	systemPrompt string // New: System prompt for role assignment
+	role         string // Role name used to attribute token usage
+	maxRepairs   int    // Re-prompts allowed when structured output fails validation
+	generation   GenerationOptions
 	promptCache  *PromptCache
 	logger       *slog.Logger
 }
@@ -35,6 +38,7 @@ func New(client AIClient, promptPath string) *Agent {
 		client:      client,
 		promptPath:  promptPath,
 		promptCache: GetPromptCache(),
+		maxRepairs:  defaultMaxRepairs,
 		logger:      slog.Default().With("component", "agent"),
 	}
 }
@@ -46,6 +50,7 @@ func NewWithSystem(client AIClient, promptPath, systemPrompt string) *Agent {
 		promptPath:   promptPath,
 		systemPrompt: systemPrompt,
 		promptCache:  GetPromptCache(),
+		maxRepairs:   defaultMaxRepairs,
 		logger:       slog.Default().With("component", "agent"),
 	}
 }
@@ -56,6 +61,32 @@ func (a *Agent) WithLogger(logger *slog.Logger) *Agent {
 	return a
 }
 
+// WithRole names the role this agent plays so its token usage can be attributed
+func (a *Agent) WithRole(role string) *Agent {
+	a.role = role
+	return a
+}
+
+// WithGenerationOptions sets the sampling parameters for this agent's requests. Options
+// set on the context of an individual call take precedence.
+func (a *Agent) WithGenerationOptions(opts GenerationOptions) *Agent {
+	a.generation = opts
+	return a
+}
+
+// WithPromptPath replaces the prompt template the agent renders requests with
+func (a *Agent) WithPromptPath(path string) *Agent {
+	a.promptPath = path
+	return a
+}
+
+// WithMaxRepairs sets how many times ExecuteInto re-prompts with validation errors
+// before giving up
+func (a *Agent) WithMaxRepairs(n int) *Agent {
+	a.maxRepairs = n
+	return a
+}
+
 func (a *Agent) Execute(ctx context.Context, prompt string, input any) (string, error) {
 	return a.execute(ctx, prompt, input, false)
 }
@@ -65,6 +96,7 @@ func (a *Agent) ExecuteJSON(ctx context.Context, prompt string, input any) (stri
 }
 
 func (a *Agent) execute(ctx context.Context, prompt string, input any, forceJSON bool) (string, error) {
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
 	startTime := time.Now()
 	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
 	
@@ -77,6 +109,95 @@ func (a *Agent) execute(ctx context.Context, prompt string, input any, forceJSON
 		"has_prompt_path", a.promptPath != "",
 		"prompt_length", len(prompt))
 	
+	fullPrompt, cacheHit := a.resolvePrompt(requestID, prompt, input)
+	
+	a.logger.Debug("executing AI request",
+		"request_id", requestID,
+		"operation", operationType,
+		"cache_hit", cacheHit,
+		"full_prompt_length", len(fullPrompt),
+		"force_json", forceJSON)
+	
+	var response string
+	var err error
+	
+	// Use system prompt if available
+	if a.systemPrompt != "" {
+		if forceJSON {
+			response, err = a.client.CompleteJSONWithSystem(ctx, a.systemPrompt, fullPrompt)
+		} else {
+			response, err = a.client.CompleteWithSystem(ctx, a.systemPrompt, fullPrompt)
+		}
+	} else {
+		// Fall back to original behavior
+		if forceJSON {
+			if client, ok := a.client.(*Client); ok {
+				response, err = client.CompleteJSON(ctx, fullPrompt)
+			} else {
+				response, err = a.client.Complete(ctx, fullPrompt)
+			}
+		} else {
+			response, err = a.client.Complete(ctx, fullPrompt)
+		}
+	}
+	
+	duration := time.Since(startTime)
+	
+	if err != nil {
+		a.logger.Error("AI request failed",
+			"request_id", requestID,
+			"duration_ms", duration.Milliseconds(),
+			"error", err)
+		return "", err
+	}
+	
+	a.logger.Info("AI request completed",
+		"request_id", requestID,
+		"operation", operationType,
+		"duration_ms", duration.Milliseconds(),
+		"response_length", len(response),
+		"cache_hit", cacheHit)
+	
+	return response, nil
+}
+
+
+
+// ExecuteStream runs the prompt like Execute but forwards generated text to onChunk as it
+// arrives. On failure the text produced so far is returned alongside the error.
+func (a *Agent) ExecuteStream(ctx context.Context, prompt string, input any, onChunk func(chunk string) error) (string, error) {
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
+	startTime := time.Now()
+	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
+	
+	fullPrompt, cacheHit := a.resolvePrompt(requestID, prompt, input)
+	
+	a.logger.Debug("executing streaming AI request",
+		"request_id", requestID,
+		"operation", extractOperationType(prompt),
+		"cache_hit", cacheHit,
+		"full_prompt_length", len(fullPrompt))
+	
+	response, err := a.client.CompleteStream(ctx, a.systemPrompt, fullPrompt, StreamCallback(onChunk))
+	if err != nil {
+		a.logger.Error("streaming AI request failed",
+			"request_id", requestID,
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"partial_length", len(response),
+			"error", err)
+		return response, err
+	}
+	
+	a.logger.Info("streaming AI request completed",
+		"request_id", requestID,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"response_length", len(response))
+	
+	return response, nil
+}
+
+// resolvePrompt combines the agent's prompt template (if any) with the call's prompt and input
+func (a *Agent) resolvePrompt(requestID, prompt string, input any) (string, bool) {
 	fullPrompt := prompt
 	cacheHit := false
 	
@@ -145,53 +266,5 @@ func (a *Agent) execute(ctx context.Context, prompt string, input any, forceJSON
 		}
 	}
 	
-	a.logger.Debug("executing AI request",
-		"request_id", requestID,
-		"operation", operationType,
-		"cache_hit", cacheHit,
-		"full_prompt_length", len(fullPrompt),
-		"force_json", forceJSON)
-	
-	var response string
-	var err error
-	
-	// Use system prompt if available
-	if a.systemPrompt != "" {
-		if forceJSON {
-			response, err = a.client.CompleteJSONWithSystem(ctx, a.systemPrompt, fullPrompt)
-		} else {
-			response, err = a.client.CompleteWithSystem(ctx, a.systemPrompt, fullPrompt)
-		}
-	} else {
-		// Fall back to original behavior
-		if forceJSON {
-			if client, ok := a.client.(*Client); ok {
-				response, err = client.CompleteJSON(ctx, fullPrompt)
-			} else {
-				response, err = a.client.Complete(ctx, fullPrompt)
-			}
-		} else {
-			response, err = a.client.Complete(ctx, fullPrompt)
-		}
-	}
-	
-	duration := time.Since(startTime)
-	
-	if err != nil {
-		a.logger.Error("AI request failed",
-			"request_id", requestID,
-			"duration_ms", duration.Milliseconds(),
-			"error", err)
-		return "", err
-	}
-	
-	a.logger.Info("AI request completed",
-		"request_id", requestID,
-		"operation", operationType,
-		"duration_ms", duration.Milliseconds(),
-		"response_length", len(response),
-		"cache_hit", cacheHit)
-	
-	return response, nil
+	return fullPrompt, cacheHit
 }
-
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrBatchUnsupported is returned by SubmitBatch when the provider has no batch API
+var ErrBatchUnsupported = errors.New("provider has no batch API")
+
+// BatchRequest is one request in a batch. CustomID identifies its result and must be
+// unique within the batch (letters, digits, '_' and '-', at most 64 characters).
+type BatchRequest struct {
+	CustomID string
+	Request
+}
+
+// BatchResult is the outcome of one batch request
+type BatchResult struct {
+	CustomID string
+	Text     string
+	Usage    Usage
+	Err      error // Set when this request failed, expired or was cancelled
+}
+
+// DoFunc sends an HTTP request and returns the body of a 2xx response, or an *APIError
+type DoFunc func(req *http.Request) ([]byte, error)
+
+// BatchProvider is implemented by providers with an asynchronous batch API, which
+// trades latency (results can take up to a day) for a lower price
+type BatchProvider interface {
+	// SubmitBatch queues the requests and returns the provider's batch ID
+	SubmitBatch(ctx context.Context, do DoFunc, endpoint Endpoint, requests []BatchRequest) (string, error)
+
+	// PollBatch reports whether the batch has ended and, once it has, its results.
+	// An error with done set means the batch ended without results.
+	PollBatch(ctx context.Context, do DoFunc, endpoint Endpoint, batchID string) (done bool, results []BatchResult, err error)
+}
+
+const defaultBatchPollInterval = 30 * time.Second
+
+// WithBatchPollInterval sets how often WaitBatch checks on a batch
+func WithBatchPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.batchPollInterval = interval
+	}
+}
+
+// SubmitBatch sends requests through the provider's batch API and returns the batch
+// ID to pass to WaitBatch. The model and generation options are filled in as for a
+// synchronous request.
+func (c *Client) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	provider, ok := c.provider.(BatchProvider)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", c.provider.Name(), ErrBatchUnsupported)
+	}
+
+	prepared := make([]BatchRequest, len(requests))
+	for i, r := range requests {
+		c.prepare(ctx, &r.Request)
+		prepared[i] = r
+	}
+
+	batchID, err := provider.SubmitBatch(ctx, c.do, Endpoint{BaseURL: c.baseURL, APIKey: c.apiKey}, prepared)
+	if err != nil {
+		return "", fmt.Errorf("submitting batch: %w", err)
+	}
+
+	c.logger.Info("batch submitted",
+		"provider", c.provider.Name(),
+		"batch_id", batchID,
+		"model", c.model,
+		"requests", len(requests))
+	return batchID, nil
+}
+
+// WaitBatch polls a batch until it ends and returns its results, ordered by CustomID.
+// Individual failures are reported in BatchResult.Err; the error is only set when the
+// batch itself could not be read.
+func (c *Client) WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error) {
+	provider, ok := c.provider.(BatchProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", c.provider.Name(), ErrBatchUnsupported)
+	}
+	endpoint := Endpoint{BaseURL: c.baseURL, APIKey: c.apiKey}
+	startTime := time.Now()
+
+	failures := 0
+	for {
+		done, results, err := provider.PollBatch(ctx, c.do, endpoint, batchID)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil && done:
+			return nil, err
+		case err != nil:
+			// A failed poll doesn't affect the batch, so only give up after repeated failures
+			failures++
+			if failures > c.maxRetries {
+				return nil, fmt.Errorf("polling batch %s: %w", batchID, err)
+			}
+			c.logger.Warn("polling batch failed, will retry", "batch_id", batchID, "error", err)
+		case done:
+			sort.Slice(results, func(i, j int) bool { return results[i].CustomID < results[j].CustomID })
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+				}
+				c.recordBatchUsage(ctx, r.Usage.InputTokens, r.Usage.OutputTokens)
+			}
+			c.logger.Info("batch ended",
+				"provider", c.provider.Name(),
+				"batch_id", batchID,
+				"results", len(results),
+				"failed", failed,
+				"duration_ms", time.Since(startTime).Milliseconds())
+			return results, nil
+		default:
+			failures = 0
+			c.logger.Debug("batch still processing", "batch_id", batchID, "elapsed_ms", time.Since(startTime).Milliseconds())
+		}
+
+		select {
+		case <-time.After(c.batchPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do sends a batch API request through the rate limiter
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	if err := c.limiter.Wait(req.Context()); err != nil {
+		return nil, fmt.Errorf("rate limit wait failed: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+	c.limiter.Observe(resp.StatusCode, resp.Header)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body), RetryAfter: retryAfter(resp.Header)}
+	}
+	return body, nil
+}
+
+// eachJSONLine calls fn for every non-empty line of a JSON Lines result file
+func eachJSONLine(data []byte, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// SubmitBatch sends the prompts, keyed by caller-chosen IDs, as one provider batch
+// using the agent's prompt template, system prompt and generation options. It returns
+// ErrBatchUnsupported when the agent's client or provider can't batch.
+func (a *Agent) SubmitBatch(ctx context.Context, prompts map[string]string) (string, error) {
+	client, ok := a.client.(BatchClient)
+	if !ok {
+		return "", ErrBatchUnsupported
+	}
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
+
+	ids := make([]string, 0, len(prompts))
+	for id := range prompts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	requests := make([]BatchRequest, len(ids))
+	for i, id := range ids {
+		fullPrompt, _ := a.resolvePrompt(id, prompts[id], nil)
+		requests[i] = BatchRequest{
+			CustomID: id,
+			Request:  Request{SystemPrompt: a.systemPrompt, UserPrompt: fullPrompt},
+		}
+	}
+	return client.SubmitBatch(ctx, requests)
+}
+
+// WaitBatch waits for a batch from SubmitBatch to end and returns the responses that
+// succeeded, keyed by the IDs they were submitted with. Failed requests are logged and
+// left out so the caller can retry them individually.
+func (a *Agent) WaitBatch(ctx context.Context, batchID string) (map[string]string, error) {
+	client, ok := a.client.(BatchClient)
+	if !ok {
+		return nil, ErrBatchUnsupported
+	}
+
+	results, err := client.WaitBatch(ContextWithRole(ctx, a.role), batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[string]string, len(results))
+	for _, r := range results {
+		if r.Err != nil {
+			a.logger.Warn("batch request failed", "batch_id", batchID, "custom_id", r.CustomID, "error", r.Err)
+			continue
+		}
+		responses[r.CustomID] = r.Text
+	}
+	return responses, nil
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer stands in for a provider batch API: it answers every queued request with
+// "echo: <prompt>" except those whose prompt contains "fail", and ends the batch on the
+// second status check
+type batchServer struct {
+	mu      sync.Mutex
+	prompts map[string]string
+	polls   int
+	usage   []Usage
+}
+
+func (s *batchServer) anthropic(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/messages/batches":
+			var body struct {
+				Requests []struct {
+					CustomID string `json:"custom_id"`
+					Params   struct {
+						Model    string `json:"model"`
+						Messages []struct {
+							Content string `json:"content"`
+						} `json:"messages"`
+					} `json:"params"`
+				} `json:"requests"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, req := range body.Requests {
+				if req.Params.Model != "model" {
+					t.Errorf("batch request model = %q, want the client's model", req.Params.Model)
+				}
+				s.prompts[req.CustomID] = req.Params.Messages[0].Content
+			}
+			io.WriteString(w, `{"id":"msgbatch_1","processing_status":"in_progress"}`)
+		case r.URL.Path == "/messages/batches/msgbatch_1":
+			s.polls++
+			status := "in_progress"
+			if s.polls > 1 {
+				status = "ended"
+			}
+			fmt.Fprintf(w, `{"id":"msgbatch_1","processing_status":%q,"results_url":%q}`, status, srv.URL+"/results/msgbatch_1")
+		case r.URL.Path == "/results/msgbatch_1":
+			for id, prompt := range s.prompts {
+				if strings.Contains(prompt, "fail") {
+					fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"errored","error":{"type":"invalid_request_error"}}}`+"\n", id)
+					continue
+				}
+				msg := map[string]interface{}{
+					"content": []map[string]string{{"type": "text", "text": "echo: " + prompt}},
+					"usage":   map[string]int{"input_tokens": 10, "output_tokens": 5},
+				}
+				line, _ := json.Marshal(map[string]interface{}{
+					"custom_id": id,
+					"result":    map[string]interface{}{"type": "succeeded", "message": msg},
+				})
+				fmt.Fprintf(w, "%s\n", line)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return srv
+}
+
+func (s *batchServer) openAI(t *testing.T) *httptest.Server {
+	var input []byte
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/files":
+			file, _, err := r.FormFile("file")
+			if err != nil || r.FormValue("purpose") != "batch" {
+				t.Errorf("file upload = %v, purpose %q", err, r.FormValue("purpose"))
+			}
+			input, _ = io.ReadAll(file)
+			io.WriteString(w, `{"id":"file-in"}`)
+		case r.Method == "POST" && r.URL.Path == "/batches":
+			io.WriteString(w, `{"id":"batch_1","status":"validating"}`)
+		case r.URL.Path == "/batches/batch_1":
+			s.polls++
+			if s.polls == 1 {
+				io.WriteString(w, `{"id":"batch_1","status":"in_progress"}`)
+				return
+			}
+			io.WriteString(w, `{"id":"batch_1","status":"completed","output_file_id":"file-out"}`)
+		case r.URL.Path == "/files/file-out/content":
+			for _, line := range strings.Split(strings.TrimSpace(string(input)), "\n") {
+				var req struct {
+					CustomID string `json:"custom_id"`
+					URL      string `json:"url"`
+					Body     struct {
+						Messages []struct {
+							Content string `json:"content"`
+						} `json:"messages"`
+					} `json:"body"`
+				}
+				json.Unmarshal([]byte(line), &req)
+				if req.URL != "/v1/chat/completions" {
+					t.Errorf("batch line url = %q", req.URL)
+				}
+				prompt := req.Body.Messages[len(req.Body.Messages)-1].Content
+				s.prompts[req.CustomID] = prompt
+				body := fmt.Sprintf(`{"choices":[{"message":{"content":%q},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`, "echo: "+prompt)
+				status := 200
+				if strings.Contains(prompt, "fail") {
+					status = 400
+				}
+				fmt.Fprintf(w, `{"custom_id":%q,"response":{"status_code":%d,"body":%s},"error":null}`+"\n", req.CustomID, status, body)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestAgentBatch(t *testing.T) {
+	for _, provider := range []string{"anthropic", "openai"} {
+		t.Run(provider, func(t *testing.T) {
+			s := &batchServer{prompts: make(map[string]string)}
+			srv := s.anthropic(t)
+			if provider == "openai" {
+				srv = s.openAI(t)
+			}
+			defer srv.Close()
+
+			p, _ := GetProvider(provider)
+			client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithProvider(p), WithRateLimit(6000, 10),
+				WithBatchPollInterval(10*time.Millisecond),
+				WithUsageHook(func(ctx context.Context, u Usage) {
+					if RoleFromContext(ctx) != "writer" {
+						t.Errorf("usage recorded without the agent role")
+					}
+					s.usage = append(s.usage, u)
+				}))
+			a := New(client, "").WithRole("writer")
+
+			ctx := context.Background()
+			batchID, err := a.SubmitBatch(ctx, map[string]string{
+				"chapter_1_scene_1": "open the story",
+				"chapter_2_scene_1": "fail this one",
+			})
+			if err != nil {
+				t.Fatalf("SubmitBatch() error = %v", err)
+			}
+
+			got, err := a.WaitBatch(ctx, batchID)
+			if err != nil {
+				t.Fatalf("WaitBatch() error = %v", err)
+			}
+			if len(got) != 1 || got["chapter_1_scene_1"] != "echo: open the story" {
+				t.Errorf("WaitBatch() = %v, want only the successful scene", got)
+			}
+			if s.polls != 2 {
+				t.Errorf("polled %d times, want 2", s.polls)
+			}
+			if len(s.usage) != 1 || s.usage[0].InputTokens != 10 || !s.usage[0].Batch {
+				t.Errorf("usage = %+v, want one batch record for the successful request", s.usage)
+			}
+		})
+	}
+}
+
+func TestBatchUnsupportedProvider(t *testing.T) {
+	p, _ := GetProvider("ollama")
+	a := New(NewClient("", WithProvider(p)), "")
+	if _, err := a.SubmitBatch(context.Background(), map[string]string{"a": "b"}); !errors.Is(err, ErrBatchUnsupported) {
+		t.Errorf("SubmitBatch() error = %v, want ErrBatchUnsupported", err)
+	}
+	if _, err := New(NewMockClient(), "").SubmitBatch(context.Background(), nil); !errors.Is(err, ErrBatchUnsupported) {
+		t.Errorf("SubmitBatch() on a client without batches error = %v, want ErrBatchUnsupported", err)
+	}
+}
@@ -7,22 +7,74 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
-	
+
 	"github.com/dotcommander/orc/internal/storage"
 )
 
+// cacheDir is where entries live within the cache's storage
+const cacheDir = "responses"
+
+// CacheKey identifies a cached response. The same prompt sent to another model, with
+// another system prompt, sampling parameters or output mode is a separate entry.
+type CacheKey struct {
+	Model      string            `json:"model"`
+	System     string            `json:"system"`
+	Prompt     string            `json:"prompt"`
+	Mode       string            `json:"mode"` // "text", "json" or the JSON schema
+	Generation GenerationOptions `json:"generation"`
+}
+
+func (k CacheKey) hash() string {
+	data, _ := json.Marshal(k)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ResponseCache stores responses on disk. Entries expire after the TTL, and with
+// limits set the least recently used entries are evicted to stay within them.
 type ResponseCache struct {
-	storage storage.Storage
-	ttl     time.Duration
-	logger  *slog.Logger
+	storage    storage.Storage
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	logger     *slog.Logger
+
+	mu sync.Mutex
+	// index tracks entry sizes and last use for eviction; nil until first needed
+	index map[string]cacheIndexEntry
+}
+
+type cacheIndexEntry struct {
+	size     int64
+	lastUsed time.Time
 }
 
 type CachedResponse struct {
 	Response  string    `json:"response"`
+	Model     string    `json:"model,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
 }
 
+// CacheStats describes the contents of a ResponseCache
+type CacheStats struct {
+	Entries int
+	Bytes   int64
+	Expired int
+	// Unreadable entries are left by interrupted writes; Prune removes them
+	Unreadable int
+	ByModel    map[string]int
+	Oldest     time.Time
+	Newest     time.Time
+}
+
+// NewResponseCache creates a cache whose entries expire after ttl; zero keeps them
+// until they are evicted
 func NewResponseCache(storage storage.Storage, ttl time.Duration) *ResponseCache {
 	return &ResponseCache{
 		storage: storage,
@@ -31,251 +83,283 @@ func NewResponseCache(storage storage.Storage, ttl time.Duration) *ResponseCache
 	}
 }
 
-func (c *ResponseCache) Get(ctx context.Context, prompt string) (string, bool) {
-	key := c.hashPrompt(prompt)
-	path := fmt.Sprintf("cache/responses/%s.json", key)
-	
-	c.logger.Debug("cache lookup",
-		"key", key,
-		"prompt_length", len(prompt))
-	
-	data, err := c.storage.Load(ctx, path)
+// WithLimits caps the cache at maxEntries responses and maxBytes on disk, evicting
+// the least recently used entries first. Zero disables a limit.
+func (c *ResponseCache) WithLimits(maxEntries int, maxBytes int64) *ResponseCache {
+	c.maxEntries = maxEntries
+	c.maxBytes = maxBytes
+	return c
+}
+
+func (c *ResponseCache) limited() bool {
+	return c.maxEntries > 0 || c.maxBytes > 0
+}
+
+func (c *ResponseCache) expired(cached CachedResponse) bool {
+	return c.ttl > 0 && time.Since(cached.Timestamp) > c.ttl
+}
+
+func entryPath(hash string) string {
+	return path.Join(cacheDir, hash+".json")
+}
+
+func (c *ResponseCache) Get(ctx context.Context, key CacheKey) (string, bool) {
+	hash := key.hash()
+
+	data, err := c.storage.Load(ctx, entryPath(hash))
 	if err != nil {
-		c.logger.Debug("cache miss - not found",
-			"key", key,
-			"error", err)
+		c.logger.Debug("cache miss", "key", hash, "model", key.Model)
 		return "", false
 	}
-	
+
 	var cached CachedResponse
 	if err := json.Unmarshal(data, &cached); err != nil {
-		c.logger.Error("cache miss - invalid data",
-			"key", key,
-			"error", err)
+		c.logger.Error("cache miss - invalid data", "key", hash, "error", err)
 		return "", false
 	}
-	
-	age := time.Since(cached.Timestamp)
-	if age > c.ttl {
-		c.logger.Debug("cache miss - expired",
-			"key", key,
-			"age", age,
-			"ttl", c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.expired(cached) {
+		c.logger.Debug("cache miss - expired", "key", hash, "age", time.Since(cached.Timestamp), "ttl", c.ttl)
+		c.removeLocked(ctx, hash)
 		return "", false
 	}
-	
+
+	// Persist the access time so eviction stays least-recently-used across runs
+	if c.limited() {
+		cached.LastUsed = time.Now()
+		if data, err := json.Marshal(cached); err == nil {
+			if err := c.storage.Save(ctx, entryPath(hash), data); err == nil && c.index != nil {
+				c.index[hash] = cacheIndexEntry{size: int64(len(data)), lastUsed: cached.LastUsed}
+			}
+		}
+	}
+
 	c.logger.Info("cache hit",
-		"key", key,
-		"age", age,
+		"key", hash,
+		"model", key.Model,
+		"age", time.Since(cached.Timestamp),
 		"response_length", len(cached.Response))
-	
+
 	return cached.Response, true
 }
 
-func (c *ResponseCache) Set(ctx context.Context, prompt, response string) error {
-	key := c.hashPrompt(prompt)
-	path := fmt.Sprintf("cache/responses/%s.json", key)
-	
-	c.logger.Debug("cache set",
-		"key", key,
-		"prompt_length", len(prompt),
-		"response_length", len(response))
-	
-	cached := CachedResponse{
+func (c *ResponseCache) Set(ctx context.Context, key CacheKey, response string) error {
+	hash := key.hash()
+	now := time.Now()
+
+	data, err := json.Marshal(CachedResponse{
 		Response:  response,
-		Timestamp: time.Now(),
-	}
-	
-	data, err := json.Marshal(cached)
+		Model:     key.Model,
+		Timestamp: now,
+		LastUsed:  now,
+	})
 	if err != nil {
-		c.logger.Error("failed to marshal cache entry",
-			"key", key,
-			"error", err)
 		return fmt.Errorf("marshaling cached response: %w", err)
 	}
-	
-	if err := c.storage.Save(ctx, path, data); err != nil {
-		c.logger.Error("failed to save cache entry",
-			"key", key,
-			"error", err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.storage.Save(ctx, entryPath(hash), data); err != nil {
+		return fmt.Errorf("saving cached response: %w", err)
+	}
+	c.logger.Debug("cache entry saved", "key", hash, "model", key.Model, "size", len(data))
+
+	if !c.limited() {
+		return nil
+	}
+	if err := c.loadIndexLocked(ctx); err != nil {
 		return err
 	}
-	
-	c.logger.Info("cache entry saved",
-		"key", key,
-		"size", len(data))
-	
+	c.index[hash] = cacheIndexEntry{size: int64(len(data)), lastUsed: now}
+	if evicted := c.enforceLimitsLocked(ctx); evicted > 0 {
+		c.logger.Info("evicted least recently used cache entries", "count", evicted)
+	}
 	return nil
 }
 
-func (c *ResponseCache) hashPrompt(prompt string) string {
-	hash := sha256.Sum256([]byte(prompt))
-	return hex.EncodeToString(hash[:])
-}
+// Stats reads every entry and summarizes the cache
+func (c *ResponseCache) Stats(ctx context.Context) (CacheStats, error) {
+	entries, unreadable, err := c.scan(ctx)
+	if err != nil {
+		return CacheStats{}, err
+	}
 
-type CachedClient struct {
-	AIClient
-	cache  *ResponseCache
-	logger *slog.Logger
+	stats := CacheStats{Unreadable: len(unreadable), ByModel: make(map[string]int)}
+	for _, e := range entries {
+		stats.Entries++
+		stats.Bytes += e.size
+		if c.expired(e.cached) {
+			stats.Expired++
+		}
+		model := e.cached.Model
+		if model == "" {
+			model = "unknown"
+		}
+		stats.ByModel[model]++
+		if stats.Oldest.IsZero() || e.cached.Timestamp.Before(stats.Oldest) {
+			stats.Oldest = e.cached.Timestamp
+		}
+		if e.cached.Timestamp.After(stats.Newest) {
+			stats.Newest = e.cached.Timestamp
+		}
+	}
+	return stats, nil
 }
 
-func WithCache(client AIClient, cache *ResponseCache) AIClient {
-	return &CachedClient{
-		AIClient: client,
-		cache:    cache,
-		logger:   slog.Default().With("component", "cached_client"),
+// Clear removes every entry and returns how many there were
+func (c *ResponseCache) Clear(ctx context.Context) (int, error) {
+	paths, err := c.storage.List(ctx, path.Join(cacheDir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("listing cache: %w", err)
 	}
-}
 
-func (c *CachedClient) Complete(ctx context.Context, prompt string) (string, error) {
-	startTime := time.Now()
-	
-	if response, found := c.cache.Get(ctx, prompt); found {
-		c.logger.Info("serving from cache",
-			"prompt_length", len(prompt),
-			"response_length", len(response),
-			"duration_ms", time.Since(startTime).Milliseconds())
-		return response, nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, p := range paths {
+		if err := c.storage.Delete(ctx, p); err != nil {
+			return removed, fmt.Errorf("clearing cache: %w", err)
+		}
+		removed++
 	}
-	
-	c.logger.Debug("cache miss, calling underlying client",
-		"prompt_length", len(prompt))
-	
-	response, err := c.AIClient.Complete(ctx, prompt)
+	c.index = nil
+	return removed, nil
+}
+
+// Prune removes expired and unreadable entries, then evicts the least recently used
+// until the cache is within its limits. It returns how many entries were removed.
+func (c *ResponseCache) Prune(ctx context.Context) (int, error) {
+	entries, unreadable, err := c.scan(ctx)
 	if err != nil {
-		c.logger.Error("underlying client failed",
-			"error", err)
-		return "", err
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, hash := range unreadable {
+		if c.storage.Delete(ctx, entryPath(hash)) == nil {
+			removed++
+		}
 	}
-	
-	if cacheErr := c.cache.Set(ctx, prompt, response); cacheErr != nil {
-		c.logger.Warn("failed to cache response",
-			"error", cacheErr)
+
+	c.index = make(map[string]cacheIndexEntry, len(entries))
+	for _, e := range entries {
+		if c.expired(e.cached) {
+			if c.storage.Delete(ctx, entryPath(e.hash)) == nil {
+				removed++
+			}
+			continue
+		}
+		c.index[e.hash] = cacheIndexEntry{size: e.size, lastUsed: e.lastUsed()}
 	}
-	
-	c.logger.Info("completed with fresh response",
-		"prompt_length", len(prompt),
-		"response_length", len(response),
-		"duration_ms", time.Since(startTime).Milliseconds())
-	
-	return response, nil
+	return removed + c.enforceLimitsLocked(ctx), nil
+}
+
+// scannedEntry is an entry read from storage
+type scannedEntry struct {
+	hash   string
+	cached CachedResponse
+	size   int64
 }
 
-func (c *CachedClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
-	startTime := time.Now()
-	
-	// Create a unique cache key for JSON requests to avoid collisions
-	jsonKey := fmt.Sprintf("JSON:%s", prompt)
-	
-	if response, found := c.cache.Get(ctx, jsonKey); found {
-		c.logger.Info("serving JSON from cache",
-			"prompt_length", len(prompt),
-			"response_length", len(response),
-			"duration_ms", time.Since(startTime).Milliseconds())
-		return response, nil
+func (e scannedEntry) lastUsed() time.Time {
+	if e.cached.LastUsed.IsZero() {
+		return e.cached.Timestamp
 	}
-	
-	c.logger.Debug("cache miss for JSON, calling underlying client",
-		"prompt_length", len(prompt))
-	
-	response, err := c.AIClient.CompleteJSON(ctx, prompt)
+	return e.cached.LastUsed
+}
+
+// scan reads every entry, returning the hashes of those that can't be parsed separately
+func (c *ResponseCache) scan(ctx context.Context) ([]scannedEntry, []string, error) {
+	paths, err := c.storage.List(ctx, path.Join(cacheDir, "*.json"))
 	if err != nil {
-		c.logger.Error("underlying JSON client failed",
-			"error", err)
-		return "", err
+		return nil, nil, fmt.Errorf("listing cache: %w", err)
 	}
-	
-	if cacheErr := c.cache.Set(ctx, jsonKey, response); cacheErr != nil {
-		c.logger.Warn("failed to cache JSON response",
-			"error", cacheErr)
+
+	var entries []scannedEntry
+	var unreadable []string
+	for _, p := range paths {
+		hash := strings.TrimSuffix(path.Base(p), ".json")
+		data, err := c.storage.Load(ctx, p)
+		if err != nil {
+			continue // Removed since listing
+		}
+		var cached CachedResponse
+		if err := json.Unmarshal(data, &cached); err != nil {
+			unreadable = append(unreadable, hash)
+			continue
+		}
+		entries = append(entries, scannedEntry{hash: hash, cached: cached, size: int64(len(data))})
 	}
-	
-	c.logger.Info("completed JSON with fresh response",
-		"prompt_length", len(prompt),
-		"response_length", len(response),
-		"duration_ms", time.Since(startTime).Milliseconds())
-	
-	return response, nil
+	return entries, unreadable, nil
 }
 
-// CompleteWithSystem makes a request with separate system and user prompts, with caching
-func (c *CachedClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	startTime := time.Now()
-	
-	// Create cache key combining system and user prompts
-	cacheKey := fmt.Sprintf("SYSTEM:%s|USER:%s", systemPrompt, userPrompt)
-	
-	if response, found := c.cache.Get(ctx, cacheKey); found {
-		c.logger.Info("serving system prompt response from cache",
-			"system_prompt_length", len(systemPrompt),
-			"user_prompt_length", len(userPrompt),
-			"response_length", len(response),
-			"duration_ms", time.Since(startTime).Milliseconds())
-		return response, nil
+func (c *ResponseCache) loadIndexLocked(ctx context.Context) error {
+	if c.index != nil {
+		return nil
 	}
-	
-	c.logger.Debug("cache miss for system prompt, calling underlying client",
-		"system_prompt_length", len(systemPrompt),
-		"user_prompt_length", len(userPrompt))
-	
-	response, err := c.AIClient.CompleteWithSystem(ctx, systemPrompt, userPrompt)
+	entries, _, err := c.scan(ctx)
 	if err != nil {
-		c.logger.Error("underlying client failed for system prompt",
-			"error", err)
-		return "", err
+		return err
 	}
-	
-	if cacheErr := c.cache.Set(ctx, cacheKey, response); cacheErr != nil {
-		c.logger.Warn("failed to cache system prompt response",
-			"error", cacheErr)
+	c.index = make(map[string]cacheIndexEntry, len(entries))
+	for _, e := range entries {
+		c.index[e.hash] = cacheIndexEntry{size: e.size, lastUsed: e.lastUsed()}
 	}
-	
-	c.logger.Info("completed system prompt with fresh response",
-		"system_prompt_length", len(systemPrompt),
-		"user_prompt_length", len(userPrompt),
-		"response_length", len(response),
-		"duration_ms", time.Since(startTime).Milliseconds())
-	
-	return response, nil
+	return nil
 }
 
-// CompleteJSONWithSystem makes a JSON request with separate system and user prompts, with caching
-func (c *CachedClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	startTime := time.Now()
-	
-	// Create cache key for JSON system requests
-	cacheKey := fmt.Sprintf("JSON_SYSTEM:%s|USER:%s", systemPrompt, userPrompt)
-	
-	if response, found := c.cache.Get(ctx, cacheKey); found {
-		c.logger.Info("serving JSON system prompt response from cache",
-			"system_prompt_length", len(systemPrompt),
-			"user_prompt_length", len(userPrompt),
-			"response_length", len(response),
-			"duration_ms", time.Since(startTime).Milliseconds())
-		return response, nil
+func (c *ResponseCache) removeLocked(ctx context.Context, hash string) {
+	if err := c.storage.Delete(ctx, entryPath(hash)); err != nil {
+		c.logger.Debug("failed to remove cache entry", "key", hash, "error", err)
 	}
-	
-	c.logger.Debug("cache miss for JSON system prompt, calling underlying client",
-		"system_prompt_length", len(systemPrompt),
-		"user_prompt_length", len(userPrompt))
-	
-	response, err := c.AIClient.CompleteJSONWithSystem(ctx, systemPrompt, userPrompt)
-	if err != nil {
-		c.logger.Error("underlying client failed for JSON system prompt",
-			"error", err)
-		return "", err
+	if c.index != nil {
+		delete(c.index, hash)
+	}
+}
+
+// enforceLimitsLocked evicts the least recently used entries until the indexed cache
+// fits its limits, returning how many it removed
+func (c *ResponseCache) enforceLimitsLocked(ctx context.Context) int {
+	var total int64
+	for _, e := range c.index {
+		total += e.size
+	}
+	within := func() bool {
+		return (c.maxEntries <= 0 || len(c.index) <= c.maxEntries) && (c.maxBytes <= 0 || total <= c.maxBytes)
 	}
-	
-	if cacheErr := c.cache.Set(ctx, cacheKey, response); cacheErr != nil {
-		c.logger.Warn("failed to cache JSON system prompt response",
-			"error", cacheErr)
+	if within() {
+		return 0
 	}
-	
-	c.logger.Info("completed JSON system prompt with fresh response",
-		"system_prompt_length", len(systemPrompt),
-		"user_prompt_length", len(userPrompt),
-		"response_length", len(response),
-		"duration_ms", time.Since(startTime).Milliseconds())
-	
-	return response, nil
-}
\ No newline at end of file
+
+	hashes := make([]string, 0, len(c.index))
+	for hash := range c.index {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return c.index[hashes[i]].lastUsed.Before(c.index[hashes[j]].lastUsed)
+	})
+
+	evicted := 0
+	for _, hash := range hashes {
+		if within() {
+			break
+		}
+		total -= c.index[hash].size
+		c.removeLocked(ctx, hash)
+		evicted++
+	}
+	return evicted
+}
+
+// WithCache wraps client so repeated requests are served from cache
+func WithCache(client AIClient, cache *ResponseCache) AIClient {
+	return NewInterceptedClient(client, CacheInterceptor(cache, nil, slog.Default().With("component", "cached_client")))
+}
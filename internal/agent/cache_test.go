@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+func TestResponseCacheKeysOnModelAndParameters(t *testing.T) {
+	ctx := context.Background()
+	cache := NewResponseCache(storage.NewFileSystem(t.TempDir()), time.Hour)
+
+	key := CacheKey{Model: "claude-3-5-sonnet-20241022", System: "You are a critic.", Prompt: "Review chapter 1", Mode: "text"}
+	if err := cache.Set(ctx, key, "solid opening"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.Get(ctx, key); !ok || got != "solid opening" {
+		t.Fatalf("Get() = %q, %v", got, ok)
+	}
+
+	otherModel := key
+	otherModel.Model = "gpt-4o-mini"
+	otherTemperature := key
+	otherTemperature.Generation = GenerationOptions{Temperature: Float64(0.2)}
+	otherMode := key
+	otherMode.Mode = "json"
+	for name, k := range map[string]CacheKey{"model": otherModel, "temperature": otherTemperature, "mode": otherMode} {
+		if _, ok := cache.Get(ctx, k); ok {
+			t.Errorf("hit for a different %s", name)
+		}
+	}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewResponseCache(storage.NewFileSystem(t.TempDir()), 0).WithLimits(2, 0)
+
+	keys := []CacheKey{{Prompt: "one"}, {Prompt: "two"}, {Prompt: "three"}}
+	cache.Set(ctx, keys[0], "1")
+	time.Sleep(2 * time.Millisecond)
+	cache.Set(ctx, keys[1], "2")
+	time.Sleep(2 * time.Millisecond)
+	cache.Get(ctx, keys[0]) // "two" is now the least recently used
+	time.Sleep(2 * time.Millisecond)
+	cache.Set(ctx, keys[2], "3")
+
+	if _, ok := cache.Get(ctx, keys[1]); ok {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	for _, k := range []CacheKey{keys[0], keys[2]} {
+		if _, ok := cache.Get(ctx, k); !ok {
+			t.Errorf("entry %q was evicted", k.Prompt)
+		}
+	}
+
+	stats, err := cache.Stats(ctx)
+	if err != nil || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, %v; want 2 entries", stats, err)
+	}
+	if removed, err := cache.Clear(ctx); err != nil || removed != 2 {
+		t.Errorf("Clear() = %d, %v", removed, err)
+	}
+}
+
+func TestCacheInterceptorPhaseOptIn(t *testing.T) {
+	calls := 0
+	send := func(ctx context.Context, call *Call) (string, error) {
+		calls++
+		return "draft", nil
+	}
+	cache := NewResponseCache(storage.NewFileSystem(t.TempDir()), time.Hour)
+	handler := CacheInterceptor(cache, []string{"planning"}, slog.Default())(send)
+
+	for _, role := range []string{"planning", "planning", "writer", "writer"} {
+		ctx := ContextWithRole(context.Background(), role)
+		if _, err := handler(ctx, &Call{Method: MethodCompleteWithSystem, Model: "m", Prompt: "Plan a novel"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("sent %d requests, want the repeated planning call cached and the writer's not", calls)
+	}
+}
@@ -0,0 +1,356 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCassetteMiss is returned by ReplayClient when a request was never recorded
+var ErrCassetteMiss = errors.New("no recorded response for request")
+
+// Cassette is the on-disk record of AI interactions, keyed by a hash of the model and
+// prompts. A key maps to every response seen for it, in order, so repeated prompts
+// replay faithfully.
+type Cassette struct {
+	Version      int                      `json:"version"`
+	RecordedAt   time.Time                `json:"recorded_at"`
+	Interactions map[string][]Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request/response pair
+type Interaction struct {
+	Method       string `json:"method"`
+	Model        string `json:"model,omitempty"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	UserPrompt   string `json:"user_prompt"`
+	Response     string `json:"response"`
+}
+
+// CassetteKey hashes the model and prompts that identify a request, so roles routed
+// to different models don't share responses
+func CassetteKey(model, systemPrompt, userPrompt string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + systemPrompt + "\x00" + userPrompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// modelOf returns the model client sends requests to, or "" when it can't say
+func modelOf(client AIClient) string {
+	if reporter, ok := client.(interface{ Model() string }); ok {
+		return reporter.Model()
+	}
+	return ""
+}
+
+// LoadCassette reads a cassette file written by RecordingClient
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	if c.Interactions == nil {
+		c.Interactions = make(map[string][]Interaction)
+	}
+	return &c, nil
+}
+
+// RecordingClient passes requests through to another client and saves every
+// successful response to a cassette file for later replay
+type RecordingClient struct {
+	inner AIClient
+	path  string
+	state *recordingState
+}
+
+// recordingState is shared by the per-role clients handed out by ClientFor
+type recordingState struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	batches  map[string][]BatchRequest // requests of submitted batches, by batch ID
+}
+
+// NewRecordingClient wraps inner and records to path, appending to an existing cassette
+func NewRecordingClient(inner AIClient, path string) (*RecordingClient, error) {
+	cassette, err := LoadCassette(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cassette = &Cassette{Version: 1, Interactions: make(map[string][]Interaction)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &RecordingClient{
+		inner: inner,
+		path:  path,
+		state: &recordingState{cassette: cassette, batches: make(map[string][]BatchRequest)},
+	}, nil
+}
+
+// ClientFor keeps per-role model routing working while recording
+func (r *RecordingClient) ClientFor(role string) AIClient {
+	resolver, ok := r.inner.(RoleClientResolver)
+	if !ok {
+		return r
+	}
+	return &RecordingClient{inner: resolver.ClientFor(role), path: r.path, state: r.state}
+}
+
+func (r *RecordingClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := r.inner.Complete(ctx, prompt)
+	return resp, r.record(err, "Complete", "", prompt, resp)
+}
+
+func (r *RecordingClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	resp, err := r.inner.CompleteJSON(ctx, prompt)
+	return resp, r.record(err, "CompleteJSON", "", prompt, resp)
+}
+
+func (r *RecordingClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := r.inner.CompleteWithSystem(ctx, systemPrompt, userPrompt)
+	return resp, r.record(err, "CompleteWithSystem", systemPrompt, userPrompt, resp)
+}
+
+func (r *RecordingClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := r.inner.CompleteJSONWithSystem(ctx, systemPrompt, userPrompt)
+	return resp, r.record(err, "CompleteJSONWithSystem", systemPrompt, userPrompt, resp)
+}
+
+func (r *RecordingClient) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	resp, err := r.inner.CompleteStream(ctx, systemPrompt, userPrompt, onChunk)
+	return resp, r.record(err, "CompleteStream", systemPrompt, userPrompt, resp)
+}
+
+// CompleteConversation records the conversation under its flattened transcript
+func (r *RecordingClient) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	resp, err := r.inner.CompleteConversation(ctx, conv)
+	return resp, r.record(err, "CompleteConversation", conv.System, conv.transcript(), resp)
+}
+
+// CompleteWithTools records only the final answer; replay doesn't run the tools
+func (r *RecordingClient) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	client, ok := r.inner.(ToolClient)
+	if !ok {
+		return "", ErrToolsUnsupported
+	}
+	resp, err := client.CompleteWithTools(ctx, conv, tools)
+	return resp, r.record(err, "CompleteWithTools", conv.System, conv.transcript(), resp)
+}
+
+func (r *RecordingClient) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	var resp string
+	var err error
+	if structured, ok := r.inner.(StructuredClient); ok {
+		resp, err = structured.CompleteStructured(ctx, systemPrompt, userPrompt, schema)
+	} else {
+		resp, err = r.inner.CompleteJSONWithSystem(ctx, systemPrompt, userPrompt)
+	}
+	return resp, r.record(err, "CompleteStructured", systemPrompt, userPrompt, resp)
+}
+
+// SubmitBatch passes the batch through and remembers its requests, so WaitBatch can
+// record each result under the prompts it answers
+func (r *RecordingClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	client, ok := r.inner.(BatchClient)
+	if !ok {
+		return "", ErrBatchUnsupported
+	}
+	batchID, err := client.SubmitBatch(ctx, requests)
+	if err != nil {
+		return "", err
+	}
+
+	r.state.mu.Lock()
+	r.state.batches[batchID] = requests
+	r.state.mu.Unlock()
+	return batchID, nil
+}
+
+// WaitBatch records each successful result as an individual interaction. Replay has
+// no batch API, so callers fall back to sending the same prompts one at a time.
+func (r *RecordingClient) WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error) {
+	client, ok := r.inner.(BatchClient)
+	if !ok {
+		return nil, ErrBatchUnsupported
+	}
+	results, err := client.WaitBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.state.mu.Lock()
+	requests := r.state.batches[batchID]
+	delete(r.state.batches, batchID)
+	r.state.mu.Unlock()
+
+	byID := make(map[string]Request, len(requests))
+	for _, req := range requests {
+		byID[req.CustomID] = req.Request
+	}
+	for _, result := range results {
+		req, ok := byID[result.CustomID]
+		if !ok || result.Err != nil {
+			continue
+		}
+		if err := r.record(nil, "Batch", req.SystemPrompt, req.UserPrompt, result.Text); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// record saves a successful interaction and passes callErr through unchanged
+func (r *RecordingClient) record(callErr error, method, systemPrompt, userPrompt, response string) error {
+	if callErr != nil {
+		return callErr
+	}
+
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	model := modelOf(r.inner)
+	key := CassetteKey(model, systemPrompt, userPrompt)
+	r.state.cassette.Interactions[key] = append(r.state.cassette.Interactions[key], Interaction{
+		Method:       method,
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Response:     response,
+	})
+	r.state.cassette.RecordedAt = time.Now()
+
+	// Write after every interaction so an interrupted run still leaves a usable cassette
+	data, err := json.MarshalIndent(r.state.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return nil
+}
+
+// ReplayClient serves responses from a cassette without touching the network.
+// Requests that were not recorded fail with ErrCassetteMiss.
+type ReplayClient struct {
+	state  *replayState
+	models map[string]string
+	base   string
+	model  string
+}
+
+// replayState is shared by the per-role clients handed out by ClientFor
+type replayState struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	served   map[string]int
+}
+
+// NewReplayClient loads the cassette at path
+func NewReplayClient(path string) (*ReplayClient, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayClient{state: &replayState{cassette: cassette, served: make(map[string]int)}}, nil
+}
+
+// WithModels routes roles to models the way ModelRouter does, so each role replays
+// the responses recorded for its model. base is the model of roles without an entry.
+func (r *ReplayClient) WithModels(models map[string]string, base string) *ReplayClient {
+	r.models = models
+	r.base = base
+	r.model = routedModel(models, "default", base)
+	return r
+}
+
+// ClientFor returns a replay client for the model role is routed to
+func (r *ReplayClient) ClientFor(role string) AIClient {
+	return &ReplayClient{state: r.state, models: r.models, base: r.base, model: routedModel(r.models, role, r.base)}
+}
+
+func (r *ReplayClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return r.replay(ctx, "", prompt)
+}
+
+func (r *ReplayClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return r.replay(ctx, "", prompt)
+}
+
+func (r *ReplayClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return r.replay(ctx, systemPrompt, userPrompt)
+}
+
+func (r *ReplayClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return r.replay(ctx, systemPrompt, userPrompt)
+}
+
+func (r *ReplayClient) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	return r.replay(ctx, systemPrompt, userPrompt)
+}
+
+func (r *ReplayClient) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	return r.replay(ctx, conv.System, conv.transcript())
+}
+
+func (r *ReplayClient) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	return r.replay(ctx, conv.System, conv.transcript())
+}
+
+func (r *ReplayClient) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	resp, err := r.replay(ctx, systemPrompt, userPrompt)
+	if err != nil || onChunk == nil {
+		return resp, err
+	}
+	if err := onChunk(resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// replay returns recorded responses for a key in order, repeating the last one once
+// they run out so retried prompts stay deterministic
+func (r *ReplayClient) replay(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	key := CassetteKey(r.model, systemPrompt, userPrompt)
+	recorded := r.state.cassette.Interactions[key]
+	if len(recorded) == 0 {
+		return "", fmt.Errorf("%w %s (model: %q, user prompt: %q)", ErrCassetteMiss, key[:12], r.model, preview(userPrompt, 80))
+	}
+
+	n := r.state.served[key]
+	r.state.served[key] = n + 1
+	if n >= len(recorded) {
+		n = len(recorded) - 1
+	}
+	return recorded[n].Response, nil
+}
+
+func preview(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// sequenceClient returns a numbered response per call so replay order can be checked
+type sequenceClient struct {
+	MockClient
+	calls int
+}
+
+func (s *sequenceClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	s.calls++
+	return fmt.Sprintf("%s #%d", userPrompt, s.calls), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassettes", "run.json")
+
+	recorder, err := NewRecordingClient(&sequenceClient{}, path)
+	if err != nil {
+		t.Fatalf("NewRecordingClient() error = %v", err)
+	}
+	recorded := []string{}
+	for _, prompt := range []string{"plan", "write", "write"} {
+		resp, err := recorder.CompleteWithSystem(ctx, "system", prompt)
+		if err != nil {
+			t.Fatalf("CompleteWithSystem() error = %v", err)
+		}
+		recorded = append(recorded, resp)
+	}
+
+	replayer, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+
+	t.Run("replays in recorded order", func(t *testing.T) {
+		for i, prompt := range []string{"plan", "write", "write"} {
+			resp, err := replayer.CompleteWithSystem(ctx, "system", prompt)
+			if err != nil {
+				t.Fatalf("replay %d error = %v", i, err)
+			}
+			if resp != recorded[i] {
+				t.Errorf("replay %d = %q, want %q", i, resp, recorded[i])
+			}
+		}
+	})
+
+	t.Run("repeats last response when exhausted", func(t *testing.T) {
+		resp, _ := replayer.CompleteWithSystem(ctx, "system", "write")
+		if resp != recorded[2] {
+			t.Errorf("got %q, want %q", resp, recorded[2])
+		}
+	})
+
+	t.Run("fails clearly on miss", func(t *testing.T) {
+		_, err := replayer.CompleteWithSystem(ctx, "other system", "plan")
+		if !errors.Is(err, ErrCassetteMiss) {
+			t.Errorf("expected ErrCassetteMiss, got %v", err)
+		}
+	})
+}
+
+// modelClient answers with its model's name so recordings can be told apart
+type modelClient struct {
+	MockClient
+	model string
+}
+
+func (m *modelClient) Model() string { return m.model }
+
+func (m *modelClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return m.model + ": " + userPrompt, nil
+}
+
+// roleRouter hands out a modelClient per role
+type roleRouter struct {
+	MockClient
+	models map[string]string
+}
+
+func (r *roleRouter) ClientFor(role string) AIClient {
+	return &modelClient{model: r.models[role]}
+}
+
+func TestCassetteKeysByModel(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "run.json")
+	models := map[string]string{"writer": "model-a", "critic": "model-b"}
+
+	recorder, err := NewRecordingClient(&roleRouter{models: models}, path)
+	if err != nil {
+		t.Fatalf("NewRecordingClient() error = %v", err)
+	}
+	for _, role := range []string{"writer", "critic"} {
+		if _, err := recorder.ClientFor(role).(*RecordingClient).CompleteWithSystem(ctx, "system", "review"); err != nil {
+			t.Fatalf("CompleteWithSystem() error = %v", err)
+		}
+	}
+
+	replayer, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+	replayer.WithModels(models, "model-base")
+	for _, role := range []string{"critic", "writer"} {
+		got, err := replayer.ClientFor(role).CompleteWithSystem(ctx, "system", "review")
+		if err != nil {
+			t.Fatalf("replay for %s error = %v", role, err)
+		}
+		if want := models[role] + ": review"; got != want {
+			t.Errorf("replay for %s = %q, want %q", role, got, want)
+		}
+	}
+
+	if _, err := replayer.CompleteWithSystem(ctx, "system", "review"); !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("a model that was never recorded should miss, got %v", err)
+	}
+}
+
+// echoBatchClient answers each batch request with its prompt
+type echoBatchClient struct {
+	MockClient
+	requests []BatchRequest
+}
+
+func (e *echoBatchClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	e.requests = requests
+	return "batch-1", nil
+}
+
+func (e *echoBatchClient) WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error) {
+	results := make([]BatchResult, len(e.requests))
+	for i, r := range e.requests {
+		results[i] = BatchResult{CustomID: r.CustomID, Text: "drafted " + r.UserPrompt}
+	}
+	return results, nil
+}
+
+func TestRecordedBatchReplaysAsSingleRequests(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "run.json")
+
+	recorder, err := NewRecordingClient(&echoBatchClient{}, path)
+	if err != nil {
+		t.Fatalf("NewRecordingClient() error = %v", err)
+	}
+	writer := NewWithSystem(recorder, "", "system")
+	batchID, err := writer.SubmitBatch(ctx, map[string]string{"scene-1": "the heist", "scene-2": "the escape"})
+	if err != nil {
+		t.Fatalf("SubmitBatch() through the recorder error = %v", err)
+	}
+	responses, err := writer.WaitBatch(ctx, batchID)
+	if err != nil || len(responses) != 2 {
+		t.Fatalf("WaitBatch() = %v, %v, want both responses", responses, err)
+	}
+
+	replayer, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+	replayed := NewWithSystem(replayer, "", "system")
+	if _, err := replayed.SubmitBatch(ctx, map[string]string{"scene-1": "the heist"}); !errors.Is(err, ErrBatchUnsupported) {
+		t.Errorf("replay SubmitBatch() error = %v, want ErrBatchUnsupported", err)
+	}
+	got, err := replayed.Execute(ctx, "the escape", nil)
+	if err != nil {
+		t.Fatalf("replay Execute() error = %v", err)
+	}
+	if got != responses["scene-2"] {
+		t.Errorf("replay Execute() = %q, want the batch result %q", got, responses["scene-2"])
+	}
+}
@@ -1,17 +1,14 @@
 package agent
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 	"time"
-	
-	"golang.org/x/time/rate"
 )
 
 type Client struct {
@@ -20,9 +17,24 @@ type Client struct {
 	model      string
 	httpClient *http.Client
 	maxRetries int
-	limiter    *rate.Limiter
-	apiType    string // "anthropic" or "openai"
+	limiter    *AdaptiveLimiter
+	provider   Provider
 	logger     *slog.Logger
+	usageHook  UsageHook
+	generation GenerationOptions
+	
+	// Follow-up requests allowed to finish a text response cut off by the token limit
+	maxContinuations int
+	
+	batchPollInterval time.Duration
+	
+	// Context window in tokens for trimming conversations; zero looks it up by model
+	contextWindow int
+	
+	maxToolRounds int
+	
+	// Set on clients in a fallback chain so overload errors move on to the next model
+	failFastOnOverload bool
 }
 
 type Option func(*Client)
@@ -46,7 +58,14 @@ func WithTimeout(timeout time.Duration) Option {
 
 func WithRateLimit(requestsPerMinute int, burst int) Option {
 	return func(c *Client) {
-		c.limiter = rate.NewLimiter(rate.Limit(float64(requestsPerMinute)/60.0), burst)
+		c.limiter = NewAdaptiveLimiter(requestsPerMinute, burst)
+	}
+}
+
+// WithLimiter shares a rate limiter between clients that draw on the same quota
+func WithLimiter(limiter *AdaptiveLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
 	}
 }
 
@@ -54,32 +73,21 @@ func WithAPIConfig(baseURL, model string) Option {
 	return func(c *Client) {
 		c.baseURL = baseURL
 		c.model = model
-		// Detect API type based on base URL
-		if contains(baseURL, "openai") {
-			c.apiType = "openai"
-		} else {
-			c.apiType = "anthropic"
-		}
 	}
 }
 
-func WithLogger(logger *slog.Logger) Option {
+// WithProvider selects the API the client speaks. Without it the provider is
+// guessed from the base URL.
+func WithProvider(provider Provider) Option {
 	return func(c *Client) {
-		c.logger = logger
+		c.provider = provider
 	}
 }
 
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || findInString(s, substr)))
-}
-
-func findInString(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
 	}
-	return false
 }
 
 func NewClient(apiKey string, opts ...Option) *Client {
@@ -102,17 +110,24 @@ func NewClient(apiKey string, opts ...Option) *Client {
 			Transport: transport,
 		},
 		maxRetries: 3,
-		limiter:    rate.NewLimiter(rate.Limit(1), 1), // Default: 60 req/min
-		apiType:    "anthropic",
+		limiter:    NewAdaptiveLimiter(60, 1), // Default: 60 req/min
 		logger:     slog.Default().With("component", "ai_client"),
+		
+		maxContinuations:  defaultMaxContinuations,
+		batchPollInterval: defaultBatchPollInterval,
+		maxToolRounds:     defaultMaxToolRounds,
 	}
 	
 	for _, opt := range opts {
 		opt(c)
 	}
 	
+	if c.provider == nil {
+		c.provider = detectProvider(c.baseURL)
+	}
+	
 	c.logger.Debug("AI client initialized",
-		"api_type", c.apiType,
+		"provider", c.provider.Name(),
 		"base_url", c.baseURL,
 		"model", c.model,
 		"max_retries", c.maxRetries,
@@ -121,6 +136,22 @@ func NewClient(apiKey string, opts ...Option) *Client {
 	return c
 }
 
+// Limiter returns the client's rate limiter, which worker pools can follow to scale
+// their concurrency with the provider's rate limits
+func (c *Client) Limiter() *AdaptiveLimiter {
+	return c.limiter
+}
+
+// Model returns the model the client sends requests to
+func (c *Client) Model() string {
+	return c.model
+}
+
+// GenerationDefaults returns the client's default sampling parameters
+func (c *Client) GenerationDefaults() GenerationOptions {
+	return c.generation
+}
+
 func (c *Client) Execute(ctx context.Context, prompt string, input any) (string, error) {
 	fullPrompt := prompt
 	if input != nil {
@@ -142,42 +173,37 @@ func (c *Client) CompleteJSON(ctx context.Context, prompt string) (string, error
 
 // CompleteWithSystem makes a request with separate system and user prompts
 func (c *Client) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	return c.completeWithSystem(ctx, systemPrompt, userPrompt, false)
+	return c.completeWithSystem(ctx, Request{SystemPrompt: systemPrompt, UserPrompt: userPrompt})
 }
 
 // CompleteJSONWithSystem makes a JSON request with separate system and user prompts
 func (c *Client) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	return c.completeWithSystem(ctx, systemPrompt, userPrompt, true)
+	return c.completeWithSystem(ctx, Request{SystemPrompt: systemPrompt, UserPrompt: userPrompt, JSON: true})
+}
+
+// CompleteStructured makes a JSON request constrained to schema using the provider's
+// structured output support
+func (c *Client) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	return c.completeWithSystem(ctx, Request{SystemPrompt: systemPrompt, UserPrompt: userPrompt, JSON: true, Schema: schema})
 }
 
 func (c *Client) complete(ctx context.Context, prompt string, forceJSON bool) (string, error) {
-	requestID := fmt.Sprintf("api_%d", time.Now().UnixNano())
+	return c.completeWithSystem(ctx, Request{UserPrompt: prompt, JSON: forceJSON})
+}
+
+// retry calls attempt until it succeeds, fails with an error that isn't retryable or
+// runs out of retries. Every attempt goes through the limiter so retries can't outrun
+// a rate limit.
+func (c *Client) retry(ctx context.Context, requestID string, attempt func(n int) error) error {
 	startTime := time.Now()
-	
-	c.logger.Debug("waiting for rate limit",
-		"request_id", requestID)
-	
-	if err := c.limiter.Wait(ctx); err != nil {
-		c.logger.Error("rate limit wait failed",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("rate limit wait failed: %w", err)
-	}
-	
-	c.logger.Debug("rate limit passed for AI request",
-		"request_id", requestID,
-		"wait_duration_ms", time.Since(startTime).Milliseconds(),
-		"limit_per_second", c.limiter.Limit(),
-		"burst_capacity", c.limiter.Burst())
-	
 	var lastErr error
 	
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(attempt) * time.Second
+	for n := 0; n <= c.maxRetries; n++ {
+		if n > 0 {
+			backoff := retryDelay(lastErr, n)
 			c.logger.Debug("retry backoff",
 				"request_id", requestID,
-				"attempt", attempt,
+				"attempt", n,
 				"backoff_seconds", backoff.Seconds())
 			
 			select {
@@ -185,50 +211,49 @@ func (c *Client) complete(ctx context.Context, prompt string, forceJSON bool) (s
 			case <-ctx.Done():
 				c.logger.Warn("request cancelled during backoff",
 					"request_id", requestID,
-					"attempt", attempt)
-				return "", ctx.Err()
+					"attempt", n)
+				return ctx.Err()
 			}
 		}
 		
-		attemptStart := time.Now()
-		// Extract operation type from prompt for better logging
-		operationType := extractOperationType(prompt)
-		c.logger.Debug("attempting AI generation request",
+		c.logger.Debug("waiting for rate limit",
+			"request_id", requestID)
+		
+		waitStart := time.Now()
+		if err := c.limiter.Wait(ctx); err != nil {
+			c.logger.Error("rate limit wait failed",
+				"request_id", requestID,
+				"error", err)
+			return fmt.Errorf("rate limit wait failed: %w", err)
+		}
+		
+		c.logger.Debug("rate limit passed for AI request",
 			"request_id", requestID,
-			"attempt", attempt,
-			"operation", operationType,
-			"prompt_length", len(prompt),
-			"force_json", forceJSON,
-			"api_type", c.apiType,
-			"model", c.model)
+			"wait_duration_ms", time.Since(waitStart).Milliseconds(),
+			"limit_per_second", c.limiter.Limit(),
+			"burst_capacity", c.limiter.Burst())
 		
-		response, err := c.doRequest(ctx, prompt, forceJSON)
+		attemptStart := time.Now()
+		err := attempt(n)
 		attemptDuration := time.Since(attemptStart)
-		
 		if err == nil {
-			c.logger.Info("API request successful",
-				"request_id", requestID,
-				"attempt", attempt,
-				"duration_ms", attemptDuration.Milliseconds(),
-				"response_length", len(response),
-				"total_duration_ms", time.Since(startTime).Milliseconds())
-			return response, nil
+			return nil
 		}
 		
 		lastErr = err
 		
-		if !isRetryable(err) {
+		if !isRetryable(err) || (c.failFastOnOverload && IsOverloaded(err)) {
 			c.logger.Error("API request failed with non-retryable error",
 				"request_id", requestID,
-				"attempt", attempt,
+				"attempt", n,
 				"duration_ms", attemptDuration.Milliseconds(),
 				"error", err)
-			return "", err
+			return err
 		}
 		
 		c.logger.Warn("API request failed, will retry",
 			"request_id", requestID,
-			"attempt", attempt,
+			"attempt", n,
 			"duration_ms", attemptDuration.Milliseconds(),
 			"error", err)
 	}
@@ -239,298 +264,173 @@ func (c *Client) complete(ctx context.Context, prompt string, forceJSON bool) (s
 		"total_duration_ms", time.Since(startTime).Milliseconds(),
 		"last_error", lastErr)
 	
-	return "", fmt.Errorf("max retries exceeded: %w", lastErr)
+	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
-func (c *Client) doRequest(ctx context.Context, prompt string, forceJSON bool) (string, error) {
-	if c.apiType == "openai" {
-		return c.doOpenAIRequest(ctx, prompt, forceJSON)
+// send performs a request through the configured provider. A text response cut off by
+// the output token limit is continued with follow-up requests and returned whole.
+func (c *Client) send(ctx context.Context, req Request) (string, error) {
+	c.prepare(ctx, &req)
+	
+	text, usage, err := c.sendOnce(ctx, req)
+	for i := 0; err == nil && usage.StopReason == StopMaxTokens && canContinue(req) && i < c.maxContinuations; i++ {
+		c.logger.Info("response hit the token limit, continuing",
+			"provider", c.provider.Name(),
+			"max_tokens", req.MaxTokens,
+			"continuation", i+1,
+			"response_length", len(text))
+		
+		req.Partial = strings.TrimRight(text, " \t\r\n")
+		var more string
+		more, usage, err = c.sendOnce(ctx, req)
+		text = req.Partial + more
 	}
-	return c.doAnthropicRequest(ctx, prompt, forceJSON)
+	return text, err
 }
 
-func (c *Client) doOpenAIRequest(ctx context.Context, prompt string, forceJSON bool) (string, error) {
-	requestID := fmt.Sprintf("openai_%d", time.Now().UnixNano())
-	
-	operationType := extractOperationType(prompt)
-	c.logger.Debug("preparing OpenAI API request",
-		"request_id", requestID,
-		"operation", operationType,
-		"model", c.model,
-		"force_json", forceJSON,
-		"message_count", 1)
-	
-	messages := []map[string]string{
-		{
-			"role":    "user",
-			"content": prompt,
-		},
-	}
-	
-	// Add system message for JSON mode
-	if forceJSON {
-		messages = append([]map[string]string{
-			{
-				"role":    "system",
-				"content": "You are a helpful assistant that MUST respond with valid JSON only. Your entire response must be a single JSON object with no additional text, markdown, or explanations.",
-			},
-		}, messages...)
-	}
-	
-	requestBody := map[string]interface{}{
-		"model": c.model,
-		"messages": messages,
-		"max_tokens": 4096,
-	}
-	
-	if forceJSON {
-		requestBody["response_format"] = map[string]string{
-			"type": "json_object",
-		}
-	}
-	
-	body, err := json.Marshal(requestBody)
-	if err != nil {
-		c.logger.Error("failed to marshal OpenAI request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-	
-	c.logger.Debug("OpenAI request body prepared",
-		"request_id", requestID,
-		"operation", operationType,
-		"body_size_bytes", len(body),
-		"max_tokens", 4096,
-		"has_json_mode", forceJSON)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		c.logger.Error("failed to create OpenAI request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("creating request: %w", err)
+// defaultMaxContinuations bounds how long a truncated response can grow
+const defaultMaxContinuations = 3
+
+// prepare fills in the model and the generation options in effect for ctx
+func (c *Client) prepare(ctx context.Context, req *Request) {
+	req.Model = c.model
+	c.generation.Merge(GenerationOptionsFromContext(ctx)).applyTo(req)
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultMaxTokens
 	}
+}
+
+// defaultMaxTokens is the output limit when no generation options set one
+const defaultMaxTokens = 4096
+
+// canContinue reports whether a truncated response to req can be extended. JSON is
+// left alone: a continuation would be a second document, not the rest of the first.
+func canContinue(req Request) bool {
+	return !req.JSON && req.Schema == nil
+}
+
+// sendOnce performs a single HTTP request through the configured provider
+func (c *Client) sendOnce(ctx context.Context, req Request) (string, Usage, error) {
+	requestID := fmt.Sprintf("%s_%d", c.provider.Name(), time.Now().UnixNano())
 	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	
-	httpStart := time.Now()
-	c.logger.Debug("sending OpenAI HTTP request",
+	operationType := extractOperationType(req.UserPrompt)
+	c.logger.Debug("preparing AI request",
 		"request_id", requestID,
+		"provider", c.provider.Name(),
 		"operation", operationType,
-		"endpoint", "/chat/completions",
-		"method", "POST")
-	
-	resp, err := c.httpClient.Do(req)
-	httpDuration := time.Since(httpStart)
+		"model", c.model,
+		"force_json", req.JSON,
+		"has_system_prompt", req.SystemPrompt != "")
 	
+	respBody, err := c.post(ctx, requestID, req)
 	if err != nil {
-		c.logger.Error("OpenAI HTTP request failed",
-			"request_id", requestID,
-			"duration_ms", httpDuration.Milliseconds(),
-			"error", err)
-		return "", fmt.Errorf("making request: %w", err)
+		return "", Usage{}, err
 	}
-	defer resp.Body.Close()
-	
-	c.logger.Debug("OpenAI HTTP response received",
-		"request_id", requestID,
-		"status_code", resp.StatusCode,
-		"duration_ms", httpDuration.Milliseconds())
 	
-	respBody, err := io.ReadAll(resp.Body)
+	content, usage, err := c.provider.ParseResponse(respBody)
 	if err != nil {
-		c.logger.Error("failed to read OpenAI response body",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-	
-	c.logger.Debug("OpenAI response body read",
-		"request_id", requestID,
-		"body_size", len(respBody))
-	
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("OpenAI API error",
-			"request_id", requestID,
-			"status_code", resp.StatusCode,
-			"response_body", string(respBody))
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
-	}
-	
-	var response struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
-	}
-	
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		c.logger.Error("failed to parse OpenAI response",
+		c.logger.Error("failed to parse response",
 			"request_id", requestID,
+			"provider", c.provider.Name(),
 			"error", err,
 			"response_body", string(respBody))
-		return "", fmt.Errorf("parsing response: %w", err)
+		return "", Usage{}, err
 	}
 	
-	if len(response.Choices) == 0 {
-		c.logger.Error("no choices in OpenAI response",
-			"request_id", requestID)
-		return "", fmt.Errorf("no choices in response")
-	}
-	
-	c.logger.Info("OpenAI request completed",
+	c.logger.Info("AI request completed",
 		"request_id", requestID,
-		"prompt_tokens", response.Usage.PromptTokens,
-		"completion_tokens", response.Usage.CompletionTokens,
-		"total_tokens", response.Usage.TotalTokens,
-		"response_length", len(response.Choices[0].Message.Content))
+		"provider", c.provider.Name(),
+		"input_tokens", usage.InputTokens,
+		"output_tokens", usage.OutputTokens,
+		"stop_reason", usage.StopReason,
+		"total_tokens", usage.InputTokens+usage.OutputTokens,
+		"response_length", len(content))
+	c.recordUsage(ctx, usage.InputTokens, usage.OutputTokens)
 	
-	return response.Choices[0].Message.Content, nil
+	return content, usage, nil
 }
 
-func (c *Client) doAnthropicRequest(ctx context.Context, prompt string, forceJSON bool) (string, error) {
-	requestID := fmt.Sprintf("anthropic_%d", time.Now().UnixNano())
-	
-	operationType := extractOperationType(prompt)
-	c.logger.Debug("preparing Anthropic API request",
-		"request_id", requestID,
-		"operation", operationType,
-		"model", c.model,
-		"force_json", forceJSON,
-		"message_count", 1)
-	
-	requestBody := map[string]interface{}{
-		"model": c.model,
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"max_tokens": 4096,
-	}
-	
-	if forceJSON {
-		requestBody["system"] = "You are a helpful assistant that responds with valid JSON only. Do not include markdown formatting, explanations, or any text outside of the JSON object."
-	}
-	
-	body, err := json.Marshal(requestBody)
+// post sends req through the provider and returns the body of a successful response
+func (c *Client) post(ctx context.Context, requestID string, req Request) ([]byte, error) {
+	httpReq, err := c.provider.BuildRequest(ctx, Endpoint{BaseURL: c.baseURL, APIKey: c.apiKey}, req)
 	if err != nil {
-		c.logger.Error("failed to marshal Anthropic request",
+		c.logger.Error("failed to build request",
 			"request_id", requestID,
 			"error", err)
-		return "", fmt.Errorf("marshaling request: %w", err)
+		return nil, err
 	}
 	
-	c.logger.Debug("Anthropic request body prepared",
-		"request_id", requestID,
-		"operation", operationType,
-		"body_size_bytes", len(body),
-		"max_tokens", 4096,
-		"has_system_prompt", forceJSON)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/messages", bytes.NewReader(body))
-	if err != nil {
-		c.logger.Error("failed to create Anthropic request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	
 	httpStart := time.Now()
-	c.logger.Debug("sending Anthropic HTTP request",
+	c.logger.Debug("sending HTTP request",
 		"request_id", requestID,
-		"operation", operationType,
-		"endpoint", "/messages",
-		"method", "POST")
+		"operation", extractOperationType(req.UserPrompt),
+		"url", httpReq.URL.String(),
+		"method", httpReq.Method)
 	
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(httpReq)
 	httpDuration := time.Since(httpStart)
 	
 	if err != nil {
-		c.logger.Error("Anthropic HTTP request failed",
+		c.logger.Error("HTTP request failed",
 			"request_id", requestID,
 			"duration_ms", httpDuration.Milliseconds(),
 			"error", err)
-		return "", fmt.Errorf("making request: %w", err)
+		return nil, fmt.Errorf("making request: %w", err)
 	}
 	defer resp.Body.Close()
 	
-	c.logger.Debug("Anthropic HTTP response received",
+	c.logger.Debug("HTTP response received",
 		"request_id", requestID,
 		"status_code", resp.StatusCode,
 		"duration_ms", httpDuration.Milliseconds())
+	c.limiter.Observe(resp.StatusCode, resp.Header)
 	
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.logger.Error("failed to read Anthropic response body",
+		c.logger.Error("failed to read response body",
 			"request_id", requestID,
 			"error", err)
-		return "", fmt.Errorf("reading response: %w", err)
+		return nil, fmt.Errorf("reading response: %w", err)
 	}
 	
-	c.logger.Debug("Anthropic response body read",
-		"request_id", requestID,
-		"body_size", len(respBody))
-	
 	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Anthropic API error",
+		c.logger.Error("API error",
 			"request_id", requestID,
+			"provider", c.provider.Name(),
 			"status_code", resp.StatusCode,
 			"response_body", string(respBody))
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
-	}
-	
-	var response struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-		Usage struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"usage"`
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody), RetryAfter: retryAfter(resp.Header)}
 	}
 	
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		c.logger.Error("failed to parse Anthropic response",
-			"request_id", requestID,
-			"error", err,
-			"response_body", string(respBody))
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-	
-	if len(response.Content) == 0 {
-		c.logger.Error("no content in Anthropic response",
-			"request_id", requestID)
-		return "", fmt.Errorf("no content in response")
-	}
-	
-	c.logger.Info("Anthropic request completed",
-		"request_id", requestID,
-		"input_tokens", response.Usage.InputTokens,
-		"output_tokens", response.Usage.OutputTokens,
-		"total_tokens", response.Usage.InputTokens+response.Usage.OutputTokens,
-		"response_length", len(response.Content[0].Text))
-	
-	return response.Content[0].Text, nil
+	return respBody, nil
 }
 
 func isRetryable(err error) bool {
 	return true
 }
 
+// APIError is a non-200 response from the provider
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // From the Retry-After header; zero when absent
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// IsOverloaded reports whether err means the model is temporarily over capacity,
+// in which case another model is more likely to succeed than a retry
+func IsOverloaded(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == 529 || apiErr.StatusCode == http.StatusServiceUnavailable ||
+		strings.Contains(apiErr.Body, "overloaded")
+}
+
 // extractOperationType analyzes the prompt to determine what operation is being performed
 func extractOperationType(prompt string) string {
 	if len(prompt) < 50 {
@@ -592,342 +492,41 @@ func extractOperationType(prompt string) string {
 	return "general_request"
 }
 
-// completeWithSystem handles requests with separate system and user prompts
-func (c *Client) completeWithSystem(ctx context.Context, systemPrompt, userPrompt string, forceJSON bool) (string, error) {
+// completeWithSystem sends a request, retrying failures that are worth another attempt
+func (c *Client) completeWithSystem(ctx context.Context, req Request) (string, error) {
 	requestID := fmt.Sprintf("api_%d", time.Now().UnixNano())
 	startTime := time.Now()
-	
-	c.logger.Debug("waiting for rate limit",
-		"request_id", requestID)
-	
-	// Wait for rate limiter
-	if err := c.limiter.Wait(ctx); err != nil {
-		return "", fmt.Errorf("rate limiting: %w", err)
-	}
-	
-	c.logger.Debug("rate limit passed for AI request",
-		"request_id", requestID,
-		"wait_duration_ms", time.Since(startTime).Milliseconds(),
-		"limit_per_second", c.limiter.Limit(),
-		"burst_capacity", c.limiter.Burst())
-	
-	operationType := extractOperationType(userPrompt)
-	
-	c.logger.Debug("attempting AI generation request",
-		"request_id", requestID,
-		"attempt", 0,
-		"operation", operationType,
-		"system_prompt_length", len(systemPrompt),
-		"user_prompt_length", len(userPrompt),
-		"force_json", forceJSON,
-		"api_type", c.apiType,
-		"model", c.model)
+	operationType := extractOperationType(req.UserPrompt)
 	
 	var response string
-	var err error
-	
-	if c.apiType == "openai" {
-		response, err = c.doOpenAIRequestWithSystem(ctx, systemPrompt, userPrompt, forceJSON)
-	} else {
-		response, err = c.doAnthropicRequestWithSystem(ctx, systemPrompt, userPrompt, forceJSON)
-	}
-	
-	if err != nil {
-		c.logger.Error("AI generation request failed",
+	var attempts int
+	err := c.retry(ctx, requestID, func(attempt int) error {
+		attempts = attempt
+		c.logger.Debug("attempting AI generation request",
 			"request_id", requestID,
+			"attempt", attempt,
 			"operation", operationType,
-			"error", err)
-		return "", fmt.Errorf("AI generation failed: %w", err)
+			"system_prompt_length", len(req.SystemPrompt),
+			"user_prompt_length", len(req.UserPrompt),
+			"force_json", req.JSON,
+			"has_schema", req.Schema != nil,
+			"provider", c.provider.Name(),
+			"model", c.model)
+		
+		var err error
+		response, err = c.send(ctx, req)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
 	
 	c.logger.Info("API request successful",
 		"request_id", requestID,
-		"attempt", 0,
-		"duration_ms", time.Since(startTime).Milliseconds(),
+		"attempt", attempts,
+		"operation", operationType,
 		"response_length", len(response),
 		"total_duration_ms", time.Since(startTime).Milliseconds())
 	
 	return response, nil
 }
-
-// doOpenAIRequestWithSystem makes OpenAI API request with separate system and user prompts
-func (c *Client) doOpenAIRequestWithSystem(ctx context.Context, systemPrompt, userPrompt string, forceJSON bool) (string, error) {
-	requestID := fmt.Sprintf("openai_%d", time.Now().UnixNano())
-	
-	operationType := extractOperationType(userPrompt)
-	c.logger.Debug("preparing OpenAI API request with system prompt",
-		"request_id", requestID,
-		"operation", operationType,
-		"model", c.model,
-		"force_json", forceJSON,
-		"message_count", 2)
-	
-	messages := []map[string]string{
-		{
-			"role":    "system",
-			"content": systemPrompt,
-		},
-		{
-			"role":    "user",
-			"content": userPrompt,
-		},
-	}
-	
-	// For JSON mode, enhance system prompt
-	if forceJSON {
-		messages[0]["content"] = systemPrompt + "\n\nIMPORTANT: You MUST respond with valid JSON only. Your entire response must be a single JSON object with no additional text, markdown, or explanations."
-	}
-	
-	requestBody := map[string]interface{}{
-		"model": c.model,
-		"messages": messages,
-		"max_tokens": 4096,
-	}
-	
-	if forceJSON {
-		requestBody["response_format"] = map[string]string{
-			"type": "json_object",
-		}
-	}
-	
-	body, err := json.Marshal(requestBody)
-	if err != nil {
-		c.logger.Error("failed to marshal OpenAI request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-	
-	c.logger.Debug("OpenAI request body prepared",
-		"request_id", requestID,
-		"operation", operationType,
-		"body_size_bytes", len(body),
-		"max_tokens", 4096,
-		"has_json_mode", forceJSON)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		c.logger.Error("failed to create OpenAI request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	
-	httpStart := time.Now()
-	c.logger.Debug("sending OpenAI HTTP request",
-		"request_id", requestID,
-		"operation", operationType,
-		"endpoint", "/chat/completions",
-		"method", "POST")
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("OpenAI HTTP request failed",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	c.logger.Debug("OpenAI HTTP response received",
-		"request_id", requestID,
-		"status_code", resp.StatusCode,
-		"duration_ms", time.Since(httpStart).Milliseconds())
-	
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.logger.Error("failed to read OpenAI response body",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-	
-	c.logger.Debug("OpenAI response body read",
-		"request_id", requestID,
-		"body_size", len(responseBody))
-	
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("OpenAI API error",
-			"request_id", requestID,
-			"status_code", resp.StatusCode,
-			"response", string(responseBody))
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(responseBody))
-	}
-	
-	var response struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
-	}
-	
-	if err := json.Unmarshal(responseBody, &response); err != nil {
-		c.logger.Error("failed to parse OpenAI response",
-			"request_id", requestID,
-			"error", err,
-			"response", string(responseBody))
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-	
-	if len(response.Choices) == 0 {
-		c.logger.Error("no choices in OpenAI response",
-			"request_id", requestID,
-			"response", string(responseBody))
-		return "", fmt.Errorf("no choices in response")
-	}
-	
-	content := response.Choices[0].Message.Content
-	
-	c.logger.Info("OpenAI request completed",
-		"request_id", requestID,
-		"prompt_tokens", response.Usage.PromptTokens,
-		"completion_tokens", response.Usage.CompletionTokens,
-		"total_tokens", response.Usage.TotalTokens,
-		"response_length", len(content))
-	
-	return content, nil
-}
-
-// doAnthropicRequestWithSystem makes Anthropic API request with separate system and user prompts  
-func (c *Client) doAnthropicRequestWithSystem(ctx context.Context, systemPrompt, userPrompt string, forceJSON bool) (string, error) {
-	requestID := fmt.Sprintf("anthropic_%d", time.Now().UnixNano())
-	
-	operationType := extractOperationType(userPrompt)
-	c.logger.Debug("preparing Anthropic API request with system prompt",
-		"request_id", requestID,
-		"operation", operationType,
-		"model", c.model,
-		"force_json", forceJSON,
-		"message_count", 1)
-	
-	// Anthropic uses system parameter separate from messages
-	finalSystemPrompt := systemPrompt
-	if forceJSON {
-		finalSystemPrompt = systemPrompt + "\n\nIMPORTANT: You MUST respond with valid JSON only. Your entire response must be a single JSON object with no additional text, markdown, or explanations."
-	}
-	
-	requestBody := map[string]interface{}{
-		"model": c.model,
-		"system": finalSystemPrompt,
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": userPrompt,
-			},
-		},
-		"max_tokens": 4096,
-	}
-	
-	body, err := json.Marshal(requestBody)
-	if err != nil {
-		c.logger.Error("failed to marshal Anthropic request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-	
-	c.logger.Debug("Anthropic request body prepared",
-		"request_id", requestID,
-		"operation", operationType,
-		"body_size_bytes", len(body),
-		"max_tokens", 4096,
-		"has_system_prompt", true)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/messages", bytes.NewReader(body))
-	if err != nil {
-		c.logger.Error("failed to create Anthropic request",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	
-	httpStart := time.Now()
-	c.logger.Debug("sending Anthropic HTTP request",
-		"request_id", requestID,
-		"operation", operationType,
-		"endpoint", "/messages",
-		"method", "POST")
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("Anthropic HTTP request failed",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	c.logger.Debug("Anthropic HTTP response received",
-		"request_id", requestID,
-		"status_code", resp.StatusCode,
-		"duration_ms", time.Since(httpStart).Milliseconds())
-	
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.logger.Error("failed to read Anthropic response body",
-			"request_id", requestID,
-			"error", err)
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-	
-	c.logger.Debug("Anthropic response body read",
-		"request_id", requestID,
-		"body_size", len(responseBody))
-	
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Anthropic API error",
-			"request_id", requestID,
-			"status_code", resp.StatusCode,
-			"response", string(responseBody))
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(responseBody))
-	}
-	
-	var response struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-		Usage struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"usage"`
-	}
-	
-	if err := json.Unmarshal(responseBody, &response); err != nil {
-		c.logger.Error("failed to parse Anthropic response",
-			"request_id", requestID,
-			"error", err,
-			"response", string(responseBody))
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-	
-	if len(response.Content) == 0 {
-		c.logger.Error("no content in Anthropic response",
-			"request_id", requestID,
-			"response", string(responseBody))
-		return "", fmt.Errorf("no content in response")
-	}
-	
-	content := response.Content[0].Text
-	
-	c.logger.Info("Anthropic request completed",
-		"request_id", requestID,
-		"input_tokens", response.Usage.InputTokens,
-		"output_tokens", response.Usage.OutputTokens,
-		"total_tokens", response.Usage.InputTokens + response.Usage.OutputTokens,
-		"response_length", len(content))
-	
-	return content, nil
-}
\ No newline at end of file
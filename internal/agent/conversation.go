@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Message roles in a Conversation
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation
+type Message struct {
+	Role    string `json:"role"` // RoleUser or RoleAssistant
+	Content string `json:"content"`
+
+	// Set on assistant messages that ask to run tools, and on the user message that
+	// answers them
+	ToolCalls   []ToolCall   `json:"tool_calls,omitempty"`
+	ToolResults []ToolResult `json:"tool_results,omitempty"`
+}
+
+// Conversation is a multi-turn exchange sent to the model as real messages rather than
+// one concatenated prompt. Messages alternate between user and assistant, starting and
+// ending with the user.
+type Conversation struct {
+	System   string    `json:"system,omitempty"`
+	Messages []Message `json:"messages"`
+	JSON     bool      `json:"json,omitempty"` // Ask for the reply as a single JSON object
+}
+
+// NewConversation starts an empty conversation with the given system prompt
+func NewConversation(system string) *Conversation {
+	return &Conversation{System: system}
+}
+
+// AddUser appends a user message
+func (c *Conversation) AddUser(content string) *Conversation {
+	c.Messages = append(c.Messages, Message{Role: RoleUser, Content: content})
+	return c
+}
+
+// AddAssistant appends a model reply
+func (c *Conversation) AddAssistant(content string) *Conversation {
+	c.Messages = append(c.Messages, Message{Role: RoleAssistant, Content: content})
+	return c
+}
+
+// transcript flattens the conversation into one string, used to key recorded and
+// cached responses
+func (c *Conversation) transcript() string {
+	var b strings.Builder
+	for _, m := range c.Messages {
+		b.WriteString(m.Role)
+		b.WriteString(": ")
+		b.WriteString(m.Content)
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// lastUserMessage returns the message the model is expected to answer
+func (c *Conversation) lastUserMessage() (string, error) {
+	if len(c.Messages) == 0 || c.Messages[len(c.Messages)-1].Role != RoleUser {
+		return "", fmt.Errorf("conversation must end with a user message")
+	}
+	return c.Messages[len(c.Messages)-1].Content, nil
+}
+
+// WithContextWindow sets the model's context window in tokens, which bounds how much
+// conversation history is sent. Without it the window is looked up by model name.
+func WithContextWindow(tokens int) Option {
+	return func(c *Client) {
+		c.contextWindow = tokens
+	}
+}
+
+// defaultContextWindow is assumed for unknown models, such as most local Ollama models
+const defaultContextWindow = 8192
+
+// contextWindows maps model name prefixes to their context window in tokens. The
+// longest matching prefix wins.
+var contextWindows = map[string]int{
+	"claude":        200000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1000000,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            128000,
+	"o3":            200000,
+	"o4":            200000,
+}
+
+// contextWindowFor returns the context window of model in tokens
+func contextWindowFor(model string) int {
+	window, matched := defaultContextWindow, 0
+	for prefix, tokens := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			window, matched = tokens, len(prefix)
+		}
+	}
+	return window
+}
+
+// estimateTokens approximates the token count of text at four characters per token,
+// which is close enough for English prose to decide what history to keep
+func estimateTokens(text string) int {
+	return len(text)/4 + 1
+}
+
+// fitContextWindow drops the oldest exchanges until the conversation plus reserve tokens
+// for the reply fit in window. The opening user message, which usually carries the
+// brief, and the latest user message are always kept. It returns the remaining messages
+// and how many were dropped.
+func fitContextWindow(system string, messages []Message, window, reserve int) ([]Message, int) {
+	total := estimateTokens(system) + reserve
+	for _, m := range messages {
+		total += estimateTokens(m.Content)
+	}
+	if total <= window {
+		return messages, 0
+	}
+
+	kept := append([]Message(nil), messages...)
+	dropped := 0
+	// Removing an assistant reply together with the user message after it keeps the
+	// roles alternating
+	for len(kept) > 3 && total > window {
+		total -= estimateTokens(kept[1].Content) + estimateTokens(kept[2].Content)
+		kept = append(kept[:1], kept[3:]...)
+		dropped += 2
+	}
+	return kept, dropped
+}
+
+// CompleteConversation sends the conversation as a list of messages and returns the
+// model's reply to its last user message. History that would overflow the model's
+// context window is trimmed, oldest exchanges first. conv is not modified.
+func (c *Client) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	prompt, err := conv.lastUserMessage()
+	if err != nil {
+		return "", err
+	}
+
+	window := c.contextWindow
+	if window == 0 {
+		window = contextWindowFor(c.model)
+	}
+	reserve := c.generation.Merge(GenerationOptionsFromContext(ctx)).MaxTokens
+	if reserve == 0 {
+		reserve = defaultMaxTokens
+	}
+
+	messages, dropped := fitContextWindow(conv.System, conv.Messages, window, reserve)
+	if dropped > 0 {
+		c.logger.Info("trimmed conversation history to fit the context window",
+			"model", c.model,
+			"context_window", window,
+			"dropped_messages", dropped,
+			"kept_messages", len(messages))
+	}
+
+	return c.completeWithSystem(ctx, Request{SystemPrompt: conv.System, UserPrompt: prompt, Messages: messages, JSON: conv.JSON})
+}
+
+// Converse sends the conversation with the agent's system prompt (unless conv sets
+// its own) and generation options, and appends the reply to conv
+func (a *Agent) Converse(ctx context.Context, conv *Conversation) (string, error) {
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
+	if conv.System == "" {
+		conv.System = a.systemPrompt
+	}
+
+	response, err := a.client.CompleteConversation(ctx, conv)
+	if err != nil {
+		a.logger.Error("conversation request failed",
+			"messages", len(conv.Messages),
+			"error", err)
+		return "", err
+	}
+	conv.AddAssistant(response)
+	return response, nil
+}
+
+// ExecuteTurn continues a conversation whose earlier messages are history, alternating
+// user and assistant and starting with the user, by sending prompt as the next user
+// message. The agent's prompt template is applied to the opening message only, so it
+// frames the conversation once instead of on every turn.
+func (a *Agent) ExecuteTurn(ctx context.Context, history []string, prompt string) (string, error) {
+	conv := NewConversation(a.systemPrompt)
+	for i, content := range history {
+		if i == 0 {
+			content, _ = a.resolvePrompt("conversation", content, nil)
+		}
+		if i%2 == 0 {
+			conv.AddUser(content)
+		} else {
+			conv.AddAssistant(content)
+		}
+	}
+	if len(history) == 0 {
+		prompt, _ = a.resolvePrompt("conversation", prompt, nil)
+	}
+	conv.AddUser(prompt)
+
+	return a.Converse(ctx, conv)
+}
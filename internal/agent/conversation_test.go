@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompleteConversationSendsMessages(t *testing.T) {
+	for _, provider := range []string{"anthropic", "openai"} {
+		t.Run(provider, func(t *testing.T) {
+			var got struct {
+				System   string    `json:"system"`
+				Messages []Message `json:"messages"`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				if provider == "openai" {
+					io.WriteString(w, `{"choices":[{"message":{"content":"a rainy harbour town"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1}}`)
+					return
+				}
+				io.WriteString(w, `{"content":[{"type":"text","text":"a rainy harbour town"}],"usage":{"input_tokens":1,"output_tokens":1}}`)
+			}))
+			defer srv.Close()
+
+			p, _ := GetProvider(provider)
+			client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithProvider(p), WithRetry(0), WithRateLimit(6000, 10))
+			a := NewWithSystem(client, "", "You are a story editor.")
+
+			reply, err := a.ExecuteTurn(context.Background(), []string{"What is this story about?", "Grief."}, "Where is it set?")
+			if err != nil {
+				t.Fatalf("ExecuteTurn() error = %v", err)
+			}
+			if reply != "a rainy harbour town" {
+				t.Errorf("ExecuteTurn() = %q", reply)
+			}
+
+			want := []Message{
+				{Role: RoleUser, Content: "What is this story about?"},
+				{Role: RoleAssistant, Content: "Grief."},
+				{Role: RoleUser, Content: "Where is it set?"},
+			}
+			if provider == "openai" {
+				want = append([]Message{{Role: "system", Content: "You are a story editor."}}, want...)
+			} else if got.System != "You are a story editor." {
+				t.Errorf("system = %q", got.System)
+			}
+			if len(got.Messages) != len(want) {
+				t.Fatalf("sent %d messages, want %d: %+v", len(got.Messages), len(want), got.Messages)
+			}
+			for i := range want {
+				if got.Messages[i].Role != want[i].Role || got.Messages[i].Content != want[i].Content {
+					t.Errorf("message %d = %+v, want %+v", i, got.Messages[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFitContextWindowDropsOldestExchanges(t *testing.T) {
+	long := strings.Repeat("x", 400) // ~100 tokens
+	messages := []Message{
+		{Role: RoleUser, Content: "brief"},
+		{Role: RoleAssistant, Content: long},
+		{Role: RoleUser, Content: long},
+		{Role: RoleAssistant, Content: long},
+		{Role: RoleUser, Content: "latest"},
+	}
+
+	kept, dropped := fitContextWindow("", messages, 200, 50)
+	if dropped != 2 {
+		t.Fatalf("dropped %d messages, want the oldest exchange", dropped)
+	}
+	if kept[0].Content != "brief" || kept[1].Role != RoleAssistant || kept[len(kept)-1].Content != "latest" {
+		t.Errorf("kept = %+v, want the brief, alternating roles and the latest message", kept)
+	}
+	if messages[1].Content != long {
+		t.Errorf("fitContextWindow modified its input")
+	}
+
+	if _, dropped := fitContextWindow("", messages, 1000, 50); dropped != 0 {
+		t.Errorf("dropped %d messages from a conversation that fits", dropped)
+	}
+	if got := contextWindowFor("gpt-4o-mini"); got != 128000 {
+		t.Errorf("contextWindowFor(gpt-4o-mini) = %d, want the gpt-4o window", got)
+	}
+}
@@ -6,8 +6,9 @@ import (
 
 // AgentFactory creates agents with appropriate prompts based on context
 type AgentFactory struct {
-	client     AIClient
-	promptsDir string
+	client      AIClient
+	promptsDir  string
+	roleOptions map[string]GenerationOptions
 }
 
 // NewAgentFactory creates a new agent factory
@@ -18,8 +19,72 @@ func NewAgentFactory(client AIClient, promptsDir string) *AgentFactory {
 	}
 }
 
+// WithRoleOptions sets generation options per agent role, such as a low temperature
+// for the critic or a higher token limit for the writer
+func (f *AgentFactory) WithRoleOptions(opts map[string]GenerationOptions) *AgentFactory {
+	f.roleOptions = opts
+	return f
+}
+
+// configure applies the role's generation options to a newly created agent. Aliased
+// roles take the options of the role they resolve to, like their model.
+func (f *AgentFactory) configure(a *Agent) *Agent {
+	if opts, ok := f.roleOptions[canonicalRole(a.role)]; ok {
+		a.WithGenerationOptions(opts)
+	}
+	return a
+}
+
+// roleAliases maps the other names fiction phases create agents under to the role
+// ai.models knows them by
+var roleAliases = map[string]string{
+	"orchestrator":      "planning",
+	"natural_writer":    "writer",
+	"contextual_editor": "editor",
+}
+
+// canonicalRole returns the name ai.models knows role by
+func canonicalRole(role string) string {
+	if canonical, ok := roleAliases[role]; ok {
+		return canonical
+	}
+	return role
+}
+
+// clientFor returns the client for role, routing to a role-specific model when the
+// factory's client supports it
+func (f *AgentFactory) clientFor(role string) AIClient {
+	if resolver, ok := f.client.(RoleClientResolver); ok {
+		return resolver.ClientFor(canonicalRole(role))
+	}
+	return f.client
+}
+
+// PromptPath resolves a prompt file name against the factory's prompts directory.
+// Absolute paths are returned unchanged.
+func (f *AgentFactory) PromptPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(f.promptsDir, name)
+}
+
+// CreateAgent creates an agent for an arbitrary role, such as one a plugin defines.
+// A relative promptPath is resolved against the prompts directory; an empty one
+// sends prompts as they are.
+func (f *AgentFactory) CreateAgent(role, promptPath string) *Agent {
+	if promptPath != "" {
+		promptPath = f.PromptPath(promptPath)
+	}
+	return f.configure(New(f.clientFor(role), promptPath).WithRole(role))
+}
+
 // CreateFictionAgent creates an agent configured for fiction generation
 func (f *AgentFactory) CreateFictionAgent(phase string) *Agent {
+	return f.configure(f.createFictionAgent(phase))
+}
+
+func (f *AgentFactory) createFictionAgent(phase string) *Agent {
 	// Use enhanced prompts with system prompts
 	switch phase {
 	case "planning", "orchestrator":
@@ -36,7 +101,7 @@ Your expertise includes:
 You approach every project with both artistic vision and commercial awareness, ensuring stories are not only compelling but also marketable.`
 		
 		promptPath := filepath.Join(f.promptsDir, "orchestrator.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "writer", "natural_writer":
 		systemPrompt := `You are Sarah Chen, an award-winning novelist known for immersive prose and authentic character voices. With expertise across multiple genres, you've mastered the art of bringing scenes to life through sensory detail and emotional resonance.
@@ -50,7 +115,7 @@ Your writing expertise includes:
 - Understanding genre conventions while bringing fresh perspectives`
 		
 		promptPath := filepath.Join(f.promptsDir, "writer.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "editor", "contextual_editor":
 		systemPrompt := `You are Michael Torres, a veteran editor with 20 years of experience working with bestselling authors. You've edited everything from literary fiction to commercial thrillers, developing an instinct for what makes stories work.
@@ -64,24 +129,28 @@ Your editing expertise includes:
 - Ensuring commercial viability while preserving artistic vision`
 		
 		promptPath := filepath.Join(f.promptsDir, "editor.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "architect":
 		promptPath := filepath.Join(f.promptsDir, "architect.txt")
-		return New(f.client, promptPath)
+		return New(f.clientFor(phase), promptPath).WithRole(phase)
 
 	case "critic":
 		promptPath := filepath.Join(f.promptsDir, "critic.txt")
-		return New(f.client, promptPath)
+		return New(f.clientFor(phase), promptPath).WithRole(phase)
 
 	default:
 		// Default to orchestrator
-		return f.CreateFictionAgent("orchestrator")
+		return f.createFictionAgent("orchestrator")
 	}
 }
 
 // CreateCodeAgent creates an agent configured for code generation
 func (f *AgentFactory) CreateCodeAgent(phase string) *Agent {
+	return f.configure(f.createCodeAgent(phase))
+}
+
+func (f *AgentFactory) createCodeAgent(phase string) *Agent {
 	// Use enhanced prompts with system prompts
 	switch phase {
 	case "planner", "code_planner":
@@ -99,7 +168,7 @@ Your expertise includes:
 You approach every project with a focus on long-term maintainability, security, and team collaboration.`
 		
 		promptPath := filepath.Join(f.promptsDir, "code_planner.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "analyzer", "code_analyzer":
 		systemPrompt := `You are Dr. Lisa Park, a code analysis expert with deep experience in architecture review and codebase assessment. You've analyzed hundreds of systems, from startups to enterprise platforms.
@@ -114,7 +183,7 @@ Your analysis expertise includes:
 - Providing actionable improvement recommendations`
 		
 		promptPath := filepath.Join(f.promptsDir, "code_analyzer.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "implementer", "code_implementer":
 		systemPrompt := `You are Alex Rivera, a full-stack developer with expertise in building production-ready applications. You're known for writing clean, efficient code that other developers love to work with.
@@ -129,15 +198,15 @@ Your implementation expertise includes:
 - Test-first development approach`
 		
 		promptPath := filepath.Join(f.promptsDir, "code_implementer.txt")
-		return NewWithSystem(f.client, promptPath, systemPrompt)
+		return NewWithSystem(f.clientFor(phase), promptPath, systemPrompt).WithRole(phase)
 
 	case "reviewer", "code_reviewer":
 		promptPath := filepath.Join(f.promptsDir, "code_reviewer.txt")
-		return New(f.client, promptPath)
+		return New(f.clientFor(phase), promptPath).WithRole(phase)
 
 	default:
 		// Default to planner
-		return f.CreateCodeAgent("planner")
+		return f.createCodeAgent("planner")
 	}
 }
 
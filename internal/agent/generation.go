@@ -0,0 +1,79 @@
+package agent
+
+import "context"
+
+// GenerationOptions are the sampling parameters sent with a request. Unset fields fall
+// back to the next level: per-call options (set on the context) override the agent's,
+// which override the client's, which override the provider's defaults.
+type GenerationOptions struct {
+	Temperature *float64 // nil leaves the provider default; 0 is deterministic
+	MaxTokens   int      // Output limit per request; truncated text is continued automatically
+	TopP        *float64
+	Stop        []string
+}
+
+// Float64 returns a pointer to v, for the optional fields of GenerationOptions
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Merge returns o with every field set in override replacing its own
+func (o GenerationOptions) Merge(override GenerationOptions) GenerationOptions {
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.MaxTokens > 0 {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if len(override.Stop) > 0 {
+		o.Stop = override.Stop
+	}
+	return o
+}
+
+// applyTo copies the options onto a provider request
+func (o GenerationOptions) applyTo(req *Request) {
+	if o.MaxTokens > 0 {
+		req.MaxTokens = o.MaxTokens
+	}
+	req.Temperature = o.Temperature
+	req.TopP = o.TopP
+	req.Stop = o.Stop
+}
+
+type generationKey struct{}
+
+// ContextWithGenerationOptions sets options for the requests made with ctx, such as a
+// single Agent.Execute call. They override anything already set on ctx.
+func ContextWithGenerationOptions(ctx context.Context, opts GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationKey{}, GenerationOptionsFromContext(ctx).Merge(opts))
+}
+
+// GenerationOptionsFromContext returns the options set by ContextWithGenerationOptions
+func GenerationOptionsFromContext(ctx context.Context) GenerationOptions {
+	opts, _ := ctx.Value(generationKey{}).(GenerationOptions)
+	return opts
+}
+
+// contextWithDefaultGeneration fills in options not already set on ctx
+func contextWithDefaultGeneration(ctx context.Context, defaults GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationKey{}, defaults.Merge(GenerationOptionsFromContext(ctx)))
+}
+
+// WithGenerationOptions sets the client's default sampling parameters
+func WithGenerationOptions(opts GenerationOptions) Option {
+	return func(c *Client) {
+		c.generation = opts
+	}
+}
+
+// WithMaxContinuations limits how many follow-up requests are made to finish a text
+// response that hit the output token limit. Zero disables continuation.
+func WithMaxContinuations(n int) Option {
+	return func(c *Client) {
+		c.maxContinuations = n
+	}
+}
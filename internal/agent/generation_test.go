@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerationOptionsPrecedence(t *testing.T) {
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		bodies = append(bodies, body)
+		io.WriteString(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(0), WithRateLimit(6000, 10),
+		WithGenerationOptions(GenerationOptions{Temperature: Float64(0.7), MaxTokens: 8000}))
+	factory := NewAgentFactory(client, t.TempDir()).WithRoleOptions(map[string]GenerationOptions{
+		"critic": {Temperature: Float64(0)},
+		"editor": {Temperature: Float64(0.2)},
+	})
+
+	ctx := context.Background()
+	factory.CreateFictionAgent("writer").Execute(ctx, "prompt", nil)
+	factory.CreateFictionAgent("critic").Execute(ctx, "prompt", nil)
+	factory.CreateFictionAgent("critic").Execute(ContextWithGenerationOptions(ctx, GenerationOptions{MaxTokens: 100, Stop: []string{"THE END"}}), "prompt", nil)
+	factory.CreateFictionAgent("contextual_editor").Execute(ctx, "prompt", nil)
+
+	want := []string{
+		"temperature=0.7 max_tokens=8000 stop=<nil>",
+		"temperature=0 max_tokens=8000 stop=<nil>",
+		"temperature=0 max_tokens=100 stop=[THE END]",
+		"temperature=0.2 max_tokens=8000 stop=<nil>",
+	}
+	if len(bodies) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(bodies), len(want))
+	}
+	for i, body := range bodies {
+		got := fmt.Sprintf("temperature=%v max_tokens=%v stop=%v", body["temperature"], body["max_tokens"], body["stop_sequences"])
+		if got != want[i] {
+			t.Errorf("request %d: %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestClientContinuesTruncatedResponses(t *testing.T) {
+	var partials []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		if last := body.Messages[len(body.Messages)-1]; last.Role == "assistant" {
+			partials = append(partials, last.Content)
+			io.WriteString(w, `{"content":[{"type":"text","text":" and the end."}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+			return
+		}
+		io.WriteString(w, `{"content":[{"type":"text","text":"The beginning \n"}],"stop_reason":"max_tokens","usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(0), WithRateLimit(6000, 10))
+	got, err := client.Complete(context.Background(), "write")
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if got != "The beginning and the end." {
+		t.Errorf("Complete() = %q, want the truncated text joined with its continuation", got)
+	}
+	if len(partials) != 1 || partials[0] != "The beginning" {
+		t.Errorf("continuation prefill = %q, want the first response without trailing whitespace", partials)
+	}
+
+	t.Run("JSON is not continued", func(t *testing.T) {
+		partials = nil
+		if _, err := client.CompleteJSON(context.Background(), "write"); err != nil {
+			t.Fatalf("CompleteJSON() error = %v", err)
+		}
+		if len(partials) != 0 {
+			t.Error("a truncated JSON response should not be continued")
+		}
+	})
+}
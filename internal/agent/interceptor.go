@@ -0,0 +1,335 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AIClient methods as they appear in Call.Method
+const (
+	MethodComplete               = "Complete"
+	MethodCompleteJSON           = "CompleteJSON"
+	MethodCompleteWithSystem     = "CompleteWithSystem"
+	MethodCompleteJSONWithSystem = "CompleteJSONWithSystem"
+	MethodCompleteStream         = "CompleteStream"
+	MethodCompleteStructured     = "CompleteStructured"
+	MethodCompleteConversation   = "CompleteConversation"
+	MethodCompleteWithTools      = "CompleteWithTools"
+)
+
+// Call is one request passing through an interceptor chain. Interceptors may rewrite
+// it before calling the next handler; the innermost handler sends whatever it receives.
+type Call struct {
+	Method string
+	// Model is the model the wrapped client targets, when it reports one
+	Model  string
+	System string
+	// Prompt is the user prompt, or the flattened transcript for conversations
+	Prompt string
+	JSON   bool
+	Schema *Schema
+	// Conversation is set for CompleteConversation and CompleteWithTools
+	Conversation *Conversation
+	Tools        []Tool
+	OnChunk      StreamCallback
+	// Generation holds the sampling parameters the request will be sent with
+	Generation GenerationOptions
+}
+
+// ModelReporter is implemented by clients that can say which model and default
+// sampling parameters their requests use, so interceptors can tell them apart
+type ModelReporter interface {
+	Model() string
+	GenerationDefaults() GenerationOptions
+}
+
+// Handler sends a call and returns the response text
+type Handler func(ctx context.Context, call *Call) (string, error)
+
+// Interceptor wraps a handler with a cross-cutting concern such as caching or metrics
+type Interceptor func(next Handler) Handler
+
+// Chain composes interceptors into one, the first being the outermost
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(next Handler) Handler {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = interceptors[i](next)
+		}
+		return next
+	}
+}
+
+// InterceptedClient runs every request through an interceptor chain before passing
+// it to the wrapped client
+type InterceptedClient struct {
+	inner        AIClient
+	interceptors []Interceptor
+	handler      Handler
+}
+
+// NewInterceptedClient wraps inner with interceptors, the first being the outermost
+func NewInterceptedClient(inner AIClient, interceptors ...Interceptor) *InterceptedClient {
+	return &InterceptedClient{
+		inner:        inner,
+		interceptors: interceptors,
+		handler:      Chain(interceptors...)(dispatch(inner)),
+	}
+}
+
+// ClientFor keeps per-role model routing working behind the chain. The role's client
+// shares the interceptors, and so their state, with the parent.
+func (c *InterceptedClient) ClientFor(role string) AIClient {
+	resolver, ok := c.inner.(RoleClientResolver)
+	if !ok {
+		return c
+	}
+	return NewInterceptedClient(resolver.ClientFor(role), c.interceptors...)
+}
+
+func (c *InterceptedClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return c.send(ctx, &Call{Method: MethodComplete, Prompt: prompt})
+}
+
+func (c *InterceptedClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteJSON, Prompt: prompt, JSON: true})
+}
+
+func (c *InterceptedClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteWithSystem, System: systemPrompt, Prompt: userPrompt})
+}
+
+func (c *InterceptedClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteJSONWithSystem, System: systemPrompt, Prompt: userPrompt, JSON: true})
+}
+
+func (c *InterceptedClient) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteStream, System: systemPrompt, Prompt: userPrompt, OnChunk: onChunk})
+}
+
+func (c *InterceptedClient) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteStructured, System: systemPrompt, Prompt: userPrompt, JSON: true, Schema: schema})
+}
+
+func (c *InterceptedClient) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteConversation, System: conv.System, Prompt: conv.transcript(), JSON: conv.JSON, Conversation: conv})
+}
+
+func (c *InterceptedClient) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	return c.send(ctx, &Call{Method: MethodCompleteWithTools, System: conv.System, Prompt: conv.transcript(), JSON: conv.JSON, Conversation: conv, Tools: tools})
+}
+
+// Model passes through the wrapped client's model so chains can be nested
+func (c *InterceptedClient) Model() string {
+	if reporter, ok := c.inner.(ModelReporter); ok {
+		return reporter.Model()
+	}
+	return ""
+}
+
+func (c *InterceptedClient) GenerationDefaults() GenerationOptions {
+	if reporter, ok := c.inner.(ModelReporter); ok {
+		return reporter.GenerationDefaults()
+	}
+	return GenerationOptions{}
+}
+
+// send fills in the model and sampling parameters and runs call through the chain
+func (c *InterceptedClient) send(ctx context.Context, call *Call) (string, error) {
+	call.Model = c.Model()
+	call.Generation = c.GenerationDefaults().Merge(GenerationOptionsFromContext(ctx))
+	return c.handler(ctx, call)
+}
+
+// SubmitBatch bypasses the chain; batch results arrive long after the call returns
+func (c *InterceptedClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	client, ok := c.inner.(BatchClient)
+	if !ok {
+		return "", ErrBatchUnsupported
+	}
+	return client.SubmitBatch(ctx, requests)
+}
+
+func (c *InterceptedClient) WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error) {
+	client, ok := c.inner.(BatchClient)
+	if !ok {
+		return nil, ErrBatchUnsupported
+	}
+	return client.WaitBatch(ctx, batchID)
+}
+
+// dispatch is the innermost handler, which sends the call to the wrapped client
+func dispatch(inner AIClient) Handler {
+	return func(ctx context.Context, call *Call) (string, error) {
+		switch call.Method {
+		case MethodComplete:
+			return inner.Complete(ctx, call.Prompt)
+		case MethodCompleteJSON:
+			return inner.CompleteJSON(ctx, call.Prompt)
+		case MethodCompleteWithSystem:
+			return inner.CompleteWithSystem(ctx, call.System, call.Prompt)
+		case MethodCompleteJSONWithSystem:
+			return inner.CompleteJSONWithSystem(ctx, call.System, call.Prompt)
+		case MethodCompleteStream:
+			return inner.CompleteStream(ctx, call.System, call.Prompt, call.OnChunk)
+		case MethodCompleteStructured:
+			if structured, ok := inner.(StructuredClient); ok {
+				return structured.CompleteStructured(ctx, call.System, call.Prompt, call.Schema)
+			}
+			return inner.CompleteJSONWithSystem(ctx, call.System, call.Prompt)
+		case MethodCompleteConversation:
+			return inner.CompleteConversation(ctx, call.Conversation)
+		case MethodCompleteWithTools:
+			client, ok := inner.(ToolClient)
+			if !ok {
+				return "", ErrToolsUnsupported
+			}
+			return client.CompleteWithTools(ctx, call.Conversation, call.Tools)
+		}
+		return "", fmt.Errorf("unknown AI client method %q", call.Method)
+	}
+}
+
+// InterceptorOptions holds the settings for one interceptor, as read from YAML
+type InterceptorOptions map[string]interface{}
+
+// String returns the option as a string, or def when it is unset
+func (o InterceptorOptions) String(key, def string) string {
+	if v, ok := o[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return def
+}
+
+// Int returns the option as an integer, or def when it is unset
+func (o InterceptorOptions) Int(key string, def int) (int, error) {
+	switch v := o[key].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("option %s: want a number, got %v", key, o[key])
+}
+
+// Bool returns the option as a boolean, or def when it is unset
+func (o InterceptorOptions) Bool(key string, def bool) (bool, error) {
+	switch v := o[key].(type) {
+	case nil:
+		return def, nil
+	case bool:
+		return v, nil
+	}
+	return false, fmt.Errorf("option %s: want true or false, got %v", key, o[key])
+}
+
+// Duration returns the option parsed as a duration ("24h"), or def when it is unset
+func (o InterceptorOptions) Duration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := o[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	d, err := time.ParseDuration(fmt.Sprint(v))
+	if err != nil {
+		return 0, fmt.Errorf("option %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// Strings returns the option as a list of strings
+func (o InterceptorOptions) Strings(key string) ([]string, error) {
+	switch v := o[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = fmt.Sprint(item)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("option %s: want a list, got %v", key, o[key])
+}
+
+// InterceptorEnv supplies the dependencies built-in interceptors need from the host
+type InterceptorEnv struct {
+	// Cache backs the cache interceptor
+	Cache *ResponseCache
+	// DumpDir is where the dump interceptor writes when no dir option is given
+	DumpDir string
+	// CheckBudget reports an error once the running session has used its budget
+	CheckBudget func(ctx context.Context) error
+	Logger      *slog.Logger
+}
+
+// InterceptorFactory builds a configured interceptor
+type InterceptorFactory func(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error)
+
+// InterceptorSpec names an interceptor and its options
+type InterceptorSpec struct {
+	Name    string
+	Options InterceptorOptions
+}
+
+var (
+	interceptorsMu sync.RWMutex
+	interceptors   = make(map[string]InterceptorFactory)
+)
+
+func init() {
+	RegisterInterceptor("cache", newCacheInterceptor)
+	RegisterInterceptor("redact", newRedactInterceptor)
+	RegisterInterceptor("metrics", newMetricsInterceptor)
+	RegisterInterceptor("ratelimit", newRateLimitInterceptor)
+	RegisterInterceptor("budget", newBudgetInterceptor)
+	RegisterInterceptor("dump", newDumpInterceptor)
+}
+
+// RegisterInterceptor makes an interceptor available to BuildInterceptors by name,
+// replacing any registered under the same name
+func RegisterInterceptor(name string, factory InterceptorFactory) {
+	interceptorsMu.Lock()
+	defer interceptorsMu.Unlock()
+	interceptors[name] = factory
+}
+
+// BuildInterceptors creates the interceptors named by specs, in order
+func BuildInterceptors(specs []InterceptorSpec, env InterceptorEnv) ([]Interceptor, error) {
+	if env.Logger == nil {
+		env.Logger = slog.Default()
+	}
+
+	interceptorsMu.RLock()
+	defer interceptorsMu.RUnlock()
+
+	built := make([]Interceptor, 0, len(specs))
+	for _, spec := range specs {
+		factory, ok := interceptors[spec.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown interceptor %q (available: %s)", spec.Name, strings.Join(listInterceptorsLocked(), ", "))
+		}
+		interceptor, err := factory(spec.Options, env)
+		if err != nil {
+			return nil, fmt.Errorf("interceptor %s: %w", spec.Name, err)
+		}
+		built = append(built, interceptor)
+	}
+	return built, nil
+}
+
+func listInterceptorsLocked() []string {
+	names := make([]string, 0, len(interceptors))
+	for name := range interceptors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
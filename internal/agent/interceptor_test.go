@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+func TestChainRunsInterceptorsOutermostFirst(t *testing.T) {
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, call *Call) (string, error) {
+				order = append(order, name)
+				return next(ctx, call)
+			}
+		}
+	}
+
+	handler := Chain(trace("outer"), trace("inner"))(func(ctx context.Context, call *Call) (string, error) {
+		order = append(order, "send")
+		return "ok", nil
+	})
+	if _, err := handler(context.Background(), &Call{Method: MethodComplete}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,send" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestInterceptedClientBuiltins(t *testing.T) {
+	requests := 0
+	var lastBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		lastBody = string(body)
+		io.WriteString(w, `{"content":[{"type":"text","text":"noted"}],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	p, _ := GetProvider("anthropic")
+	inner := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithProvider(p), WithRetry(0), WithRateLimit(6000, 10))
+
+	interceptors, err := BuildInterceptors([]InterceptorSpec{
+		{Name: "cache"},
+		{Name: "budget", Options: InterceptorOptions{"max_calls": 2}},
+		{Name: "redact"},
+	}, InterceptorEnv{Cache: NewResponseCache(storage.NewFileSystem(t.TempDir()), time.Hour)})
+	if err != nil {
+		t.Fatalf("BuildInterceptors() error = %v", err)
+	}
+	client := NewInterceptedClient(inner, interceptors...)
+	ctx := context.Background()
+
+	if _, err := client.CompleteWithSystem(ctx, "system", "Email jane@example.com the draft"); err != nil {
+		t.Fatalf("CompleteWithSystem() error = %v", err)
+	}
+	if strings.Contains(lastBody, "jane@example.com") || !strings.Contains(lastBody, "[REDACTED]") {
+		t.Errorf("request wasn't redacted: %s", lastBody)
+	}
+
+	if got, err := client.CompleteWithSystem(ctx, "system", "Email jane@example.com the draft"); err != nil || got != "noted" {
+		t.Fatalf("cached CompleteWithSystem() = %q, %v", got, err)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want the repeat served from cache", requests)
+	}
+
+	// Cache hits don't count against the budget; the JSON form of the prompt is a
+	// separate cache entry and uses the last call
+	if _, err := client.CompleteJSONWithSystem(ctx, "system", "Email jane@example.com the draft"); err != nil {
+		t.Fatalf("CompleteJSONWithSystem() error = %v", err)
+	}
+	if _, err := client.Complete(ctx, "one more"); !errors.Is(err, ErrCallLimitReached) {
+		t.Errorf("Complete() error = %v, want ErrCallLimitReached", err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+}
+
+func TestBuildInterceptorsUnknownName(t *testing.T) {
+	if _, err := BuildInterceptors([]InterceptorSpec{{Name: "compress"}}, InterceptorEnv{}); err == nil || !strings.Contains(err.Error(), "cache") {
+		t.Errorf("BuildInterceptors() error = %v, want unknown interceptor listing the available ones", err)
+	}
+}
@@ -0,0 +1,358 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrCallLimitReached is returned by the budget interceptor once its call limit is used
+var ErrCallLimitReached = errors.New("AI call limit reached")
+
+// CacheInterceptor serves repeated requests from cache. With phases set, only agents
+// in those roles use it. A hit on a streaming request is delivered as one chunk.
+// Tool-use requests always go through, since their tools may have side effects.
+func CacheInterceptor(cache *ResponseCache, phases []string, logger *slog.Logger) Interceptor {
+	var enabled map[string]bool
+	if len(phases) > 0 {
+		enabled = make(map[string]bool, len(phases))
+		for _, phase := range phases {
+			enabled[phase] = true
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			if call.Method == MethodCompleteWithTools || (enabled != nil && !enabled[RoleFromContext(ctx)]) {
+				return next(ctx, call)
+			}
+
+			key := callCacheKey(call)
+			if response, found := cache.Get(ctx, key); found {
+				if call.OnChunk != nil {
+					if err := call.OnChunk(response); err != nil {
+						return response, err
+					}
+				}
+				return response, nil
+			}
+
+			response, err := next(ctx, call)
+			if err != nil {
+				return response, err
+			}
+			if err := cache.Set(ctx, key, response); err != nil {
+				logger.Warn("failed to cache response", "method", call.Method, "error", err)
+			}
+			return response, nil
+		}
+	}
+}
+
+// callCacheKey separates text, JSON and schema-constrained answers to the same prompt
+func callCacheKey(call *Call) CacheKey {
+	mode := "text"
+	if call.Schema != nil {
+		schema, _ := json.Marshal(call.Schema)
+		mode = string(schema)
+	} else if call.JSON {
+		mode = "json"
+	}
+	return CacheKey{
+		Model:      call.Model,
+		System:     call.System,
+		Prompt:     call.Prompt,
+		Mode:       mode,
+		Generation: call.Generation,
+	}
+}
+
+func newCacheInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	if env.Cache == nil {
+		return nil, errors.New("no response cache configured")
+	}
+	phases, err := opts.Strings("phases")
+	if err != nil {
+		return nil, err
+	}
+	return CacheInterceptor(env.Cache, phases, env.Logger), nil
+}
+
+// defaultRedactPatterns match secrets and personal data that shouldn't leave the machine
+var defaultRedactPatterns = []string{
+	`sk-[A-Za-z0-9_-]{20,}`,                          // OpenAI and Anthropic API keys
+	`AKIA[0-9A-Z]{16}`,                               // AWS access key IDs
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, // email addresses
+}
+
+// RedactInterceptor replaces every match of patterns in outgoing prompts, including
+// earlier conversation messages and tool results, with replacement
+func RedactInterceptor(patterns []*regexp.Regexp, replacement string) Interceptor {
+	redact := func(s string) string {
+		for _, re := range patterns {
+			s = re.ReplaceAllString(s, replacement)
+		}
+		return s
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			redacted := *call
+			redacted.System = redact(call.System)
+			redacted.Prompt = redact(call.Prompt)
+			if call.Conversation != nil {
+				conv := *call.Conversation
+				conv.System = redacted.System
+				conv.Messages = make([]Message, len(call.Conversation.Messages))
+				for i, msg := range call.Conversation.Messages {
+					msg.Content = redact(msg.Content)
+					if len(msg.ToolResults) > 0 {
+						results := make([]ToolResult, len(msg.ToolResults))
+						for j, result := range msg.ToolResults {
+							result.Content = redact(result.Content)
+							results[j] = result
+						}
+						msg.ToolResults = results
+					}
+					conv.Messages[i] = msg
+				}
+				redacted.Conversation = &conv
+			}
+			return next(ctx, &redacted)
+		}
+	}
+}
+
+func newRedactInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	sources, err := opts.Strings("patterns")
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		sources = defaultRedactPatterns
+	}
+	patterns := make([]*regexp.Regexp, len(sources))
+	for i, source := range sources {
+		if patterns[i], err = regexp.Compile(source); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", source, err)
+		}
+	}
+	return RedactInterceptor(patterns, opts.String("replacement", "[REDACTED]")), nil
+}
+
+// MethodMetrics are the running totals for one AIClient method
+type MethodMetrics struct {
+	Calls         int
+	Errors        int
+	TotalDuration time.Duration
+	PromptChars   int
+	ResponseChars int
+}
+
+// Metrics collects request counts and latencies from a metrics interceptor
+type Metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*MethodMetrics)}
+}
+
+// Snapshot returns a copy of the totals keyed by method
+func (m *Metrics) Snapshot() map[string]MethodMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]MethodMetrics, len(m.methods))
+	for method, totals := range m.methods {
+		out[method] = *totals
+	}
+	return out
+}
+
+func (m *Metrics) observe(call *Call, response string, err error, duration time.Duration) MethodMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totals, ok := m.methods[call.Method]
+	if !ok {
+		totals = &MethodMetrics{}
+		m.methods[call.Method] = totals
+	}
+	totals.Calls++
+	if err != nil {
+		totals.Errors++
+	}
+	totals.TotalDuration += duration
+	totals.PromptChars += len(call.System) + len(call.Prompt)
+	totals.ResponseChars += len(response)
+	return *totals
+}
+
+// MetricsInterceptor records every call in metrics and logs it with the running totals
+func MetricsInterceptor(metrics *Metrics, logger *slog.Logger) Interceptor {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			start := time.Now()
+			response, err := next(ctx, call)
+			duration := time.Since(start)
+			totals := metrics.observe(call, response, err, duration)
+
+			logger.Info("AI call",
+				"method", call.Method,
+				"role", RoleFromContext(ctx),
+				"duration_ms", duration.Milliseconds(),
+				"prompt_length", len(call.System)+len(call.Prompt),
+				"response_length", len(response),
+				"failed", err != nil,
+				"method_calls", totals.Calls,
+				"method_errors", totals.Errors)
+			return response, err
+		}
+	}
+}
+
+func newMetricsInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	return MetricsInterceptor(NewMetrics(), env.Logger.With("component", "ai_metrics")), nil
+}
+
+// RateLimitInterceptor makes each call wait for limiter. A tool-use call counts once,
+// however many rounds it takes.
+func RateLimitInterceptor(limiter *AdaptiveLimiter) Interceptor {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			if err := limiter.Wait(ctx); err != nil {
+				return "", fmt.Errorf("rate limiter: %w", err)
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+func newRateLimitInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	rpm, err := opts.Int("requests_per_minute", 60)
+	if err != nil {
+		return nil, err
+	}
+	burst, err := opts.Int("burst", 1)
+	if err != nil {
+		return nil, err
+	}
+	if rpm < 1 || burst < 1 {
+		return nil, errors.New("requests_per_minute and burst must be at least 1")
+	}
+	return RateLimitInterceptor(NewAdaptiveLimiter(rpm, burst)), nil
+}
+
+// BudgetInterceptor refuses calls once check reports an error, such as the session
+// ledger being over budget, or once maxCalls calls have been made. Either may be
+// left unset.
+func BudgetInterceptor(check func(ctx context.Context) error, maxCalls int) Interceptor {
+	var mu sync.Mutex
+	calls := 0
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			if check != nil {
+				if err := check(ctx); err != nil {
+					return "", err
+				}
+			}
+			if maxCalls > 0 {
+				mu.Lock()
+				if calls >= maxCalls {
+					mu.Unlock()
+					return "", fmt.Errorf("%w: %d of %d calls made", ErrCallLimitReached, calls, maxCalls)
+				}
+				calls++
+				mu.Unlock()
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+func newBudgetInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	maxCalls, err := opts.Int("max_calls", 0)
+	if err != nil {
+		return nil, err
+	}
+	return BudgetInterceptor(env.CheckBudget, maxCalls), nil
+}
+
+// dumpRecord is the file the dump interceptor writes for each call
+type dumpRecord struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Role       string    `json:"role,omitempty"`
+	System     string    `json:"system,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// DumpInterceptor writes every request and its response to a JSON file in dir, for
+// debugging prompts. Write failures are logged and don't fail the call.
+func DumpInterceptor(dir string, logger *slog.Logger) Interceptor {
+	var mu sync.Mutex
+	seq := 0
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (string, error) {
+			start := time.Now()
+			response, err := next(ctx, call)
+
+			record := dumpRecord{
+				Time:       start,
+				Method:     call.Method,
+				Role:       RoleFromContext(ctx),
+				System:     call.System,
+				Prompt:     call.Prompt,
+				Response:   response,
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+
+			mu.Lock()
+			seq++
+			name := fmt.Sprintf("%s_%04d_%s.json", start.Format("20060102T150405"), seq, call.Method)
+			mu.Unlock()
+
+			if dumpErr := writeDump(filepath.Join(dir, name), record); dumpErr != nil {
+				logger.Warn("failed to dump AI call", "method", call.Method, "error", dumpErr)
+			}
+			return response, err
+		}
+	}
+}
+
+func writeDump(path string, record dumpRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func newDumpInterceptor(opts InterceptorOptions, env InterceptorEnv) (Interceptor, error) {
+	dir := opts.String("dir", env.DumpDir)
+	if dir == "" {
+		return nil, errors.New("no dump directory configured")
+	}
+	return DumpInterceptor(dir, env.Logger), nil
+}
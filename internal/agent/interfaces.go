@@ -8,4 +8,28 @@ type AIClient interface {
 	// Enhanced methods with system prompt support
 	CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error)
 	CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error)
-}
\ No newline at end of file
+	// CompleteStream delivers text incrementally through onChunk and returns the full response
+	CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error)
+	// CompleteConversation sends a multi-turn conversation and returns the reply to its last message
+	CompleteConversation(ctx context.Context, conv *Conversation) (string, error)
+}
+// StructuredClient is implemented by clients that can ask the provider to constrain
+// output to a JSON schema (tool calling or a native structured output mode).
+// Agent.ExecuteInto falls back to CompleteJSONWithSystem for clients without it.
+type StructuredClient interface {
+	CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error)
+}
+
+// ToolClient is implemented by clients that can let the model call tools while it
+// answers. CompleteWithTools fails with ErrToolsUnsupported when the provider can't.
+type ToolClient interface {
+	CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error)
+}
+
+// BatchClient is implemented by clients that can send many requests through the
+// provider's asynchronous batch API, which is slower but cheaper than individual calls.
+// SubmitBatch fails with ErrBatchUnsupported when the provider has no batch API.
+type BatchClient interface {
+	SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error)
+	WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error)
+}
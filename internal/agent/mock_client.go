@@ -140,4 +140,27 @@ func (m *MockClient) CompleteWithSystem(ctx context.Context, systemPrompt, userP
 func (m *MockClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	// For mock, we just use the user prompt
 	return m.CompleteJSON(ctx, userPrompt)
-}
\ No newline at end of file
+}
+
+// CompleteStream delivers the mock response as a single chunk
+func (m *MockClient) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	response, err := m.Complete(ctx, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	if onChunk != nil {
+		if err := onChunk(response); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+// CompleteConversation answers the last user message as Complete would
+func (m *MockClient) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	prompt, err := conv.lastUserMessage()
+	if err != nil {
+		return "", err
+	}
+	return m.Complete(ctx, prompt)
+}
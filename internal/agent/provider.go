@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Request is a provider-neutral completion request
+type Request struct {
+	Model        string
+	SystemPrompt string
+	UserPrompt   string
+	Messages     []Message // Conversation to send instead of UserPrompt
+	Tools        []Tool    // Tools the model may call; needs a ToolProvider
+	MaxTokens    int
+	Temperature  *float64
+	TopP         *float64
+	Stop         []string
+	JSON         bool    // Ask the model for a single JSON object
+	Schema       *Schema // Constrain the JSON to this schema where the provider supports it
+	Stream       bool
+	Partial      string // Text already generated for this prompt; the model continues from it
+}
+
+// requestMessages returns the conversation to send for req: its Messages, or a single
+// user message holding UserPrompt
+func requestMessages(req Request) []Message {
+	if len(req.Messages) == 0 {
+		return []Message{{Role: RoleUser, Content: req.UserPrompt}}
+	}
+	return req.Messages
+}
+
+// continuePrompt asks providers without assistant prefill to pick up a truncated answer
+const continuePrompt = "Continue exactly where your previous message stopped. Do not repeat or summarize anything already written."
+
+// normalizeStopReason maps the OpenAI and Ollama "length" finish reason onto StopMaxTokens
+func normalizeStopReason(reason string) string {
+	if reason == "length" {
+		return StopMaxTokens
+	}
+	return reason
+}
+
+// Endpoint holds the connection details a provider needs to build HTTP requests
+type Endpoint struct {
+	BaseURL string
+	APIKey  string
+}
+
+// Provider maps completion requests and responses onto a specific LLM HTTP API
+type Provider interface {
+	// Name is the identifier used in the `provider:` config setting
+	Name() string
+
+	// BuildRequest creates the HTTP request for req, including auth headers
+	BuildRequest(ctx context.Context, endpoint Endpoint, req Request) (*http.Request, error)
+
+	// ParseResponse extracts the text and token usage from a successful non-streaming response
+	ParseResponse(body []byte) (string, Usage, error)
+
+	// ReadStream consumes a streaming response, passing text to emit as it arrives.
+	// It returns the usage reported by the provider, if any.
+	ReadStream(body io.Reader, emit StreamCallback) (Usage, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+func init() {
+	RegisterProvider(anthropicProvider{})
+	RegisterProvider(openAIProvider{name: "openai", authHeader: "Authorization"})
+	RegisterProvider(openAIProvider{name: "azure", authHeader: "api-key"})
+	RegisterProvider(ollamaProvider{})
+}
+
+// RegisterProvider adds or replaces a provider in the registry
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider returns the registered provider with the given name
+func GetProvider(name string) (Provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(listProvidersLocked(), ", "))
+	}
+	return p, nil
+}
+
+// ListProviders returns the names of all registered providers
+func ListProviders() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return listProvidersLocked()
+}
+
+func listProvidersLocked() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectProvider guesses the provider from the base URL for configs written before
+// `provider:` existed. New configs should name the provider explicitly.
+func detectProvider(baseURL string) Provider {
+	name := "anthropic"
+	if strings.Contains(baseURL, "openai") {
+		name = "openai"
+	}
+	p, _ := GetProvider(name)
+	return p
+}
+
+const jsonInstruction = "IMPORTANT: You MUST respond with valid JSON only. Your entire response must be a single JSON object with no additional text, markdown, or explanations."
+
+// effectiveSystemPrompt appends the JSON-only instruction when req asks for JSON
+func effectiveSystemPrompt(req Request) string {
+	if !req.JSON {
+		return req.SystemPrompt
+	}
+	if req.SystemPrompt == "" {
+		return jsonInstruction
+	}
+	return req.SystemPrompt + "\n\n" + jsonInstruction
+}
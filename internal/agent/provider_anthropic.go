@@ -0,0 +1,345 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// anthropicProvider speaks the Anthropic Messages API
+type anthropicProvider struct{}
+
+func (anthropicProvider) Name() string { return "anthropic" }
+
+func (p anthropicProvider) BuildRequest(ctx context.Context, endpoint Endpoint, req Request) (*http.Request, error) {
+	payload, err := json.Marshal(p.messagesBody(req))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := p.newRequest(ctx, endpoint, "POST", strings.TrimSuffix(endpoint.BaseURL, "/")+"/messages", payload)
+	if err != nil {
+		return nil, err
+	}
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
+
+// messagesBody is the Messages API request body for req, also used as the params of
+// a batch request
+func (anthropicProvider) messagesBody(req Request) map[string]interface{} {
+	messages := anthropicMessages(requestMessages(req))
+	if req.Partial != "" {
+		// Prefilling the assistant turn makes the model continue the text; the API
+		// rejects a prefill ending in whitespace
+		messages = append(messages, map[string]interface{}{"role": "assistant", "content": strings.TrimRight(req.Partial, " \t\r\n")})
+	}
+
+	body := map[string]interface{}{
+		"model":      req.Model,
+		"messages":   messages,
+		"max_tokens": req.MaxTokens,
+	}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		body["top_p"] = *req.TopP
+	}
+	if len(req.Stop) > 0 {
+		body["stop_sequences"] = req.Stop
+	}
+	if system := effectiveSystemPrompt(req); system != "" {
+		body["system"] = system
+	}
+	if req.Schema != nil {
+		// Anthropic has no JSON mode; forcing a single tool call yields arguments
+		// that follow the tool's input schema
+		body["tools"] = []map[string]interface{}{{
+			"name":         req.Schema.Title,
+			"description":  "Record the response in the required structure.",
+			"input_schema": req.Schema,
+		}}
+		body["tool_choice"] = map[string]string{"type": "tool", "name": req.Schema.Title}
+	} else if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, len(req.Tools))
+		for i, t := range req.Tools {
+			tools[i] = map[string]interface{}{
+				"name":         t.Name,
+				"description":  t.Description,
+				"input_schema": t.parameters(),
+			}
+		}
+		body["tools"] = tools
+	}
+	if req.Stream {
+		body["stream"] = true
+	}
+	return body
+}
+
+// anthropicMessages converts messages to the Messages API format, where tool calls
+// and their results are content blocks
+func anthropicMessages(messages []Message) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(messages))
+	for i, m := range messages {
+		if len(m.ToolCalls) == 0 && len(m.ToolResults) == 0 {
+			out[i] = map[string]interface{}{"role": m.Role, "content": m.Content}
+			continue
+		}
+
+		blocks := []map[string]interface{}{}
+		if m.Content != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": m.Content})
+		}
+		for _, call := range m.ToolCalls {
+			input := call.Arguments
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "tool_use", "id": call.ID, "name": call.Name, "input": input})
+		}
+		for _, result := range m.ToolResults {
+			blocks = append(blocks, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": result.CallID,
+				"content":     result.Content,
+				"is_error":    result.IsError,
+			})
+		}
+		out[i] = map[string]interface{}{"role": m.Role, "content": blocks}
+	}
+	return out
+}
+
+// newRequest creates an authenticated API request; payload may be nil
+func (anthropicProvider) newRequest(ctx context.Context, endpoint Endpoint, method, url string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if endpoint.APIKey != "" {
+		httpReq.Header.Set("x-api-key", endpoint.APIKey)
+	}
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	return httpReq, nil
+}
+
+func (anthropicProvider) ParseResponse(body []byte) (string, Usage, error) {
+	var response struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", Usage{}, fmt.Errorf("parsing response: %w", err)
+	}
+	if len(response.Content) == 0 {
+		return "", Usage{}, fmt.Errorf("no content in response")
+	}
+
+	usage := Usage{InputTokens: response.Usage.InputTokens, OutputTokens: response.Usage.OutputTokens, StopReason: response.StopReason}
+	for _, block := range response.Content {
+		if block.Type == "tool_use" {
+			return string(block.Input), usage, nil
+		}
+	}
+	return response.Content[0].Text, usage, nil
+}
+
+// ParseMessage returns the response's text along with any tool_use blocks
+func (anthropicProvider) ParseMessage(body []byte) (Message, Usage, error) {
+	var response struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Message{}, Usage{}, fmt.Errorf("parsing response: %w", err)
+	}
+
+	msg := Message{Role: RoleAssistant}
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			msg.Content += block.Text
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+		}
+	}
+	usage := Usage{InputTokens: response.Usage.InputTokens, OutputTokens: response.Usage.OutputTokens, StopReason: response.StopReason}
+	return msg, usage, nil
+}
+
+func (anthropicProvider) ReadStream(body io.Reader, emit StreamCallback) (Usage, error) {
+	var usage Usage
+
+	err := readSSE(body, func(event, data string) error {
+		var msg struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return fmt.Errorf("parsing stream event: %w", err)
+		}
+
+		switch msg.Type {
+		case "message_start":
+			usage.InputTokens = msg.Message.Usage.InputTokens
+		case "content_block_delta":
+			if msg.Delta.Type == "text_delta" {
+				return emit(msg.Delta.Text)
+			}
+		case "message_delta":
+			usage.OutputTokens = msg.Usage.OutputTokens
+			usage.StopReason = msg.Delta.StopReason
+		case "message_stop":
+			return errStreamDone
+		case "error":
+			return fmt.Errorf("stream error (%s): %s", msg.Error.Type, msg.Error.Message)
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// SubmitBatch creates a Message Batch with one Messages API request per entry
+func (p anthropicProvider) SubmitBatch(ctx context.Context, do DoFunc, endpoint Endpoint, requests []BatchRequest) (string, error) {
+	items := make([]map[string]interface{}, len(requests))
+	for i, r := range requests {
+		items[i] = map[string]interface{}{
+			"custom_id": r.CustomID,
+			"params":    p.messagesBody(r.Request),
+		}
+	}
+	payload, err := json.Marshal(map[string]interface{}{"requests": items})
+	if err != nil {
+		return "", fmt.Errorf("marshaling batch: %w", err)
+	}
+
+	httpReq, err := p.newRequest(ctx, endpoint, "POST", strings.TrimSuffix(endpoint.BaseURL, "/")+"/messages/batches", payload)
+	if err != nil {
+		return "", err
+	}
+	body, err := do(httpReq)
+	if err != nil {
+		return "", err
+	}
+
+	var batch struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return "", fmt.Errorf("parsing batch: %w", err)
+	}
+	if batch.ID == "" {
+		return "", fmt.Errorf("batch response has no id")
+	}
+	return batch.ID, nil
+}
+
+// PollBatch checks the batch's processing status and, once it has ended, downloads
+// the results file
+func (p anthropicProvider) PollBatch(ctx context.Context, do DoFunc, endpoint Endpoint, batchID string) (bool, []BatchResult, error) {
+	httpReq, err := p.newRequest(ctx, endpoint, "GET", strings.TrimSuffix(endpoint.BaseURL, "/")+"/messages/batches/"+batchID, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	body, err := do(httpReq)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var batch struct {
+		ProcessingStatus string `json:"processing_status"`
+		ResultsURL       string `json:"results_url"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return false, nil, fmt.Errorf("parsing batch: %w", err)
+	}
+	if batch.ProcessingStatus != "ended" {
+		return false, nil, nil
+	}
+
+	httpReq, err = p.newRequest(ctx, endpoint, "GET", batch.ResultsURL, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	data, err := do(httpReq)
+	if err != nil {
+		return false, nil, fmt.Errorf("downloading batch results: %w", err)
+	}
+
+	var results []BatchResult
+	err = eachJSONLine(data, func(line []byte) error {
+		var entry struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type    string          `json:"type"`
+				Message json.RawMessage `json:"message"`
+				Error   json.RawMessage `json:"error"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("parsing batch result: %w", err)
+		}
+
+		result := BatchResult{CustomID: entry.CustomID}
+		switch entry.Result.Type {
+		case "succeeded":
+			result.Text, result.Usage, result.Err = p.ParseResponse(entry.Result.Message)
+		case "errored":
+			result.Err = fmt.Errorf("batch request errored: %s", entry.Result.Error)
+		default:
+			result.Err = fmt.Errorf("batch request %s", entry.Result.Type)
+		}
+		results = append(results, result)
+		return nil
+	})
+	return true, results, err
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ollamaProvider speaks Ollama's native /api/chat API, which streams newline-delimited
+// JSON rather than server-sent events and needs no API key
+type ollamaProvider struct{}
+
+func (ollamaProvider) Name() string { return "ollama" }
+
+func (ollamaProvider) BuildRequest(ctx context.Context, endpoint Endpoint, req Request) (*http.Request, error) {
+	messages := []map[string]string{}
+	if system := effectiveSystemPrompt(req); system != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": system})
+	}
+	for _, m := range requestMessages(req) {
+		messages = append(messages, map[string]string{"role": m.Role, "content": m.Content})
+	}
+	if req.Partial != "" {
+		messages = append(messages,
+			map[string]string{"role": "assistant", "content": req.Partial},
+			map[string]string{"role": "user", "content": continuePrompt})
+	}
+
+	options := map[string]interface{}{"num_predict": req.MaxTokens}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		options["top_p"] = *req.TopP
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+
+	body := map[string]interface{}{
+		"model":    req.Model,
+		"messages": messages,
+		"stream":   req.Stream,
+		"options":  options,
+	}
+	if req.Schema != nil {
+		body["format"] = req.Schema
+	} else if req.JSON {
+		body["format"] = "json"
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(endpoint.BaseURL, "/"), "/api")
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if endpoint.APIKey != "" {
+		// Only needed when Ollama sits behind an authenticating proxy
+		httpReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+	}
+	return httpReq, nil
+}
+
+// ollamaMessage is both the full response and each streamed line
+type ollamaMessage struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (ollamaProvider) ParseResponse(body []byte) (string, Usage, error) {
+	var response ollamaMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", Usage{}, fmt.Errorf("parsing response: %w", err)
+	}
+	if response.Error != "" {
+		return "", Usage{}, fmt.Errorf("ollama error: %s", response.Error)
+	}
+
+	usage := Usage{InputTokens: response.PromptEvalCount, OutputTokens: response.EvalCount, StopReason: normalizeStopReason(response.DoneReason)}
+	return response.Message.Content, usage, nil
+}
+
+func (ollamaProvider) ReadStream(body io.Reader, emit StreamCallback) (Usage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg ollamaMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return Usage{}, fmt.Errorf("parsing stream line: %w", err)
+		}
+		if msg.Error != "" {
+			return Usage{}, fmt.Errorf("stream error: %s", msg.Error)
+		}
+		if err := emit(msg.Message.Content); err != nil {
+			return Usage{}, err
+		}
+		if msg.Done {
+			return Usage{InputTokens: msg.PromptEvalCount, OutputTokens: msg.EvalCount, StopReason: normalizeStopReason(msg.DoneReason)}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Usage{}, fmt.Errorf("reading stream: %w", err)
+	}
+	return Usage{}, fmt.Errorf("stream ended before completion: %w", io.ErrUnexpectedEOF)
+}
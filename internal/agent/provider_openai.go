@@ -0,0 +1,422 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// openAIProvider speaks the OpenAI chat completions API. It also covers compatible
+// servers such as OpenRouter, vLLM, llama.cpp and Ollama's /v1 endpoint; Azure differs
+// only in the auth header.
+type openAIProvider struct {
+	name       string
+	authHeader string // "Authorization" sends a bearer token, anything else sends the raw key
+}
+
+func (p openAIProvider) Name() string { return p.name }
+
+func (p openAIProvider) BuildRequest(ctx context.Context, endpoint Endpoint, req Request) (*http.Request, error) {
+	payload, err := json.Marshal(p.chatBody(req))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := p.newRequest(ctx, endpoint, "POST", "/chat/completions", bytes.NewReader(payload), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
+
+// chatBody is the chat completions request body for req, also used as the body of
+// a batch request
+func (openAIProvider) chatBody(req Request) map[string]interface{} {
+	messages := []map[string]interface{}{}
+	if system := effectiveSystemPrompt(req); system != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": system})
+	}
+	messages = append(messages, openAIMessages(requestMessages(req))...)
+	if req.Partial != "" {
+		messages = append(messages,
+			map[string]interface{}{"role": "assistant", "content": req.Partial},
+			map[string]interface{}{"role": "user", "content": continuePrompt})
+	}
+
+	body := map[string]interface{}{
+		"model":      req.Model,
+		"messages":   messages,
+		"max_tokens": req.MaxTokens,
+	}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		body["top_p"] = *req.TopP
+	}
+	if len(req.Stop) > 0 {
+		body["stop"] = req.Stop
+	}
+	if req.Schema != nil {
+		body["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   req.Schema.Title,
+				"schema": req.Schema,
+			},
+		}
+	} else if req.JSON {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, len(req.Tools))
+		for i, t := range req.Tools {
+			tools[i] = map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        t.Name,
+					"description": t.Description,
+					"parameters":  t.parameters(),
+				},
+			}
+		}
+		body["tools"] = tools
+	}
+	if req.Stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	return body
+}
+
+// openAIMessages converts messages to the chat completions format, where tool calls
+// hang off the assistant message and each result is a separate "tool" message
+func openAIMessages(messages []Message) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(messages))
+	for _, m := range messages {
+		if len(m.ToolResults) > 0 {
+			for _, result := range m.ToolResults {
+				content := result.Content
+				if result.IsError {
+					content = "Error: " + content
+				}
+				out = append(out, map[string]interface{}{"role": "tool", "tool_call_id": result.CallID, "content": content})
+			}
+			continue
+		}
+
+		msg := map[string]interface{}{"role": m.Role, "content": m.Content}
+		if len(m.ToolCalls) > 0 {
+			calls := make([]map[string]interface{}, len(m.ToolCalls))
+			for i, call := range m.ToolCalls {
+				args := string(call.Arguments)
+				if args == "" {
+					args = "{}"
+				}
+				calls[i] = map[string]interface{}{
+					"id":       call.ID,
+					"type":     "function",
+					"function": map[string]string{"name": call.Name, "arguments": args},
+				}
+			}
+			msg["tool_calls"] = calls
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+// newRequest creates an authenticated request for path under the base URL; body may be nil
+func (p openAIProvider) newRequest(ctx context.Context, endpoint Endpoint, method, path string, body io.Reader, contentType string) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(endpoint.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if endpoint.APIKey != "" {
+		if p.authHeader == "Authorization" {
+			httpReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+		} else {
+			httpReq.Header.Set(p.authHeader, endpoint.APIKey)
+		}
+	}
+	return httpReq, nil
+}
+
+func (openAIProvider) ParseResponse(body []byte) (string, Usage, error) {
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", Usage{}, fmt.Errorf("parsing response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", Usage{}, fmt.Errorf("no choices in response")
+	}
+
+	usage := Usage{
+		InputTokens:  response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+		StopReason:   normalizeStopReason(response.Choices[0].FinishReason),
+	}
+	return response.Choices[0].Message.Content, usage, nil
+}
+
+// ParseMessage returns the first choice's message, including any function calls
+func (openAIProvider) ParseMessage(body []byte) (Message, Usage, error) {
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"` // JSON encoded as a string
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Message{}, Usage{}, fmt.Errorf("parsing response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return Message{}, Usage{}, fmt.Errorf("no choices in response")
+	}
+
+	choice := response.Choices[0]
+	msg := Message{Role: RoleAssistant, Content: choice.Message.Content}
+	for _, call := range choice.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: json.RawMessage(call.Function.Arguments)})
+	}
+	usage := Usage{
+		InputTokens:  response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+		StopReason:   normalizeStopReason(choice.FinishReason),
+	}
+	return msg, usage, nil
+}
+
+func (openAIProvider) ReadStream(body io.Reader, emit StreamCallback) (Usage, error) {
+	var usage Usage
+
+	err := readSSE(body, func(event, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("parsing stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage.InputTokens = chunk.Usage.PromptTokens
+			usage.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				usage.StopReason = normalizeStopReason(choice.FinishReason)
+			}
+			if err := emit(choice.Delta.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// openAIBatchEndpoint is the API path every line of a batch input file targets
+const openAIBatchEndpoint = "/v1/chat/completions"
+
+// SubmitBatch uploads the requests as a JSON Lines file and creates a batch from it
+func (p openAIProvider) SubmitBatch(ctx context.Context, do DoFunc, endpoint Endpoint, requests []BatchRequest) (string, error) {
+	var input bytes.Buffer
+	enc := json.NewEncoder(&input)
+	for _, r := range requests {
+		line := map[string]interface{}{
+			"custom_id": r.CustomID,
+			"method":    "POST",
+			"url":       openAIBatchEndpoint,
+			"body":      p.chatBody(r.Request),
+		}
+		if err := enc.Encode(line); err != nil {
+			return "", fmt.Errorf("marshaling batch: %w", err)
+		}
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("purpose", "batch")
+	part, err := writer.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", fmt.Errorf("creating batch upload: %w", err)
+	}
+	part.Write(input.Bytes())
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("creating batch upload: %w", err)
+	}
+
+	httpReq, err := p.newRequest(ctx, endpoint, "POST", "/files", &form, writer.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	body, err := do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("uploading batch input: %w", err)
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil {
+		return "", fmt.Errorf("parsing file upload: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"input_file_id":     file.ID,
+		"endpoint":          openAIBatchEndpoint,
+		"completion_window": "24h",
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshaling batch: %w", err)
+	}
+	httpReq, err = p.newRequest(ctx, endpoint, "POST", "/batches", bytes.NewReader(payload), "application/json")
+	if err != nil {
+		return "", err
+	}
+	if body, err = do(httpReq); err != nil {
+		return "", err
+	}
+
+	var batch struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return "", fmt.Errorf("parsing batch: %w", err)
+	}
+	if batch.ID == "" {
+		return "", fmt.Errorf("batch response has no id")
+	}
+	return batch.ID, nil
+}
+
+// PollBatch checks the batch status and, once it is final, reads the output and
+// error files
+func (p openAIProvider) PollBatch(ctx context.Context, do DoFunc, endpoint Endpoint, batchID string) (bool, []BatchResult, error) {
+	httpReq, err := p.newRequest(ctx, endpoint, "GET", "/batches/"+batchID, nil, "")
+	if err != nil {
+		return false, nil, err
+	}
+	body, err := do(httpReq)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var batch struct {
+		Status       string `json:"status"`
+		OutputFileID string `json:"output_file_id"`
+		ErrorFileID  string `json:"error_file_id"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return false, nil, fmt.Errorf("parsing batch: %w", err)
+	}
+	switch batch.Status {
+	case "completed", "expired", "cancelled":
+	case "failed":
+		return true, nil, fmt.Errorf("batch %s failed validation", batchID)
+	default:
+		return false, nil, nil
+	}
+
+	var results []BatchResult
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		httpReq, err := p.newRequest(ctx, endpoint, "GET", "/files/"+fileID+"/content", nil, "")
+		if err != nil {
+			return false, nil, err
+		}
+		data, err := do(httpReq)
+		if err != nil {
+			return false, nil, fmt.Errorf("downloading batch results: %w", err)
+		}
+
+		err = eachJSONLine(data, func(line []byte) error {
+			var entry struct {
+				CustomID string `json:"custom_id"`
+				Response *struct {
+					StatusCode int             `json:"status_code"`
+					Body       json.RawMessage `json:"body"`
+				} `json:"response"`
+				Error *struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return fmt.Errorf("parsing batch result: %w", err)
+			}
+
+			result := BatchResult{CustomID: entry.CustomID}
+			switch {
+			case entry.Error != nil:
+				result.Err = fmt.Errorf("batch request failed: %s: %s", entry.Error.Code, entry.Error.Message)
+			case entry.Response == nil:
+				result.Err = fmt.Errorf("batch request has no response")
+			case entry.Response.StatusCode != http.StatusOK:
+				result.Err = &APIError{StatusCode: entry.Response.StatusCode, Body: string(entry.Response.Body)}
+			default:
+				result.Text, result.Usage, result.Err = p.ParseResponse(entry.Response.Body)
+			}
+			results = append(results, result)
+			return nil
+		})
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	return true, results, nil
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	for _, name := range []string{"anthropic", "openai", "azure", "ollama"} {
+		if _, err := GetProvider(name); err != nil {
+			t.Errorf("GetProvider(%q) error = %v", name, err)
+		}
+	}
+	if _, err := GetProvider("nope"); err == nil {
+		t.Error("GetProvider() expected error for unknown provider")
+	}
+
+	// Legacy configs without `provider:` keep the URL heuristic
+	if p := detectProvider("https://api.openai.com/v1"); p.Name() != "openai" {
+		t.Errorf("detectProvider() = %s, want openai", p.Name())
+	}
+	if p := detectProvider("https://api.anthropic.com/v1"); p.Name() != "anthropic" {
+		t.Errorf("detectProvider() = %s, want anthropic", p.Name())
+	}
+}
+
+func TestProviderRequestMapping(t *testing.T) {
+	tests := []struct {
+		provider   string
+		response   string
+		wantPath   string
+		wantHeader string
+	}{
+		{"anthropic", `{"content":[{"text":"hi"}],"usage":{"input_tokens":3,"output_tokens":1}}`, "/messages", "X-Api-Key"},
+		{"openai", `{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`, "/chat/completions", "Authorization"},
+		{"azure", `{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`, "/chat/completions", "Api-Key"},
+		{"ollama", `{"message":{"content":"hi"},"done":true,"prompt_eval_count":3,"eval_count":1}`, "/api/chat", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			var gotPath string
+			var gotBody map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				if tt.wantHeader != "" && r.Header.Get(tt.wantHeader) == "" {
+					t.Errorf("missing %s header", tt.wantHeader)
+				}
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &gotBody)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer srv.Close()
+
+			p, _ := GetProvider(tt.provider)
+			var usage Usage
+			client := NewClient("test-key-1234567890abcdef",
+				WithAPIConfig(srv.URL, "some-local-model"),
+				WithProvider(p),
+				WithUsageHook(func(ctx context.Context, u Usage) { usage = u }))
+
+			text, err := client.CompleteJSONWithSystem(context.Background(), "system", "prompt")
+			if err != nil {
+				t.Fatalf("CompleteJSONWithSystem() error = %v", err)
+			}
+			if text != "hi" {
+				t.Errorf("text = %q, want %q", text, "hi")
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("path = %q, want %q", gotPath, tt.wantPath)
+			}
+			if gotBody["model"] != "some-local-model" {
+				t.Errorf("model = %v, want some-local-model", gotBody["model"])
+			}
+			if usage.InputTokens != 3 || usage.OutputTokens != 1 {
+				t.Errorf("usage = %+v, want 3 in / 1 out", usage)
+			}
+		})
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"content":""},"done":true,"prompt_eval_count":4,"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	p, _ := GetProvider("ollama")
+	client := NewClient("", WithAPIConfig(srv.URL, "llama3"), WithProvider(p))
+
+	text, err := client.CompleteStream(context.Background(), "", "prompt", nil)
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+	if text != "Hello" {
+		t.Errorf("CompleteStream() = %q, want %q", text, "Hello")
+	}
+}
@@ -0,0 +1,255 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// AIMD tuning: a 429 halves the rate, a nearly spent provider quota trims it, and every
+// other successful response wins back a twentieth of the configured rate
+const (
+	rateDecreaseFactor  = 0.5
+	quotaDecreaseFactor = 0.8
+	rateIncreaseSteps   = 20
+	minRateFraction     = 0.05
+	lowQuotaFraction    = 0.1
+	maxRetryAfter       = 5 * time.Minute
+)
+
+// AdaptiveLimiter is a request rate limiter that adapts to the provider. It starts at
+// the configured rate, backs off multiplicatively on 429s and low remaining quota,
+// recovers additively on success, and holds every request while a Retry-After or
+// rate-limit reset is pending. One limiter is shared by all clients using the same
+// API key.
+type AdaptiveLimiter struct {
+	limiter *rate.Limiter
+	ceiling rate.Limit
+	floor   rate.Limit
+	logger  *slog.Logger
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewAdaptiveLimiter creates a limiter that never exceeds requestsPerMinute
+func NewAdaptiveLimiter(requestsPerMinute, burst int) *AdaptiveLimiter {
+	ceiling := rate.Limit(float64(requestsPerMinute) / 60.0)
+	return &AdaptiveLimiter{
+		limiter: rate.NewLimiter(ceiling, burst),
+		ceiling: ceiling,
+		floor:   ceiling * minRateFraction,
+		logger:  slog.Default().With("component", "rate_limiter"),
+	}
+}
+
+// Wait blocks until a request may be sent
+func (l *AdaptiveLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if pause > 0 {
+		l.logger.Debug("waiting for provider rate limit reset", "wait_ms", pause.Milliseconds())
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Limit returns the current rate in requests per second
+func (l *AdaptiveLimiter) Limit() rate.Limit {
+	return l.limiter.Limit()
+}
+
+// Burst returns the maximum burst size
+func (l *AdaptiveLimiter) Burst() int {
+	return l.limiter.Burst()
+}
+
+// Concurrency scales max by how far the rate has backed off from its configured
+// ceiling, so worker pools shrink with the limiter and grow back as it recovers.
+// It never returns less than one.
+func (l *AdaptiveLimiter) Concurrency(max int) int {
+	if max <= 1 {
+		return 1
+	}
+	l.mu.Lock()
+	paused := time.Now().Before(l.pausedUntil)
+	l.mu.Unlock()
+	if paused {
+		return 1
+	}
+	n := int(math.Ceil(float64(max) * float64(l.limiter.Limit()/l.ceiling)))
+	if n < 1 {
+		return 1
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// Observe adjusts the rate from a provider response's status and rate-limit headers
+func (l *AdaptiveLimiter) Observe(statusCode int, header http.Header) {
+	if statusCode == http.StatusTooManyRequests {
+		l.scale(rateDecreaseFactor, "rate limited")
+		l.pause(retryAfter(header))
+		return
+	}
+
+	quota := parseQuota(header)
+	if quota.exhausted() {
+		l.pause(time.Until(quota.reset))
+	}
+	if quota.low() {
+		l.scale(quotaDecreaseFactor, "provider quota low")
+		return
+	}
+	if statusCode == http.StatusOK {
+		l.increase()
+	}
+}
+
+// scale multiplies the rate by factor, keeping it above the floor
+func (l *AdaptiveLimiter) scale(factor float64, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.limiter.Limit()
+	next := max(current*rate.Limit(factor), l.floor)
+	if next == current {
+		return
+	}
+	l.limiter.SetLimit(next)
+	l.logger.Warn("reducing request rate",
+		"reason", reason,
+		"requests_per_minute", float64(next)*60)
+}
+
+// increase raises the rate by one step, up to the configured ceiling
+func (l *AdaptiveLimiter) increase() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.limiter.Limit()
+	if current >= l.ceiling {
+		return
+	}
+	next := min(current+l.ceiling/rateIncreaseSteps, l.ceiling)
+	l.limiter.SetLimit(next)
+	l.logger.Debug("increasing request rate", "requests_per_minute", float64(next)*60)
+}
+
+// pause holds every request for d, extending any pause already in effect
+func (l *AdaptiveLimiter) pause(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	d = min(d, maxRetryAfter)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		l.logger.Warn("pausing requests until the provider rate limit resets", "pause_ms", d.Milliseconds())
+	}
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date,
+// falling back to OpenAI's retry-after-ms
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// quota is the tightest of the request and token budgets a provider reported
+type quota struct {
+	remaining, limit int64
+	reset            time.Time
+	known            bool
+}
+
+func (q quota) exhausted() bool {
+	return q.known && q.remaining <= 0 && !q.reset.IsZero()
+}
+
+func (q quota) low() bool {
+	return q.known && q.limit > 0 && float64(q.remaining) < float64(q.limit)*lowQuotaFraction
+}
+
+// parseQuota reads Anthropic's anthropic-ratelimit-* and OpenAI's x-ratelimit-* headers
+func parseQuota(header http.Header) quota {
+	var tightest quota
+	consider := func(remaining, limit, reset string, resetIsDuration bool) {
+		r, err := strconv.ParseInt(header.Get(remaining), 10, 64)
+		if err != nil {
+			return
+		}
+		q := quota{remaining: r, known: true}
+		q.limit, _ = strconv.ParseInt(header.Get(limit), 10, 64)
+		if value := header.Get(reset); value != "" {
+			if resetIsDuration {
+				if d, err := time.ParseDuration(value); err == nil {
+					q.reset = time.Now().Add(d)
+				}
+			} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+				q.reset = t
+			}
+		}
+		if !tightest.known || q.fraction() < tightest.fraction() {
+			tightest = q
+		}
+	}
+
+	for _, kind := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		consider("anthropic-ratelimit-"+kind+"-remaining", "anthropic-ratelimit-"+kind+"-limit", "anthropic-ratelimit-"+kind+"-reset", false)
+	}
+	for _, kind := range []string{"requests", "tokens"} {
+		consider("x-ratelimit-remaining-"+kind, "x-ratelimit-limit-"+kind, "x-ratelimit-reset-"+kind, true)
+	}
+	return tightest
+}
+
+func (q quota) fraction() float64 {
+	if q.limit <= 0 {
+		if q.remaining <= 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(q.remaining) / float64(q.limit)
+}
+
+// retryDelay is how long to wait before retry attempt: the provider's Retry-After when
+// it sent one, otherwise exponential backoff from one second capped at thirty
+func retryDelay(err error, attempt int) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, maxRetryAfter)
+	}
+	backoff := time.Duration(1<<min(attempt-1, 5)) * time.Second
+	return min(backoff, 30*time.Second)
+}
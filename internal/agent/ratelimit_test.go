@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientHonorsRetryAfterAndBacksOff(t *testing.T) {
+	var calls []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, time.Now())
+		if len(calls) == 1 {
+			w.Header().Set("Retry-After", "0.3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error"}}`)
+			return
+		}
+		io.WriteString(w, `{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(1), WithRateLimit(6000, 10))
+	if _, err := client.Complete(context.Background(), "prompt"); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d requests, want a retry after the 429", len(calls))
+	}
+	if gap := calls[1].Sub(calls[0]); gap < 300*time.Millisecond || gap > 900*time.Millisecond {
+		t.Errorf("retried after %v, want the 300ms Retry-After rather than the default backoff", gap)
+	}
+
+	// Halved by the 429, then one additive step back up from the success
+	if got, want := float64(client.Limiter().Limit())*60, 6000*0.5+6000/20.0; got != want {
+		t.Errorf("rate = %v req/min, want %v", got, want)
+	}
+	if got := client.Limiter().Concurrency(10); got != 6 {
+		t.Errorf("Concurrency(10) = %d, want 6 at 55%% of the configured rate", got)
+	}
+}
+
+func TestSystemPromptRequestsRetryAfter429(t *testing.T) {
+	calls := 0
+	var gap time.Duration
+	var last time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls%2 == 1 {
+			last = time.Now()
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error"}}`)
+			return
+		}
+		gap = time.Since(last)
+		io.WriteString(w, `{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(1), WithRateLimit(6000, 10))
+	requests := map[string]func(ctx context.Context) (string, error){
+		"CompleteWithSystem": func(ctx context.Context) (string, error) {
+			return client.CompleteWithSystem(ctx, "system", "prompt")
+		},
+		"CompleteJSONWithSystem": func(ctx context.Context) (string, error) {
+			return client.CompleteJSONWithSystem(ctx, "system", "prompt")
+		},
+		"CompleteConversation": func(ctx context.Context) (string, error) {
+			conv := NewConversation("system")
+			conv.AddUser("prompt")
+			return client.CompleteConversation(ctx, conv)
+		},
+	}
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			if got, err := request(context.Background()); err != nil || got != "ok" {
+				t.Fatalf("%s() = %q, %v, want ok after a retry", name, got, err)
+			}
+			if calls != 2 {
+				t.Fatalf("got %d requests, want a retry after the 429", calls)
+			}
+			if gap < 200*time.Millisecond || gap > 800*time.Millisecond {
+				t.Errorf("retried after %v, want the 200ms Retry-After", gap)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterReadsQuotaHeaders(t *testing.T) {
+	l := NewAdaptiveLimiter(600, 1)
+
+	header := http.Header{}
+	header.Set("anthropic-ratelimit-requests-limit", "1000")
+	header.Set("anthropic-ratelimit-requests-remaining", "900")
+	header.Set("anthropic-ratelimit-tokens-limit", "80000")
+	header.Set("anthropic-ratelimit-tokens-remaining", "2000")
+	l.Observe(http.StatusOK, header)
+	if got := float64(l.Limit()) * 60; got != 480 {
+		t.Errorf("rate after low token quota = %v req/min, want 480", got)
+	}
+
+	header = http.Header{}
+	header.Set("x-ratelimit-limit-requests", "500")
+	header.Set("x-ratelimit-remaining-requests", "0")
+	header.Set("x-ratelimit-reset-requests", "200ms")
+	l.Observe(http.StatusOK, header)
+	if got := l.Concurrency(8); got != 1 {
+		t.Errorf("Concurrency() while the quota is exhausted = %d, want 1", got)
+	}
+
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 150*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want it to hold until the quota resets", waited)
+	}
+}
@@ -0,0 +1,320 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a model has failed repeatedly and is being skipped
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// RoleClientResolver is implemented by clients that route agent roles to different models.
+// AgentFactory uses it to give each agent the client for its role.
+type RoleClientResolver interface {
+	ClientFor(role string) AIClient
+}
+
+// withModel returns a copy of the client that targets a different model. The copy
+// shares the HTTP transport, rate limiter, provider and usage hook.
+func (c *Client) withModel(model string) *Client {
+	clone := *c
+	clone.model = model
+	return &clone
+}
+
+// ModelRouter resolves a client per agent role and falls back through an ordered list
+// of models when one is overloaded or its circuit breaker is open
+type ModelRouter struct {
+	base      *Client
+	models    map[string]string // role -> model; "default" overrides the base model
+	fallbacks []string
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	clients  map[string]*Client
+	breakers map[string]*circuitBreaker
+}
+
+// NewModelRouter creates a router over base. Roles missing from models use the
+// "default" entry, or the base client's model if there is none.
+func NewModelRouter(base *Client, models map[string]string, fallbacks []string) *ModelRouter {
+	return &ModelRouter{
+		base:      base,
+		models:    models,
+		fallbacks: fallbacks,
+		logger:    base.logger,
+		clients:   make(map[string]*Client),
+		breakers:  make(map[string]*circuitBreaker),
+	}
+}
+
+// ClientFor returns the client chain for role: its configured model followed by the fallbacks
+func (r *ModelRouter) ClientFor(role string) AIClient {
+	return r.chainFor(role)
+}
+
+func (r *ModelRouter) chainFor(role string) *fallbackClient {
+	primary := routedModel(r.models, role, r.base.model)
+	chain := []string{primary}
+	for _, m := range r.fallbacks {
+		if m != primary {
+			chain = append(chain, m)
+		}
+	}
+
+	return &fallbackClient{router: r, models: chain}
+}
+
+// routedModel returns the model role is routed to: its entry in models, else the
+// "default" entry, else base
+func routedModel(models map[string]string, role, base string) string {
+	model, ok := models[role]
+	if !ok {
+		model, ok = models["default"]
+	}
+	if !ok || model == "" {
+		return base
+	}
+	return model
+}
+
+func (r *ModelRouter) clientFor(model string, inChain bool) *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := fmt.Sprintf("%s|%t", model, inChain)
+	if c, ok := r.clients[key]; ok {
+		return c
+	}
+	c := r.base.withModel(model)
+	c.failFastOnOverload = inChain
+	r.clients[key] = c
+	return c
+}
+
+func (r *ModelRouter) breaker(model string) *circuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[model]
+	if !ok {
+		b = &circuitBreaker{threshold: 3, cooldown: 30 * time.Second}
+		r.breakers[model] = b
+	}
+	return b
+}
+
+// The router itself serves the "default" role so it can stand in for a plain client
+
+func (r *ModelRouter) Complete(ctx context.Context, prompt string) (string, error) {
+	return r.ClientFor("default").Complete(ctx, prompt)
+}
+
+func (r *ModelRouter) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return r.ClientFor("default").CompleteJSON(ctx, prompt)
+}
+
+func (r *ModelRouter) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return r.ClientFor("default").CompleteWithSystem(ctx, systemPrompt, userPrompt)
+}
+
+func (r *ModelRouter) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return r.ClientFor("default").CompleteJSONWithSystem(ctx, systemPrompt, userPrompt)
+}
+
+func (r *ModelRouter) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	return r.ClientFor("default").CompleteStream(ctx, systemPrompt, userPrompt, onChunk)
+}
+
+func (r *ModelRouter) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	return r.ClientFor("default").CompleteConversation(ctx, conv)
+}
+
+func (r *ModelRouter) Model() string {
+	return r.chainFor("default").Model()
+}
+
+func (r *ModelRouter) GenerationDefaults() GenerationOptions {
+	return r.base.generation
+}
+
+func (r *ModelRouter) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	return r.chainFor("default").CompleteWithTools(ctx, conv, tools)
+}
+
+func (r *ModelRouter) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	return r.chainFor("default").CompleteStructured(ctx, systemPrompt, userPrompt, schema)
+}
+
+// fallbackClient tries each model in order until one succeeds
+type fallbackClient struct {
+	router *ModelRouter
+	models []string
+}
+
+// Model returns the chain's primary model; fallbacks answer the same request
+func (f *fallbackClient) Model() string {
+	return f.models[0]
+}
+
+func (f *fallbackClient) GenerationDefaults() GenerationOptions {
+	return f.router.base.generation
+}
+
+func (f *fallbackClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.Complete(ctx, prompt) })
+}
+
+func (f *fallbackClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteJSON(ctx, prompt) })
+}
+
+func (f *fallbackClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteWithSystem(ctx, systemPrompt, userPrompt) })
+}
+
+func (f *fallbackClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteJSONWithSystem(ctx, systemPrompt, userPrompt) })
+}
+
+func (f *fallbackClient) CompleteStructured(ctx context.Context, systemPrompt, userPrompt string, schema *Schema) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteStructured(ctx, systemPrompt, userPrompt, schema) })
+}
+
+func (f *fallbackClient) CompleteConversation(ctx context.Context, conv *Conversation) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteConversation(ctx, conv) })
+}
+
+// CompleteWithTools falls back like the other calls; tool handlers may run again on the
+// next model if the first fails partway through the loop
+func (f *fallbackClient) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	return f.try(ctx, func(c *Client) (string, error) { return c.CompleteWithTools(ctx, conv, tools) })
+}
+
+func (f *fallbackClient) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	delivered := false
+	return f.try(ctx, func(c *Client) (string, error) {
+		text, err := c.CompleteStream(ctx, systemPrompt, userPrompt, func(chunk string) error {
+			delivered = true
+			if onChunk == nil {
+				return nil
+			}
+			return onChunk(chunk)
+		})
+		if err != nil && delivered {
+			// The caller already has part of this model's answer; switching models would garble it
+			return text, &noFallbackError{err}
+		}
+		return text, err
+	})
+}
+
+// SubmitBatch sends the batch to the chain's primary model. Batches don't fall back:
+// overload surfaces as failed results, which callers retry individually.
+func (f *fallbackClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	return f.router.clientFor(f.models[0], false).SubmitBatch(ctx, requests)
+}
+
+func (f *fallbackClient) WaitBatch(ctx context.Context, batchID string) ([]BatchResult, error) {
+	return f.router.clientFor(f.models[0], false).WaitBatch(ctx, batchID)
+}
+
+func (f *fallbackClient) try(ctx context.Context, call func(c *Client) (string, error)) (string, error) {
+	hasFallback := len(f.models) > 1
+	var lastErr error
+
+	for i, model := range f.models {
+		breaker := f.router.breaker(model)
+		if !breaker.allow() {
+			f.router.logger.Warn("skipping model with open circuit breaker", "model", model)
+			lastErr = fmt.Errorf("model %s: %w", model, ErrCircuitOpen)
+			continue
+		}
+
+		text, err := call(f.router.clientFor(model, hasFallback))
+		if err == nil {
+			breaker.success()
+			return text, nil
+		}
+
+		var noFallback *noFallbackError
+		if errors.As(err, &noFallback) {
+			breaker.failure()
+			return text, noFallback.err
+		}
+		if ctx.Err() != nil {
+			return text, err
+		}
+
+		breaker.failure()
+		lastErr = fmt.Errorf("model %s: %w", model, err)
+
+		if !IsOverloaded(err) && !breaker.isOpen() {
+			return text, err
+		}
+		if i+1 < len(f.models) {
+			f.router.logger.Warn("model unavailable, falling back",
+				"model", model,
+				"next_model", f.models[i+1],
+				"error", err)
+		}
+	}
+
+	return "", lastErr
+}
+
+// noFallbackError marks a failure that must be returned rather than retried on another model
+type noFallbackError struct{ err error }
+
+func (e *noFallbackError) Error() string { return e.err.Error() }
+func (e *noFallbackError) Unwrap() error { return e.err }
+
+// circuitBreaker opens after threshold consecutive failures and lets a single trial
+// request through once cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	// Half-open: allow one trial and hold the circuit open for everyone else
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
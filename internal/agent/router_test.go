@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// modelServer answers Anthropic-style requests, returning 529 for models in overloaded
+func modelServer(t *testing.T, overloaded map[string]bool) (*httptest.Server, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &req)
+
+		mu.Lock()
+		seen = append(seen, req.Model)
+		mu.Unlock()
+
+		if overloaded[req.Model] {
+			w.WriteHeader(529)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"content":[{"text":"from %s"}],"usage":{"input_tokens":1,"output_tokens":1}}`, req.Model)
+	}))
+	return srv, &seen
+}
+
+func TestModelRouterRoutesByRole(t *testing.T) {
+	srv, _ := modelServer(t, nil)
+	defer srv.Close()
+
+	base := NewClient("test-key", WithAPIConfig(srv.URL, "base-model"), WithRetry(0), WithRateLimit(6000, 10))
+	router := NewModelRouter(base, map[string]string{"planning": "big-model", "writer": "cheap-model"}, nil)
+
+	factory := NewAgentFactory(router, t.TempDir())
+	tests := map[string]string{
+		"planning":       "from big-model",
+		"orchestrator":   "from big-model",
+		"writer":         "from cheap-model",
+		"natural_writer": "from cheap-model",
+		"editor":         "from base-model",
+	}
+	for role, want := range tests {
+		got, err := factory.CreateFictionAgent(role).Execute(context.Background(), "prompt", nil)
+		if err != nil {
+			t.Fatalf("%s: Execute() error = %v", role, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", role, got, want)
+		}
+	}
+}
+
+func TestModelRouterFallsBackOnOverload(t *testing.T) {
+	srv, seen := modelServer(t, map[string]bool{"big-model": true})
+	defer srv.Close()
+
+	base := NewClient("test-key", WithAPIConfig(srv.URL, "big-model"), WithRetry(3), WithRateLimit(6000, 10))
+	router := NewModelRouter(base, nil, []string{"backup-model"})
+
+	got, err := router.Complete(context.Background(), "prompt")
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if got != "from backup-model" {
+		t.Errorf("Complete() = %q, want response from backup-model", got)
+	}
+	// Overload on a chained client should not burn the retry budget on the same model
+	if len(*seen) != 2 {
+		t.Errorf("expected 2 requests (one per model), got %v", *seen)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, cooldown: 0}
+	b.failure()
+	if !b.allow() || b.isOpen() {
+		t.Fatal("breaker should stay closed below threshold")
+	}
+	b.failure()
+	if !b.isOpen() {
+		t.Fatal("breaker should open at threshold")
+	}
+	b.success()
+	if b.isOpen() {
+		t.Fatal("success should close the breaker")
+	}
+}
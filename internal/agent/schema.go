@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema needed to describe Go structs to a model and
+// check what comes back
+type Schema struct {
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	schemaNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// SchemaFor builds a schema from the JSON encoding of v's type. Fields are required
+// unless they are pointers or tagged omitempty, mirroring what json.Marshal would emit.
+func SchemaFor(v any) *Schema {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return &Schema{}
+	}
+
+	s := schemaForType(t, map[reflect.Type]bool{})
+	s.Title = schemaNameChars.ReplaceAllString(t.Name(), "_")
+	if s.Title == "" {
+		s.Title = "response"
+	}
+	return s
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string"}
+	}
+	if t == rawMessageType {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem(), visiting)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"} // []byte is base64 encoded
+		}
+		return &Schema{Type: "array", Items: schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: "object"} // Recursive type; leave the inner level open
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addStructFields(s, t, visiting)
+		sort.Strings(s.Required)
+		return s
+	default:
+		// interface{} and anything else json can't describe precisely
+		return &Schema{}
+	}
+}
+
+// addStructFields adds t's exported fields to s, flattening embedded structs the way
+// encoding/json does
+func addStructFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(s, ft, visiting)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = schemaForType(field.Type, visiting)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// SchemaError lists every place a JSON document departs from its schema
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "response does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that data is JSON matching the schema. Mismatches are reported as a
+// *SchemaError naming each offending path.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return &SchemaError{Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	if dec.More() {
+		return &SchemaError{Problems: []string{"invalid JSON: unexpected data after the top-level value"}}
+	}
+
+	var problems []string
+	s.validate(value, "$", &problems)
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *Schema) validate(value any, path string, problems *[]string) {
+	if s.Type == "" {
+		return
+	}
+	if got := jsonType(value); got != s.Type && !(s.Type == "number" && got == "integer") {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, got))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required field %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				prop.validate(v[k], path+"."+k, problems)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(v[k], path+"."+k, problems)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StreamCallback receives each text fragment as it arrives. Returning an error aborts the stream.
+type StreamCallback func(chunk string) error
+
+// CompleteStream sends the prompts with server-sent events enabled and invokes onChunk for
+// every text delta. The accumulated text is returned even when the stream fails part-way,
+// so callers can keep whatever was generated before a timeout or disconnect.
+func (c *Client) CompleteStream(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	requestID := fmt.Sprintf("api_%d", time.Now().UnixNano())
+	startTime := time.Now()
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retryDelay(lastErr, attempt)):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		if err := c.limiter.Wait(ctx); err != nil {
+			return "", fmt.Errorf("rate limit wait failed: %w", err)
+		}
+
+		c.logger.Debug("attempting streaming AI request",
+			"request_id", requestID,
+			"attempt", attempt,
+			"operation", extractOperationType(userPrompt),
+			"provider", c.provider.Name(),
+			"model", c.model)
+
+		delivered := false
+		text, err := c.doStreamRequest(ctx, systemPrompt, userPrompt, func(chunk string) error {
+			delivered = true
+			if onChunk == nil {
+				return nil
+			}
+			return onChunk(chunk)
+		})
+		if err == nil {
+			c.logger.Info("streaming request successful",
+				"request_id", requestID,
+				"attempt", attempt,
+				"response_length", len(text),
+				"total_duration_ms", time.Since(startTime).Milliseconds())
+			return text, nil
+		}
+
+		lastErr = err
+
+		// Once text has reached the caller a retry would duplicate it, so surface the partial result
+		if delivered || ctx.Err() != nil || !isRetryable(err) || (c.failFastOnOverload && IsOverloaded(err)) {
+			c.logger.Error("streaming request failed",
+				"request_id", requestID,
+				"attempt", attempt,
+				"partial_length", len(text),
+				"error", err)
+			return text, err
+		}
+
+		c.logger.Warn("streaming request failed before first chunk, will retry",
+			"request_id", requestID,
+			"attempt", attempt,
+			"error", err)
+	}
+
+	return "", fmt.Errorf("max retries exceeded: %w", lastErr)
+}
+
+// doStreamRequest streams one response, continuing it with follow-up requests while
+// it stops at the output token limit
+func (c *Client) doStreamRequest(ctx context.Context, systemPrompt, userPrompt string, onChunk StreamCallback) (string, error) {
+	req := Request{
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Stream:       true,
+	}
+	c.prepare(ctx, &req)
+
+	text, usage, err := c.streamOnce(ctx, req, onChunk)
+	for i := 0; err == nil && usage.StopReason == StopMaxTokens && i < c.maxContinuations; i++ {
+		c.logger.Info("stream hit the token limit, continuing",
+			"provider", c.provider.Name(),
+			"continuation", i+1,
+			"response_length", len(text))
+
+		// The provider sees the prefill without trailing whitespace, but that whitespace has
+		// already been delivered, so it is skipped where the continuation repeats it
+		req.Partial = strings.TrimRight(text, " \t\r\n")
+		pending := text[len(req.Partial):]
+		var more string
+		more, usage, err = c.streamOnce(ctx, req, func(chunk string) error {
+			chunk, pending = skipStreamed(chunk, pending)
+			if chunk == "" {
+				return nil
+			}
+			return onChunk(chunk)
+		})
+		more, _ = skipStreamed(more, text[len(req.Partial):])
+		text += more
+	}
+	return text, err
+}
+
+// skipStreamed drops the start of chunk that repeats pending, the text already streamed
+// past a continuation's prefill, and returns what is left of both. Once the two differ
+// nothing more is skipped.
+func skipStreamed(chunk, pending string) (string, string) {
+	n := 0
+	for n < len(chunk) && n < len(pending) && chunk[n] == pending[n] {
+		n++
+	}
+	if n < len(chunk) {
+		return chunk[n:], ""
+	}
+	return "", pending[n:]
+}
+
+func (c *Client) streamOnce(ctx context.Context, req Request, onChunk StreamCallback) (string, Usage, error) {
+	httpReq, err := c.provider.BuildRequest(ctx, Endpoint{BaseURL: c.baseURL, APIKey: c.apiKey}, req)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	// The overall client timeout would cut long generations short; rely on ctx instead
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+	c.limiter.Observe(resp.StatusCode, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", Usage{}, &APIError{StatusCode: resp.StatusCode, Body: string(respBody), RetryAfter: retryAfter(resp.Header)}
+	}
+
+	var text strings.Builder
+	usage, err := c.provider.ReadStream(resp.Body, func(chunk string) error {
+		if chunk == "" {
+			return nil
+		}
+		text.WriteString(chunk)
+		return onChunk(chunk)
+	})
+
+	c.logger.Info("stream completed",
+		"provider", c.provider.Name(),
+		"input_tokens", usage.InputTokens,
+		"output_tokens", usage.OutputTokens,
+		"stop_reason", usage.StopReason,
+		"error", err)
+	c.recordUsage(ctx, usage.InputTokens, usage.OutputTokens)
+
+	return text.String(), usage, err
+}
+
+// errStreamDone signals a clean end of stream from inside an SSE handler
+var errStreamDone = errors.New("stream done")
+
+// readSSE parses a text/event-stream body and calls handle for every event carrying data.
+// A handler returns errStreamDone on the provider's terminal event; reaching EOF before
+// that means the connection was cut and is reported as io.ErrUnexpectedEOF.
+func readSSE(r io.Reader, handle func(event, data string) error) error {
+	reader := bufio.NewReader(r)
+	var event string
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := handle(event, strings.Join(data, "\n"))
+		event, data = "", data[:0]
+		return err
+	}
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("reading stream: %w", readErr)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		var err error
+		switch {
+		case line == "":
+			err = dispatch()
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		if err == nil && readErr == io.EOF {
+			err = dispatch()
+		}
+
+		if err == errStreamDone {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if readErr == io.EOF {
+			return fmt.Errorf("stream ended before completion: %w", io.ErrUnexpectedEOF)
+		}
+	}
+}
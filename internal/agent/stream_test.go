@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sseServer(t *testing.T, events ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			fmt.Fprint(w, ev)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestCompleteStream(t *testing.T) {
+	t.Run("anthropic", func(t *testing.T) {
+		srv := sseServer(t,
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12}}}\n\n",
+			": ping\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\", world\"}}\n\n",
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		)
+		defer srv.Close()
+
+		client := NewClient("test-key", WithAPIConfig(srv.URL, "test-model"))
+
+		var chunks []string
+		text, err := client.CompleteStream(context.Background(), "system", "prompt", func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("CompleteStream() error = %v", err)
+		}
+		if text != "Hello, world" {
+			t.Errorf("CompleteStream() = %q, want %q", text, "Hello, world")
+		}
+		if len(chunks) != 2 {
+			t.Errorf("got %d chunks, want 2", len(chunks))
+		}
+	})
+
+	t.Run("openai", func(t *testing.T) {
+		srv := sseServer(t,
+			"data: {\"choices\":[{\"delta\":{\"content\":\"foo\"}}]}\n\n",
+			"data: {\"choices\":[{\"delta\":{\"content\":\"bar\"},\"finish_reason\":\"stop\"}]}\n\n",
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2}}\n\n",
+			"data: [DONE]\n\n",
+		)
+		defer srv.Close()
+
+		openai, _ := GetProvider("openai")
+		client := NewClient("test-key", WithAPIConfig(srv.URL, "test-model"), WithProvider(openai))
+
+		text, err := client.CompleteStream(context.Background(), "", "prompt", nil)
+		if err != nil {
+			t.Fatalf("CompleteStream() error = %v", err)
+		}
+		if text != "foobar" {
+			t.Errorf("CompleteStream() = %q, want %q", text, "foobar")
+		}
+	})
+
+	t.Run("truncated stream keeps partial text", func(t *testing.T) {
+		srv := sseServer(t,
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"partial\"}}\n\n",
+		)
+		defer srv.Close()
+
+		client := NewClient("test-key", WithAPIConfig(srv.URL, "test-model"))
+
+		text, err := client.CompleteStream(context.Background(), "", "prompt", nil)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("CompleteStream() error = %v, want unexpected EOF", err)
+		}
+		if text != "partial" {
+			t.Errorf("CompleteStream() = %q, want %q", text, "partial")
+		}
+	})
+
+	t.Run("callback error aborts", func(t *testing.T) {
+		srv := sseServer(t,
+			"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"a\"}}\n\n",
+			"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"b\"}}\n\n",
+			"data: {\"type\":\"message_stop\"}\n\n",
+		)
+		defer srv.Close()
+
+		client := NewClient("test-key", WithAPIConfig(srv.URL, "test-model"))
+		stop := errors.New("stop")
+
+		_, err := client.CompleteStream(context.Background(), "", "prompt", func(chunk string) error {
+			if strings.Contains(chunk, "a") {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("CompleteStream() error = %v, want %v", err, stop)
+		}
+	})
+}
+
+func TestCompleteStreamContinuation(t *testing.T) {
+	delta := func(text string) string {
+		return fmt.Sprintf("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", text)
+	}
+	stop := func(reason string) string {
+		return fmt.Sprintf("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":%q},\"usage\":{\"output_tokens\":1}}\n\n", reason) +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	}
+
+	tests := []struct {
+		name         string
+		continuation []string
+		want         string
+	}{
+		{"continuation repeats the whitespace", []string{" ", "and the end."}, "The beginning and the end."},
+		{"continuation omits the whitespace", []string{"and the end."}, "The beginning and the end."},
+		{"continuation starts a new paragraph", []string{"\n\nThe end."}, "The beginning \n\nThe end."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var partials []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Messages []struct {
+						Role    string `json:"role"`
+						Content string `json:"content"`
+					} `json:"messages"`
+				}
+				data, _ := io.ReadAll(r.Body)
+				json.Unmarshal(data, &body)
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				if last := body.Messages[len(body.Messages)-1]; last.Role == "assistant" {
+					partials = append(partials, last.Content)
+					for _, chunk := range tt.continuation {
+						fmt.Fprint(w, delta(chunk))
+					}
+					fmt.Fprint(w, stop("end_turn"))
+					return
+				}
+				fmt.Fprint(w, delta("The beginning "), stop("max_tokens"))
+			}))
+			defer srv.Close()
+
+			client := NewClient("test-key", WithAPIConfig(srv.URL, "test-model"), WithRetry(0))
+
+			var streamed strings.Builder
+			text, err := client.CompleteStream(context.Background(), "system", "prompt", func(chunk string) error {
+				streamed.WriteString(chunk)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("CompleteStream() error = %v", err)
+			}
+			if text != tt.want {
+				t.Errorf("CompleteStream() = %q, want %q", text, tt.want)
+			}
+			if streamed.String() != text {
+				t.Errorf("streamed %q, want the returned text %q", streamed.String(), text)
+			}
+			if len(partials) != 1 || partials[0] != "The beginning" {
+				t.Errorf("continuation prefill = %q, want the streamed text without trailing whitespace", partials)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultMaxRepairs is how many times a response that fails schema validation is sent
+// back to the model with the errors
+const defaultMaxRepairs = 2
+
+// ExecuteStructured runs prompt on a and decodes the response into a T, generating the
+// JSON schema from T and re-prompting when the response does not match it
+func ExecuteStructured[T any](ctx context.Context, a *Agent, prompt string, input any) (T, error) {
+	var out T
+	err := a.ExecuteInto(ctx, prompt, input, &out)
+	return out, err
+}
+
+// ExecuteInto runs prompt and decodes the response into out, which must be a non-nil
+// pointer. A JSON schema derived from out's type is passed to the provider's structured
+// output mode when the client supports one. Responses that still fail validation are
+// sent back with the errors up to the agent's repair limit; the last *SchemaError is
+// returned if none pass.
+func (a *Agent) ExecuteInto(ctx context.Context, prompt string, input any, out any) error {
+	if out == nil {
+		return fmt.Errorf("ExecuteInto: out must be a non-nil pointer")
+	}
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
+	startTime := time.Now()
+	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
+
+	schema := SchemaFor(out)
+	fullPrompt, cacheHit := a.resolvePrompt(requestID, prompt, input)
+
+	structured, native := a.client.(StructuredClient)
+	if !native {
+		fullPrompt = withSchemaInstructions(fullPrompt, schema)
+	}
+
+	a.logger.Debug("executing structured AI request",
+		"request_id", requestID,
+		"operation", extractOperationType(prompt),
+		"schema", schema.Title,
+		"native_schema", native,
+		"cache_hit", cacheHit,
+		"full_prompt_length", len(fullPrompt))
+
+	userPrompt := fullPrompt
+	var lastErr error
+	for attempt := 0; attempt <= a.maxRepairs; attempt++ {
+		var response string
+		var err error
+		if native {
+			response, err = structured.CompleteStructured(ctx, a.systemPrompt, userPrompt, schema)
+		} else {
+			response, err = a.client.CompleteJSONWithSystem(ctx, a.systemPrompt, userPrompt)
+		}
+		if err != nil {
+			a.logger.Error("structured AI request failed",
+				"request_id", requestID,
+				"attempt", attempt,
+				"error", err)
+			return err
+		}
+
+		response = stripCodeFence(response)
+		lastErr = schema.Validate([]byte(response))
+		if lastErr == nil {
+			if err := json.Unmarshal([]byte(response), out); err != nil {
+				return fmt.Errorf("decoding %s: %w", schema.Title, err)
+			}
+			a.logger.Info("structured AI request completed",
+				"request_id", requestID,
+				"schema", schema.Title,
+				"attempts", attempt+1,
+				"duration_ms", time.Since(startTime).Milliseconds())
+			return nil
+		}
+
+		a.logger.Warn("structured response failed validation",
+			"request_id", requestID,
+			"schema", schema.Title,
+			"attempt", attempt,
+			"error", lastErr)
+		userPrompt = repairPrompt(fullPrompt, response, lastErr)
+	}
+
+	return fmt.Errorf("%s still invalid after %d repair attempts: %w", schema.Title, a.maxRepairs, lastErr)
+}
+
+// withSchemaInstructions spells the schema out in the prompt for clients that cannot
+// enforce it themselves
+func withSchemaInstructions(prompt string, schema *Schema) string {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return prompt
+	}
+	return prompt + "\n\nRespond with a JSON object that conforms to this JSON Schema:\n" + string(data)
+}
+
+// repairPrompt asks the model to correct its previous answer
+func repairPrompt(prompt, response string, validationErr error) string {
+	problems := []string{validationErr.Error()}
+	var schemaErr *SchemaError
+	if errors.As(validationErr, &schemaErr) {
+		problems = schemaErr.Problems
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous response was:\n")
+	b.WriteString(response)
+	b.WriteString("\n\nIt failed validation:\n")
+	for _, p := range problems {
+		b.WriteString("- ")
+		b.WriteString(p)
+		b.WriteString("\n")
+	}
+	b.WriteString("\nReturn the corrected JSON object only.")
+	return b.String()
+}
+
+// stripCodeFence removes a markdown code fence wrapped around a JSON response
+func stripCodeFence(response string) string {
+	response = strings.TrimSpace(response)
+	if !strings.HasPrefix(response, "```") {
+		return response
+	}
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	return strings.TrimSpace(response)
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPlan struct {
+	Title string     `json:"title"`
+	Steps []testStep `json:"steps"`
+	Notes string     `json:"notes,omitempty"`
+}
+
+type testStep struct {
+	Order int    `json:"order"`
+	Name  string `json:"name"`
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := SchemaFor(&testPlan{})
+	if schema.Title != "testPlan" || strings.Join(schema.Required, ",") != "steps,title" {
+		t.Fatalf("unexpected schema: title=%q required=%v", schema.Title, schema.Required)
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"valid", `{"title":"x","steps":[{"order":1,"name":"a"}]}`, nil},
+		{"missing field", `{"steps":[]}`, []string{`$: missing required field "title"`}},
+		{"wrong nested type", `{"title":"x","steps":[{"order":"1","name":"a"}]}`, []string{"$.steps[0].order: expected integer, got string"}},
+		{"not json", `here is your plan`, []string{"invalid JSON"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tt.json))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Validate() error = %v, want *SchemaError", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// scriptedClient returns canned responses in order and records the prompts it saw
+type scriptedClient struct {
+	MockClient
+	responses []string
+	prompts   []string
+}
+
+func (s *scriptedClient) CompleteJSONWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	s.prompts = append(s.prompts, userPrompt)
+	resp := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	return resp, nil
+}
+
+func TestExecuteStructuredRepairs(t *testing.T) {
+	client := &scriptedClient{responses: []string{
+		`{"title":"Plan"}`,
+		"```json\n{\"title\":\"Plan\",\"steps\":[{\"order\":1,\"name\":\"build\"}]}\n```",
+	}}
+	a := New(client, "")
+
+	plan, err := ExecuteStructured[testPlan](context.Background(), a, "make a plan", nil)
+	if err != nil {
+		t.Fatalf("ExecuteStructured() error = %v", err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Name != "build" {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if len(client.prompts) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(client.prompts))
+	}
+	if !strings.Contains(client.prompts[0], `"required"`) {
+		t.Error("first prompt should carry the schema for clients without native support")
+	}
+	if !strings.Contains(client.prompts[1], `missing required field "steps"`) {
+		t.Errorf("repair prompt should include validation errors, got:\n%s", client.prompts[1])
+	}
+
+	t.Run("gives up after max repairs", func(t *testing.T) {
+		client := &scriptedClient{responses: []string{`{"title":"Plan"}`}}
+		_, err := ExecuteStructured[testPlan](context.Background(), New(client, "").WithMaxRepairs(1), "make a plan", nil)
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("expected *SchemaError, got %v", err)
+		}
+		if len(client.prompts) != 2 {
+			t.Errorf("expected 2 requests, got %d", len(client.prompts))
+		}
+	})
+}
+
+func TestAnthropicStructuredUsesForcedTool(t *testing.T) {
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotBody)
+		io.WriteString(w, `{"content":[{"type":"tool_use","name":"testPlan","input":{"title":"T","steps":[]}}],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(0), WithRateLimit(6000, 10))
+	plan, err := ExecuteStructured[testPlan](context.Background(), New(client, ""), "make a plan", nil)
+	if err != nil {
+		t.Fatalf("ExecuteStructured() error = %v", err)
+	}
+	if plan.Title != "T" {
+		t.Errorf("plan.Title = %q, want T", plan.Title)
+	}
+	choice, _ := gotBody["tool_choice"].(map[string]interface{})
+	if choice["name"] != "testPlan" {
+		t.Errorf("tool_choice = %v, want forced testPlan tool", gotBody["tool_choice"])
+	}
+}
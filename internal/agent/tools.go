@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrToolsUnsupported is returned when the provider has no tool calling API
+var ErrToolsUnsupported = errors.New("provider does not support tool calling")
+
+// ToolHandler runs a tool with the arguments the model supplied, as a JSON object, and
+// returns the result to send back. An error is reported to the model, which can
+// correct its arguments or carry on without the result.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool is a function the model may call while answering
+type Tool struct {
+	Name        string // Letters, digits, '_' and '-'
+	Description string // Tells the model what the tool does and when to use it
+	Parameters  *Schema
+	Handler     ToolHandler
+}
+
+// NewTool creates a tool whose arguments are decoded into a T, with the parameter
+// schema derived from T the same way as for ExecuteInto
+func NewTool[T any](name, description string, fn func(ctx context.Context, args T) (string, error)) Tool {
+	var zero T
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  SchemaFor(&zero),
+		Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args T
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			return fn(ctx, args)
+		},
+	}
+}
+
+// parameters returns the tool's argument schema; providers require an object even for
+// tools that take no arguments
+func (t Tool) parameters() *Schema {
+	if t.Parameters == nil || t.Parameters.Type == "" {
+		return &Schema{Type: "object", Properties: map[string]*Schema{}}
+	}
+	return t.Parameters
+}
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolResult answers a ToolCall
+type ToolResult struct {
+	CallID  string `json:"call_id"`
+	Content string `json:"content"`
+	IsError bool   `json:"is_error,omitempty"`
+}
+
+// ToolProvider is implemented by providers whose API supports tool calling. BuildRequest
+// declares Request.Tools and sends the tool calls and results carried by messages.
+type ToolProvider interface {
+	// ParseMessage returns the assistant message in a successful response, including
+	// any tools it asked to call, and the token usage
+	ParseMessage(body []byte) (Message, Usage, error)
+}
+
+// defaultMaxToolRounds bounds how many rounds of tool calls one request may make
+const defaultMaxToolRounds = 10
+
+// WithMaxToolRounds limits how many times the model may call tools before it must
+// answer
+func WithMaxToolRounds(n int) Option {
+	return func(c *Client) {
+		c.maxToolRounds = n
+	}
+}
+
+// CompleteWithTools sends the conversation along with tools the model may call. The
+// client runs each round of calls through the tools' handlers and sends the results
+// back until the model answers in text, which is returned. conv is not modified.
+func (c *Client) CompleteWithTools(ctx context.Context, conv *Conversation, tools []Tool) (string, error) {
+	provider, ok := c.provider.(ToolProvider)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", c.provider.Name(), ErrToolsUnsupported)
+	}
+	prompt, err := conv.lastUserMessage()
+	if err != nil {
+		return "", err
+	}
+
+	byName := make(map[string]Tool, len(tools))
+	for _, t := range tools {
+		byName[t.Name] = t
+	}
+
+	requestID := fmt.Sprintf("api_%d", time.Now().UnixNano())
+	startTime := time.Now()
+	messages := append([]Message(nil), conv.Messages...)
+	calls := 0
+
+	for round := 0; round <= c.maxToolRounds; round++ {
+		req := Request{SystemPrompt: conv.System, UserPrompt: prompt, Messages: messages, JSON: conv.JSON, Tools: tools}
+		c.prepare(ctx, &req)
+
+		// Each round is retried on its own so a failure doesn't rerun tools that already ran
+		var reply Message
+		var usage Usage
+		err := c.retry(ctx, requestID, func(attempt int) error {
+			body, err := c.post(ctx, requestID, req)
+			if err != nil {
+				return err
+			}
+			reply, usage, err = provider.ParseMessage(body)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		c.recordUsage(ctx, usage.InputTokens, usage.OutputTokens)
+
+		if len(reply.ToolCalls) == 0 {
+			c.logger.Info("API request successful",
+				"request_id", requestID,
+				"tool_rounds", round,
+				"tool_calls", calls,
+				"response_length", len(reply.Content),
+				"total_duration_ms", time.Since(startTime).Milliseconds())
+			return reply.Content, nil
+		}
+
+		if round == c.maxToolRounds {
+			break
+		}
+		calls += len(reply.ToolCalls)
+		messages = append(messages, reply, Message{Role: RoleUser, ToolResults: c.runTools(ctx, requestID, reply.ToolCalls, byName)})
+	}
+
+	return "", fmt.Errorf("model still calling tools after %d rounds", c.maxToolRounds)
+}
+
+// runTools runs the calls in order. Failures become error results for the model
+// rather than failing the request.
+func (c *Client) runTools(ctx context.Context, requestID string, calls []ToolCall, byName map[string]Tool) []ToolResult {
+	results := make([]ToolResult, len(calls))
+	for i, call := range calls {
+		results[i].CallID = call.ID
+
+		tool, ok := byName[call.Name]
+		if !ok {
+			results[i].Content = fmt.Sprintf("unknown tool %q", call.Name)
+			results[i].IsError = true
+			continue
+		}
+
+		args := call.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		out, err := tool.Handler(ctx, args)
+		if err != nil {
+			c.logger.Warn("tool call failed",
+				"request_id", requestID,
+				"tool", call.Name,
+				"error", err)
+			results[i].Content = err.Error()
+			results[i].IsError = true
+			continue
+		}
+
+		c.logger.Debug("tool call completed",
+			"request_id", requestID,
+			"tool", call.Name,
+			"result_length", len(out))
+		results[i].Content = out
+	}
+	return results
+}
+
+// ExecuteWithTools runs the prompt like Execute while letting the model call tools,
+// such as looking up earlier output instead of receiving all of it in the prompt. It
+// returns ErrToolsUnsupported when the agent's client or provider can't call tools.
+func (a *Agent) ExecuteWithTools(ctx context.Context, prompt string, input any, tools []Tool) (string, error) {
+	client, ok := a.client.(ToolClient)
+	if !ok {
+		return "", ErrToolsUnsupported
+	}
+	ctx = contextWithDefaultGeneration(ContextWithRole(ctx, a.role), a.generation)
+	startTime := time.Now()
+	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
+
+	fullPrompt, cacheHit := a.resolvePrompt(requestID, prompt, input)
+	a.logger.Debug("executing AI request with tools",
+		"request_id", requestID,
+		"operation", extractOperationType(prompt),
+		"tools", len(tools),
+		"cache_hit", cacheHit,
+		"full_prompt_length", len(fullPrompt))
+
+	response, err := client.CompleteWithTools(ctx, NewConversation(a.systemPrompt).AddUser(fullPrompt), tools)
+	if err != nil {
+		a.logger.Error("AI request with tools failed",
+			"request_id", requestID,
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"error", err)
+		return "", err
+	}
+
+	a.logger.Info("AI request with tools completed",
+		"request_id", requestID,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"response_length", len(response))
+	return response, nil
+}
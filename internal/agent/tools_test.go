@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// toolServer asks for the word count of chapter 2 on the first request and answers
+// with whatever the tool returned on the second
+func toolServer(t *testing.T, provider string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		body, _ := io.ReadAll(r.Body)
+
+		if *requests == 1 {
+			if !strings.Contains(string(body), `"count_words"`) {
+				t.Errorf("first request doesn't declare the tool: %s", body)
+			}
+			if provider == "openai" {
+				io.WriteString(w, `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"count_words","arguments":"{\"chapter\":2}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":3}}`)
+				return
+			}
+			io.WriteString(w, `{"content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"count_words","input":{"chapter":2}}],"stop_reason":"tool_use","usage":{"input_tokens":5,"output_tokens":3}}`)
+			return
+		}
+
+		// The second request must carry the call and its result
+		var result string
+		if provider == "openai" {
+			var req struct {
+				Messages []struct {
+					Role       string `json:"role"`
+					Content    string `json:"content"`
+					ToolCallID string `json:"tool_call_id"`
+				} `json:"messages"`
+			}
+			json.Unmarshal(body, &req)
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role != "tool" || last.ToolCallID != "call_1" {
+				t.Errorf("last message = %+v, want the tool result", last)
+			}
+			result = last.Content
+			io.WriteString(w, `{"choices":[{"message":{"content":"Chapter 2 has `+result+` words."},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":4}}`)
+			return
+		}
+
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content []struct {
+					Type      string `json:"type"`
+					ToolUseID string `json:"tool_use_id"`
+					Content   string `json:"content"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		json.Unmarshal(body, &req)
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role != "user" || len(last.Content) != 1 || last.Content[0].ToolUseID != "toolu_1" {
+			t.Errorf("last message = %+v, want the tool result", last)
+		} else {
+			result = last.Content[0].Content
+		}
+		io.WriteString(w, `{"content":[{"type":"text","text":"Chapter 2 has `+result+` words."}],"stop_reason":"end_turn","usage":{"input_tokens":9,"output_tokens":4}}`)
+	}))
+}
+
+func TestExecuteWithToolsRunsToolLoop(t *testing.T) {
+	type chapterArgs struct {
+		Chapter int `json:"chapter"`
+	}
+
+	for _, provider := range []string{"anthropic", "openai"} {
+		t.Run(provider, func(t *testing.T) {
+			requests := 0
+			srv := toolServer(t, provider, &requests)
+			defer srv.Close()
+
+			var usage []Usage
+			p, _ := GetProvider(provider)
+			client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithProvider(p), WithRetry(0), WithRateLimit(6000, 10),
+				WithUsageHook(func(ctx context.Context, u Usage) { usage = append(usage, u) }))
+
+			var asked int
+			countWords := NewTool("count_words", "Count the words in a chapter.", func(ctx context.Context, args chapterArgs) (string, error) {
+				asked = args.Chapter
+				return "1200", nil
+			})
+
+			got, err := New(client, "").ExecuteWithTools(context.Background(), "How long is chapter 2?", nil, []Tool{countWords})
+			if err != nil {
+				t.Fatalf("ExecuteWithTools() error = %v", err)
+			}
+			if got != "Chapter 2 has 1200 words." || asked != 2 {
+				t.Errorf("ExecuteWithTools() = %q with chapter %d, want the tool's answer for chapter 2", got, asked)
+			}
+			if requests != 2 || len(usage) != 2 {
+				t.Errorf("made %d requests with %d usage records, want 2 of each", requests, len(usage))
+			}
+		})
+	}
+}
+
+func TestCompleteWithToolsRetriesARound(t *testing.T) {
+	requests := 0
+	tools := toolServer(t, "anthropic", &requests)
+	defer tools.Close()
+
+	// Rate limit the round carrying the tool result once
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 2 {
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error"}}`)
+			return
+		}
+		tools.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	runs := 0
+	countWords := NewTool("count_words", "Count the words in a chapter.", func(ctx context.Context, args struct{}) (string, error) {
+		runs++
+		return "1200", nil
+	})
+	client := NewClient("test-key", WithAPIConfig(srv.URL, "model"), WithRetry(1), WithRateLimit(6000, 10))
+	conv := NewConversation("")
+	conv.AddUser("How long is chapter 2?")
+
+	got, err := client.CompleteWithTools(context.Background(), conv, []Tool{countWords})
+	if err != nil {
+		t.Fatalf("CompleteWithTools() error = %v", err)
+	}
+	if got != "Chapter 2 has 1200 words." || calls != 3 {
+		t.Errorf("CompleteWithTools() = %q after %d requests, want the answer after a retried round", got, calls)
+	}
+	if runs != 1 {
+		t.Errorf("tool ran %d times, want the retry to resend its result rather than rerun it", runs)
+	}
+}
+
+func TestExecuteWithToolsUnsupportedProvider(t *testing.T) {
+	p, _ := GetProvider("ollama")
+	a := New(NewClient("", WithProvider(p)), "")
+	if _, err := a.ExecuteWithTools(context.Background(), "prompt", nil, nil); !errors.Is(err, ErrToolsUnsupported) {
+		t.Errorf("ExecuteWithTools() error = %v, want ErrToolsUnsupported", err)
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import "context"
+
+// Usage is the token count reported by the provider for a single completed request
+type Usage struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	StopReason   string // StopMaxTokens when the output limit cut the response short
+	Batch        bool   // Sent through a batch API, which bills at a discount
+}
+
+// StopMaxTokens is the normalized stop reason for a response truncated by MaxTokens
+const StopMaxTokens = "max_tokens"
+
+// UsageHook is invoked after every request that reports token usage. The context is the
+// one the request was made with, so callers can recover attribution such as the agent role.
+type UsageHook func(ctx context.Context, usage Usage)
+
+// WithUsageHook registers a callback that receives token usage for every request
+func WithUsageHook(hook UsageHook) Option {
+	return func(c *Client) {
+		c.usageHook = hook
+	}
+}
+
+func (c *Client) recordUsage(ctx context.Context, inputTokens, outputTokens int) {
+	c.reportUsage(ctx, Usage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// recordBatchUsage reports the tokens of a request answered by a batch
+func (c *Client) recordBatchUsage(ctx context.Context, inputTokens, outputTokens int) {
+	c.reportUsage(ctx, Usage{InputTokens: inputTokens, OutputTokens: outputTokens, Batch: true})
+}
+
+func (c *Client) reportUsage(ctx context.Context, usage Usage) {
+	if c.usageHook == nil || usage.InputTokens+usage.OutputTokens == 0 {
+		return
+	}
+	usage.Model = c.model
+	c.usageHook(ctx, usage)
+}
+
+type roleKey struct{}
+
+// ContextWithRole tags ctx with the agent role making the request
+func ContextWithRole(ctx context.Context, role string) context.Context {
+	if role == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the agent role set by ContextWithRole, or "" if none
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
@@ -3,10 +3,14 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/dotcommander/orc/internal/agent"
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
@@ -17,13 +21,150 @@ type Config struct {
 	Paths   PathsConfig   `yaml:"paths" validate:"required"`
 	Limits  Limits        `yaml:"limits" validate:"required"`
 	Plugins PluginsConfig `yaml:"plugins" validate:"required"`
+	Storage StorageConfig `yaml:"storage,omitempty"`
 }
 
 type AIConfig struct {
-	APIKey   string `yaml:"api_key" validate:"required,min=20"`
-	Model    string `yaml:"model" validate:"required,oneof=claude-3-5-sonnet-20241022 claude-3-opus-20240229 gpt-4 gpt-4-turbo gpt-4-turbo-preview gpt-4.1 gpt-4o-mini"`
+	// Provider names the API to speak (anthropic, openai, azure, ollama). When empty it is
+	// guessed from BaseURL and Model is limited to knownModels.
+	Provider string `yaml:"provider,omitempty"`
+	APIKey   string `yaml:"api_key" validate:"omitempty,min=20"`
+	Model    string `yaml:"model" validate:"required"`
 	BaseURL  string `yaml:"base_url" validate:"required,url"`
 	Timeout  int    `yaml:"timeout" validate:"required,min=10,max=3600"`
+	
+	// NoAPIKey sends requests without a key, for servers that don't check one. A
+	// loopback BaseURL never needs a key.
+	NoAPIKey bool `yaml:"no_api_key,omitempty"`
+	
+	// Models overrides the model per agent role (planning, writer, editor, critic,
+	// planner, implementer, reviewer, ...); the "default" key applies to unlisted roles
+	Models map[string]string `yaml:"models,omitempty"`
+	
+	// FallbackModels are tried in order when a model is overloaded or its circuit is open
+	FallbackModels []string `yaml:"fallback_models,omitempty"`
+	
+	// ContextWindow is the model's context size in tokens, used to trim long conversations.
+	// Zero looks it up from the model name.
+	ContextWindow int `yaml:"context_window,omitempty" validate:"omitempty,min=1024"`
+	
+	// Sampling parameters for every request (ai.temperature, ai.max_tokens, ...)
+	GenerationConfig `yaml:",inline"`
+	
+	// Generation overrides the sampling parameters per agent role, keyed like Models
+	Generation map[string]GenerationConfig `yaml:"generation,omitempty" validate:"omitempty,dive"`
+	
+	// Cache stores responses on disk so repeated requests skip the API
+	Cache CacheConfig `yaml:"cache,omitempty"`
+	
+	// Interceptors wrap every AI request, the first listed being the outermost
+	// (cache, redact, metrics, ratelimit, budget, dump)
+	Interceptors []InterceptorConfig `yaml:"interceptors,omitempty" validate:"omitempty,dive"`
+}
+
+// CacheConfig controls the on-disk response cache
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	
+	// Dir holds the cache; empty uses <output_dir>/cache
+	Dir string `yaml:"dir,omitempty"`
+	
+	// TTL is how long a response stays valid; zero uses 24 hours
+	TTL time.Duration `yaml:"ttl,omitempty" validate:"omitempty,min=1m"`
+	
+	// MaxEntries and MaxSizeMB cap the cache, evicting the least recently used
+	// responses first. Zero disables a limit.
+	MaxEntries int `yaml:"max_entries,omitempty" validate:"omitempty,min=1"`
+	MaxSizeMB  int `yaml:"max_size_mb,omitempty" validate:"omitempty,min=1"`
+	
+	// Phases limits caching to the listed phases (agent roles, as in Models); empty
+	// caches every phase
+	Phases []string `yaml:"phases,omitempty"`
+}
+
+// InterceptorConfig names a registered interceptor and its options
+type InterceptorConfig struct {
+	Name    string                 `yaml:"name" validate:"required"`
+	Options map[string]interface{} `yaml:"options,omitempty"`
+}
+
+// GenerationConfig holds sampling parameters; unset fields keep the provider's defaults
+type GenerationConfig struct {
+	Temperature *float64 `yaml:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty" validate:"omitempty,min=1"`
+	TopP        *float64 `yaml:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
+	Stop        []string `yaml:"stop,omitempty"`
+}
+
+// knownModels are accepted when no provider is configured explicitly
+var knownModels = map[string]bool{
+	"claude-3-5-sonnet-20241022": true,
+	"claude-3-opus-20240229":     true,
+	"gpt-4":                      true,
+	"gpt-4-turbo":                true,
+	"gpt-4-turbo-preview":        true,
+	"gpt-4.1":                    true,
+	"gpt-4o-mini":                true,
+}
+
+// keylessProviders run locally and don't need an API key
+var keylessProviders = map[string]bool{
+	"ollama": true,
+}
+
+// RequiresAPIKey reports whether the configured provider needs an API key
+func (c AIConfig) RequiresAPIKey() bool {
+	return !keylessProviders[c.Provider] && !c.NoAPIKey && !isLoopbackURL(c.BaseURL)
+}
+
+// isLoopbackURL reports whether rawURL points at this machine
+func isLoopbackURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateAIConfig applies the checks that depend on which provider is configured
+func validateAIConfig(sl validator.StructLevel) {
+	ai := sl.Current().Interface().(AIConfig)
+	if ai.APIKey == "" && ai.RequiresAPIKey() {
+		sl.ReportError(ai.APIKey, "APIKey", "APIKey", "required", "")
+	}
+	if ai.Provider != "" {
+		if _, err := agent.GetProvider(ai.Provider); err != nil {
+			sl.ReportError(ai.Provider, "Provider", "Provider", "oneof", strings.Join(agent.ListProviders(), " "))
+		}
+	}
+	if ai.Provider == "" && ai.Model != "" && !knownModels[ai.Model] {
+		sl.ReportError(ai.Model, "Model", "Model", "oneof", "")
+	}
+	if ai.Provider == "" {
+		for role, model := range ai.Models {
+			if !knownModels[model] {
+				sl.ReportError(model, "Models["+role+"]", "Models", "oneof", "")
+			}
+		}
+		for _, model := range ai.FallbackModels {
+			if !knownModels[model] {
+				sl.ReportError(model, "FallbackModels", "FallbackModels", "oneof", "")
+			}
+		}
+	}
+}
+
+// validateStorageConfig requires a bucket when sessions go to S3
+func validateStorageConfig(sl validator.StructLevel) {
+	st := sl.Current().Interface().(StorageConfig)
+	if st.Backend == "s3" && st.S3.Bucket == "" {
+		sl.ReportError(st.S3.Bucket, "S3.Bucket", "Bucket", "required", "")
+	}
 }
 
 type PathsConfig struct {
@@ -31,6 +172,40 @@ type PathsConfig struct {
 	Prompts   PromptsConfig `yaml:"prompts" validate:"required"`
 }
 
+// StorageConfig chooses where sessions are kept. The default keeps them only under
+// paths.output_dir; with s3 or sqlite that directory holds a local copy of each session.
+type StorageConfig struct {
+	Backend string       `yaml:"backend,omitempty" validate:"omitempty,oneof=filesystem s3 sqlite"`
+	S3      S3Config     `yaml:"s3,omitempty"`
+	SQLite  SQLiteConfig `yaml:"sqlite,omitempty"`
+}
+
+// SQLiteConfig locates the database for the sqlite backend
+type SQLiteConfig struct {
+	// Path is the database file; empty uses orc.db in paths.output_dir
+	Path string `yaml:"path,omitempty"`
+}
+
+// S3Config locates the bucket for the s3 backend. Unset credentials and region are
+// read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION.
+type S3Config struct {
+	// Endpoint is the service URL, e.g. http://localhost:9000 for MinIO; empty uses AWS
+	Endpoint string `yaml:"endpoint,omitempty" validate:"omitempty,url"`
+	Region   string `yaml:"region,omitempty"`
+	Bucket   string `yaml:"bucket,omitempty"`
+	
+	// Prefix is where sessions go in the bucket; {session} marks the session ID and
+	// is appended when missing. Empty uses "orc/sessions/{session}".
+	Prefix string `yaml:"prefix,omitempty"`
+	
+	AccessKey    string `yaml:"access_key,omitempty"`
+	SecretKey    string `yaml:"secret_key,omitempty"`
+	SessionToken string `yaml:"session_token,omitempty"`
+	
+	// PathStyle addresses the bucket in the URL path, which MinIO needs
+	PathStyle bool `yaml:"path_style,omitempty"`
+}
+
 type PromptsConfig struct {
 	Orchestrator string `yaml:"orchestrator" validate:"required,filepath"`
 	Architect    string `yaml:"architect" validate:"required,filepath"`
@@ -79,7 +254,7 @@ type PluginSettings struct {
 	// Plugin load timeout
 	LoadTimeout string `yaml:"load_timeout"`
 	
-	// Enable plugin sandboxing (future enhancement)
+	// Withhold network access from external plugins
 	EnableSandboxing bool `yaml:"enable_sandboxing"`
 }
 
@@ -106,7 +281,7 @@ func Load() (*Config, error) {
 	}
 	
 	// Try to get API key from environment
-	if cfg.AI.APIKey == "" || cfg.AI.APIKey == "${OPENAI_API_KEY}" {
+	if cfg.AI.RequiresAPIKey() && (cfg.AI.APIKey == "" || cfg.AI.APIKey == "${OPENAI_API_KEY}") {
 		if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
 			cfg.AI.APIKey = apiKey
 		} else {
@@ -205,6 +380,8 @@ func (c *Config) validate() error {
 		c.Limits = DefaultLimits()
 	}
 	
+	c.Storage.SQLite.Path = expandTilde(c.Storage.SQLite.Path)
+	
 	// Set plugin defaults
 	if len(c.Plugins.DiscoveryPaths) == 0 {
 		c.Plugins = DefaultPluginsConfig()
@@ -226,6 +403,9 @@ func (c *Config) validate() error {
 		return fl.Field().String() != ""
 	})
 	
+	validate.RegisterStructValidation(validateAIConfig, AIConfig{})
+	validate.RegisterStructValidation(validateStorageConfig, StorageConfig{})
+	
 	if err := validate.Struct(c); err != nil {
 		return fmt.Errorf("config validation failed: %w", err)
 	}
@@ -293,6 +473,7 @@ func createConfigInteractively(configPath string) (*Config, error) {
 func createOpenAIConfig() Config {
 	return Config{
 		AI: AIConfig{
+			Provider: "openai",
 			Model:   "gpt-4.1",
 			BaseURL: "https://api.openai.com/v1",
 			Timeout: 900, // Extended from 300 to 900 seconds (15 minutes)
@@ -304,6 +485,7 @@ func createOpenAIConfig() Config {
 func createAnthropicConfig() Config {
 	return Config{
 		AI: AIConfig{
+			Provider: "anthropic",
 			Model:   "claude-3-5-sonnet-20241022",
 			BaseURL: "https://api.anthropic.com",
 			Timeout: 900, // Extended from 300 to 900 seconds (15 minutes)
@@ -211,4 +211,57 @@ func TestDefaultLimits(t *testing.T) {
 	if err := cfg.validate(); err != nil {
 		t.Errorf("DefaultLimits() should produce valid config, got error: %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestProviderValidation(t *testing.T) {
+	base := func(ai AIConfig) Config {
+		return Config{
+			AI: ai,
+			Paths: PathsConfig{
+				OutputDir: "output",
+				Prompts: PromptsConfig{
+					Orchestrator: "prompts/orchestrator.txt",
+					Architect:    "prompts/architect.txt",
+					Writer:       "prompts/writer.txt",
+					Critic:       "prompts/critic.txt",
+				},
+			},
+			Limits: DefaultLimits(),
+		}
+	}
+	
+	// Local providers accept any model and need no key
+	cfg := base(AIConfig{Provider: "ollama", Model: "llama3.1:8b", BaseURL: "http://localhost:11434", Timeout: 30})
+	if err := cfg.validate(); err != nil {
+		t.Errorf("ollama config should be valid, got: %v", err)
+	}
+	
+	// OpenAI-compatible gateways use their own model names
+	cfg = base(AIConfig{Provider: "openai", APIKey: "sk-1234567890abcdef1234567890abcdef", Model: "meta-llama/llama-3-70b", BaseURL: "https://openrouter.ai/api/v1", Timeout: 30})
+	if err := cfg.validate(); err != nil {
+		t.Errorf("openai-compatible config should be valid, got: %v", err)
+	}
+	
+	// Hosted providers still require a key
+	cfg = base(AIConfig{Provider: "openai", Model: "gpt-4.1", BaseURL: "https://api.openai.com/v1", Timeout: 30})
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "APIKey") {
+		t.Errorf("expected APIKey error, got: %v", err)
+	}
+	
+	// Unless the server is on this machine, or the config opts out
+	for _, baseURL := range []string{"http://localhost:8000/v1", "http://127.0.0.1:8080/v1", "http://[::1]:8080/v1"} {
+		cfg = base(AIConfig{Provider: "openai", Model: "qwen2.5", BaseURL: baseURL, Timeout: 30})
+		if err := cfg.validate(); err != nil {
+			t.Errorf("keyless %s config should be valid, got: %v", baseURL, err)
+		}
+	}
+	cfg = base(AIConfig{Provider: "openai", Model: "qwen2.5", BaseURL: "http://gpu-box:8000/v1", Timeout: 30, NoAPIKey: true})
+	if err := cfg.validate(); err != nil {
+		t.Errorf("no_api_key config should be valid, got: %v", err)
+	}
+	
+	// Providers must be registered
+	cfg = base(AIConfig{Provider: "antrhopic", APIKey: "sk-1234567890abcdef1234567890abcdef", Model: "claude-3-5-sonnet-20241022", BaseURL: "https://api.anthropic.com", Timeout: 30})
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "Provider") {
+		t.Errorf("expected Provider error, got: %v", err)
+	}
+}
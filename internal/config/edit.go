@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigPath returns the config file location honoring ORCHESTRATOR_CONFIG and XDG_CONFIG_HOME
+func ConfigPath() string {
+	return getConfigPath()
+}
+
+// GetValue returns the YAML value stored under a dotted key such as "ai.model"
+func GetValue(configPath, key string) (string, error) {
+	root, err := readConfigNode(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	node := lookupNode(root, strings.Split(key, "."))
+	if node == nil {
+		return "", fmt.Errorf("key %q not set in %s", key, configPath)
+	}
+
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, nil
+	}
+
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return "", fmt.Errorf("encoding value: %w", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// SetValue stores value under a dotted key, creating intermediate sections as needed.
+// Comments and unrelated keys in the file are preserved.
+func SetValue(configPath, key, value string) error {
+	parts := strings.Split(key, ".")
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+
+	root, err := readConfigNode(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if root == nil {
+		root = &yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	var valueNode yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &valueNode); err != nil || len(valueNode.Content) == 0 {
+		valueNode = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	} else {
+		valueNode = *valueNode.Content[0]
+	}
+
+	if err := setNode(root, parts, &valueNode); err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+
+	// Reject values that no longer decode into the config structure
+	var check Config
+	if err := yaml.Unmarshal(buf.Bytes(), &check); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	return os.WriteFile(configPath, buf.Bytes(), 0644)
+}
+
+// readConfigNode parses the config file and returns its top-level mapping node
+func readConfigNode(configPath string) (*yaml.Node, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s is not a YAML mapping", configPath)
+	}
+	return root, nil
+}
+
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, part := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == part {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+func setNode(node *yaml.Node, path []string, value *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%q is not a section", path[0])
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			// Keep any trailing comment attached to the old value
+			value.LineComment = node.Content[i+1].LineComment
+			node.Content[i+1] = value
+			return nil
+		}
+		return setNode(node.Content[i+1], path[1:], value)
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		node.Content = append(node.Content, keyNode, value)
+		return nil
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, keyNode, child)
+	return setNode(child, path[1:], value)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetValuePreservesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := "ai:\n  model: gpt-4 # preferred model\n  timeout: 30\nlimits:\n  max_retries: 3\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetValue(path, "ai.model", "gpt-4o-mini"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if err := SetValue(path, "limits.rate_limit.burst_size", "5"); err != nil {
+		t.Fatalf("SetValue() nested error = %v", err)
+	}
+
+	got, err := GetValue(path, "ai.model")
+	if err != nil || got != "gpt-4o-mini" {
+		t.Errorf("GetValue(ai.model) = %q, %v", got, err)
+	}
+	got, err = GetValue(path, "limits.rate_limit.burst_size")
+	if err != nil || got != "5" {
+		t.Errorf("GetValue(limits.rate_limit.burst_size) = %q, %v", got, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# preferred model") {
+		t.Errorf("comment was not preserved:\n%s", data)
+	}
+	if !strings.Contains(string(data), "timeout: 30") {
+		t.Errorf("unrelated key was lost:\n%s", data)
+	}
+}
+
+func TestSetValueRejectsBadInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("ai:\n  timeout: 30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetValue(path, "ai.timeout", "forever"); err == nil {
+		t.Error("expected error for non-numeric timeout")
+	}
+	if err := SetValue(path, "ai..model", "gpt-4"); err == nil {
+		t.Error("expected error for empty key segment")
+	}
+	if err := SetValue(path, "ai.timeout.seconds", "10"); err == nil {
+		t.Error("expected error when descending into a scalar")
+	}
+	if _, err := GetValue(path, "ai.missing"); err == nil {
+		t.Error("expected error for missing key")
+	}
+}
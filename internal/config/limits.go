@@ -14,6 +14,13 @@ type Limits struct {
 	TotalTimeout        time.Duration     `yaml:"total_timeout" validate:"required,min=1m,max=24h"`
 	PhaseTimeouts       PhaseTimeouts     `yaml:"phase_timeouts"`
 	RateLimit           RateLimitConfig   `yaml:"rate_limit" validate:"required"`
+	Budget              BudgetLimits      `yaml:"budget"`
+}
+
+// BudgetLimits caps the tokens and estimated cost of a single session. Zero disables a limit.
+type BudgetLimits struct {
+	MaxTokens  int     `yaml:"max_tokens" validate:"min=0"`
+	MaxCostUSD float64 `yaml:"max_cost_usd" validate:"min=0"`
 }
 
 type PhaseTimeouts struct {
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -52,12 +53,12 @@ func (c *MemoryCache[K, V]) Get(key K) (V, bool) {
 	c.mutex.RUnlock()
 	
 	if !exists || entry.IsExpired() {
-		c.misses++
+		atomic.AddUint64(&c.misses, 1) // Get runs concurrently under the read lock
 		var zero V
 		return zero, false
 	}
 	
-	c.hits++
+	atomic.AddUint64(&c.hits, 1)
 	return entry.Value, true
 }
 
@@ -100,7 +101,7 @@ func (c *MemoryCache[K, V]) Clear() {
 func (c *MemoryCache[K, V]) Stats() (hits, misses uint64, size int) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.hits, c.misses, len(c.data)
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses), len(c.data)
 }
 
 // evictOldest removes the oldest entry (simple LRU approximation)
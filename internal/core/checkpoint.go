@@ -4,7 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Checkpoint struct {
@@ -22,10 +29,24 @@ type Checkpoint struct {
 	ResumeCount      int                 `json:"resume_count"`
 	LastResumeTime   *time.Time         `json:"last_resume_time,omitempty"`
 	CanResumeWithin  bool               `json:"can_resume_within"`
+	
+	// Token usage accumulated by the session so far
+	Usage            []UsageEntry       `json:"usage,omitempty"`
+	
+	// Position in the session's checkpoint history
+	CheckpointID     string             `json:"checkpoint_id,omitempty"`
+	Sequence         int                `json:"sequence,omitempty"`
+	ParentID         string             `json:"parent_id,omitempty"`   // Checkpoint this one was restored or forked from
+	ForkedFrom       string             `json:"forked_from,omitempty"` // Session this one was forked from
 }
 
+// CheckpointManager keeps an append-only history of checkpoints per session under
+// checkpoints/<session>/, alongside checkpoints/<session>.json holding the latest one.
+// Load and resume read the latest; RestoreTo and Fork rewind to any earlier entry.
 type CheckpointManager struct {
 	storage Storage
+	ledger  *TokenLedger
+	mu      sync.Mutex // Serializes appends so sequence numbers stay unique
 }
 
 func NewCheckpointManager(storage Storage) *CheckpointManager {
@@ -34,6 +55,12 @@ func NewCheckpointManager(storage Storage) *CheckpointManager {
 	}
 }
 
+// WithLedger makes every saved checkpoint carry the ledger's current token usage
+func (cm *CheckpointManager) WithLedger(ledger *TokenLedger) *CheckpointManager {
+	cm.ledger = ledger
+	return cm
+}
+
 func (cm *CheckpointManager) Save(ctx context.Context, sessionID string, phaseIndex int, phaseName string, data interface{}) error {
 	checkpoint := &Checkpoint{
 		ID:         sessionID,
@@ -42,34 +69,58 @@ func (cm *CheckpointManager) Save(ctx context.Context, sessionID string, phaseIn
 		Timestamp:  time.Now(),
 		State:      map[string]any{"data": data},
 	}
+	if cm.ledger != nil {
+		checkpoint.Usage = cm.ledger.Entries()
+	}
 	// Increment resume count if this is a resume operation
 	if checkpoint.LastResumeTime != nil {
 		checkpoint.ResumeCount++
 	}
 	
-	checkpointData, err := json.MarshalIndent(checkpoint, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshaling checkpoint: %w", err)
-	}
-	
-	filename := fmt.Sprintf("checkpoints/%s.json", sessionID)
-	return cm.storage.Save(ctx, filename, checkpointData)
+	return cm.appendCheckpoint(ctx, checkpoint)
 }
 
 // SaveCheckpoint saves a checkpoint struct directly (for internal use)
 func (cm *CheckpointManager) SaveCheckpoint(ctx context.Context, checkpoint *Checkpoint) error {
+	if cm.ledger != nil {
+		checkpoint.Usage = cm.ledger.Entries()
+	}
+	
 	// Increment resume count if this is a resume operation
 	if checkpoint.LastResumeTime != nil {
 		checkpoint.ResumeCount++
 	}
 	
-	data, err := json.MarshalIndent(checkpoint, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshaling checkpoint: %w", err)
-	}
-	
-	filename := fmt.Sprintf("checkpoints/%s.json", checkpoint.ID)
-	return cm.storage.Save(ctx, filename, data)
+	return cm.appendCheckpoint(ctx, checkpoint)
+}
+
+// appendCheckpoint records checkpoint as the next entry in its session's history and
+// makes it the latest. On transactional storage both writes commit together, along
+// with anything else in the caller's transaction.
+func (cm *CheckpointManager) appendCheckpoint(ctx context.Context, checkpoint *Checkpoint) error {
+	return WithinTx(ctx, cm.storage, func(ctx context.Context) error {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		
+		files, err := cm.historyFiles(ctx, checkpoint.ID)
+		if err != nil {
+			return err
+		}
+		checkpoint.Sequence = 1
+		if len(files) > 0 {
+			checkpoint.Sequence = sequenceOf(files[len(files)-1]) + 1
+		}
+		checkpoint.CheckpointID = fmt.Sprintf("%06d", checkpoint.Sequence)
+		
+		data, err := json.MarshalIndent(checkpoint, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling checkpoint: %w", err)
+		}
+		if err := cm.storage.Save(ctx, historyPath(checkpoint.ID, checkpoint.CheckpointID), data); err != nil {
+			return fmt.Errorf("saving checkpoint history: %w", err)
+		}
+		return cm.storage.Save(ctx, headPath(checkpoint.ID), data)
+	})
 }
 
 // SaveWithSceneProgress saves checkpoint with scene-level progress
@@ -91,11 +142,32 @@ func (cm *CheckpointManager) MarkAsResumed(ctx context.Context, id string) error
 	
 	now := time.Now()
 	checkpoint.LastResumeTime = &now
-	return cm.SaveCheckpoint(ctx, checkpoint)
+	checkpoint.ResumeCount++
+	
+	// Resuming doesn't change the session's state, so only the latest checkpoint is updated
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling checkpoint: %w", err)
+	}
+	return cm.storage.Save(ctx, headPath(id), data)
 }
 
 func (cm *CheckpointManager) Load(ctx context.Context, sessionID string) (*Checkpoint, error) {
-	filename := fmt.Sprintf("checkpoints/%s.json", sessionID)
+	return cm.loadFile(ctx, headPath(sessionID))
+}
+
+// LoadAt returns one entry from a session's checkpoint history
+func (cm *CheckpointManager) LoadAt(ctx context.Context, sessionID, checkpointID string) (*Checkpoint, error) {
+	if checkpointID == "" || strings.ContainsAny(checkpointID, `/\*?[`) {
+		return nil, fmt.Errorf("invalid checkpoint ID %q", checkpointID)
+	}
+	if n, err := strconv.Atoi(checkpointID); err == nil {
+		checkpointID = fmt.Sprintf("%06d", n) // Accept "3" for "000003"
+	}
+	return cm.loadFile(ctx, historyPath(sessionID, checkpointID))
+}
+
+func (cm *CheckpointManager) loadFile(ctx context.Context, filename string) (*Checkpoint, error) {
 	data, err := cm.storage.Load(ctx, filename)
 	if err != nil {
 		return nil, fmt.Errorf("loading checkpoint: %w", err)
@@ -109,6 +181,122 @@ func (cm *CheckpointManager) Load(ctx context.Context, sessionID string) (*Check
 	return &checkpoint, nil
 }
 
+// History returns every checkpoint recorded for a session, oldest first
+func (cm *CheckpointManager) History(ctx context.Context, sessionID string) ([]*Checkpoint, error) {
+	files, err := cm.historyFiles(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	checkpoints := make([]*Checkpoint, 0, len(files))
+	for _, file := range files {
+		checkpoint, err := cm.loadFile(ctx, file)
+		if err != nil {
+			continue
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// ListByPhase returns the checkpoints of a session taken after the named phase
+// completed, oldest first
+func (cm *CheckpointManager) ListByPhase(ctx context.Context, sessionID, phaseName string) ([]*Checkpoint, error) {
+	history, err := cm.History(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	var matches []*Checkpoint
+	for _, checkpoint := range history {
+		// Checkpoints taken after a parallel wave name every phase in it, joined by "+"
+		for _, name := range strings.Split(checkpoint.PhaseName, "+") {
+			if name == phaseName {
+				matches = append(matches, checkpoint)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// RestoreTo rewinds a session to an earlier checkpoint. The checkpoint is appended to
+// the history as the latest entry, so a resume continues from it and nothing recorded
+// since is lost.
+func (cm *CheckpointManager) RestoreTo(ctx context.Context, sessionID, checkpointID string) (*Checkpoint, error) {
+	checkpoint, err := cm.LoadAt(ctx, sessionID, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	
+	checkpoint.ParentID = checkpoint.CheckpointID
+	checkpoint.Timestamp = time.Now()
+	checkpoint.LastResumeTime = nil
+	if err := cm.appendCheckpoint(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// Fork starts a new session from one of sessionID's checkpoints and returns its ID.
+// Both sessions then continue independently.
+func (cm *CheckpointManager) Fork(ctx context.Context, sessionID, checkpointID string) (string, error) {
+	return cm.ForkInto(ctx, sessionID, checkpointID, cm)
+}
+
+// ForkInto is Fork for sessions kept in separate storage, such as one directory per
+// session; the new session's history is written through target
+func (cm *CheckpointManager) ForkInto(ctx context.Context, sessionID, checkpointID string, target *CheckpointManager) (string, error) {
+	checkpoint, err := cm.LoadAt(ctx, sessionID, checkpointID)
+	if err != nil {
+		return "", err
+	}
+	
+	forked := *checkpoint
+	forked.ID = uuid.New().String()
+	forked.ForkedFrom = sessionID
+	forked.ParentID = checkpoint.CheckpointID
+	forked.Timestamp = time.Now()
+	forked.ResumeCount = 0
+	forked.LastResumeTime = nil
+	if err := target.appendCheckpoint(ctx, &forked); err != nil {
+		return "", err
+	}
+	return forked.ID, nil
+}
+
+// historyFiles lists a session's history entries in sequence order
+func (cm *CheckpointManager) historyFiles(ctx context.Context, sessionID string) ([]string, error) {
+	pattern := historyPath(sessionID, "*")
+	files, err := cm.storage.List(ctx, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoint history: %w", err)
+	}
+	
+	// Not every storage backend matches patterns exactly
+	matched := files[:0]
+	for _, file := range files {
+		if ok, _ := path.Match(pattern, file); ok {
+			matched = append(matched, file)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return sequenceOf(matched[i]) < sequenceOf(matched[j]) })
+	return matched, nil
+}
+
+func headPath(sessionID string) string {
+	return fmt.Sprintf("checkpoints/%s.json", sessionID)
+}
+
+func historyPath(sessionID, checkpointID string) string {
+	return fmt.Sprintf("checkpoints/%s/%s.json", sessionID, checkpointID)
+}
+
+func sequenceOf(file string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(path.Base(file), ".json"))
+	return n
+}
+
 func (cm *CheckpointManager) List(ctx context.Context) ([]*Checkpoint, error) {
 	files, err := cm.storage.List(ctx, "checkpoints/*.json")
 	if err != nil {
@@ -133,7 +321,16 @@ func (cm *CheckpointManager) List(ctx context.Context) ([]*Checkpoint, error) {
 	return checkpoints, nil
 }
 
+// Delete removes a session's latest checkpoint and its history
 func (cm *CheckpointManager) Delete(ctx context.Context, sessionID string) error {
-	filename := fmt.Sprintf("checkpoints/%s.json", sessionID)
-	return cm.storage.Delete(ctx, filename)
+	files, err := cm.historyFiles(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := cm.storage.Delete(ctx, file); err != nil {
+			return err
+		}
+	}
+	return cm.storage.Delete(ctx, headPath(sessionID))
 }
\ No newline at end of file
@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/core"
+)
+
+func TestCheckpointHistoryRestoreAndFork(t *testing.T) {
+	ctx := context.Background()
+	cm := core.NewCheckpointManager(newMockStorage())
+
+	for i, phase := range []string{"Planning", "Architecture", "Writing"} {
+		if err := cm.Save(ctx, "novel", i+1, phase, phase+" output"); err != nil {
+			t.Fatalf("Save(%s) error = %v", phase, err)
+		}
+	}
+
+	history, err := cm.History(ctx, "novel")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("History() = %d checkpoints, %v; want 3", len(history), err)
+	}
+	if history[0].CheckpointID != "000001" || history[2].PhaseName != "Writing" {
+		t.Errorf("history out of order: first=%s last=%s", history[0].CheckpointID, history[2].PhaseName)
+	}
+	if byPhase, _ := cm.ListByPhase(ctx, "novel", "Architecture"); len(byPhase) != 1 || byPhase[0].PhaseIndex != 2 {
+		t.Errorf("ListByPhase(Architecture) = %v", byPhase)
+	}
+
+	restored, err := cm.RestoreTo(ctx, "novel", "2")
+	if err != nil {
+		t.Fatalf("RestoreTo() error = %v", err)
+	}
+	head, _ := cm.Load(ctx, "novel")
+	if head.PhaseName != "Architecture" || head.PhaseIndex != 2 || head.ParentID != "000002" || head.CheckpointID != restored.CheckpointID {
+		t.Errorf("head after restore = %+v, want the Architecture checkpoint", head)
+	}
+	if history, _ := cm.History(ctx, "novel"); len(history) != 4 {
+		t.Errorf("restore should append to the history, got %d entries", len(history))
+	}
+
+	forkID, err := cm.Fork(ctx, "novel", "000001")
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	forked, err := cm.Load(ctx, forkID)
+	if err != nil {
+		t.Fatalf("Load(fork) error = %v", err)
+	}
+	if forked.ForkedFrom != "novel" || forked.PhaseName != "Planning" || forked.State["data"] != "Planning output" {
+		t.Errorf("forked checkpoint = %+v, want a copy of the Planning checkpoint", forked)
+	}
+	if head, _ := cm.Load(ctx, "novel"); head.PhaseName != "Architecture" {
+		t.Errorf("forking changed the original session's head to %s", head.PhaseName)
+	}
+}
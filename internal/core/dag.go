@@ -0,0 +1,338 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// PhaseSchedule describes where a phase sits in the pipeline graph. It mirrors the
+// order, parallel and depends_on fields of a plugin manifest's phase definitions.
+type PhaseSchedule struct {
+	Name      string
+	Order     int
+	Parallel  bool     // Safe to run alongside other phases in the same wave
+	DependsOn []string // Explicit dependencies; when empty they are derived from Order
+}
+
+// PhaseDAG is a dependency graph of phases grouped into waves. Every phase in a wave
+// depends only on phases in earlier waves.
+type PhaseDAG struct {
+	waves    [][]Phase
+	deps     map[string][]string
+	parallel map[string]bool
+}
+
+// BuildPhaseDAG arranges phases into waves using schedule. When a phase has no explicit
+// dependencies it depends on every phase in the preceding order group; phases sharing
+// an order only form a group when all of them are marked parallel. Phases missing from
+// schedule keep their position in phases, so a nil schedule yields a linear pipeline.
+func BuildPhaseDAG(phases []Phase, schedule []PhaseSchedule) (*PhaseDAG, error) {
+	specs := make(map[string]PhaseSchedule, len(schedule))
+	for _, s := range schedule {
+		specs[s.Name] = s
+	}
+
+	byName := make(map[string]Phase, len(phases))
+	position := make(map[string]int, len(phases))
+	ordered := make([]Phase, len(phases))
+	copy(ordered, phases)
+	for i, p := range phases {
+		if _, dup := byName[p.Name()]; dup {
+			return nil, fmt.Errorf("duplicate phase %q", p.Name())
+		}
+		byName[p.Name()] = p
+		position[p.Name()] = i
+	}
+
+	orderOf := func(p Phase) int {
+		if s, ok := specs[p.Name()]; ok {
+			return s.Order
+		}
+		return position[p.Name()] + 1
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return orderOf(ordered[i]) < orderOf(ordered[j]) })
+
+	dag := &PhaseDAG{
+		deps:     make(map[string][]string, len(phases)),
+		parallel: make(map[string]bool, len(phases)),
+	}
+
+	// Derive implicit dependencies from order groups
+	var previous, current []string
+	for i, p := range ordered {
+		name := p.Name()
+		spec := specs[name]
+		dag.parallel[name] = spec.Parallel
+
+		if i > 0 {
+			prev := ordered[i-1]
+			sameGroup := orderOf(prev) == orderOf(p) && spec.Parallel && dag.parallel[prev.Name()]
+			if !sameGroup {
+				previous, current = current, nil
+			}
+		}
+		current = append(current, name)
+
+		if len(spec.DependsOn) > 0 {
+			for _, dep := range spec.DependsOn {
+				if _, ok := byName[dep]; !ok {
+					return nil, fmt.Errorf("phase %q depends on unknown phase %q", name, dep)
+				}
+			}
+			dag.deps[name] = append([]string(nil), spec.DependsOn...)
+		} else {
+			dag.deps[name] = append([]string(nil), previous...)
+		}
+	}
+
+	// Kahn's algorithm, one wave per level, keeping the order-sorted sequence within a wave
+	done := make(map[string]bool, len(ordered))
+	for len(done) < len(ordered) {
+		var wave []Phase
+		for _, p := range ordered {
+			if done[p.Name()] {
+				continue
+			}
+			ready := true
+			for _, dep := range dag.deps[p.Name()] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, p)
+			}
+		}
+		if len(wave) == 0 {
+			var stuck []string
+			for _, p := range ordered {
+				if !done[p.Name()] {
+					stuck = append(stuck, p.Name())
+				}
+			}
+			return nil, fmt.Errorf("phase dependency cycle among: %s", strings.Join(stuck, ", "))
+		}
+		for _, p := range wave {
+			done[p.Name()] = true
+		}
+		dag.waves = append(dag.waves, wave)
+	}
+
+	return dag, nil
+}
+
+// Waves returns the phases grouped by execution wave
+func (d *PhaseDAG) Waves() [][]Phase {
+	return d.waves
+}
+
+// Dependencies returns the names of the phases whose output feeds the named phase
+func (d *PhaseDAG) Dependencies(name string) []string {
+	return d.deps[name]
+}
+
+// Phases returns every phase in execution order. Checkpoint phase indexes count into
+// this sequence, so a resumed run skips whole waves.
+func (d *PhaseDAG) Phases() []Phase {
+	var all []Phase
+	for _, wave := range d.waves {
+		all = append(all, wave...)
+	}
+	return all
+}
+
+// inputFor fans in the outputs of a phase's dependencies. A single dependency passes
+// its data through unchanged; several are keyed by phase name.
+func (d *PhaseDAG) inputFor(name string, outputs map[string]interface{}) interface{} {
+	deps := d.deps[name]
+	switch len(deps) {
+	case 0:
+		return nil
+	case 1:
+		return outputs[deps[0]]
+	}
+
+	fanIn := make(map[string]interface{}, len(deps))
+	for _, dep := range deps {
+		fanIn[dep] = outputs[dep]
+	}
+	return fanIn
+}
+
+// ExecuteDAG runs the graph wave by wave. Parallel phases within a wave run concurrently
+// up to the engine's concurrency limit; the rest run one at a time before them. A
+// checkpoint holding every phase output so far is saved after each wave, and startPhase
+// (an index into dag.Phases) resumes from one.
+func (e *ExecutionEngine) ExecuteDAG(ctx context.Context, dag *PhaseDAG, request string, sessionID string, startPhase int, checkpoint *CheckpointManager) error {
+	e.logger.Info("starting DAG orchestration",
+		"session", sessionID,
+		"waves", len(dag.waves),
+		"start_phase", startPhase)
+
+	outputs := make(map[string]interface{})
+	if startPhase > 0 && checkpoint != nil {
+		chkpt, err := checkpoint.Load(ctx, sessionID)
+		if err == nil {
+			for name, data := range chkpt.PhaseStates {
+				outputs[name] = data
+			}
+			if len(chkpt.Usage) > 0 {
+				e.ledger.Restore(chkpt.Usage)
+			}
+		}
+	}
+
+	if err := e.ledger.CheckBudget(); err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.ledger.setOnExceeded(func() { cancel(ErrBudgetExceeded) })
+	defer e.ledger.setOnExceeded(nil)
+
+	var mu sync.Mutex
+	completed := 0
+	last := ""
+	for i, wave := range dag.waves {
+		if completed+len(wave) <= startPhase {
+			completed += len(wave)
+			last = wave[len(wave)-1].Name()
+			continue
+		}
+
+		if err := e.executeWave(runCtx, dag, wave, request, sessionID, outputs, &mu); err != nil {
+			if errors.Is(context.Cause(runCtx), ErrBudgetExceeded) {
+				// The whole wave reruns on resume, fed by the outputs of the waves before it
+				budgetErr := e.ledger.CheckBudget()
+				e.logger.Warn("budget exhausted, stopping mid-wave", "wave", i+1, "phases", waveNames(wave), "error", budgetErr)
+				if checkpoint != nil && ctx.Err() == nil {
+					e.saveWaveCheckpoint(ctx, checkpoint, sessionID, request, completed, strings.Join(waveNames(wave), "+"), outputs, outputs[last])
+				}
+				return fmt.Errorf("phase %s interrupted: %w", wave[0].Name(), budgetErr)
+			}
+			return err
+		}
+		completed += len(wave)
+		last = wave[len(wave)-1].Name()
+		e.logger.Info("wave completed", "wave", i+1, "phases", waveNames(wave))
+
+		if checkpoint != nil {
+			e.saveWaveCheckpoint(ctx, checkpoint, sessionID, request, completed, strings.Join(waveNames(wave), "+"), outputs, outputs[last])
+		}
+
+		if err := e.ledger.CheckBudget(); err != nil && i+1 < len(dag.waves) {
+			e.logger.Warn("budget exhausted, stopping after wave", "wave", i+1, "error", err)
+			return err
+		}
+	}
+
+	e.logger.Info("orchestration completed successfully", "session", sessionID)
+	return nil
+}
+
+// saveWaveCheckpoint saves every phase output so far, resuming at phaseIndex
+func (e *ExecutionEngine) saveWaveCheckpoint(ctx context.Context, checkpoint *CheckpointManager, sessionID, request string, phaseIndex int, phaseName string, outputs map[string]interface{}, data interface{}) {
+	phaseStates := make(map[string]any, len(outputs))
+	for name, output := range outputs {
+		phaseStates[name] = output
+	}
+	if err := checkpoint.SaveCheckpoint(ctx, &Checkpoint{
+		ID:          sessionID,
+		PhaseIndex:  phaseIndex,
+		PhaseName:   phaseName,
+		Timestamp:   time.Now(),
+		State:       map[string]any{"data": data},
+		PhaseStates: phaseStates,
+		Request:     request,
+	}); err != nil {
+		e.logger.Warn("failed to save checkpoint", "error", err)
+	}
+}
+
+// executeWave runs one wave and stores each phase's output under its name
+func (e *ExecutionEngine) executeWave(ctx context.Context, dag *PhaseDAG, wave []Phase, request string, sessionID string, outputs map[string]interface{}, mu *sync.Mutex) error {
+	run := func(ctx context.Context, phase Phase) error {
+		mu.Lock()
+		input := PhaseOutput{Data: dag.inputFor(phase.Name(), outputs)}
+		mu.Unlock()
+
+		output, err := e.executeDAGPhase(ContextWithUsageScope(ctx, e.ledger, phase.Name()), phase, request, input, sessionID)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		outputs[phase.Name()] = output.Data
+		mu.Unlock()
+		return nil
+	}
+
+	var concurrent []Phase
+	for _, phase := range wave {
+		if dag.parallel[phase.Name()] && len(wave) > 1 {
+			concurrent = append(concurrent, phase)
+			continue
+		}
+		if err := run(ctx, phase); err != nil {
+			return err
+		}
+	}
+	if len(concurrent) == 0 {
+		return nil
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(e.concurrencyLimit())
+	for _, phase := range concurrent {
+		phase := phase
+		g.Go(func() error { return run(gctx, phase) })
+	}
+	return g.Wait()
+}
+
+// executeDAGPhase runs a phase with retries and validation, consulting the result cache
+// when performance optimization is enabled
+func (e *ExecutionEngine) executeDAGPhase(ctx context.Context, phase Phase, request string, input PhaseOutput, sessionID string) (PhaseOutput, error) {
+	cacheKey := PhaseInput{Request: request, Data: input.Data, SessionID: sessionID}
+	if e.enableCache && e.resultCache != nil {
+		if cached, found := e.resultCache.Get(ctx, phase.Name(), cacheKey); found {
+			e.logger.Debug("cache hit", "phase", phase.Name())
+			return cached, nil
+		}
+	}
+
+	output := input
+	if err := e.executePhaseWithRetry(ctx, phase, request, &output, sessionID); err != nil {
+		return PhaseOutput{}, err
+	}
+
+	if e.enableCache && e.resultCache != nil {
+		e.resultCache.Set(ctx, phase.Name(), cacheKey, output)
+	}
+	return output, nil
+}
+
+func (e *ExecutionEngine) concurrencyLimit() int {
+	if e.executor != nil && e.executor.maxConcurrency > 0 {
+		return e.executor.maxConcurrency
+	}
+	return runtime.NumCPU()
+}
+
+func waveNames(wave []Phase) []string {
+	names := make([]string, len(wave))
+	for i, p := range wave {
+		names[i] = p.Name()
+	}
+	return names
+}
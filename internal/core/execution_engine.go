@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -14,6 +15,8 @@ type ExecutionEngine struct {
 	validationLogger *ValidationLogger
 	enableCache      bool
 	maxRetries       int
+	ledger           *TokenLedger
+	schedule         []PhaseSchedule
 	logger           *slog.Logger
 }
 
@@ -22,10 +25,28 @@ func NewExecutionEngine(logger *slog.Logger, maxRetries int) *ExecutionEngine {
 	return &ExecutionEngine{
 		validationLogger: NewValidationLogger(),
 		maxRetries:       maxRetries,
+		ledger:           NewTokenLedger(Budget{}),
 		logger:           logger,
 	}
 }
 
+// WithBudget limits the tokens and cost the execution may consume
+func (e *ExecutionEngine) WithBudget(budget Budget) *ExecutionEngine {
+	e.ledger = NewTokenLedger(budget)
+	return e
+}
+
+// WithSchedule sets the phase ordering and parallelism used to build the execution DAG
+func (e *ExecutionEngine) WithSchedule(schedule []PhaseSchedule) *ExecutionEngine {
+	e.schedule = schedule
+	return e
+}
+
+// Ledger returns the token ledger that records usage for this execution
+func (e *ExecutionEngine) Ledger() *TokenLedger {
+	return e.ledger
+}
+
 // WithPerformanceOptimization enables caching and parallel execution
 func (e *ExecutionEngine) WithPerformanceOptimization(enabled bool) *ExecutionEngine {
 	if enabled {
@@ -67,12 +88,12 @@ func (e *ExecutionEngine) executeOptimized(ctx context.Context, phases []Phase,
 		"cache_enabled", e.enableCache)
 	
 	// For sequential phases, use standard flow with caching
-	if len(phases) <= 2 {
+	if len(phases) <= 2 && startPhase == 0 {
 		return e.runOptimizedSequential(ctx, phases, request, sessionID)
 	}
 	
 	// For many phases, use parallel execution where possible
-	return e.runOptimizedParallel(ctx, phases, request, sessionID)
+	return e.runOptimizedParallel(ctx, phases, request, sessionID, startPhase, checkpoint)
 }
 
 // runOptimizedSequential handles sequential execution with caching
@@ -87,23 +108,30 @@ func (e *ExecutionEngine) runOptimizedSequential(ctx context.Context, phases []P
 			Metadata:  map[string]interface{}{"phase_index": i},
 		}
 		
-		output, err := e.executePhaseOptimized(ctx, phase, input)
+		output, err := e.executePhaseOptimized(ContextWithUsageScope(ctx, e.ledger, phase.Name()), phase, input)
 		if err != nil {
 			return NewPhaseError(phase.Name(), 1, err, output.Data)
 		}
 		
 		lastOutput = output
 		e.logger.Info("phase completed", "name", phase.Name())
+		
+		if err := e.ledger.CheckBudget(); err != nil && i+1 < len(phases) {
+			return err
+		}
 	}
 	
 	return nil
 }
 
-// runOptimizedParallel handles parallel execution for independent phases
-func (e *ExecutionEngine) runOptimizedParallel(ctx context.Context, phases []Phase, request string, sessionID string) error {
-	// For now, fall back to sequential since phases typically depend on each other
-	// In future, could analyze phase dependencies for true parallel execution
-	return e.runOptimizedSequential(ctx, phases, request, sessionID)
+// runOptimizedParallel runs independent phases concurrently, following the DAG built
+// from the engine's schedule
+func (e *ExecutionEngine) runOptimizedParallel(ctx context.Context, phases []Phase, request string, sessionID string, startPhase int, checkpoint *CheckpointManager) error {
+	dag, err := BuildPhaseDAG(phases, e.schedule)
+	if err != nil {
+		return fmt.Errorf("building phase graph: %w", err)
+	}
+	return e.ExecuteDAG(ctx, dag, request, sessionID, startPhase, checkpoint)
 }
 
 // executeStandard handles standard execution with retry logic
@@ -120,14 +148,34 @@ func (e *ExecutionEngine) executeStandard(ctx context.Context, phases []Phase, r
 		if err == nil && chkpt.State != nil {
 			if data, ok := chkpt.State["last_output"]; ok {
 				lastOutput.Data = data
+			} else if data, ok := chkpt.State["data"]; ok {
+				// CheckpointManager.Save stores phase output under "data"
+				lastOutput.Data = data
 			}
 		}
+		if err == nil && len(chkpt.Usage) > 0 {
+			e.ledger.Restore(chkpt.Usage)
+		}
 	}
 	
+	if err := e.ledger.CheckBudget(); err != nil {
+		return err
+	}
+	
+	// Cancel in-flight requests as soon as the ledger crosses the budget
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.ledger.setOnExceeded(func() { cancel(ErrBudgetExceeded) })
+	defer e.ledger.setOnExceeded(nil)
+	
 	for i := startPhase; i < len(phases); i++ {
 		phase := phases[i]
 		
-		if err := e.executePhaseWithRetry(ctx, phase, request, &lastOutput, sessionID); err != nil {
+		phaseCtx := ContextWithUsageScope(runCtx, e.ledger, phase.Name())
+		if err := e.executePhaseWithRetry(phaseCtx, phase, request, &lastOutput, sessionID); err != nil {
+			if errors.Is(context.Cause(runCtx), ErrBudgetExceeded) {
+				return e.stopForBudget(ctx, checkpoint, sessionID, i, phase.Name(), lastOutput)
+			}
 			return err
 		}
 		
@@ -144,12 +192,31 @@ func (e *ExecutionEngine) executeStandard(ctx context.Context, phases []Phase, r
 				}
 			}
 		}
+		
+		if err := e.ledger.CheckBudget(); err != nil && i+1 < len(phases) {
+			e.logger.Warn("budget exhausted, stopping after phase", "phase", phase.Name(), "error", err)
+			return err
+		}
 	}
 	
 	e.logger.Info("orchestration completed successfully", "session", sessionID)
 	return nil
 }
 
+// stopForBudget checkpoints the session so the interrupted phase reruns on resume
+func (e *ExecutionEngine) stopForBudget(ctx context.Context, checkpoint *CheckpointManager, sessionID string, phaseIndex int, phaseName string, lastOutput PhaseOutput) error {
+	budgetErr := e.ledger.CheckBudget()
+	e.logger.Warn("budget exhausted, stopping mid-phase", "phase", phaseName, "error", budgetErr)
+	
+	if checkpoint != nil && ctx.Err() == nil {
+		if err := checkpoint.Save(ctx, sessionID, phaseIndex, phaseName, lastOutput.Data); err != nil {
+			e.logger.Warn("failed to save checkpoint", "error", err)
+		}
+	}
+	
+	return fmt.Errorf("phase %s interrupted: %w", phaseName, budgetErr)
+}
+
 // executePhaseOptimized uses caching and performance optimizations
 func (e *ExecutionEngine) executePhaseOptimized(ctx context.Context, phase Phase, input PhaseInput) (PhaseOutput, error) {
 	// Check cache first if enabled
@@ -245,5 +312,5 @@ func (e *ExecutionEngine) GetValidationReport() string {
 	if e.validationLogger == nil {
 		return "No validation logger available"
 	}
-	return e.validationLogger.GetValidationReport()
+	return e.validationLogger.GetValidationReport() + "\n" + e.ledger.Report()
 }
\ No newline at end of file
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -33,6 +35,65 @@ type Agent interface {
 	ExecuteJSON(ctx context.Context, prompt string, input any) (string, error)
 }
 
+// StreamingAgent is implemented by agents that can deliver output incrementally.
+// Phases type-assert for it and fall back to Execute when it is unavailable.
+type StreamingAgent interface {
+	Agent
+	ExecuteStream(ctx context.Context, prompt string, input any, onChunk func(chunk string) error) (string, error)
+}
+
+// StructuredAgent is implemented by agents that can decode a response straight into a
+// Go value, validating it against a schema derived from out and repairing mismatches.
+// Phases type-assert for it and fall back to ExecuteJSON when it is unavailable.
+type StructuredAgent interface {
+	Agent
+	ExecuteInto(ctx context.Context, prompt string, input any, out any) error
+}
+
+// BatchAgent is implemented by agents that can send many prompts as one asynchronous
+// provider batch, which is slower but cheaper than individual calls. SubmitBatch takes
+// prompts keyed by caller-chosen IDs and returns a batch ID; WaitBatch blocks until the
+// batch ends and returns the responses that succeeded under the same keys. Phases
+// fall back to Execute for prompts missing from the result or when SubmitBatch fails.
+type BatchAgent interface {
+	Agent
+	SubmitBatch(ctx context.Context, prompts map[string]string) (string, error)
+	WaitBatch(ctx context.Context, batchID string) (map[string]string, error)
+}
+
+// ConversationalAgent is implemented by agents that can send a multi-turn conversation
+// as real messages. history holds the earlier messages, alternating user and assistant
+// and starting with the user; prompt is the next user message. Phases keep the history
+// themselves and fall back to Execute when it is unavailable.
+type ConversationalAgent interface {
+	Agent
+	ExecuteTurn(ctx context.Context, history []string, prompt string) (string, error)
+}
+
+// Tool is a function the model may call while answering a ToolAgent request. Args is
+// a zero value of the struct the arguments decode into, from which the agent derives
+// their JSON schema; nil means the tool takes no arguments. Handler receives the
+// arguments as JSON, and an error it returns is reported to the model.
+type Tool struct {
+	Name        string
+	Description string
+	Args        any
+	Handler     func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// ErrToolsUnsupported is returned by ToolAgent.ExecuteWithTools when the provider has
+// no tool calling API
+var ErrToolsUnsupported = errors.New("tool calling not supported")
+
+// ToolAgent is implemented by agents that can let the model call tools, such as
+// reading earlier output on demand instead of receiving all of it in the prompt.
+// Phases fall back to Execute with the context inlined when it is unavailable or
+// returns ErrToolsUnsupported.
+type ToolAgent interface {
+	Agent
+	ExecuteWithTools(ctx context.Context, prompt string, input any, tools []Tool) (string, error)
+}
+
 type Storage interface {
 	Save(ctx context.Context, path string, data []byte) error
 	Load(ctx context.Context, path string) ([]byte, error)
@@ -41,6 +102,23 @@ type Storage interface {
 	Delete(ctx context.Context, path string) error
 }
 
+// Transactor is implemented by storage that can commit several writes together. fn
+// runs with a context carrying the transaction: storage calls made with it, including
+// nested WithinTx calls, join the transaction, which commits when fn returns nil and
+// rolls back otherwise.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithinTx runs fn in a transaction when storage is a Transactor. Other storage runs
+// fn directly, so each write stands alone.
+func WithinTx(ctx context.Context, storage Storage, fn func(ctx context.Context) error) error {
+	if tx, ok := storage.(Transactor); ok {
+		return tx.InTx(ctx, fn)
+	}
+	return fn(ctx)
+}
+
 type DomainValidator interface {
 	ValidateInput(input interface{}) error
 	ValidateOutput(output interface{}) error
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned when a session has used up its token or cost budget
+var ErrBudgetExceeded = errors.New("session budget exceeded")
+
+// ModelPrice is the price in USD per million input and output tokens
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// DefaultModelPricing lists published list prices for the models the config accepts.
+// Models not listed are still counted in tokens but contribute zero cost.
+var DefaultModelPricing = map[string]ModelPrice{
+	"claude-3-5-sonnet-20241022": {InputPerMillion: 3, OutputPerMillion: 15},
+	"claude-3-opus-20240229":     {InputPerMillion: 15, OutputPerMillion: 75},
+	"gpt-4":                      {InputPerMillion: 30, OutputPerMillion: 60},
+	"gpt-4-turbo":                {InputPerMillion: 10, OutputPerMillion: 30},
+	"gpt-4-turbo-preview":        {InputPerMillion: 10, OutputPerMillion: 30},
+	"gpt-4.1":                    {InputPerMillion: 2, OutputPerMillion: 8},
+	"gpt-4o-mini":                {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+}
+
+// BatchDiscount is the share of the list price charged for requests sent through a
+// provider batch API; Anthropic and OpenAI both bill batches at half price
+const BatchDiscount = 0.5
+
+// Budget caps a session's spend. Zero values mean unlimited.
+type Budget struct {
+	MaxTokens  int
+	MaxCostUSD float64
+}
+
+// UsageEntry aggregates requests sharing the same phase, agent role and model
+type UsageEntry struct {
+	Phase        string  `json:"phase"`
+	Role         string  `json:"role,omitempty"`
+	Model        string  `json:"model"`
+	Requests     int     `json:"requests"`
+	Batched      int     `json:"batched,omitempty"` // Requests billed at BatchDiscount
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// TotalTokens returns input plus output tokens
+func (e UsageEntry) TotalTokens() int {
+	return e.InputTokens + e.OutputTokens
+}
+
+// TokenLedger records token usage and estimated cost for a session
+type TokenLedger struct {
+	mu         sync.Mutex
+	entries    map[string]*UsageEntry
+	pricing    map[string]ModelPrice
+	budget     Budget
+	onExceeded func()
+}
+
+// NewTokenLedger creates a ledger enforcing budget using DefaultModelPricing
+func NewTokenLedger(budget Budget) *TokenLedger {
+	return &TokenLedger{
+		entries: make(map[string]*UsageEntry),
+		pricing: DefaultModelPricing,
+		budget:  budget,
+	}
+}
+
+// Record adds one request's usage to the ledger. If this pushes the session over
+// budget, the exceeded callback fires so in-flight work can be stopped.
+func (l *TokenLedger) Record(phase, role, model string, inputTokens, outputTokens int) {
+	l.record(phase, role, model, inputTokens, outputTokens, false)
+}
+
+// RecordBatch adds the usage of a request answered by a provider batch, priced at
+// BatchDiscount
+func (l *TokenLedger) RecordBatch(phase, role, model string, inputTokens, outputTokens int) {
+	l.record(phase, role, model, inputTokens, outputTokens, true)
+}
+
+func (l *TokenLedger) record(phase, role, model string, inputTokens, outputTokens int, batch bool) {
+	l.mu.Lock()
+
+	key := phase + "|" + role + "|" + model
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &UsageEntry{Phase: phase, Role: role, Model: model}
+		l.entries[key] = entry
+	}
+
+	price := l.pricing[model]
+	cost := float64(inputTokens)*price.InputPerMillion/1e6 + float64(outputTokens)*price.OutputPerMillion/1e6
+	if batch {
+		cost *= BatchDiscount
+		entry.Batched++
+	}
+	entry.Requests++
+	entry.InputTokens += inputTokens
+	entry.OutputTokens += outputTokens
+	entry.CostUSD += cost
+
+	exceeded := l.checkLocked() != nil
+	onExceeded := l.onExceeded
+	l.mu.Unlock()
+
+	if exceeded && onExceeded != nil {
+		onExceeded()
+	}
+}
+
+// Totals returns the session-wide token count and estimated cost
+func (l *TokenLedger) Totals() (tokens int, costUSD float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.totalsLocked()
+}
+
+func (l *TokenLedger) totalsLocked() (tokens int, costUSD float64) {
+	for _, e := range l.entries {
+		tokens += e.TotalTokens()
+		costUSD += e.CostUSD
+	}
+	return tokens, costUSD
+}
+
+// CheckBudget returns ErrBudgetExceeded if the session has used its budget
+func (l *TokenLedger) CheckBudget() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkLocked()
+}
+
+func (l *TokenLedger) checkLocked() error {
+	tokens, cost := l.totalsLocked()
+	if l.budget.MaxTokens > 0 && tokens >= l.budget.MaxTokens {
+		return fmt.Errorf("%w: %d of %d tokens used", ErrBudgetExceeded, tokens, l.budget.MaxTokens)
+	}
+	if l.budget.MaxCostUSD > 0 && cost >= l.budget.MaxCostUSD {
+		return fmt.Errorf("%w: $%.4f of $%.2f spent", ErrBudgetExceeded, cost, l.budget.MaxCostUSD)
+	}
+	return nil
+}
+
+// setOnExceeded registers the callback fired when Record crosses the budget
+func (l *TokenLedger) setOnExceeded(fn func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onExceeded = fn
+}
+
+// Entries returns a copy of the ledger sorted by phase, role and model
+func (l *TokenLedger) Entries() []UsageEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]UsageEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Phase != entries[j].Phase {
+			return entries[i].Phase < entries[j].Phase
+		}
+		if entries[i].Role != entries[j].Role {
+			return entries[i].Role < entries[j].Role
+		}
+		return entries[i].Model < entries[j].Model
+	})
+	return entries
+}
+
+// Restore replaces the ledger contents with entries saved in a checkpoint
+func (l *TokenLedger) Restore(entries []UsageEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = make(map[string]*UsageEntry, len(entries))
+	for i := range entries {
+		e := entries[i]
+		l.entries[e.Phase+"|"+e.Role+"|"+e.Model] = &e
+	}
+}
+
+// Report renders the ledger as a human readable table
+func (l *TokenLedger) Report() string {
+	entries := l.Entries()
+	tokens, cost := l.Totals()
+
+	var b strings.Builder
+	b.WriteString("Token Usage Report\n")
+	b.WriteString("==================\n")
+	for _, e := range entries {
+		role := e.Role
+		if role == "" {
+			role = "-"
+		}
+		fmt.Fprintf(&b, "%-16s %-14s %-28s %4d req %9d in %9d out  $%.4f\n",
+			e.Phase, role, e.Model, e.Requests, e.InputTokens, e.OutputTokens, e.CostUSD)
+	}
+	fmt.Fprintf(&b, "Total: %d tokens, $%.4f", tokens, cost)
+	if l.budget.MaxTokens > 0 {
+		fmt.Fprintf(&b, " (token budget %d)", l.budget.MaxTokens)
+	}
+	if l.budget.MaxCostUSD > 0 {
+		fmt.Fprintf(&b, " (cost budget $%.2f)", l.budget.MaxCostUSD)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+type usageScopeKey struct{}
+
+type usageScope struct {
+	ledger *TokenLedger
+	phase  string
+}
+
+// ContextWithUsageScope attributes usage recorded under ctx to phase in ledger
+func ContextWithUsageScope(ctx context.Context, ledger *TokenLedger, phase string) context.Context {
+	if ledger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, usageScopeKey{}, usageScope{ledger: ledger, phase: phase})
+}
+
+// RecordUsage adds usage to the ledger attached to ctx, if any. AI clients call this
+// from their usage hook so that requests are attributed to the running phase.
+func RecordUsage(ctx context.Context, role, model string, inputTokens, outputTokens int) {
+	scope, ok := ctx.Value(usageScopeKey{}).(usageScope)
+	if !ok {
+		return
+	}
+	scope.ledger.Record(scope.phase, role, model, inputTokens, outputTokens)
+}
+
+// RecordBatchUsage is RecordUsage for a request answered by a provider batch
+func RecordBatchUsage(ctx context.Context, role, model string, inputTokens, outputTokens int) {
+	scope, ok := ctx.Value(usageScopeKey{}).(usageScope)
+	if !ok {
+		return
+	}
+	scope.ledger.RecordBatch(scope.phase, role, model, inputTokens, outputTokens)
+}
+
+// CheckUsageBudget returns ErrBudgetExceeded if the ledger attached to ctx has used
+// its budget. Requests made outside a usage scope are never refused.
+func CheckUsageBudget(ctx context.Context) error {
+	scope, ok := ctx.Value(usageScopeKey{}).(usageScope)
+	if !ok {
+		return nil
+	}
+	return scope.ledger.CheckBudget()
+}
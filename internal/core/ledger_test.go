@@ -0,0 +1,30 @@
+package core_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/core"
+)
+
+func TestLedgerPricesBatchesAtDiscount(t *testing.T) {
+	ledger := core.NewTokenLedger(core.Budget{})
+	ctx := core.ContextWithUsageScope(context.Background(), ledger, "Writing")
+
+	// claude-3-5-sonnet lists at $3 in and $15 out per million tokens
+	core.RecordUsage(ctx, "writer", "claude-3-5-sonnet-20241022", 1_000_000, 0)
+	core.RecordBatchUsage(ctx, "writer", "claude-3-5-sonnet-20241022", 1_000_000, 1_000_000)
+
+	entries := ledger.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want one for the phase, role and model", entries)
+	}
+	e := entries[0]
+	if e.Requests != 2 || e.Batched != 1 || e.TotalTokens() != 3_000_000 {
+		t.Errorf("entry = %+v, want 2 requests, 1 batched, 3M tokens", e)
+	}
+	if want := 3 + (3+15)*core.BatchDiscount; math.Abs(e.CostUSD-want) > 1e-9 {
+		t.Errorf("cost = $%.4f, want $%.4f", e.CostUSD, want)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	
 	"github.com/google/uuid"
@@ -13,6 +14,8 @@ type OrchestratorConfig struct {
 	MaxRetries          int
 	PerformanceEnabled  bool
 	MaxConcurrency      int
+	Budget              Budget // Token/cost limits; zero means unlimited
+	Schedule            []PhaseSchedule // Phase ordering and parallelism, usually from a plugin manifest
 }
 
 // DefaultConfig returns sensible defaults
@@ -38,12 +41,12 @@ type Option func(*Orchestrator)
 
 func WithConfig(config OrchestratorConfig) Option {
 	return func(o *Orchestrator) {
+		o.engine = NewExecutionEngine(o.logger, config.MaxRetries).WithBudget(config.Budget).WithSchedule(config.Schedule)
+		
 		if config.CheckpointingEnabled {
-			o.checkpoint = NewCheckpointManager(o.storage)
+			o.checkpoint = NewCheckpointManager(o.storage).WithLedger(o.engine.Ledger())
 		}
 		
-		o.engine = NewExecutionEngine(o.logger, config.MaxRetries)
-		
 		if config.PerformanceEnabled {
 			if config.MaxConcurrency > 0 {
 				o.engine.WithCustomConcurrency(config.MaxConcurrency)
@@ -78,7 +81,10 @@ func (o *Orchestrator) WithLogger(logger *slog.Logger) *Orchestrator {
 	o.logger = logger
 	// Update engine with new logger if it exists
 	if o.engine != nil {
+		ledger, schedule := o.engine.Ledger(), o.engine.schedule
 		o.engine = NewExecutionEngine(logger, 3) // Use default retry count
+		o.engine.ledger = ledger
+		o.engine.schedule = schedule
 	}
 	return o
 }
@@ -96,15 +102,34 @@ func (o *Orchestrator) Run(ctx context.Context, request string) error {
 	return o.RunWithResume(ctx, request, 0)
 }
 
-// RunOptimized executes phases with performance optimizations enabled
+// RunOptimized executes phases as a dependency graph, running independent phases
+// concurrently and checkpointing after each wave
 func (o *Orchestrator) RunOptimized(ctx context.Context, request string) error {
-	return o.engine.ExecutePhases(ctx, o.phases, request, o.sessionID, 0, o.checkpoint)
+	return o.RunGraphWithResume(ctx, request, 0)
+}
+
+// RunGraphWithResume runs the phase graph built from the configured schedule, skipping
+// the first startPhase phases of its execution order
+func (o *Orchestrator) RunGraphWithResume(ctx context.Context, request string, startPhase int) error {
+	dag, err := BuildPhaseDAG(o.phases, o.engine.schedule)
+	if err != nil {
+		return fmt.Errorf("building phase graph: %w", err)
+	}
+	return o.engine.ExecuteDAG(ctx, dag, request, o.sessionID, startPhase, o.checkpoint)
 }
 
 func (o *Orchestrator) RunWithResume(ctx context.Context, request string, startPhase int) error {
 	return o.engine.ExecutePhases(ctx, o.phases, request, o.sessionID, startPhase, o.checkpoint)
 }
 
+// Usage returns the session's token ledger
+func (o *Orchestrator) Usage() *TokenLedger {
+	if o.engine == nil {
+		return nil
+	}
+	return o.engine.Ledger()
+}
+
 // GetValidationReport returns the validation report for the session
 func (o *Orchestrator) GetValidationReport() string {
 	if o.engine == nil {
@@ -29,6 +29,7 @@ type PhaseNode struct {
 	Status      PhaseStatus
 	Priority    float64
 	CanParallel bool
+	DependsOn   []string
 	Conditions  []PhaseCondition
 	Results     interface{}
 }
@@ -78,6 +79,9 @@ func (pf *PhaseFlow) RegisterPhase(phase Phase, opts ...PhaseOption) {
 
 	pf.phases[phase.Name()] = phase
 	pf.graph.nodes[phase.Name()] = node
+	if len(node.DependsOn) > 0 {
+		pf.graph.edges[phase.Name()] = append(pf.graph.edges[phase.Name()], node.DependsOn...)
+	}
 }
 
 // PhaseOption configures phase behavior
@@ -86,8 +90,7 @@ type PhaseOption func(*PhaseNode)
 // WithDependencies sets phase dependencies
 func WithDependencies(deps ...string) PhaseOption {
 	return func(n *PhaseNode) {
-		// Dependencies will be validated at execution time
-		// deps parameter is available for future use
+		n.DependsOn = append(n.DependsOn, deps...)
 	}
 }
 
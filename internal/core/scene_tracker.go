@@ -14,6 +14,8 @@ type SceneProgress struct {
 	TotalScenes     int                   `json:"total_scenes"`
 	CompletedScenes map[string]SceneResult `json:"completed_scenes"` // Key: "chapter_X_scene_Y"
 	FailedScenes    map[string]SceneError  `json:"failed_scenes"`
+	PartialScenes   map[string]SceneResult `json:"partial_scenes,omitempty"` // In-flight streamed text
+	BatchID         string                 `json:"batch_id,omitempty"`       // Provider batch writing pending scenes
 	StartTime       time.Time             `json:"start_time"`
 	LastUpdate      time.Time             `json:"last_update"`
 }
@@ -51,6 +53,7 @@ func NewAtomicSceneTracker(storage Storage, sessionID string, totalScenes int) *
 			TotalScenes:     totalScenes,
 			CompletedScenes: make(map[string]SceneResult),
 			FailedScenes:    make(map[string]SceneError),
+			PartialScenes:   make(map[string]SceneResult),
 			StartTime:       time.Now(),
 			LastUpdate:      time.Now(),
 		},
@@ -73,14 +76,17 @@ func (t *AtomicSceneTracker) LoadProgress(ctx context.Context) error {
 		return fmt.Errorf("parsing progress: %w", err)
 	}
 
+	if progress.PartialScenes == nil {
+		progress.PartialScenes = make(map[string]SceneResult)
+	}
 	t.progress = &progress
 	return nil
 }
 
-func (t *AtomicSceneTracker) saveProgress(ctx context.Context) error {
-	t.progress.LastUpdate = time.Now()
+func (t *AtomicSceneTracker) saveProgress(ctx context.Context, progress *SceneProgress) error {
+	progress.LastUpdate = time.Now()
 	
-	data, err := json.MarshalIndent(t.progress, "", "  ")
+	data, err := json.MarshalIndent(progress, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling progress: %w", err)
 	}
@@ -89,50 +95,162 @@ func (t *AtomicSceneTracker) saveProgress(ctx context.Context) error {
 	return t.storage.Save(ctx, progressFile, data)
 }
 
-func (t *AtomicSceneTracker) MarkCompleted(ctx context.Context, chapterNum, sceneNum int, content string) error {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// update applies fn to a copy of the progress and saves it, and only then makes
+// it the tracker's progress, so a failed write leaves memory agreeing with storage.
+// The tracker's writers take the transaction before the lock so they can't
+// deadlock a caller's.
+func (t *AtomicSceneTracker) update(ctx context.Context, fn func(ctx context.Context, progress *SceneProgress) error) error {
+	return WithinTx(ctx, t.storage, func(ctx context.Context) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		next := t.progress.clone()
+		if err := fn(ctx, next); err != nil {
+			return err
+		}
+		if err := t.saveProgress(ctx, next); err != nil {
+			return err
+		}
+		t.progress = next
+		return nil
+	})
+}
 
-	sceneKey := fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
-	
-	// Save scene content to individual file
-	sceneFile := fmt.Sprintf("scenes/chapter_%d_scene_%d.txt", chapterNum, sceneNum)
-	if err := t.storage.Save(ctx, sceneFile, []byte(content)); err != nil {
-		return fmt.Errorf("saving scene content: %w", err)
+// clone copies the progress deeply enough for update to change the copy
+func (p *SceneProgress) clone() *SceneProgress {
+	c := *p
+	c.CompletedScenes = make(map[string]SceneResult, len(p.CompletedScenes))
+	for k, v := range p.CompletedScenes {
+		c.CompletedScenes[k] = v
+	}
+	c.FailedScenes = make(map[string]SceneError, len(p.FailedScenes))
+	for k, v := range p.FailedScenes {
+		c.FailedScenes[k] = v
+	}
+	c.PartialScenes = make(map[string]SceneResult, len(p.PartialScenes))
+	for k, v := range p.PartialScenes {
+		c.PartialScenes[k] = v
 	}
+	return &c
+}
+
+// WithinTx runs fn in a storage transaction that the tracker's writes join. If fn
+// fails, and the transaction with it, the tracker's progress goes back to what it
+// was, so memory doesn't count scenes that storage rolled back.
+func (t *AtomicSceneTracker) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.RLock()
+	before := t.progress
+	t.mu.RUnlock()
 
-	// Update progress tracking
-	t.progress.CompletedScenes[sceneKey] = SceneResult{
-		ChapterNum:  chapterNum,
-		SceneNum:    sceneNum,
-		Content:     content,
-		CompletedAt: time.Now(),
+	err := WithinTx(ctx, t.storage, fn)
+	if err != nil {
+		t.mu.Lock()
+		t.progress = before
+		t.mu.Unlock()
 	}
+	return err
+}
 
-	// Remove from failed scenes if it was there
-	delete(t.progress.FailedScenes, sceneKey)
+// MarkCompleted stores the scene's text and records it as done. On transactional
+// storage the scene file and the progress update commit together.
+func (t *AtomicSceneTracker) MarkCompleted(ctx context.Context, chapterNum, sceneNum int, content string) error {
+	return t.update(ctx, func(ctx context.Context, progress *SceneProgress) error {
+		sceneKey := fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
+		
+		// Save scene content to individual file
+		sceneFile := fmt.Sprintf("scenes/chapter_%d_scene_%d.txt", chapterNum, sceneNum)
+		if err := t.storage.Save(ctx, sceneFile, []byte(content)); err != nil {
+			return fmt.Errorf("saving scene content: %w", err)
+		}
+
+		// Update progress tracking
+		progress.CompletedScenes[sceneKey] = SceneResult{
+			ChapterNum:  chapterNum,
+			SceneNum:    sceneNum,
+			Content:     content,
+			CompletedAt: time.Now(),
+		}
+
+		// Remove from failed scenes if it was there
+		delete(progress.FailedScenes, sceneKey)
+		
+		// Drop any streamed partial now that the full scene is stored
+		if _, streamed := progress.PartialScenes[sceneKey]; streamed {
+			delete(progress.PartialScenes, sceneKey)
+			partialFile := fmt.Sprintf("scenes/chapter_%d_scene_%d.partial.txt", chapterNum, sceneNum)
+			_ = t.storage.Delete(ctx, partialFile)
+		}
+		return nil
+	})
+}
 
-	// Persist progress atomically
-	return t.saveProgress(ctx)
+// SavePartial persists text streamed so far for a scene that is still being generated,
+// so a crash or timeout mid-scene leaves the partial output on disk
+func (t *AtomicSceneTracker) SavePartial(ctx context.Context, chapterNum, sceneNum int, content string) error {
+	return t.update(ctx, func(ctx context.Context, progress *SceneProgress) error {
+		sceneKey := fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
+		if _, done := progress.CompletedScenes[sceneKey]; done {
+			return nil
+		}
+
+		partialFile := fmt.Sprintf("scenes/chapter_%d_scene_%d.partial.txt", chapterNum, sceneNum)
+		if err := t.storage.Save(ctx, partialFile, []byte(content)); err != nil {
+			return fmt.Errorf("saving partial scene: %w", err)
+		}
+
+		progress.PartialScenes[sceneKey] = SceneResult{
+			ChapterNum:  chapterNum,
+			SceneNum:    sceneNum,
+			Content:     content,
+			CompletedAt: time.Now(),
+		}
+		return nil
+	})
 }
 
-func (t *AtomicSceneTracker) MarkFailed(ctx context.Context, chapterNum, sceneNum int, attempt int, err error, retryable bool) error {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// GetPartialScenes returns streamed but unfinished scene text keyed like completed scenes
+func (t *AtomicSceneTracker) GetPartialScenes() map[string]SceneResult {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	sceneKey := fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
-	
-	t.progress.FailedScenes[sceneKey] = SceneError{
-		ChapterNum: chapterNum,
-		SceneNum:   sceneNum,
-		Attempt:    attempt,
-		Error:      err.Error(),
-		Timestamp:  time.Now(),
-		Retryable:  retryable,
+	result := make(map[string]SceneResult)
+	for k, v := range t.progress.PartialScenes {
+		result[k] = v
 	}
+	return result
+}
 
-	// Persist progress atomically
-	return t.saveProgress(ctx)
+// SetBatchID records the provider batch writing the pending scenes so a resumed run
+// collects its results instead of submitting the scenes again. An empty ID clears it.
+func (t *AtomicSceneTracker) SetBatchID(ctx context.Context, batchID string) error {
+	return t.update(ctx, func(ctx context.Context, progress *SceneProgress) error {
+		progress.BatchID = batchID
+		return nil
+	})
+}
+
+// BatchID returns the batch recorded by SetBatchID, or "" if none is pending
+func (t *AtomicSceneTracker) BatchID() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.progress.BatchID
+}
+
+func (t *AtomicSceneTracker) MarkFailed(ctx context.Context, chapterNum, sceneNum int, attempt int, err error, retryable bool) error {
+	return t.update(ctx, func(ctx context.Context, progress *SceneProgress) error {
+		sceneKey := fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
+		
+		progress.FailedScenes[sceneKey] = SceneError{
+			ChapterNum: chapterNum,
+			SceneNum:   sceneNum,
+			Attempt:    attempt,
+			Error:      err.Error(),
+			Timestamp:  time.Now(),
+			Retryable:  retryable,
+		}
+		return nil
+	})
 }
 
 func (t *AtomicSceneTracker) GetProgress() (completed, failed, total int) {
@@ -0,0 +1,58 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/core"
+)
+
+// progressFailingStorage refuses to save scene progress
+type progressFailingStorage struct {
+	*mockStorage
+}
+
+func (s progressFailingStorage) Save(ctx context.Context, path string, data []byte) error {
+	if strings.HasPrefix(path, "progress/") {
+		return errors.New("disk full")
+	}
+	return s.mockStorage.Save(ctx, path, data)
+}
+
+func TestSceneTrackerKeepsMemoryWithStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("failed progress write", func(t *testing.T) {
+		tracker := core.NewAtomicSceneTracker(progressFailingStorage{newMockStorage()}, "s1", 3)
+		if err := tracker.MarkCompleted(ctx, 1, 1, "text"); err == nil {
+			t.Fatal("MarkCompleted() succeeded without saving progress")
+		}
+		if tracker.IsCompleted(1, 1) {
+			t.Error("scene counted as completed though its progress wasn't saved")
+		}
+	})
+
+	t.Run("failed transaction", func(t *testing.T) {
+		tracker := core.NewAtomicSceneTracker(newMockStorage(), "s1", 3)
+		err := tracker.WithinTx(ctx, func(ctx context.Context) error {
+			if err := tracker.MarkCompleted(ctx, 1, 1, "text"); err != nil {
+				return err
+			}
+			return errors.New("checkpoint failed")
+		})
+		if err == nil {
+			t.Fatal("WithinTx() didn't return fn's error")
+		}
+		if tracker.IsCompleted(1, 1) {
+			t.Error("scene counted as completed though its transaction failed")
+		}
+
+		if err := tracker.WithinTx(ctx, func(ctx context.Context) error {
+			return tracker.MarkCompleted(ctx, 1, 1, "text")
+		}); err != nil || !tracker.IsCompleted(1, 1) {
+			t.Errorf("WithinTx() = %v, completed = %v", err, tracker.IsCompleted(1, 1))
+		}
+	})
+}
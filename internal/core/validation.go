@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -113,6 +114,7 @@ func (v *BaseValidator) ValidateFileExtension(filename string, expectedLanguage
 
 // ValidationLogger tracks validation events for debugging
 type ValidationLogger struct {
+	mu     sync.Mutex // Phases in a DAG wave log concurrently
 	Events []ValidationEvent
 }
 
@@ -142,10 +144,15 @@ func (l *ValidationLogger) LogValidation(phase, validationType string, success b
 		event.Error = err.Error()
 	}
 	
+	l.mu.Lock()
 	l.Events = append(l.Events, event)
+	l.mu.Unlock()
 }
 
 func (l *ValidationLogger) GetValidationReport() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
 	report := "=== VALIDATION REPORT ===\n"
 	for _, event := range l.Events {
 		status := "✓ PASS"
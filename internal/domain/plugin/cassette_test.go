@@ -0,0 +1,76 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/domain/plugin"
+)
+
+// timestamps matches the times phases stamp their output with, which no replay repeats
+var timestamps = regexp.MustCompile(`"timestamp":"[^"]*"`)
+
+// runCodePipeline drives the code plugin's phases through the orchestrator with client
+// and returns the output its last checkpoint holds, without timestamps
+func runCodePipeline(t *testing.T, client agent.AIClient, request string) (string, error) {
+	t.Helper()
+	storage := newMockDomainStorage()
+	p := plugin.NewCodePlugin(&mockDomainAgent{}, storage, t.TempDir(), client)
+	orch := core.New(plugin.CorePhases(p), storage, core.WithConfig(core.OrchestratorConfig{
+		CheckpointingEnabled: true,
+		MaxRetries:           1,
+	})).WithSessionID("s1")
+
+	ctx := context.Background()
+	if err := orch.Run(ctx, request); err != nil {
+		return "", err
+	}
+	checkpoint, err := core.NewCheckpointManager(storage).Load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	data, _ := json.Marshal(checkpoint.State["data"])
+	return timestamps.ReplaceAllString(string(data), `"timestamp":""`), nil
+}
+
+func TestCodePipelineReplaysCassette(t *testing.T) {
+	const request = "Create a command-line calculator in Python"
+	path := filepath.Join(t.TempDir(), "cassettes", "code.json")
+
+	recorder, err := agent.NewRecordingClient(agent.NewMockClient(), path)
+	if err != nil {
+		t.Fatalf("NewRecordingClient() error = %v", err)
+	}
+	recorded, err := runCodePipeline(t, recorder, request)
+	if err != nil {
+		t.Fatalf("recording run error = %v", err)
+	}
+	cassette, err := agent.LoadCassette(path)
+	if err != nil || len(cassette.Interactions) == 0 {
+		t.Fatalf("cassette = %v, %v; want the run's requests", cassette, err)
+	}
+
+	replayer, err := agent.NewReplayClient(path)
+	if err != nil {
+		t.Fatalf("NewReplayClient() error = %v", err)
+	}
+	replayed, err := runCodePipeline(t, replayer, request)
+	if err != nil {
+		t.Fatalf("replayed run error = %v", err)
+	}
+	if replayed != recorded {
+		t.Errorf("replayed output differs from the recording:\n got %s\nwant %s", replayed, recorded)
+	}
+
+	// A request the cassette never saw stops the pipeline instead of going online
+	replayer, _ = agent.NewReplayClient(path)
+	if _, err := runCodePipeline(t, replayer, "Create a REST API for user management in Go"); !errors.Is(err, agent.ErrCassetteMiss) {
+		t.Errorf("unrecorded run error = %v, want ErrCassetteMiss", err)
+	}
+}
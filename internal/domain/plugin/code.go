@@ -46,33 +46,18 @@ func (p *CodePlugin) Description() string {
 	return "AI-powered code generation that flows like water: conversational exploration, incremental building, quality refinement, and gentle validation"
 }
 
-// GetPhases returns the ordered phases for code tasks using enhanced V2 prompts
+// GetPhases returns the phases of the built-in code pipeline
 func (p *CodePlugin) GetPhases() []domain.Phase {
-	// Create enhanced phases that use the agent factory
-	enhancedPhases := []domain.Phase{
-		&enhancedConversationalExplorerPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-			logger:  p.logger,
-		},
-		&enhancedCodePlannerPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-			logger:  p.logger,
-		},
-		&enhancedCodeImplementerPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-			logger:  p.logger,
-		},
-		&enhancedCodeRefinerPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-			logger:  p.logger,
-		},
+	return mustBuiltinPipeline("code", p.PhaseEnv())
+}
+
+// PhaseEnv exposes the agents, storage and logger pipeline phases are built with
+func (p *CodePlugin) PhaseEnv() PhaseEnv {
+	return PhaseEnv{
+		Agents:  p.agentFactory,
+		Storage: p.storage,
+		Logger:  p.logger,
 	}
-	
-	return enhancedPhases
 }
 
 // GetDefaultConfig returns default configuration for code tasks
@@ -348,6 +333,11 @@ func (s *domainToCoreStorageAdapter) List(ctx context.Context, pattern string) (
 	return s.storage.List(ctx, pattern)
 }
 
+// InTx forwards to the wrapped storage when it supports transactions
+func (s *domainToCoreStorageAdapter) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return core.WithinTx(ctx, s.storage, fn)
+}
+
 type coreToDomainPhaseAdapter struct {
 	phase core.Phase
 }
@@ -398,8 +388,96 @@ func (p *coreToDomainPhaseAdapter) CanRetry(err error) bool {
 	return p.phase.CanRetry(err)
 }
 
+// CorePhases adapts a plugin's domain phases so they can be driven by core.Orchestrator
+func CorePhases(plugin DomainPlugin) []core.Phase {
+	return toCorePhases(plugin.GetPhases())
+}
+
+func toCorePhases(domainPhases []domain.Phase) []core.Phase {
+	phases := make([]core.Phase, 0, len(domainPhases))
+	for _, phase := range domainPhases {
+		phases = append(phases, &domainToCorePhaseAdapter{phase: phase})
+	}
+	return phases
+}
+
+// ScheduledPlugin is implemented by plugins whose phases declare their order,
+// dependencies and parallelism, such as manifest plugins and pipelines
+type ScheduledPlugin interface {
+	DomainPlugin
+	Schedule() []core.PhaseSchedule
+}
+
+// PhaseSchedule returns the schedule plugin declares for its phases, or nil when
+// they simply run in the order GetPhases returns them
+func PhaseSchedule(plugin DomainPlugin) []core.PhaseSchedule {
+	if scheduled, ok := plugin.(ScheduledPlugin); ok {
+		return scheduled.Schedule()
+	}
+	return nil
+}
+
+type domainToCorePhaseAdapter struct {
+	phase domain.Phase
+}
+
+func (p *domainToCorePhaseAdapter) Name() string {
+	return p.phase.Name()
+}
+
+func (p *domainToCorePhaseAdapter) Execute(ctx context.Context, input core.PhaseInput) (core.PhaseOutput, error) {
+	domainOutput, err := p.phase.Execute(ctx, toDomainInput(input))
+	if err != nil {
+		return core.PhaseOutput{Error: err}, err
+	}
+
+	return core.PhaseOutput{
+		Data:     domainOutput.Data,
+		Error:    domainOutput.Error,
+		Metadata: domainOutput.Metadata,
+	}, nil
+}
+
+func (p *domainToCorePhaseAdapter) ValidateInput(ctx context.Context, input core.PhaseInput) error {
+	return p.phase.ValidateInput(ctx, toDomainInput(input))
+}
+
+func (p *domainToCorePhaseAdapter) ValidateOutput(ctx context.Context, output core.PhaseOutput) error {
+	return p.phase.ValidateOutput(ctx, domain.PhaseOutput{
+		Data:     output.Data,
+		Error:    output.Error,
+		Metadata: output.Metadata,
+	})
+}
+
+func (p *domainToCorePhaseAdapter) EstimatedDuration() time.Duration {
+	return p.phase.EstimatedDuration()
+}
+
+func (p *domainToCorePhaseAdapter) CanRetry(err error) bool {
+	return p.phase.CanRetry(err)
+}
+
+// toDomainInput carries the session ID through metadata, where the enhanced phases look for it
+func toDomainInput(input core.PhaseInput) domain.PhaseInput {
+	metadata := make(map[string]interface{}, len(input.Metadata)+1)
+	for k, v := range input.Metadata {
+		metadata[k] = v
+	}
+	if input.SessionID != "" {
+		metadata["session_id"] = input.SessionID
+	}
+
+	return domain.PhaseInput{
+		Request:  input.Request,
+		Data:     input.Data,
+		Metadata: metadata,
+	}
+}
+
 // Enhanced conversational explorer phase
 type enhancedConversationalExplorerPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 	logger  *slog.Logger
@@ -411,7 +489,7 @@ func (p *enhancedConversationalExplorerPhase) Name() string {
 
 func (p *enhancedConversationalExplorerPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced code analyzer agent
-	analyzerAgent := p.factory.CreateCodeAgent("analyzer")
+	analyzerAgent := p.codeAgent(p.factory, "analyzer")
 	
 	// Convert to core agent adapter
 	coreAgent := &codeAgentToCoreAdapter{agent: analyzerAgent}
@@ -457,6 +535,7 @@ func (p *enhancedConversationalExplorerPhase) CanRetry(err error) bool {
 
 // Enhanced code planner phase
 type enhancedCodePlannerPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 	logger  *slog.Logger
@@ -468,7 +547,7 @@ func (p *enhancedCodePlannerPhase) Name() string {
 
 func (p *enhancedCodePlannerPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced code planner agent
-	plannerAgent := p.factory.CreateCodeAgent("planner")
+	plannerAgent := p.codeAgent(p.factory, "planner")
 	
 	// Convert to core agent adapter
 	coreAgent := &codeAgentToCoreAdapter{agent: plannerAgent}
@@ -523,6 +602,7 @@ func (p *enhancedCodePlannerPhase) CanRetry(err error) bool {
 
 // Enhanced code implementer phase
 type enhancedCodeImplementerPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 	logger  *slog.Logger
@@ -534,7 +614,7 @@ func (p *enhancedCodeImplementerPhase) Name() string {
 
 func (p *enhancedCodeImplementerPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced code implementer agent
-	implementerAgent := p.factory.CreateCodeAgent("implementer")
+	implementerAgent := p.codeAgent(p.factory, "implementer")
 	
 	// Convert to core agent adapter
 	coreAgent := &codeAgentToCoreAdapter{agent: implementerAgent}
@@ -581,6 +661,7 @@ func (p *enhancedCodeImplementerPhase) CanRetry(err error) bool {
 
 // Enhanced code refiner phase
 type enhancedCodeRefinerPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 	logger  *slog.Logger
@@ -592,7 +673,7 @@ func (p *enhancedCodeRefinerPhase) Name() string {
 
 func (p *enhancedCodeRefinerPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced code reviewer agent (using analyzer for refinement)
-	reviewerAgent := p.factory.CreateCodeAgent("analyzer")
+	reviewerAgent := p.codeAgent(p.factory, "analyzer")
 	
 	// Convert to core agent adapter
 	coreAgent := &codeAgentToCoreAdapter{agent: reviewerAgent}
@@ -649,6 +730,22 @@ func (a *codeAgentToCoreAdapter) ExecuteJSON(ctx context.Context, prompt string,
 	return a.agent.ExecuteJSON(ctx, prompt, input)
 }
 
+func (a *codeAgentToCoreAdapter) ExecuteStream(ctx context.Context, prompt string, input any, onChunk func(chunk string) error) (string, error) {
+	return a.agent.ExecuteStream(ctx, prompt, input, onChunk)
+}
+
+func (a *codeAgentToCoreAdapter) ExecuteInto(ctx context.Context, prompt string, input any, out any) error {
+	return a.agent.ExecuteInto(ctx, prompt, input, out)
+}
+
+func (a *codeAgentToCoreAdapter) ExecuteWithTools(ctx context.Context, prompt string, input any, tools []core.Tool) (string, error) {
+	return executeWithTools(ctx, a.agent, prompt, input, tools)
+}
+
+func (a *codeAgentToCoreAdapter) ExecuteTurn(ctx context.Context, history []string, prompt string) (string, error) {
+	return a.agent.ExecuteTurn(ctx, history, prompt)
+}
+
 // getCodeSessionID extracts session ID from metadata for code plugin
 func getCodeSessionID(metadata map[string]interface{}) string {
 	if metadata == nil {
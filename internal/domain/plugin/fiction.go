@@ -2,7 +2,9 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +13,7 @@ import (
 	"github.com/dotcommander/orc/internal/agent"
 	"github.com/dotcommander/orc/internal/core"
 	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/internal/phase"
 	"github.com/dotcommander/orc/internal/phase/fiction"
 )
 
@@ -22,6 +25,9 @@ type FictionPlugin struct {
 	checkpointMgr domain.CheckpointManager
 	sessionID     string
 	agentFactory  *agent.AgentFactory
+	writers       int
+	writerLimit   phase.ConcurrencyLimit
+	batch         bool
 }
 
 // NewFictionPlugin creates a new fiction plugin with enhanced prompts
@@ -44,6 +50,21 @@ func (p *FictionPlugin) WithCheckpointing(mgr domain.CheckpointManager, sessionI
 	return p
 }
 
+// WithWriterPool writes up to workers chapters at once, fewer while limit says the
+// provider is rate-limiting
+func (p *FictionPlugin) WithWriterPool(workers int, limit phase.ConcurrencyLimit) *FictionPlugin {
+	p.writers = workers
+	p.writerLimit = limit
+	return p
+}
+
+// WithBatchMode drafts scenes through the provider's batch API, which costs less but
+// can take hours
+func (p *FictionPlugin) WithBatchMode(enabled bool) *FictionPlugin {
+	p.batch = enabled
+	return p
+}
+
 // Name returns the plugin name
 func (p *FictionPlugin) Name() string {
 	return "fiction"
@@ -54,29 +75,21 @@ func (p *FictionPlugin) Description() string {
 	return "Professional AI novel generation with enhanced prompts: strategic planning, targeted writing, contextual editing, and polished assembly"
 }
 
-// GetPhases returns enhanced phases with professional prompts
+// GetPhases returns the phases of the built-in fiction pipeline
 func (p *FictionPlugin) GetPhases() []domain.Phase {
-	// Create enhanced phases that use the agent factory
-	enhancedPhases := []domain.Phase{
-		&enhancedPlannerPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-		},
-		&enhancedWriterPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-		},
-		&enhancedEditorPhase{
-			factory: p.agentFactory,
-			storage: p.storage,
-		},
-		// Assembler doesn't need AI, so use the standard one
-		&coreToDomainPhaseAdapter{
-			phase: fiction.NewSystematicAssembler(&domainToCoreStorageAdapter{storage: p.storage}),
-		},
+	return mustBuiltinPipeline("fiction", p.PhaseEnv())
+}
+
+// PhaseEnv exposes the agents and storage pipeline phases are built with
+func (p *FictionPlugin) PhaseEnv() PhaseEnv {
+	return PhaseEnv{
+		Agents:  p.agentFactory,
+		Storage: p.storage,
+		Logger:  slog.Default().With("component", "fiction_plugin"),
+		Workers: p.writers,
+		Limit:   p.writerLimit,
+		Batch:   p.batch,
 	}
-	
-	return enhancedPhases
 }
 
 // GetDefaultConfig returns default configuration for fiction generation
@@ -278,6 +291,7 @@ func getPromptsDir() string {
 
 // Enhanced phase implementations
 type enhancedPlannerPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 }
@@ -288,7 +302,7 @@ func (p *enhancedPlannerPhase) Name() string {
 
 func (p *enhancedPlannerPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced planning agent
-	plannerAgent := p.factory.CreateFictionAgent("planning")
+	plannerAgent := p.fictionAgent(p.factory, "planning")
 	
 	// Convert to core agent adapter
 	coreAgent := &agentToCoreAdapter{agent: plannerAgent}
@@ -333,10 +347,68 @@ func (p *enhancedPlannerPhase) CanRetry(err error) bool {
 	return true
 }
 
+// Enhanced conversational planning phase
+type enhancedConversationPhase struct {
+	agentSpec
+	factory *agent.AgentFactory
+	storage domain.Storage
+}
+
+func (p *enhancedConversationPhase) Name() string {
+	return "Conversational Planning"
+}
+
+// Execute develops the story as one multi-turn conversation. Its output, which the
+// orchestrator checkpoints, carries every turn alongside the story core and chapters.
+func (p *enhancedConversationPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	plannerAgent := p.fictionAgent(p.factory, "planning")
+	
+	coreAgent := &agentToCoreAdapter{agent: plannerAgent}
+	coreStorage := &domainToCoreStorageAdapter{storage: p.storage}
+	
+	planner := fiction.NewConversationalPlanner(coreAgent, coreStorage)
+	
+	coreOutput, err := planner.Execute(ctx, core.PhaseInput{
+		Request:   input.Request,
+		SessionID: getSessionID(input.Metadata),
+	})
+	if err != nil {
+		return domain.PhaseOutput{Error: err}, err
+	}
+	
+	return domain.PhaseOutput{
+		Data:     coreOutput.Data,
+		Metadata: input.Metadata,
+	}, nil
+}
+
+func (p *enhancedConversationPhase) ValidateInput(ctx context.Context, input domain.PhaseInput) error {
+	if strings.TrimSpace(input.Request) == "" {
+		return fmt.Errorf("request cannot be empty")
+	}
+	return nil
+}
+
+func (p *enhancedConversationPhase) ValidateOutput(ctx context.Context, output domain.PhaseOutput) error {
+	return nil
+}
+
+func (p *enhancedConversationPhase) EstimatedDuration() time.Duration {
+	return 15 * time.Minute
+}
+
+func (p *enhancedConversationPhase) CanRetry(err error) bool {
+	return true
+}
+
 // Enhanced writer phase
 type enhancedWriterPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
+	workers int
+	limit   phase.ConcurrencyLimit
+	batch   bool
 }
 
 func (p *enhancedWriterPhase) Name() string {
@@ -345,14 +417,14 @@ func (p *enhancedWriterPhase) Name() string {
 
 func (p *enhancedWriterPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced writing agent
-	writerAgent := p.factory.CreateFictionAgent("writer")
+	writerAgent := p.fictionAgent(p.factory, "writer")
 	
 	// Convert to core agent adapter
 	coreAgent := &agentToCoreAdapter{agent: writerAgent}
 	coreStorage := &domainToCoreStorageAdapter{storage: p.storage}
 	
 	// Use the targeted writer with enhanced agent
-	writer := fiction.NewTargetedWriter(coreAgent, coreStorage)
+	writer := fiction.NewTargetedWriter(coreAgent, coreStorage, p.writerOptions()...)
 	
 	// Convert input and execute
 	coreInput := core.PhaseInput{
@@ -371,6 +443,23 @@ func (p *enhancedWriterPhase) Execute(ctx context.Context, input domain.PhaseInp
 	}, nil
 }
 
+// writerOptions sizes the writer's chapter pool, throttled by the rate limiter, and
+// turns on batch drafting
+func (p *enhancedWriterPhase) writerOptions() []fiction.TargetedWriterOption {
+	var opts []fiction.TargetedWriterOption
+	if p.workers > 1 {
+		var poolOpts []phase.WorkerPoolOption
+		if p.limit != nil {
+			poolOpts = append(poolOpts, phase.WithConcurrencyLimit(p.limit))
+		}
+		opts = append(opts, fiction.WithChapterPool(p.workers, poolOpts...))
+	}
+	if p.batch {
+		opts = append(opts, fiction.WithBatchMode())
+	}
+	return opts
+}
+
 func (p *enhancedWriterPhase) ValidateInput(ctx context.Context, input domain.PhaseInput) error {
 	if input.Data == nil {
 		return fmt.Errorf("writer requires plan data")
@@ -392,6 +481,7 @@ func (p *enhancedWriterPhase) CanRetry(err error) bool {
 
 // Enhanced editor phase
 type enhancedEditorPhase struct {
+	agentSpec
 	factory *agent.AgentFactory
 	storage domain.Storage
 }
@@ -402,7 +492,7 @@ func (p *enhancedEditorPhase) Name() string {
 
 func (p *enhancedEditorPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
 	// Create enhanced editing agent
-	editorAgent := p.factory.CreateFictionAgent("editor")
+	editorAgent := p.fictionAgent(p.factory, "editor")
 	
 	// Convert to core agent adapter
 	coreAgent := &agentToCoreAdapter{agent: editorAgent}
@@ -460,6 +550,48 @@ func (a *agentToCoreAdapter) ExecuteJSON(ctx context.Context, prompt string, inp
 	return a.agent.ExecuteJSON(ctx, prompt, input)
 }
 
+func (a *agentToCoreAdapter) ExecuteStream(ctx context.Context, prompt string, input any, onChunk func(chunk string) error) (string, error) {
+	return a.agent.ExecuteStream(ctx, prompt, input, onChunk)
+}
+
+func (a *agentToCoreAdapter) ExecuteInto(ctx context.Context, prompt string, input any, out any) error {
+	return a.agent.ExecuteInto(ctx, prompt, input, out)
+}
+
+func (a *agentToCoreAdapter) SubmitBatch(ctx context.Context, prompts map[string]string) (string, error) {
+	return a.agent.SubmitBatch(ctx, prompts)
+}
+
+func (a *agentToCoreAdapter) WaitBatch(ctx context.Context, batchID string) (map[string]string, error) {
+	return a.agent.WaitBatch(ctx, batchID)
+}
+
+func (a *agentToCoreAdapter) ExecuteTurn(ctx context.Context, history []string, prompt string) (string, error) {
+	return a.agent.ExecuteTurn(ctx, history, prompt)
+}
+
+func (a *agentToCoreAdapter) ExecuteWithTools(ctx context.Context, prompt string, input any, tools []core.Tool) (string, error) {
+	return executeWithTools(ctx, a.agent, prompt, input, tools)
+}
+
+// executeWithTools runs core tools through an agent, translating the unsupported
+// error so phases can recognise it
+func executeWithTools(ctx context.Context, a *agent.Agent, prompt string, input any, tools []core.Tool) (string, error) {
+	agentTools := make([]agent.Tool, len(tools))
+	for i, t := range tools {
+		agentTools[i] = agent.Tool{Name: t.Name, Description: t.Description, Handler: t.Handler}
+		if t.Args != nil {
+			agentTools[i].Parameters = agent.SchemaFor(t.Args)
+		}
+	}
+
+	response, err := a.ExecuteWithTools(ctx, prompt, input, agentTools)
+	if errors.Is(err, agent.ErrToolsUnsupported) {
+		return "", fmt.Errorf("%w: %v", core.ErrToolsUnsupported, err)
+	}
+	return response, err
+}
+
 // Helper to extract session ID from metadata
 func getSessionID(metadata map[string]interface{}) string {
 	if metadata == nil {
@@ -0,0 +1,283 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/internal/phase/fiction"
+)
+
+// concurrentClient records the most requests it has had in flight at once. Each
+// request takes a moment, so requests sent together overlap.
+type concurrentClient struct {
+	*agent.MockClient
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *concurrentClient) CompleteWithSystem(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.peak = max(c.peak, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return "The scene.", nil
+}
+
+// fourChapterPlan is a novel plan with one scene in each of four chapters
+func fourChapterPlan() fiction.NovelPlan {
+	plan := fiction.NovelPlan{Title: "Tides", Synopsis: "A lighthouse keeper's last winter"}
+	for i := 1; i <= 4; i++ {
+		plan.Chapters = append(plan.Chapters, fiction.Chapter{
+			Number: i,
+			Title:  fmt.Sprintf("Chapter %d", i),
+			Scenes: []fiction.Scene{{ChapterNum: i, SceneNum: 1, Summary: "The storm arrives"}},
+		})
+	}
+	return plan
+}
+
+// writerPhase returns the fiction pipeline's writing phase
+func writerPhase(t *testing.T, p *plugin.FictionPlugin) domain.Phase {
+	t.Helper()
+	for _, phase := range p.GetPhases() {
+		if phase.Name() == "Targeted Writing" {
+			return phase
+		}
+	}
+	t.Fatal("fiction pipeline has no writing phase")
+	return nil
+}
+
+func TestFictionWriterPoolFollowsRateLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		throttle func(*agent.AdaptiveLimiter)
+		wantPeak int
+	}{
+		{"full rate", func(*agent.AdaptiveLimiter) {}, 4},
+		{"rate-limited", func(l *agent.AdaptiveLimiter) {
+			l.Observe(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}})
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := agent.NewAdaptiveLimiter(6000, 100)
+			tt.throttle(limiter)
+			client := &concurrentClient{MockClient: agent.NewMockClient()}
+			storage := newMockDomainStorage()
+			p := plugin.NewFictionPlugin(&mockDomainAgent{}, storage, t.TempDir(), client).
+				WithWriterPool(4, limiter)
+
+			_, err := writerPhase(t, p).Execute(context.Background(), domain.PhaseInput{Data: fourChapterPlan()})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if client.peak != tt.wantPeak {
+				t.Errorf("peak concurrent requests = %d, want %d", client.peak, tt.wantPeak)
+			}
+			for i := 1; i <= 4; i++ {
+				if !storage.Exists(context.Background(), fmt.Sprintf("scenes/ch%d_sc1.md", i)) {
+					t.Errorf("chapter %d's scene wasn't saved", i)
+				}
+			}
+		})
+	}
+}
+
+// sceneBatchServer stands in for the Anthropic Messages and Message Batches APIs. Batch
+// requests are answered with "batched: <custom id>" unless their prompt contains
+// "storm", and every individual message with "written alone".
+type sceneBatchServer struct {
+	mu       sync.Mutex
+	batches  [][]string // Custom IDs submitted, per batch
+	prompts  map[string]string
+	messages int
+}
+
+func (s *sceneBatchServer) start(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/messages":
+			s.messages++
+			io.WriteString(w, `{"content":[{"type":"text","text":"written alone"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+		case r.Method == "POST" && r.URL.Path == "/messages/batches":
+			var body struct {
+				Requests []struct {
+					CustomID string `json:"custom_id"`
+					Params   struct {
+						Messages []struct {
+							Content string `json:"content"`
+						} `json:"messages"`
+					} `json:"params"`
+				} `json:"requests"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			var ids []string
+			for _, req := range body.Requests {
+				ids = append(ids, req.CustomID)
+				s.prompts[req.CustomID] = req.Params.Messages[0].Content
+			}
+			sort.Strings(ids)
+			s.batches = append(s.batches, ids)
+			fmt.Fprintf(w, `{"id":"msgbatch_%d","processing_status":"in_progress"}`, len(s.batches))
+		case strings.HasPrefix(r.URL.Path, "/messages/batches/"):
+			id := strings.TrimPrefix(r.URL.Path, "/messages/batches/")
+			fmt.Fprintf(w, `{"id":%q,"processing_status":"ended","results_url":%q}`, id, srv.URL+"/results/"+id)
+		case strings.HasPrefix(r.URL.Path, "/results/"):
+			for _, id := range s.batches[len(s.batches)-1] {
+				result := map[string]interface{}{"type": "errored", "error": map[string]string{"type": "overloaded_error"}}
+				if !strings.Contains(s.prompts[id], "storm") {
+					result = map[string]interface{}{"type": "succeeded", "message": map[string]interface{}{
+						"content": []map[string]string{{"type": "text", "text": "batched: " + id}},
+						"usage":   map[string]int{"input_tokens": 10, "output_tokens": 5},
+					}}
+				}
+				line, _ := json.Marshal(map[string]interface{}{"custom_id": id, "result": result})
+				fmt.Fprintf(w, "%s\n", line)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return srv
+}
+
+func TestFictionWriterBatchMode(t *testing.T) {
+	s := &sceneBatchServer{prompts: make(map[string]string)}
+	srv := s.start(t)
+	defer srv.Close()
+
+	provider, _ := agent.GetProvider("anthropic")
+	client := agent.NewClient("test-key", agent.WithAPIConfig(srv.URL, "model"), agent.WithProvider(provider),
+		agent.WithRateLimit(6000, 10), agent.WithBatchPollInterval(10*time.Millisecond))
+	storage := newMockDomainStorage()
+	p := plugin.NewFictionPlugin(&mockDomainAgent{}, storage, t.TempDir(), client).WithBatchMode(true)
+
+	plan := fiction.NovelPlan{Title: "Tides", Chapters: []fiction.Chapter{
+		{Number: 1, Title: "Calm", Scenes: []fiction.Scene{{SceneNum: 1, Summary: "The keeper trims the lamp"}}},
+		{Number: 2, Title: "Gale", Scenes: []fiction.Scene{{SceneNum: 1, Summary: "The storm arrives"}}},
+	}}
+	input := domain.PhaseInput{Data: plan, Metadata: map[string]interface{}{"session_id": "s1"}}
+	ctx := context.Background()
+
+	if _, err := writerPhase(t, p).Execute(ctx, input); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := [][]string{{"chapter_1_scene_1", "chapter_2_scene_1"}}; fmt.Sprint(s.batches) != fmt.Sprint(want) {
+		t.Errorf("batches = %v, want %v", s.batches, want)
+	}
+	scene1, _ := storage.Load(ctx, "scenes/ch1_sc1.md")
+	scene2, _ := storage.Load(ctx, "scenes/ch2_sc1.md")
+	if string(scene1) != "batched: chapter_1_scene_1" || string(scene2) != "written alone" {
+		t.Errorf("scenes = %q, %q; want the batch draft and an individually written scene", scene1, scene2)
+	}
+	if s.messages == 0 {
+		t.Error("the scene the batch failed wasn't written individually")
+	}
+
+	var progress struct {
+		CompletedScenes map[string]json.RawMessage `json:"completed_scenes"`
+		BatchID         string                     `json:"batch_id"`
+	}
+	data, err := storage.Load(ctx, "progress/writing_progress_s1.json")
+	if err != nil || json.Unmarshal(data, &progress) != nil {
+		t.Fatalf("scene progress wasn't saved: %v", err)
+	}
+	if _, ok := progress.CompletedScenes["chapter_1_scene_1"]; !ok || len(progress.CompletedScenes) != 1 || progress.BatchID != "" {
+		t.Errorf("progress = %d scenes, batch %q; want the batched scene and no pending batch", len(progress.CompletedScenes), progress.BatchID)
+	}
+
+	// Resuming the session only batches the scene the tracker doesn't have
+	if _, err := writerPhase(t, p).Execute(ctx, input); err != nil {
+		t.Fatalf("resumed Execute() error = %v", err)
+	}
+	if len(s.batches) != 2 || fmt.Sprint(s.batches[1]) != "[chapter_2_scene_1]" {
+		t.Errorf("batches = %v, want a second batch of only the missing scene", s.batches)
+	}
+}
+
+// turnClient answers conversations, recording how many messages each request carried
+type turnClient struct {
+	*agent.MockClient
+	sizes []int
+}
+
+func (c *turnClient) CompleteConversation(ctx context.Context, conv *agent.Conversation) (string, error) {
+	c.sizes = append(c.sizes, len(conv.Messages))
+	return fmt.Sprintf("answer %d", len(c.sizes)), nil
+}
+
+func TestFictionConversationKeepsTurnsInCheckpoint(t *testing.T) {
+	client := &turnClient{MockClient: agent.NewMockClient()}
+	storage := newMockDomainStorage()
+	p := plugin.NewFictionPlugin(&mockDomainAgent{}, storage, t.TempDir(), client)
+
+	def, err := plugin.ParsePipeline([]byte(`
+name: conversation
+plugin: fiction
+phases:
+  - name: Conversational Planning
+    type: fiction.conversation
+`))
+	if err != nil {
+		t.Fatalf("ParsePipeline() error = %v", err)
+	}
+	pp, err := plugin.NewPipelinePlugin(p, def)
+	if err != nil {
+		t.Fatalf("NewPipelinePlugin() error = %v", err)
+	}
+
+	ctx := context.Background()
+	orch := core.New(plugin.CorePhases(pp), storage, core.WithConfig(core.OrchestratorConfig{
+		CheckpointingEnabled: true,
+		MaxRetries:           1,
+	})).WithSessionID("s1")
+	if err := orch.Run(ctx, "A lighthouse keeper's last winter"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Seven questions, each sent after the earlier questions and answers
+	if want := "[1 3 5 7 9 11 13]"; fmt.Sprint(client.sizes) != want {
+		t.Errorf("messages per request = %v, want %s", client.sizes, want)
+	}
+
+	checkpoint, err := core.NewCheckpointManager(storage).Load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	data, _ := json.Marshal(checkpoint.State["data"])
+	var state struct {
+		Conversation []fiction.ConversationTurn `json:"conversation"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("checkpoint state %s: %v", data, err)
+	}
+	if len(state.Conversation) != 7 || state.Conversation[6].Answer != "answer 7" {
+		t.Errorf("checkpointed conversation = %+v, want all seven turns", state.Conversation)
+	}
+}
@@ -0,0 +1,506 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/internal/phase"
+	"github.com/dotcommander/orc/internal/phase/fiction"
+)
+
+//go:embed pipelines/*.yaml
+var builtinPipelines embed.FS
+
+// PipelineDefinition describes which phases a plugin runs, in what order and how. It
+// is loaded from YAML so variants of a plugin need no Go changes.
+type PipelineDefinition struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Plugin      string            `yaml:"plugin"`
+	Vars        map[string]string `yaml:"vars,omitempty"`
+	Phases      []PipelineStep    `yaml:"phases"`
+}
+
+// PipelineStep configures one phase of a pipeline
+type PipelineStep struct {
+	Name      string        `yaml:"name"`
+	Type      string        `yaml:"type"`                 // Registered phase type, e.g. fiction.planner
+	Prompt    string        `yaml:"prompt,omitempty"`     // Prompt file, relative to the prompts directory
+	Role      string        `yaml:"role,omitempty"`       // Agent role; also selects the routed model
+	DependsOn []string      `yaml:"depends_on,omitempty"` // Defaults to the previous step
+	Parallel  bool          `yaml:"parallel,omitempty"`   // Consecutive parallel steps run as one group
+	Retry     RetryPolicy   `yaml:"retry,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+	Condition string        `yaml:"condition,omitempty"` // Skip the step unless this holds for Vars
+}
+
+// RetryPolicy controls how often a step is attempted. A zero MaxAttempts leaves
+// retries to the orchestrator.
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts,omitempty"`
+	Backoff     time.Duration `yaml:"backoff,omitempty"`
+}
+
+// PhaseEnv is what a phase factory needs to construct a phase
+type PhaseEnv struct {
+	Agents  *agent.AgentFactory
+	Storage domain.Storage
+	Logger  *slog.Logger
+
+	// Workers caps how many pieces of work a phase runs at once, and Limit scales it
+	// down with the AI client's rate limits. Zero workers runs one at a time.
+	Workers int
+	Limit   phase.ConcurrencyLimit
+
+	// Batch sends bulk generation through the provider's batch API where a phase can
+	Batch bool
+}
+
+// PhaseFactory builds a phase of a registered type for a pipeline step
+type PhaseFactory func(env PhaseEnv, step PipelineStep) (domain.Phase, error)
+
+// PipelineHost is implemented by plugins whose phases can be assembled from a
+// pipeline definition
+type PipelineHost interface {
+	DomainPlugin
+	PhaseEnv() PhaseEnv
+}
+
+var (
+	phaseTypesMu sync.RWMutex
+	phaseTypes   = make(map[string]PhaseFactory)
+)
+
+func init() {
+	RegisterPhaseType("fiction.planner", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedPlannerPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage}, nil
+	})
+	RegisterPhaseType("fiction.conversation", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedConversationPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage}, nil
+	})
+	RegisterPhaseType("fiction.writer", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedWriterPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage, workers: env.Workers, limit: env.Limit, batch: env.Batch}, nil
+	})
+	RegisterPhaseType("fiction.editor", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedEditorPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage}, nil
+	})
+	RegisterPhaseType("fiction.assembler", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		// Assembler doesn't need AI, so use the standard one
+		return &coreToDomainPhaseAdapter{
+			phase: fiction.NewSystematicAssembler(&domainToCoreStorageAdapter{storage: env.Storage}),
+		}, nil
+	})
+	RegisterPhaseType("code.explorer", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedConversationalExplorerPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage, logger: env.Logger}, nil
+	})
+	RegisterPhaseType("code.planner", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedCodePlannerPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage, logger: env.Logger}, nil
+	})
+	RegisterPhaseType("code.implementer", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedCodeImplementerPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage, logger: env.Logger}, nil
+	})
+	RegisterPhaseType("code.refiner", func(env PhaseEnv, step PipelineStep) (domain.Phase, error) {
+		return &enhancedCodeRefinerPhase{agentSpec: specFor(step), factory: env.Agents, storage: env.Storage, logger: env.Logger}, nil
+	})
+}
+
+// RegisterPhaseType makes a phase type available to pipeline definitions. Registering
+// an existing name replaces it.
+func RegisterPhaseType(name string, factory PhaseFactory) {
+	phaseTypesMu.Lock()
+	defer phaseTypesMu.Unlock()
+	phaseTypes[name] = factory
+}
+
+// ListPhaseTypes returns the names of all registered phase types
+func ListPhaseTypes() []string {
+	phaseTypesMu.RLock()
+	defer phaseTypesMu.RUnlock()
+
+	names := make([]string, 0, len(phaseTypes))
+	for name := range phaseTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupPhaseType(name string) (PhaseFactory, bool) {
+	phaseTypesMu.RLock()
+	defer phaseTypesMu.RUnlock()
+	factory, ok := phaseTypes[name]
+	return factory, ok
+}
+
+// LoadPipeline reads and validates a pipeline definition from a YAML file
+func LoadPipeline(filename string) (*PipelineDefinition, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading pipeline: %w", err)
+	}
+	def, err := ParsePipeline(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return def, nil
+}
+
+// ParsePipeline decodes and validates a YAML pipeline definition
+func ParsePipeline(data []byte) (*PipelineDefinition, error) {
+	var def PipelineDefinition
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("parsing pipeline: %w", err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// BuiltinPipeline returns one of the pipeline definitions shipped with orc
+func BuiltinPipeline(name string) (*PipelineDefinition, error) {
+	data, err := builtinPipelines.ReadFile(path.Join("pipelines", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown pipeline %q (built-in: %s)", name, strings.Join(ListBuiltinPipelines(), ", "))
+	}
+	return ParsePipeline(data)
+}
+
+// ListBuiltinPipelines returns the names of the pipelines shipped with orc
+func ListBuiltinPipelines() []string {
+	entries, _ := builtinPipelines.ReadDir("pipelines")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// mustBuiltinPipeline loads a pipeline compiled into the binary; failure is a bug
+func mustBuiltinPipeline(name string, env PhaseEnv) []domain.Phase {
+	def, err := BuiltinPipeline(name)
+	if err == nil {
+		var phases []domain.Phase
+		if phases, err = def.Build(env); err == nil {
+			return phases
+		}
+	}
+	panic(fmt.Sprintf("built-in pipeline %q: %v", name, err))
+}
+
+// Validate checks that every step has a unique name, a registered type, known
+// dependencies and a well-formed condition
+func (d *PipelineDefinition) Validate() error {
+	if len(d.Phases) == 0 {
+		return fmt.Errorf("pipeline %q has no phases", d.Name)
+	}
+
+	seen := make(map[string]bool, len(d.Phases))
+	for i, step := range d.Phases {
+		if step.Name == "" {
+			return fmt.Errorf("phase %d: name is required", i+1)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("duplicate phase %q", step.Name)
+		}
+		seen[step.Name] = true
+
+		if _, ok := lookupPhaseType(step.Type); !ok {
+			return fmt.Errorf("phase %q: unknown type %q (available: %s)", step.Name, step.Type, strings.Join(ListPhaseTypes(), ", "))
+		}
+		if step.Retry.MaxAttempts < 0 || step.Retry.Backoff < 0 || step.Timeout < 0 {
+			return fmt.Errorf("phase %q: retry and timeout values cannot be negative", step.Name)
+		}
+		if _, err := parseCondition(step.Condition); err != nil {
+			return fmt.Errorf("phase %q: %w", step.Name, err)
+		}
+	}
+
+	for _, step := range d.Phases {
+		for _, dep := range step.DependsOn {
+			if !seen[dep] {
+				return fmt.Errorf("phase %q depends on unknown phase %q", step.Name, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// Build instantiates the pipeline's phases in definition order
+func (d *PipelineDefinition) Build(env PhaseEnv) ([]domain.Phase, error) {
+	if env.Logger == nil {
+		env.Logger = slog.Default()
+	}
+
+	phases := make([]domain.Phase, 0, len(d.Phases))
+	for _, step := range d.Phases {
+		factory, ok := lookupPhaseType(step.Type)
+		if !ok {
+			return nil, fmt.Errorf("phase %q: unknown type %q", step.Name, step.Type)
+		}
+		phase, err := factory(env, step)
+		if err != nil {
+			return nil, fmt.Errorf("phase %q: %w", step.Name, err)
+		}
+		cond, err := parseCondition(step.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("phase %q: %w", step.Name, err)
+		}
+		phases = append(phases, &pipelinePhase{
+			Phase:  phase,
+			step:   step,
+			active: cond(d.Vars),
+			logger: env.Logger,
+		})
+	}
+
+	// Cycles are only visible once the whole graph is known
+	if _, err := core.BuildPhaseDAG(toCorePhases(phases), d.Schedule()); err != nil {
+		return nil, err
+	}
+	return phases, nil
+}
+
+// Schedule converts the steps into the schedule the orchestrator builds its execution
+// graph from. Consecutive parallel steps share an order and so run as one group.
+func (d *PipelineDefinition) Schedule() []core.PhaseSchedule {
+	schedule := make([]core.PhaseSchedule, len(d.Phases))
+	order := 0
+	for i, step := range d.Phases {
+		if i == 0 || !(step.Parallel && d.Phases[i-1].Parallel) {
+			order++
+		}
+		schedule[i] = core.PhaseSchedule{
+			Name:      step.Name,
+			Order:     order,
+			Parallel:  step.Parallel,
+			DependsOn: step.DependsOn,
+		}
+	}
+	return schedule
+}
+
+// PipelinePlugin is a plugin whose phases come from a pipeline definition rather
+// than its built-in GetPhases
+type PipelinePlugin struct {
+	DomainPlugin
+	def    *PipelineDefinition
+	phases []domain.Phase
+}
+
+// NewPipelinePlugin assembles def's phases using base's agents and storage
+func NewPipelinePlugin(base DomainPlugin, def *PipelineDefinition) (*PipelinePlugin, error) {
+	host, ok := base.(PipelineHost)
+	if !ok {
+		return nil, fmt.Errorf("plugin %q does not support pipeline definitions", base.Name())
+	}
+	if def.Plugin != "" && def.Plugin != base.Name() {
+		return nil, fmt.Errorf("pipeline %q is for plugin %q, not %q", def.Name, def.Plugin, base.Name())
+	}
+
+	phases, err := def.Build(host.PhaseEnv())
+	if err != nil {
+		return nil, fmt.Errorf("building pipeline %q: %w", def.Name, err)
+	}
+	return &PipelinePlugin{DomainPlugin: base, def: def, phases: phases}, nil
+}
+
+// GetPhases returns the phases built from the pipeline definition
+func (p *PipelinePlugin) GetPhases() []domain.Phase {
+	return p.phases
+}
+
+// Pipeline returns the definition the plugin was built from
+func (p *PipelinePlugin) Pipeline() *PipelineDefinition {
+	return p.def
+}
+
+// Schedule returns the pipeline's step ordering
+func (p *PipelinePlugin) Schedule() []core.PhaseSchedule {
+	return p.def.Schedule()
+}
+
+// agentSpec overrides the role and prompt a built-in phase's agent is created with.
+// The zero value keeps the phase's defaults.
+type agentSpec struct {
+	role   string
+	prompt string
+}
+
+func specFor(step PipelineStep) agentSpec {
+	return agentSpec{role: step.Role, prompt: step.Prompt}
+}
+
+func (s agentSpec) fictionAgent(factory *agent.AgentFactory, defaultRole string) *agent.Agent {
+	return s.apply(factory, factory.CreateFictionAgent(s.roleOr(defaultRole)))
+}
+
+func (s agentSpec) codeAgent(factory *agent.AgentFactory, defaultRole string) *agent.Agent {
+	return s.apply(factory, factory.CreateCodeAgent(s.roleOr(defaultRole)))
+}
+
+func (s agentSpec) roleOr(defaultRole string) string {
+	if s.role != "" {
+		return s.role
+	}
+	return defaultRole
+}
+
+func (s agentSpec) apply(factory *agent.AgentFactory, a *agent.Agent) *agent.Agent {
+	if s.prompt != "" {
+		a.WithPromptPath(factory.PromptPath(s.prompt))
+	}
+	return a
+}
+
+// pipelinePhase applies a step's name, timeout, retry policy and condition to the
+// phase built for it
+type pipelinePhase struct {
+	domain.Phase
+	step   PipelineStep
+	active bool
+	logger *slog.Logger
+}
+
+func (p *pipelinePhase) Name() string {
+	return p.step.Name
+}
+
+func (p *pipelinePhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	if !p.active {
+		p.logger.Info("skipping phase", "phase", p.step.Name, "condition", p.step.Condition)
+		return domain.PhaseOutput{Data: input.Data, Metadata: input.Metadata}, nil
+	}
+
+	attempts := max(p.step.Retry.MaxAttempts, 1)
+	var output domain.PhaseOutput
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		output, err = p.executeOnce(ctx, input)
+		if err == nil || attempt == attempts || !p.Phase.CanRetry(err) {
+			break
+		}
+
+		p.logger.Warn("phase attempt failed, retrying",
+			"phase", p.step.Name,
+			"attempt", attempt,
+			"max_attempts", attempts,
+			"error", err)
+		select {
+		case <-ctx.Done():
+			return output, ctx.Err()
+		case <-time.After(p.step.Retry.Backoff * time.Duration(attempt)):
+		}
+	}
+	return output, err
+}
+
+func (p *pipelinePhase) executeOnce(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	if p.step.Timeout <= 0 {
+		return p.Phase.Execute(ctx, input)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.step.Timeout)
+	defer cancel()
+	return p.Phase.Execute(ctx, input)
+}
+
+func (p *pipelinePhase) ValidateInput(ctx context.Context, input domain.PhaseInput) error {
+	if !p.active {
+		return nil
+	}
+	return p.Phase.ValidateInput(ctx, input)
+}
+
+func (p *pipelinePhase) ValidateOutput(ctx context.Context, output domain.PhaseOutput) error {
+	if !p.active {
+		return nil
+	}
+	return p.Phase.ValidateOutput(ctx, output)
+}
+
+// EstimatedDuration covers every attempt the step's retry policy allows and the
+// backoff between them, as the orchestrator uses it as the whole phase's deadline
+func (p *pipelinePhase) EstimatedDuration() time.Duration {
+	perAttempt := p.Phase.EstimatedDuration()
+	if p.step.Timeout > 0 {
+		perAttempt = p.step.Timeout
+	}
+	attempts := max(p.step.Retry.MaxAttempts, 1)
+	backoff := p.step.Retry.Backoff * time.Duration(attempts*(attempts-1)/2)
+	return perAttempt*time.Duration(attempts) + backoff
+}
+
+// CanRetry defers to the step's retry policy when it has one, so the orchestrator
+// does not retry on top of it
+func (p *pipelinePhase) CanRetry(err error) bool {
+	if p.step.Retry.MaxAttempts > 0 {
+		return false
+	}
+	return p.Phase.CanRetry(err)
+}
+
+// parseCondition compiles a step condition. Supported forms are "var", "!var",
+// "var == value" and "var != value"; a var is true unless empty, "false", "0" or
+// "no". An empty condition always holds.
+func parseCondition(expr string) (func(vars map[string]string) bool, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return func(map[string]string) bool { return true }, nil
+	}
+
+	for _, op := range []string{"==", "!="} {
+		name, value, found := strings.Cut(expr, op)
+		if !found {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(value), `"'`)
+		if !isConditionVar(name) {
+			return nil, fmt.Errorf("invalid condition %q", expr)
+		}
+		equal := op == "=="
+		return func(vars map[string]string) bool { return (vars[name] == value) == equal }, nil
+	}
+
+	name, negate := strings.CutPrefix(expr, "!")
+	name = strings.TrimSpace(name)
+	if !isConditionVar(name) {
+		return nil, fmt.Errorf("invalid condition %q", expr)
+	}
+	return func(vars map[string]string) bool { return truthy(vars[name]) != negate }, nil
+}
+
+func isConditionVar(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r == '-' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "0", "no", "off":
+		return false
+	}
+	return true
+}
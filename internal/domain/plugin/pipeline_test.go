@@ -0,0 +1,175 @@
+package plugin_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/internal/domain/plugin"
+)
+
+// flakyPhase fails its first failures executions, then appends "+ran" to its input
+type flakyPhase struct {
+	failures int
+	calls    int
+}
+
+func (p *flakyPhase) Name() string { return "flaky" }
+
+func (p *flakyPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return domain.PhaseOutput{}, errors.New("transient failure")
+	}
+	data, _ := input.Data.(string)
+	return domain.PhaseOutput{Data: data + "+ran"}, nil
+}
+
+func (p *flakyPhase) ValidateInput(ctx context.Context, input domain.PhaseInput) error    { return nil }
+func (p *flakyPhase) ValidateOutput(ctx context.Context, output domain.PhaseOutput) error { return nil }
+func (p *flakyPhase) EstimatedDuration() time.Duration                                    { return time.Minute }
+func (p *flakyPhase) CanRetry(err error) bool                                             { return true }
+
+func TestBuiltinPipelinesKeepPhaseOrder(t *testing.T) {
+	fictionPlugin := plugin.NewFictionPlugin(&mockDomainAgent{}, newMockDomainStorage(), "testdata/prompts", agent.NewMockClient())
+
+	var names []string
+	for _, phase := range fictionPlugin.GetPhases() {
+		names = append(names, phase.Name())
+	}
+	want := "Strategic Planning,Targeted Writing,Contextual Editing,Systematic Assembly"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("fiction phases = %s, want %s", got, want)
+	}
+
+	for _, name := range plugin.ListBuiltinPipelines() {
+		if _, err := plugin.BuiltinPipeline(name); err != nil {
+			t.Errorf("built-in pipeline %s: %v", name, err)
+		}
+	}
+}
+
+func TestParsePipelineRejectsInvalidDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown type", "name: x\nphases:\n  - {name: A, type: fiction.nope}", `unknown type "fiction.nope"`},
+		{"unknown dependency", "name: x\nphases:\n  - {name: A, type: fiction.planner, depends_on: [B]}", `unknown phase "B"`},
+		{"duplicate phase", "name: x\nphases:\n  - {name: A, type: fiction.planner}\n  - {name: A, type: fiction.writer}", `duplicate phase "A"`},
+		{"bad condition", "name: x\nphases:\n  - {name: A, type: fiction.planner, condition: 'a b'}", "invalid condition"},
+		{"unknown field", "name: x\nphases:\n  - {name: A, type: fiction.planner, retries: 3}", "retries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := plugin.ParsePipeline([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParsePipeline() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRetriesAndConditions(t *testing.T) {
+	flaky := &flakyPhase{failures: 1}
+	plugin.RegisterPhaseType("test.flaky", func(env plugin.PhaseEnv, step plugin.PipelineStep) (domain.Phase, error) {
+		return flaky, nil
+	})
+
+	def, err := plugin.ParsePipeline([]byte(`
+name: test
+vars:
+  critique: "false"
+phases:
+  - name: Draft
+    type: test.flaky
+    retry: {max_attempts: 2}
+  - name: Critique
+    type: test.flaky
+    condition: critique
+`))
+	if err != nil {
+		t.Fatalf("ParsePipeline() error = %v", err)
+	}
+	phases, err := def.Build(plugin.PhaseEnv{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	ctx := context.Background()
+	draft, critique := phases[0], phases[1]
+	if draft.Name() != "Draft" || draft.CanRetry(errors.New("x")) {
+		t.Error("a step with a retry policy should keep the orchestrator from retrying it again")
+	}
+
+	out, err := draft.Execute(ctx, domain.PhaseInput{Data: "in"})
+	if err != nil || out.Data != "in+ran" || flaky.calls != 2 {
+		t.Fatalf("Draft = %v, %v after %d calls; want in+ran after 2", out.Data, err, flaky.calls)
+	}
+
+	out, err = critique.Execute(ctx, domain.PhaseInput{Data: "draft"})
+	if err != nil || out.Data != "draft" || flaky.calls != 2 {
+		t.Errorf("skipped Critique should pass its input through, got %v, %v", out.Data, err)
+	}
+
+	def.Vars["critique"] = "true"
+	phases, _ = def.Build(plugin.PhaseEnv{})
+	if out, _ := phases[1].Execute(ctx, domain.PhaseInput{Data: "draft"}); out.Data != "draft+ran" {
+		t.Errorf("Critique with critique=true = %v, want draft+ran", out.Data)
+	}
+}
+
+// stallingPhase hangs until its deadline on the first call and succeeds after that
+type stallingPhase struct {
+	flakyPhase
+}
+
+func (p *stallingPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	p.calls++
+	if p.calls == 1 {
+		<-ctx.Done()
+		return domain.PhaseOutput{}, ctx.Err()
+	}
+	return domain.PhaseOutput{Data: "recovered"}, nil
+}
+
+func TestPipelineRetriesTimedOutStepUnderOrchestrator(t *testing.T) {
+	stalling := &stallingPhase{}
+	plugin.RegisterPhaseType("test.stalling", func(env plugin.PhaseEnv, step plugin.PipelineStep) (domain.Phase, error) {
+		return stalling, nil
+	})
+
+	def, err := plugin.ParsePipeline([]byte(`
+name: test
+phases:
+  - name: Draft
+    type: test.stalling
+    timeout: 50ms
+    retry: {max_attempts: 2, backoff: 10ms}
+`))
+	if err != nil {
+		t.Fatalf("ParsePipeline() error = %v", err)
+	}
+	base := plugin.NewFictionPlugin(&mockDomainAgent{}, newMockDomainStorage(), "testdata/prompts", agent.NewMockClient())
+	pp, err := plugin.NewPipelinePlugin(base, def)
+	if err != nil {
+		t.Fatalf("NewPipelinePlugin() error = %v", err)
+	}
+	if got, want := pp.GetPhases()[0].EstimatedDuration(), 110*time.Millisecond; got != want {
+		t.Errorf("EstimatedDuration() = %v, want both attempts and the backoff (%v)", got, want)
+	}
+
+	orch := core.New(plugin.CorePhases(pp), newMockDomainStorage(), core.WithConfig(core.OrchestratorConfig{MaxRetries: 1}))
+	if err := orch.Run(context.Background(), "request"); err != nil {
+		t.Fatalf("Run() error = %v, want the second attempt to succeed", err)
+	}
+	if stalling.calls != 2 {
+		t.Errorf("Draft ran %d times, want a timed-out attempt and a retry", stalling.calls)
+	}
+}
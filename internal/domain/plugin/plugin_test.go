@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,8 +33,9 @@ func (m *mockDomainAgent) ExecuteJSON(ctx context.Context, prompt string, input
 	return `{"result": "mock json"}`, nil
 }
 
-// Mock domain storage for testing
+// Mock domain storage for testing; phases may write from several goroutines
 type mockDomainStorage struct {
+	mu   sync.Mutex
 	data map[string][]byte
 }
 
@@ -44,11 +46,15 @@ func newMockDomainStorage() *mockDomainStorage {
 }
 
 func (m *mockDomainStorage) Save(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.data[key] = data
 	return nil
 }
 
 func (m *mockDomainStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	data, ok := m.data[key]
 	if !ok {
 		return nil, errors.New("not found")
@@ -57,6 +63,8 @@ func (m *mockDomainStorage) Load(ctx context.Context, key string) ([]byte, error
 }
 
 func (m *mockDomainStorage) Exists(ctx context.Context, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	_, ok := m.data[key]
 	return ok
 }
@@ -191,11 +199,15 @@ func (m *mockDomainPhase) CanRetry(err error) bool {
 }
 
 func (m *mockDomainStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	delete(m.data, key)
 	return nil
 }
 
 func (m *mockDomainStorage) List(ctx context.Context, pattern string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	var results []string
 	for key := range m.data {
 		results = append(results, key)
@@ -62,8 +62,11 @@ type QualityMetrics struct {
 	UserExperience string `json:"user_experience"`
 }
 
+// DialogueExchange is one step of the exploration. Prompt and Answer are the messages
+// actually exchanged; earlier exchanges are sent as history with each new prompt.
 type DialogueExchange struct {
 	Question string `json:"question"`
+	Prompt   string `json:"prompt"`
 	Answer   string `json:"answer"`
 	Insights []string `json:"insights"`
 	Timestamp time.Time `json:"timestamp"`
@@ -131,7 +134,7 @@ func (ce *ConversationalExplorer) Execute(ctx context.Context, input core.PhaseI
 func (ce *ConversationalExplorer) conductInitialDiscovery(ctx context.Context, request string, exploration *ProjectExploration) error {
 	prompt := ce.buildInitialDiscoveryPrompt(request)
 	
-	response, err := ce.agent.Execute(ctx, prompt, nil)
+	response, err := ce.ask(ctx, exploration, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to get initial discovery response: %w", err)
 	}
@@ -149,6 +152,7 @@ func (ce *ConversationalExplorer) conductInitialDiscovery(ctx context.Context, r
 	// Record the dialogue
 	exchange := DialogueExchange{
 		Question:  "What type of project are we building and what are the core requirements?",
+		Prompt:    prompt,
 		Answer:    response,
 		Insights:  discovery.Requirements,
 		Timestamp: time.Now(),
@@ -161,7 +165,7 @@ func (ce *ConversationalExplorer) conductInitialDiscovery(ctx context.Context, r
 func (ce *ConversationalExplorer) conductRequirementsAnalysis(ctx context.Context, exploration *ProjectExploration) error {
 	prompt := ce.buildRequirementsPrompt(exploration)
 	
-	response, err := ce.agent.Execute(ctx, prompt, nil)
+	response, err := ce.ask(ctx, exploration, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to get requirements analysis: %w", err)
 	}
@@ -176,6 +180,7 @@ func (ce *ConversationalExplorer) conductRequirementsAnalysis(ctx context.Contex
 
 	exchange := DialogueExchange{
 		Question:  "Let's break down the features and prioritize them based on user value and complexity",
+		Prompt:    prompt,
 		Answer:    response,
 		Insights:  ce.extractFeatureInsights(features),
 		Timestamp: time.Now(),
@@ -188,7 +193,7 @@ func (ce *ConversationalExplorer) conductRequirementsAnalysis(ctx context.Contex
 func (ce *ConversationalExplorer) conductTechnicalDiscussion(ctx context.Context, exploration *ProjectExploration) error {
 	prompt := ce.buildTechnicalPrompt(exploration)
 	
-	response, err := ce.agent.Execute(ctx, prompt, nil)
+	response, err := ce.ask(ctx, exploration, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to get technical discussion: %w", err)
 	}
@@ -204,6 +209,7 @@ func (ce *ConversationalExplorer) conductTechnicalDiscussion(ctx context.Context
 
 	exchange := DialogueExchange{
 		Question:  "What's the best technical approach and architecture for this project?",
+		Prompt:    prompt,
 		Answer:    response,
 		Insights:  []string{techChoices.TechStack.Rationale, techChoices.Architecture.Rationale},
 		Timestamp: time.Now(),
@@ -216,7 +222,7 @@ func (ce *ConversationalExplorer) conductTechnicalDiscussion(ctx context.Context
 func (ce *ConversationalExplorer) conductQualityAlignment(ctx context.Context, exploration *ProjectExploration) error {
 	prompt := ce.buildQualityPrompt(exploration)
 	
-	response, err := ce.agent.Execute(ctx, prompt, nil)
+	response, err := ce.ask(ctx, exploration, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to get quality alignment: %w", err)
 	}
@@ -232,6 +238,7 @@ func (ce *ConversationalExplorer) conductQualityAlignment(ctx context.Context, e
 
 	exchange := DialogueExchange{
 		Question:  "What are our quality goals and constraints for this project?",
+		Prompt:    prompt,
 		Answer:    response,
 		Insights:  constraints,
 		Timestamp: time.Now(),
@@ -241,6 +248,21 @@ func (ce *ConversationalExplorer) conductQualityAlignment(ctx context.Context, e
 	return nil
 }
 
+// ask sends prompt as the next message of the exploration dialogue. Agents without
+// conversation support only see the prompt, which restates what it builds on.
+func (ce *ConversationalExplorer) ask(ctx context.Context, exploration *ProjectExploration, prompt string) (string, error) {
+	conversational, ok := ce.agent.(core.ConversationalAgent)
+	if !ok {
+		return ce.agent.Execute(ctx, prompt, nil)
+	}
+
+	history := make([]string, 0, 2*len(exploration.DialogueHistory))
+	for _, exchange := range exploration.DialogueHistory {
+		history = append(history, exchange.Prompt, exchange.Answer)
+	}
+	return conversational.ExecuteTurn(ctx, history, prompt)
+}
+
 func (ce *ConversationalExplorer) buildInitialDiscoveryPrompt(request string) string {
 	return fmt.Sprintf(`You are a senior software architect having a natural conversation with a client about their project needs.
 
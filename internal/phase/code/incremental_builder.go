@@ -3,10 +3,12 @@ package code
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -209,9 +211,21 @@ func (ib *IncrementalBuilder) executePhase(ctx context.Context, phase BuildPhase
 }
 
 func (ib *IncrementalBuilder) generateFile(ctx context.Context, deliverable Deliverable, phase BuildPhase, plan *BuildPlan, exploration *ProjectExploration, progress *BuildProgress) error {
-	prompt := ib.buildFileGenerationPrompt(deliverable, phase, plan, exploration, progress)
-	
-	response, err := ib.agent.Execute(ctx, prompt, nil)
+	// With tools the model reads the earlier files it needs instead of getting all of them
+	var response string
+	var err error
+	toolAgent, useTools := ib.agent.(core.ToolAgent)
+	if useTools {
+		prompt := ib.buildFileGenerationPrompt(deliverable, phase, plan, exploration, progress, false)
+		response, err = toolAgent.ExecuteWithTools(ctx, prompt, nil, ib.fileTools(progress))
+		if errors.Is(err, core.ErrToolsUnsupported) {
+			useTools = false
+		}
+	}
+	if !useTools {
+		prompt := ib.buildFileGenerationPrompt(deliverable, phase, plan, exploration, progress, true)
+		response, err = ib.agent.Execute(ctx, prompt, nil)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to generate file content: %w", err)
 	}
@@ -241,6 +255,40 @@ func (ib *IncrementalBuilder) generateFile(ctx context.Context, deliverable Deli
 	return nil
 }
 
+type readFileArgs struct {
+	Path string `json:"path"`
+}
+
+// fileTools lets the model read files generated earlier in the build
+func (ib *IncrementalBuilder) fileTools(progress *BuildProgress) []core.Tool {
+	return []core.Tool{{
+		Name:        "read_file",
+		Description: "Read the full content of a file already generated for this project, by its path.",
+		Args:        readFileArgs{},
+		Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args readFileArgs
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return "", err
+			}
+			content, ok := progress.GeneratedCode[args.Path]
+			if !ok {
+				return "", fmt.Errorf("no generated file at %q; generated files: %s", args.Path, strings.Join(generatedPaths(progress), ", "))
+			}
+			return content, nil
+		},
+	}}
+}
+
+// generatedPaths lists the files generated so far in a stable order
+func generatedPaths(progress *BuildProgress) []string {
+	paths := make([]string, 0, len(progress.GeneratedCode))
+	for path := range progress.GeneratedCode {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 func (ib *IncrementalBuilder) saveFileToDisk(ctx context.Context, filePath, content string) error {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(filePath)
@@ -350,13 +398,21 @@ Return your response in this JSON format:
 		exploration.QualityGoals.Maintainability)
 }
 
-func (ib *IncrementalBuilder) buildFileGenerationPrompt(deliverable Deliverable, phase BuildPhase, plan *BuildPlan, exploration *ProjectExploration, progress *BuildProgress) string {
+// buildFileGenerationPrompt writes the prompt for one file. With inlineFiles the
+// previously generated files are included in full; otherwise only their paths are
+// listed for the model to read with the read_file tool.
+func (ib *IncrementalBuilder) buildFileGenerationPrompt(deliverable Deliverable, phase BuildPhase, plan *BuildPlan, exploration *ProjectExploration, progress *BuildProgress, inlineFiles bool) string {
 	// Build context from previous files
 	contextFiles := make([]string, 0)
-	for path, content := range progress.GeneratedCode {
-		if len(content) > 0 {
-			contextFiles = append(contextFiles, fmt.Sprintf("%s:\n"+"`"+"``"+"\n%s\n"+"`"+"``", path, content))
+	if inlineFiles {
+		for path, content := range progress.GeneratedCode {
+			if len(content) > 0 {
+				contextFiles = append(contextFiles, fmt.Sprintf("%s:\n"+"`"+"``"+"\n%s\n"+"`"+"``", path, content))
+			}
 		}
+	} else if paths := generatedPaths(progress); len(paths) > 0 {
+		contextFiles = append(contextFiles, "- "+strings.Join(paths, "\n- "),
+			"Use the read_file tool to read any of these you need to integrate with.")
 	}
 
 	return fmt.Sprintf(`You are implementing file "%s" as part of phase "%s" in a %s project.
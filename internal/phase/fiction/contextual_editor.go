@@ -2,6 +2,8 @@ package fiction
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -199,10 +201,12 @@ Focus on big-picture story issues, not line editing yet.`,
 	slog.Info("Overall novel assessment completed", "notes_length", len(overallNotes))
 
 	// Now edit each chapter with full novel context
+	tools := e.storyTools(progress, func(n int) string { return e.extractChapterContent(fullManuscript, n) })
 	for _, chapter := range progress.NovelPlan.Chapters {
 		chapterContent := e.extractChapterContent(fullManuscript, chapter.Number)
 		
-		editPrompt := fmt.Sprintf(`
+		editPrompt := func(storyContext string) string {
+			return fmt.Sprintf(`
 You are editing Chapter %d of this novel. You have read the ENTIRE novel, so you know:
 
 FULL STORY CONTEXT:
@@ -222,10 +226,11 @@ Improve this chapter for:
 5. Internal consistency (timeline, details, character knowledge)
 
 Return the improved chapter, maintaining the same basic events but enhancing the storytelling:`,
-			chapter.Number, truncateString(fullManuscript, 6000),
-			truncateString(overallNotes, 1000), chapter.Number, chapter.Title, chapterContent)
+				chapter.Number, storyContext,
+				truncateString(overallNotes, 1000), chapter.Number, chapter.Title, chapterContent)
+		}
 
-		editedContent, err := e.agent.Execute(ctx, editPrompt, nil)
+		editedContent, err := e.executeEdit(ctx, editPrompt(truncateString(fullManuscript, 6000)), editPrompt(e.storyOutline(progress)), tools)
 		if err != nil {
 			slog.Warn("Failed to edit chapter", "chapter", chapter.Number, "error", err)
 			editedContent = chapterContent // Keep original
@@ -262,8 +267,10 @@ func (e *ContextualEditor) editorialPassPacing(ctx context.Context, previousPass
 	// Assemble manuscript from previous pass
 	manuscript := e.assembleFromChapterEdits(previousPass.ChapterEdits)
 
+	tools := e.storyTools(progress, func(n int) string { return previousPass.ChapterEdits[n].EditedContent })
 	for chapterNum, prevEdit := range previousPass.ChapterEdits {
-		pacingPrompt := fmt.Sprintf(`
+		pacingPrompt := func(storyContext string) string {
+			return fmt.Sprintf(`
 You are doing a pacing and flow pass on Chapter %d. You know the full story context.
 
 FULL NOVEL CONTEXT (for pacing awareness):
@@ -280,9 +287,10 @@ Improve this chapter for:
 5. Action/description balance (right mix for pacing)
 
 Enhance the pacing and flow while keeping the same basic content:`,
-			chapterNum, truncateString(manuscript, 6000), chapterNum, prevEdit.EditedContent)
+				chapterNum, storyContext, chapterNum, prevEdit.EditedContent)
+		}
 
-		editedContent, err := e.agent.Execute(ctx, pacingPrompt, nil)
+		editedContent, err := e.executeEdit(ctx, pacingPrompt(truncateString(manuscript, 6000)), pacingPrompt(e.storyOutline(progress)), tools)
 		if err != nil {
 			slog.Warn("Failed to improve pacing", "chapter", chapterNum, "error", err)
 			editedContent = prevEdit.EditedContent // Keep previous version
@@ -417,6 +425,95 @@ Keep all essential story elements but make it more focused:`,
 	return pass, nil
 }
 
+// executeEdit sends an editing prompt. Agents that can call tools get toolPrompt, which
+// replaces the truncated manuscript with an outline, and look up the chapters and
+// characters they need; others get inlinePrompt.
+func (e *ContextualEditor) executeEdit(ctx context.Context, inlinePrompt, toolPrompt string, tools []core.Tool) (string, error) {
+	if toolAgent, ok := e.agent.(core.ToolAgent); ok {
+		response, err := toolAgent.ExecuteWithTools(ctx, toolPrompt, nil, tools)
+		if !errors.Is(err, core.ErrToolsUnsupported) {
+			return response, err
+		}
+	}
+	return e.agent.Execute(ctx, inlinePrompt, nil)
+}
+
+// storyOutline summarizes every chapter and points the model at the story tools
+func (e *ContextualEditor) storyOutline(progress NovelProgress) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE: %s\nPREMISE: %s\n\nCHAPTERS:\n", progress.NovelPlan.Title, progress.NovelPlan.Synopsis)
+	for _, chapter := range progress.NovelPlan.Chapters {
+		fmt.Fprintf(&b, "%d. %s - %s\n", chapter.Number, chapter.Title, chapter.Summary)
+	}
+	b.WriteString("\nUse read_chapter to read any chapter in full, lookup_character to check a character's profile and count_words to measure a chapter.")
+	return b.String()
+}
+
+type chapterArgs struct {
+	Chapter int `json:"chapter"`
+}
+
+type characterArgs struct {
+	Name string `json:"name"`
+}
+
+// storyTools lets the model query the story bible and the current text of any chapter
+func (e *ContextualEditor) storyTools(progress NovelProgress, chapterText func(n int) string) []core.Tool {
+	readChapter := func(raw json.RawMessage) (string, error) {
+		var args chapterArgs
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return "", err
+		}
+		text := chapterText(args.Chapter)
+		if text == "" {
+			return "", fmt.Errorf("there is no chapter %d", args.Chapter)
+		}
+		return text, nil
+	}
+
+	return []core.Tool{
+		{
+			Name:        "read_chapter",
+			Description: "Read the current text of a chapter by its number.",
+			Args:        chapterArgs{},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				return readChapter(raw)
+			},
+		},
+		{
+			Name:        "count_words",
+			Description: "Count the words in a chapter by its number.",
+			Args:        chapterArgs{},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				text, err := readChapter(raw)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%d", e.countWords(text)), nil
+			},
+		},
+		{
+			Name:        "lookup_character",
+			Description: "Look up a main character in the story bible by name: their role, description and arc.",
+			Args:        characterArgs{},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args characterArgs
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", err
+				}
+				names := make([]string, 0, len(progress.NovelPlan.MainCharacters))
+				for _, c := range progress.NovelPlan.MainCharacters {
+					if strings.Contains(strings.ToLower(c.Name), strings.ToLower(args.Name)) {
+						return fmt.Sprintf("%s (%s)\n%s\nArc: %s", c.Name, c.Role, c.Description, c.Arc), nil
+					}
+					names = append(names, c.Name)
+				}
+				return "", fmt.Errorf("no character named %q; main characters: %s", args.Name, strings.Join(names, ", "))
+			},
+		},
+	}
+}
+
 func (e *ContextualEditor) assessQuality(finalPass EditorialPass, progress NovelProgress) QualityMetrics {
 	finalTotal := 0
 	for _, edit := range finalPass.ChapterEdits {
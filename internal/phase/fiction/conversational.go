@@ -19,6 +19,8 @@ type ConversationalPlanner struct {
 	conversation []ConversationTurn
 }
 
+// ConversationTurn is one question and answer. The turns so far are sent as message
+// history with each new question, so the model sees the whole conversation.
 type ConversationTurn struct {
 	Question string `json:"question"`
 	Answer   string `json:"answer"`
@@ -48,6 +50,9 @@ func (p *ConversationalPlanner) Execute(ctx context.Context, input core.PhaseInp
 		"phase", p.Name(),
 		"request_preview", truncateString(input.Request, 100))
 
+	// A retried phase starts the conversation over
+	p.conversation = p.conversation[:0]
+
 	// Start with understanding what the user wants
 	storyCore, err := p.discoverStoryCore(ctx, input.Request)
 	if err != nil {
@@ -100,11 +105,9 @@ func (p *ConversationalPlanner) discoverStoryCore(ctx context.Context, userReque
 	}
 
 	// Extract setting naturally
-	settingQuestion := fmt.Sprintf(`
-	For a story about: "%s"
-	
-	Where and when should this take place? Just describe the setting naturally - 
-	don't worry about being comprehensive, just what feels right for this story.`, response)
+	settingQuestion := `
+	Where and when should this story take place? Just describe the setting naturally - 
+	don't worry about being comprehensive, just what feels right for this story.`
 
 	settingResponse, err := p.askAI(ctx, settingQuestion, "choosing setting")
 	if err != nil {
@@ -112,12 +115,9 @@ func (p *ConversationalPlanner) discoverStoryCore(ctx context.Context, userReque
 	}
 
 	// Extract characters naturally
-	characterQuestion := fmt.Sprintf(`
-	For this story: "%s"
-	Set in: "%s"
-	
-	Who are the main people we should care about? Just describe them as you'd 
-	tell a friend about interesting people you met. Don't make it formal.`, response, settingResponse)
+	characterQuestion := `
+	Who are the main people we should care about in this story and setting? Just describe 
+	them as you'd tell a friend about interesting people you met. Don't make it formal.`
 
 	characterResponse, err := p.askAI(ctx, characterQuestion, "meeting characters")
 	if err != nil {
@@ -136,29 +136,19 @@ func (p *ConversationalPlanner) discoverStoryCore(ctx context.Context, userReque
 
 // refinePremise improves the story through iterative conversation
 func (p *ConversationalPlanner) refinePremise(ctx context.Context, core *StoryCore) (*StoryCore, error) {
-	refinementQuestion := fmt.Sprintf(`
-	We have this story developing:
-	
-	Core idea: %s
-	Setting: %s
-	Characters: %s
-	
-	What's one thing that would make this story more interesting or compelling? 
-	Just suggest one improvement - don't rewrite everything.`, 
-	core.Premise, core.Setting, strings.Join(core.Characters, ", "))
+	refinementQuestion := `
+	Looking at the story we have developing - the core idea, the setting and the characters - 
+	what's one thing that would make it more interesting or compelling? 
+	Just suggest one improvement - don't rewrite everything.`
 
-	improvement, err := p.askAI(ctx, refinementQuestion, "improving the story")
-	if err != nil {
+	if _, err := p.askAI(ctx, refinementQuestion, "improving the story"); err != nil {
 		return core, nil // Return original if refinement fails
 	}
 
 	// Apply improvement naturally
-	applyQuestion := fmt.Sprintf(`
-	Original premise: %s
-	Suggested improvement: %s
-	
-	Now describe the improved story premise in one clear paragraph. 
-	Make it sound natural and engaging.`, core.Premise, improvement)
+	applyQuestion := `
+	Now describe the story premise with that improvement in one clear paragraph. 
+	Make it sound natural and engaging.`
 
 	improvedPremise, err := p.askAI(ctx, applyQuestion, "applying improvement")
 	if err != nil {
@@ -171,23 +161,18 @@ func (p *ConversationalPlanner) refinePremise(ctx context.Context, core *StoryCo
 
 // buildChapterFlow creates chapter structure through natural progression
 func (p *ConversationalPlanner) buildChapterFlow(ctx context.Context, core *StoryCore) ([]map[string]interface{}, error) {
-	flowQuestion := fmt.Sprintf(`
-	For this story: "%s"
-	
-	How should this story unfold? Describe the natural flow from beginning to end.
+	flowQuestion := `
+	How should this improved story unfold? Describe the natural flow from beginning to end.
 	Don't worry about exact chapters - just tell me how the story should progress.
-	What happens first, then what, then what? Keep it conversational.`, core.Premise)
+	What happens first, then what, then what? Keep it conversational.`
 
-	flow, err := p.askAI(ctx, flowQuestion, "planning story flow")
-	if err != nil {
+	if _, err := p.askAI(ctx, flowQuestion, "planning story flow"); err != nil {
 		return nil, err
 	}
 
 	// Convert flow into loose chapters
-	chapterQuestion := fmt.Sprintf(`
-	Story flow: "%s"
-	
-	Break this flow into natural chapters. For each chapter, just give me:
+	chapterQuestion := `
+	Break that flow into natural chapters. For each chapter, just give me:
 	- A simple title
 	- What happens in that chapter (one sentence)
 	
@@ -195,7 +180,7 @@ func (p *ConversationalPlanner) buildChapterFlow(ctx context.Context, core *Stor
 	Chapter 1: Title - What happens
 	Chapter 2: Title - What happens
 	
-	Keep it simple and natural.`, flow)
+	Keep it simple and natural.`
 
 	chapters, err := p.askAI(ctx, chapterQuestion, "organizing chapters")
 	if err != nil {
@@ -250,7 +235,13 @@ func (p *ConversationalPlanner) askAI(ctx context.Context, question, context str
 		"context", context,
 		"question_length", len(question))
 
-	response, err := p.agent.Execute(ctx, question, nil)
+	var response string
+	var err error
+	if conversational, ok := p.agent.(core.ConversationalAgent); ok {
+		response, err = conversational.ExecuteTurn(ctx, p.history(), question)
+	} else {
+		response, err = p.agent.Execute(ctx, p.transcript()+question, nil)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -270,6 +261,25 @@ func (p *ConversationalPlanner) askAI(ctx context.Context, question, context str
 	return response, nil
 }
 
+// history flattens the turns so far into alternating user and assistant messages
+func (p *ConversationalPlanner) history() []string {
+	messages := make([]string, 0, 2*len(p.conversation))
+	for _, turn := range p.conversation {
+		messages = append(messages, turn.Question, turn.Answer)
+	}
+	return messages
+}
+
+// transcript writes the turns so far into a prompt prefix for agents that can't take
+// message history
+func (p *ConversationalPlanner) transcript() string {
+	var b strings.Builder
+	for _, turn := range p.conversation {
+		fmt.Fprintf(&b, "Earlier you were asked:%s\n\nYou answered:\n%s\n\n", turn.Question, turn.Answer)
+	}
+	return b.String()
+}
+
 // saveConversation stores the conversation for later review
 func (p *ConversationalPlanner) saveConversation(ctx context.Context, sessionID string) error {
 	conversationData := map[string]interface{}{
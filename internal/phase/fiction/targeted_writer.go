@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/phase"
 )
 
 // TargetedWriter writes individual scenes with specific word targets and full context
@@ -15,8 +18,49 @@ type TargetedWriter struct {
 	BasePhase
 	agent   core.Agent
 	storage core.Storage
+	pool    *phase.WorkerPool[Chapter, chapterResult]
+	batch   bool
 }
 
+type TargetedWriterOption func(*TargetedWriter)
+
+// WithChapterPool writes up to workers chapters at once; a chapter's scenes are still
+// written in order. Chapters written side by side can't see how the one before ends.
+// Pass phase.WithConcurrencyLimit with the AI client's limiter to have it back off when
+// the provider rate-limits.
+func WithChapterPool(workers int, opts ...phase.WorkerPoolOption) TargetedWriterOption {
+	return func(w *TargetedWriter) {
+		w.pool = newChapterPool(append([]phase.WorkerPoolOption{phase.WithWorkers(workers)}, opts...)...)
+	}
+}
+
+// WithBatchMode drafts every scene in one request to the provider's batch API when the
+// agent supports it. Batches cost less but can take hours, and scenes drafted together
+// can't see how the scene before them ends. Scenes the batch doesn't return are written
+// one at a time afterwards.
+func WithBatchMode() TargetedWriterOption {
+	return func(w *TargetedWriter) {
+		w.batch = true
+	}
+}
+
+func newChapterPool(opts ...phase.WorkerPoolOption) *phase.WorkerPool[Chapter, chapterResult] {
+	return phase.NewWorkerPool[Chapter, chapterResult](append([]phase.WorkerPoolOption{
+		phase.WithWorkers(1),
+		phase.WithBufferSize(10),
+		phase.WithTimeout(30*time.Minute),
+	}, opts...)...)
+}
+
+// chapterResult is what the pool returns for a written chapter
+type chapterResult struct {
+	number int
+	words  int
+}
+
+func (r chapterResult) ItemID() string { return fmt.Sprintf("chapter_%d", r.number) }
+func (r chapterResult) Error() error   { return nil }
+
 type SceneOutput struct {
 	ChapterNumber int    `json:"chapter_number"`
 	SceneNumber   int    `json:"scene_number"`
@@ -34,12 +78,17 @@ type NovelProgress struct {
 	NovelPlan         NovelPlan              `json:"novel_plan"`
 }
 
-func NewTargetedWriter(agent core.Agent, storage core.Storage) *TargetedWriter {
-	return &TargetedWriter{
+func NewTargetedWriter(agent core.Agent, storage core.Storage, opts ...TargetedWriterOption) *TargetedWriter {
+	w := &TargetedWriter{
 		BasePhase: NewBasePhase("Targeted Writing", 90*time.Minute),
 		agent:     agent,
 		storage:   storage,
+		pool:      newChapterPool(),
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
 }
 
 func (w *TargetedWriter) Execute(ctx context.Context, input core.PhaseInput) (core.PhaseOutput, error) {
@@ -70,63 +119,20 @@ func (w *TargetedWriter) Execute(ctx context.Context, input core.PhaseInput) (co
 		NovelPlan:         plan,
 	}
 
-	// Write each scene systematically
-	for _, chapter := range plan.Chapters {
-		// Calculate target words for this chapter
-		chapterTargetWords := wordsPerChapter
-		sceneTargetWords := chapterTargetWords / len(chapter.Scenes)
-		
-		slog.Info("Writing chapter scenes",
-			"chapter", chapter.Number,
-			"chapter_title", chapter.Title,
-			"target_words", chapterTargetWords,
-			"scenes", len(chapter.Scenes))
-
-		chapterWordCount := 0
-
-		for _, scene := range chapter.Scenes {
-			sceneKey := fmt.Sprintf("ch%d_sc%d", chapter.Number, scene.SceneNum)
-			
-			slog.Info("Writing individual scene",
-				"chapter", chapter.Number,
-				"scene", scene.SceneNum,
-				"target_words", sceneTargetWords,
-				"summary", truncateString(scene.Summary, 50))
-
-			// Write the scene with full context
-			sceneOutput, err := w.writeScene(ctx, chapter, scene, plan, progress, sceneTargetWords)
-			if err != nil {
-				return core.PhaseOutput{}, fmt.Errorf("writing scene %s: %w", sceneKey, err)
-			}
-
-			// Track progress
-			progress.Scenes[sceneKey] = sceneOutput
-			chapterWordCount += sceneOutput.ActualWords
-			progress.TotalWordsSoFar += sceneOutput.ActualWords
-
-			// Save individual scene
-			sceneFile := fmt.Sprintf("scenes/%s.md", sceneKey)
-			if err := w.storage.Save(ctx, sceneFile, []byte(sceneOutput.Content)); err != nil {
-				slog.Warn("Failed to save scene", "scene", sceneKey, "error", err)
-			}
-
-			slog.Info("Scene completed",
-				"scene", sceneKey,
-				"actual_words", sceneOutput.ActualWords,
-				"target_words", sceneTargetWords,
-				"chapter_progress", fmt.Sprintf("%d/%d words", chapterWordCount, chapterTargetWords))
-		}
-
-		progress.CompletedChapters = append(progress.CompletedChapters, chapter.Number)
+	var drafts map[string]string
+	if w.batch {
+		drafts = w.draftScenesBatch(ctx, input.SessionID, plan, progress, wordsPerChapter)
+	}
 
-		slog.Info("Chapter completed",
-			"chapter", chapter.Number,
-			"actual_words", chapterWordCount,
-			"target_words", chapterTargetWords,
-			"total_progress", fmt.Sprintf("%d/%d words (%.1f%%)", 
-				progress.TotalWordsSoFar, targetWords,
-				float64(progress.TotalWordsSoFar)/float64(targetWords)*100))
+	// Chapters may be written concurrently, so progress is shared under a lock
+	var mu sync.Mutex
+	_, err := w.pool.ProcessWithErrGroup(ctx, plan.Chapters, func(ctx context.Context, chapter Chapter) (chapterResult, error) {
+		return w.writeChapter(ctx, chapter, plan, &progress, &mu, wordsPerChapter, drafts)
+	})
+	if err != nil {
+		return core.PhaseOutput{}, err
 	}
+	sort.Ints(progress.CompletedChapters)
 
 	slog.Info("Targeted writing completed",
 		"phase", w.Name(),
@@ -138,21 +144,84 @@ func (w *TargetedWriter) Execute(ctx context.Context, input core.PhaseInput) (co
 	return core.PhaseOutput{Data: progress}, nil
 }
 
-func (w *TargetedWriter) writeScene(ctx context.Context, chapter Chapter, scene Scene, plan NovelPlan, progress NovelProgress, targetWords int) (SceneOutput, error) {
-	// Build comprehensive context for the scene
-	contextPrompt := w.buildSceneContext(chapter, scene, plan, progress)
-	
-	// Create targeted writing prompt
-	writingPrompt := fmt.Sprintf(`%s
+// writeChapter writes a chapter's scenes in order, recording each in progress. Scenes
+// with a batch draft use it as they are.
+func (w *TargetedWriter) writeChapter(ctx context.Context, chapter Chapter, plan NovelPlan, progress *NovelProgress, mu *sync.Mutex, wordsPerChapter int, drafts map[string]string) (chapterResult, error) {
+	// Calculate target words for this chapter
+	chapterTargetWords := wordsPerChapter
+	sceneTargetWords := chapterTargetWords / len(chapter.Scenes)
 
-Now write this specific scene. Requirements:
-- Target length: %d words (this is important for pacing)
-- Scene objective: %s
-- Make it engaging and well-written
-- Include dialogue, action, and description as appropriate
-- End at a natural stopping point that flows to the next scene
+	slog.Info("Writing chapter scenes",
+		"chapter", chapter.Number,
+		"chapter_title", chapter.Title,
+		"target_words", chapterTargetWords,
+		"scenes", len(chapter.Scenes))
 
-Write the scene now:`, contextPrompt, targetWords, scene.Summary)
+	chapterWordCount := 0
+
+	for _, scene := range chapter.Scenes {
+		sceneKey := fmt.Sprintf("ch%d_sc%d", chapter.Number, scene.SceneNum)
+
+		slog.Info("Writing individual scene",
+			"chapter", chapter.Number,
+			"scene", scene.SceneNum,
+			"target_words", sceneTargetWords,
+			"summary", truncateString(scene.Summary, 50))
+
+		sceneOutput, drafted := SceneOutput{}, false
+		if draft, ok := drafts[batchKey(chapter.Number, scene.SceneNum)]; ok {
+			sceneOutput, drafted = w.sceneOutput(chapter, scene, draft, sceneTargetWords), true
+		}
+		if !drafted {
+			// Write the scene with full context
+			mu.Lock()
+			contextPrompt := w.buildSceneContext(chapter, scene, plan, *progress)
+			mu.Unlock()
+			var err error
+			if sceneOutput, err = w.writeScene(ctx, chapter, scene, contextPrompt, sceneTargetWords); err != nil {
+				return chapterResult{}, fmt.Errorf("writing scene %s: %w", sceneKey, err)
+			}
+		}
+
+		// Track progress
+		mu.Lock()
+		progress.Scenes[sceneKey] = sceneOutput
+		progress.TotalWordsSoFar += sceneOutput.ActualWords
+		mu.Unlock()
+		chapterWordCount += sceneOutput.ActualWords
+
+		// Save individual scene
+		sceneFile := fmt.Sprintf("scenes/%s.md", sceneKey)
+		if err := w.storage.Save(ctx, sceneFile, []byte(sceneOutput.Content)); err != nil {
+			slog.Warn("Failed to save scene", "scene", sceneKey, "error", err)
+		}
+
+		slog.Info("Scene completed",
+			"scene", sceneKey,
+			"actual_words", sceneOutput.ActualWords,
+			"target_words", sceneTargetWords,
+			"chapter_progress", fmt.Sprintf("%d/%d words", chapterWordCount, chapterTargetWords))
+	}
+
+	mu.Lock()
+	progress.CompletedChapters = append(progress.CompletedChapters, chapter.Number)
+	totalWords := progress.TotalWordsSoFar
+	mu.Unlock()
+
+	slog.Info("Chapter completed",
+		"chapter", chapter.Number,
+		"actual_words", chapterWordCount,
+		"target_words", chapterTargetWords,
+		"total_progress", fmt.Sprintf("%d/%d words (%.1f%%)",
+			totalWords, progress.TargetWords,
+			float64(totalWords)/float64(progress.TargetWords)*100))
+
+	return chapterResult{number: chapter.Number, words: chapterWordCount}, nil
+}
+
+func (w *TargetedWriter) writeScene(ctx context.Context, chapter Chapter, scene Scene, contextPrompt string, targetWords int) (SceneOutput, error) {
+	// Create targeted writing prompt
+	writingPrompt := scenePrompt(contextPrompt, scene, targetWords)
 
 	slog.Debug("Scene writing prompt prepared",
 		"chapter", chapter.Number,
@@ -188,14 +257,136 @@ Write the scene now:`, contextPrompt, targetWords, scene.Summary)
 		}
 	}
 
+	return w.sceneOutput(chapter, scene, content, targetWords), nil
+}
+
+// scenePrompt asks for a scene given the context built for it
+func scenePrompt(contextPrompt string, scene Scene, targetWords int) string {
+	return fmt.Sprintf(`%s
+
+Now write this specific scene. Requirements:
+- Target length: %d words (this is important for pacing)
+- Scene objective: %s
+- Make it engaging and well-written
+- Include dialogue, action, and description as appropriate
+- End at a natural stopping point that flows to the next scene
+
+Write the scene now:`, contextPrompt, targetWords, scene.Summary)
+}
+
+func (w *TargetedWriter) sceneOutput(chapter Chapter, scene Scene, content string, targetWords int) SceneOutput {
 	return SceneOutput{
 		ChapterNumber: chapter.Number,
 		SceneNumber:   scene.SceneNum,
 		Content:       content,
-		ActualWords:   actualWords,
+		ActualWords:   w.countWords(content),
 		TargetWords:   targetWords,
 		Title:         fmt.Sprintf("%s - Scene %d", chapter.Title, scene.SceneNum),
-	}, nil
+	}
+}
+
+// draftScenesBatch drafts every scene in one provider batch and waits for it, returning
+// the drafts keyed by batchKey. It returns nil when the agent can't batch; scenes
+// missing from the result are written individually. With a session ID the drafts are
+// recorded in the session's scene tracker, so a resumed run keeps them and collects a
+// batch that was still pending instead of submitting its scenes again.
+func (w *TargetedWriter) draftScenesBatch(ctx context.Context, sessionID string, plan NovelPlan, progress NovelProgress, wordsPerChapter int) map[string]string {
+	batcher, ok := w.agent.(core.BatchAgent)
+	if !ok {
+		slog.Warn("Agent does not support batches, writing scenes individually")
+		return nil
+	}
+
+	drafts := make(map[string]string)
+	var tracker *core.AtomicSceneTracker
+	if sessionID != "" {
+		totalScenes := 0
+		for _, chapter := range plan.Chapters {
+			totalScenes += len(chapter.Scenes)
+		}
+		tracker = core.NewAtomicSceneTracker(w.storage, sessionID, totalScenes)
+		if err := tracker.LoadProgress(ctx); err != nil {
+			slog.Warn("Failed to load scene progress", "error", err)
+		}
+		for key, scene := range tracker.GetCompletedScenes() {
+			drafts[key] = scene.Content
+		}
+	}
+
+	batchID := ""
+	if tracker != nil {
+		batchID = tracker.BatchID()
+	}
+	if batchID == "" {
+		prompts := make(map[string]string)
+		for _, chapter := range plan.Chapters {
+			targetWords := wordsPerChapter / len(chapter.Scenes)
+			for _, scene := range chapter.Scenes {
+				key := batchKey(chapter.Number, scene.SceneNum)
+				if _, done := drafts[key]; !done {
+					prompts[key] = scenePrompt(w.buildSceneContext(chapter, scene, plan, progress), scene, targetWords)
+				}
+			}
+		}
+		if len(prompts) == 0 {
+			return drafts
+		}
+
+		var err error
+		if batchID, err = batcher.SubmitBatch(ctx, prompts); err != nil {
+			slog.Warn("Batch submission failed, writing scenes individually", "error", err)
+			return drafts
+		}
+		if tracker != nil {
+			if err := tracker.SetBatchID(ctx, batchID); err != nil {
+				slog.Warn("Failed to record scene batch", "batch_id", batchID, "error", err)
+			}
+		}
+	} else {
+		slog.Info("Resuming pending scene batch", "batch_id", batchID)
+	}
+
+	slog.Info("Waiting for scene batch", "batch_id", batchID)
+	contents, err := batcher.WaitBatch(ctx, batchID)
+	if err != nil {
+		slog.Warn("Scene batch failed, writing scenes individually", "batch_id", batchID, "error", err)
+		// An interrupted run leaves the batch recorded for the next one to collect
+		if tracker != nil && ctx.Err() == nil {
+			tracker.SetBatchID(ctx, "")
+		}
+		return drafts
+	}
+
+	written := 0
+	for _, chapter := range plan.Chapters {
+		for _, scene := range chapter.Scenes {
+			content, ok := contents[batchKey(chapter.Number, scene.SceneNum)]
+			if !ok {
+				continue
+			}
+			drafts[batchKey(chapter.Number, scene.SceneNum)] = content
+			written++
+			if tracker != nil {
+				if err := tracker.MarkCompleted(ctx, chapter.Number, scene.SceneNum, content); err != nil {
+					slog.Error("Failed to record batched scene", "chapter", chapter.Number, "scene", scene.SceneNum, "error", err)
+				}
+			}
+		}
+	}
+
+	// Every draft the batch returned is recorded; the rest are written one at a time
+	if tracker != nil {
+		if err := tracker.SetBatchID(ctx, ""); err != nil {
+			slog.Warn("Failed to clear scene batch", "batch_id", batchID, "error", err)
+		}
+	}
+	slog.Info("Scene batch completed", "batch_id", batchID, "drafted", written)
+	return drafts
+}
+
+// batchKey identifies a scene in batches, matching the scene tracker's keys
+func batchKey(chapterNum, sceneNum int) string {
+	return fmt.Sprintf("chapter_%d_scene_%d", chapterNum, sceneNum)
 }
 
 func (w *TargetedWriter) buildSceneContext(chapter Chapter, scene Scene, plan NovelPlan, progress NovelProgress) string {
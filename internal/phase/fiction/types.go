@@ -56,8 +56,8 @@ type Scene struct {
 	ChapterTitle string                 `json:"chapter_title"`
 	Title        string                 `json:"title"`
 	Summary      string                 `json:"summary"`
-	Content      string                 `json:"content"`
-	Context      map[string]interface{} `json:"context"`
+	Content      string                 `json:"content,omitempty"` // Empty until the scene is written
+	Context      map[string]interface{} `json:"context,omitempty"`
 }
 
 // ID implements WorkItem interface
@@ -70,6 +70,16 @@ func (s Scene) Priority() int {
 	return s.ChapterNum*1000 + s.SceneNum
 }
 
+// ID implements WorkItem interface
+func (c Chapter) ID() string {
+	return fmt.Sprintf("chapter_%d", c.Number)
+}
+
+// Priority implements WorkItem interface - chapters are processed in order
+func (c Chapter) Priority() int {
+	return c.Number
+}
+
 // SceneResult represents a completed scene
 type SceneResult struct {
 	ChapterNum int    `json:"chapter_num"`
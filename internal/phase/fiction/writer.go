@@ -24,13 +24,15 @@ type Writer struct {
 
 type WriterOption func(*Writer)
 
-func WithWorkerPool(workers int) WriterOption {
+// WithWorkerPool writes up to workers scenes at once. Pass phase.WithConcurrencyLimit
+// with the AI client's limiter to have it back off when the provider rate-limits.
+func WithWorkerPool(workers int, opts ...phase.WorkerPoolOption) WriterOption {
 	return func(w *Writer) {
-		w.pool = phase.NewWorkerPool[Scene, SceneResult](
+		w.pool = phase.NewWorkerPool[Scene, SceneResult](append([]phase.WorkerPoolOption{
 			phase.WithWorkers(workers),
 			phase.WithBufferSize(10),
 			phase.WithTimeout(5*time.Minute),
-		)
+		}, opts...)...)
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -169,7 +170,9 @@ func (w *ResilientWriter) processChaptersResilient(ctx context.Context, plan Nov
 		if err != nil {
 			// Save partial progress before failing
 			if w.resumeEnabled && w.checkpointMgr != nil {
-				w.saveCheckpoint(ctx, globalIdx, plan, arch, results)
+				if err := w.saveCheckpoint(ctx, globalIdx, plan, arch, results); err != nil {
+					w.logger.Error("Failed to save checkpoint", "error", err)
+				}
 			}
 			return results, fmt.Errorf("failed to process chapter %d after retries: %w", scene.ChapterNum, err)
 		}
@@ -178,15 +181,28 @@ func (w *ResilientWriter) processChaptersResilient(ctx context.Context, plan Nov
 		results = append(results, result)
 		mu.Unlock()
 		
-		// Update tracker
+		// Update tracker and checkpoint periodically; on transactional storage the
+		// scene and the checkpoint that counts it commit together, or neither does
+		record := func(ctx context.Context) error {
+			if w.sceneTracker != nil {
+				if err := w.sceneTracker.MarkCompleted(ctx, result.ChapterNum, result.SceneNum, result.Content); err != nil {
+					return err
+				}
+			}
+			
+			if w.resumeEnabled && w.checkpointMgr != nil && (globalIdx+1)%w.checkpointEvery == 0 {
+				w.logger.Info("Creating checkpoint", "scenes_completed", globalIdx+1)
+				return w.saveCheckpoint(ctx, globalIdx+1, plan, arch, results)
+			}
+			return nil
+		}
 		if w.sceneTracker != nil {
-			w.sceneTracker.MarkCompleted(ctx, result.ChapterNum, result.SceneNum, result.Content)
+			err = w.sceneTracker.WithinTx(ctx, record)
+		} else {
+			err = core.WithinTx(ctx, w.storage, record)
 		}
-		
-		// Checkpoint periodically
-		if w.resumeEnabled && w.checkpointMgr != nil && (globalIdx+1)%w.checkpointEvery == 0 {
-			w.logger.Info("Creating checkpoint", "scenes_completed", globalIdx+1)
-			w.saveCheckpoint(ctx, globalIdx+1, plan, arch, results)
+		if err != nil {
+			w.logger.Warn("Failed to record completed scene", "chapter", result.ChapterNum, "scene", result.SceneNum, "error", err)
 		}
 	}
 	
@@ -263,7 +279,7 @@ func (w *ResilientWriter) writeScene(ctx context.Context, scene Scene) (SceneRes
 		return SceneResult{}, fmt.Errorf("loading prompt: %w", err)
 	}
 	
-	content, err := w.agent.Execute(ctx, prompt, "")
+	content, err := w.generateScene(ctx, scene, prompt)
 	if err != nil {
 		return SceneResult{}, err
 	}
@@ -275,6 +291,46 @@ func (w *ResilientWriter) writeScene(ctx context.Context, scene Scene) (SceneRes
 	}, nil
 }
 
+// partialFlushInterval bounds how often streamed scene text is written to the tracker
+const partialFlushInterval = 2 * time.Second
+
+// generateScene streams the scene when the agent supports it so that text produced before a
+// timeout is kept by the scene tracker; otherwise it falls back to a single blocking call
+func (w *ResilientWriter) generateScene(ctx context.Context, scene Scene, prompt string) (string, error) {
+	streamer, ok := w.agent.(core.StreamingAgent)
+	if !ok || w.sceneTracker == nil {
+		return w.agent.Execute(ctx, prompt, "")
+	}
+	
+	var buf strings.Builder
+	lastFlush := time.Now()
+	flush := func() {
+		// Use a fresh context so a scene timeout doesn't prevent saving what we have
+		saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := w.sceneTracker.SavePartial(saveCtx, scene.ChapterNum, scene.SceneNum, buf.String()); err != nil {
+			w.logger.Warn("Failed to save partial scene", "chapter", scene.ChapterNum, "error", err)
+		}
+		lastFlush = time.Now()
+	}
+	
+	content, err := streamer.ExecuteStream(ctx, prompt, "", func(chunk string) error {
+		buf.WriteString(chunk)
+		if time.Since(lastFlush) >= partialFlushInterval {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if buf.Len() > 0 {
+			flush()
+		}
+		return "", err
+	}
+	
+	return content, nil
+}
+
 func (w *ResilientWriter) createScenes(plan NovelPlan, arch NovelArchitecture, userRequest string) []Scene {
 	var scenes []Scene
 	
@@ -296,7 +352,7 @@ func (w *ResilientWriter) createScenes(plan NovelPlan, arch NovelArchitecture, u
 	return scenes
 }
 
-func (w *ResilientWriter) saveCheckpoint(ctx context.Context, completedScenes int, plan NovelPlan, arch NovelArchitecture, results []SceneResult) {
+func (w *ResilientWriter) saveCheckpoint(ctx context.Context, completedScenes int, plan NovelPlan, arch NovelArchitecture, results []SceneResult) error {
 	checkpoint := &core.Checkpoint{
 		ID:         w.sessionID,
 		PhaseIndex: 2, // Writing is typically the 3rd phase (0-indexed)
@@ -322,8 +378,9 @@ func (w *ResilientWriter) saveCheckpoint(ctx context.Context, completedScenes in
 	}
 	
 	if err := w.checkpointMgr.SaveWithSceneProgress(ctx, checkpoint, w.sceneTracker); err != nil {
-		w.logger.Error("Failed to save checkpoint", "error", err)
+		return fmt.Errorf("saving checkpoint: %w", err)
 	}
+	return nil
 }
 
 // ValidateInput validates the writer input
@@ -25,13 +25,24 @@ type WorkResult interface {
 // Processor defines the function signature for processing work items
 type Processor[T WorkItem, R WorkResult] func(context.Context, T) (R, error)
 
+// ConcurrencyLimit scales a pool's worker count at runtime, e.g. an AI client's rate
+// limiter backing off after 429s. Concurrency returns how many of max workers may run.
+type ConcurrencyLimit interface {
+	Concurrency(max int) int
+}
+
 // WorkerPool provides concurrent processing of work items
 type WorkerPool[T WorkItem, R WorkResult] struct {
 	workers    int
 	bufferSize int
 	timeout    time.Duration
+	limit      ConcurrencyLimit
 	mu         sync.RWMutex
 	results    []R
+
+	// Workers currently processing an item, and a signal when one finishes
+	active int
+	freed  chan struct{}
 }
 
 // WorkerPoolOption allows customization of worker pool behavior
@@ -41,6 +52,7 @@ type workerPoolConfig struct {
 	workers    int
 	bufferSize int
 	timeout    time.Duration
+	limit      ConcurrencyLimit
 }
 
 // WithWorkers sets the number of concurrent workers
@@ -70,6 +82,14 @@ func WithTimeout(timeout time.Duration) WorkerPoolOption {
 	}
 }
 
+// WithConcurrencyLimit lets limit shrink and grow the number of items processed at
+// once, up to the configured worker count
+func WithConcurrencyLimit(limit ConcurrencyLimit) WorkerPoolOption {
+	return func(c *workerPoolConfig) {
+		c.limit = limit
+	}
+}
+
 // NewWorkerPool creates a new worker pool with the specified configuration
 func NewWorkerPool[T WorkItem, R WorkResult](options ...WorkerPoolOption) *WorkerPool[T, R] {
 	config := workerPoolConfig{
@@ -86,7 +106,46 @@ func NewWorkerPool[T WorkItem, R WorkResult](options ...WorkerPoolOption) *Worke
 		workers:    config.workers,
 		bufferSize: config.bufferSize,
 		timeout:    config.timeout,
+		limit:      config.limit,
 		results:    make([]R, 0),
+		freed:      make(chan struct{}, 1),
+	}
+}
+
+// acquire waits until the concurrency limit allows another item to be processed and
+// returns the function that releases the slot
+func (p *WorkerPool[T, R]) acquire(ctx context.Context) (func(), error) {
+	if p.limit == nil {
+		return func() {}, nil
+	}
+
+	for {
+		p.mu.Lock()
+		if allowed := p.limit.Concurrency(p.workers); p.active < allowed {
+			p.active++
+			p.mu.Unlock()
+			return p.release, nil
+		}
+		p.mu.Unlock()
+
+		// The limit can also rise without a worker finishing, so poll as well
+		select {
+		case <-p.freed:
+		case <-time.After(250 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *WorkerPool[T, R]) release() {
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+
+	select {
+	case p.freed <- struct{}{}:
+	default:
 	}
 }
 
@@ -135,10 +194,17 @@ func (p *WorkerPool[T, R]) ProcessBasic(ctx context.Context, items []T, processo
 						"worker_id", workerID,
 						"item_id", item.ID(),
 					)
+					release, err := p.acquire(ctx)
+					if err != nil {
+						errorCh <- err
+						return
+					}
+
 					// Create a timeout context for this work item
 					itemCtx, cancel := context.WithTimeout(ctx, p.timeout)
 					result, err := processor(itemCtx, item)
 					cancel()
+					release()
 
 					if err != nil {
 						slog.Error("Worker failed to process item",
@@ -246,10 +312,16 @@ func (p *WorkerPool[T, R]) ProcessWithErrGroup(ctx context.Context, items []T, p
 						"item_id", item.ID(),
 						"item_priority", item.Priority(),
 					)
+					release, err := p.acquire(ctx)
+					if err != nil {
+						return err
+					}
+
 					// Create a timeout context for this work item
 					itemCtx, cancel := context.WithTimeout(ctx, p.timeout)
 					result, err := processor(itemCtx, item)
 					cancel()
+					release()
 
 					if err != nil {
 						slog.Error("Worker failed to process item",
@@ -351,6 +423,11 @@ func (p *WorkerPool[T, R]) ProcessWithSemaphore(ctx context.Context, items []T,
 			case <-ctx.Done():
 				return ctx.Err()
 			}
+			release, err := p.acquire(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
 
 			// Process the item with timeout
 			itemCtx, cancel := context.WithTimeout(ctx, p.timeout)
@@ -476,8 +553,12 @@ func (p *WorkerPool[T, R]) GetMetrics() WorkerPoolMetrics {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	workers := p.workers
+	if p.limit != nil {
+		workers = p.limit.Concurrency(p.workers)
+	}
 	return WorkerPoolMetrics{
-		Workers:        p.workers,
+		Workers:        workers,
 		BufferSize:     p.bufferSize,
 		Timeout:        p.timeout,
 		LastResultCount: len(p.results),
@@ -13,14 +13,25 @@ import (
 	"github.com/dotcommander/orc/internal/config"
 	"github.com/dotcommander/orc/internal/domain"
 	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/internal/phase"
+	pkgPlugin "github.com/dotcommander/orc/pkg/plugin"
 )
 
-// PluginIntegrator manages both domain (built-in) and external plugins
+// PluginIntegrator manages both domain (built-in) and external plugins.
+// External plugins with a manifest (plugin.yaml) are loaded by pkg/plugin's
+// Loader into the domain registry; bare orc-* files are only listed.
 type PluginIntegrator struct {
 	config         *config.Config
 	domainRegistry *domainPlugin.DomainRegistry
 	externalPlugins map[string]ExternalPlugin
 	logger         *slog.Logger
+
+	loader      *pkgPlugin.Loader
+	discoverer  *pkgPlugin.Discoverer
+	security    *pkgPlugin.SecurityManager
+	services    pkgPlugin.HostServices
+	entryPoints map[string]bool // Files of plugins loaded from a manifest
+	limit       phase.ConcurrencyLimit
 }
 
 // ExternalPlugin represents a dynamically loaded plugin
@@ -59,23 +70,58 @@ type PluginError struct {
 
 // NewPluginIntegrator creates a new plugin integrator
 func NewPluginIntegrator(cfg *config.Config, logger *slog.Logger) *PluginIntegrator {
+	registry := domainPlugin.NewDomainRegistry()
+	discoverer := pkgPlugin.NewDiscoverer(logger)
+	discoverer.SetSearchPaths(cfg.Plugins.DiscoveryPaths)
+	security := pkgPlugin.NewSecurityManager(logger)
+
 	return &PluginIntegrator{
 		config:          cfg,
-		domainRegistry:  domainPlugin.NewDomainRegistry(),
+		domainRegistry:  registry,
 		externalPlugins: make(map[string]ExternalPlugin),
 		logger:          logger,
+		loader:          pkgPlugin.NewLoader(logger, discoverer, registry),
+		discoverer:      discoverer,
+		security:        security,
+		services:        pkgPlugin.HostServices{Security: security},
+		entryPoints:     make(map[string]bool),
 	}
 }
 
-// InitializeBuiltinPlugins now only logs that built-in plugins are deprecated
+// SetHostVersion sets the orc version checked against the versions external
+// plugins' manifests support
+func (pi *PluginIntegrator) SetHostVersion(version string) {
+	pi.services.HostVersion = version
+}
+
+// SetConcurrencyLimit makes the built-in plugins' worker pools follow limit, usually
+// the AI client's rate limiter, so they shrink while the provider is rate-limiting
+func (pi *PluginIntegrator) SetConcurrencyLimit(limit phase.ConcurrencyLimit) {
+	pi.limit = limit
+}
+
+// InitializeBuiltinPlugins registers the fiction and code plugins with the domain registry.
+// Plugins disabled in configuration are skipped; re-initializing replaces earlier instances.
 func (pi *PluginIntegrator) InitializeBuiltinPlugins(domainAgent domain.Agent, storage domain.Storage, promptsDir string, aiClient agent.AIClient) error {
-	pi.logger.Info("Built-in plugins are deprecated. Use external plugins in plugins/ directory")
-	
-	// TODO: Remove this method entirely once migration is complete
-	// For now, we'll try to load fiction and code as external plugins
-	
-	pi.logger.Info("Attempting to load fiction and code as external plugins")
-	
+	pi.services.Agent = domainAgent
+	pi.services.Storage = storage
+
+	builtins := []domainPlugin.DomainPlugin{
+		domainPlugin.NewFictionPlugin(domainAgent, storage, promptsDir, aiClient).
+			WithWriterPool(pi.config.Limits.MaxConcurrentWriters, pi.limit).
+			WithBatchMode(pi.pluginSetting("fiction", "batch") == true),
+		domainPlugin.NewCodePlugin(domainAgent, storage, promptsDir, aiClient),
+	}
+
+	for _, p := range builtins {
+		if !pi.isPluginEnabled(p.Name()) {
+			pi.logger.Info("Built-in plugin disabled by configuration", "name", p.Name())
+			continue
+		}
+		pi.domainRegistry.Replace(p)
+		pi.logger.Debug("Registered built-in plugin", "name", p.Name())
+	}
+
 	return nil
 }
 
@@ -98,6 +144,8 @@ func (pi *PluginIntegrator) DiscoverExternalPlugins(ctx context.Context) (*Plugi
 		"paths", pi.config.Plugins.DiscoveryPaths,
 		"max_plugins", pi.config.Plugins.Settings.MaxExternalPlugins)
 	
+	pi.loadManifestPlugins(result)
+
 	for _, searchPath := range pi.config.Plugins.DiscoveryPaths {
 		if err := pi.discoverPluginsInPath(ctx, searchPath, result); err != nil {
 			pi.logger.Warn("Error discovering plugins in path", 
@@ -118,6 +166,62 @@ func (pi *PluginIntegrator) DiscoverExternalPlugins(ctx context.Context) (*Plugi
 	return result, nil
 }
 
+// loadManifestPlugins loads the enabled plugins whose manifests are under the
+// discovery paths, up to the configured maximum. Each gets the default security
+// policy, which grants agent and storage calls back into orc; network access is
+// only withheld when sandboxing is enabled.
+func (pi *PluginIntegrator) loadManifestPlugins(result *PluginDiscoveryResult) {
+	manifests, err := pi.discoverer.Discover()
+	if err != nil {
+		result.Errors = append(result.Errors, PluginError{Error: err.Error()})
+		return
+	}
+
+	var enabled []*pkgPlugin.Manifest
+	for _, manifest := range manifests {
+		if !pi.isPluginEnabled(manifest.Name) {
+			pi.logger.Info("External plugin disabled by configuration", "name", manifest.Name)
+			continue
+		}
+		if len(enabled) >= pi.config.Plugins.Settings.MaxExternalPlugins {
+			pi.logger.Warn("Maximum external plugins reached",
+				"max", pi.config.Plugins.Settings.MaxExternalPlugins)
+			break
+		}
+		if _, exists := pi.security.GetPolicy(manifest.Name); !exists {
+			policy := pkgPlugin.DefaultSecurityPolicy()
+			if !pi.config.Plugins.Settings.EnableSandboxing {
+				policy.Capabilities[pkgPlugin.CapabilityNetwork] = true
+			}
+			pi.security.SetPolicy(manifest.Name, policy)
+		}
+		enabled = append(enabled, manifest)
+	}
+
+	pi.loader.SetHostServices(pi.services)
+	if err := pi.loader.LoadManifests(enabled); err != nil {
+		pi.logger.Warn("Failed to load external plugins", "error", err)
+		result.Errors = append(result.Errors, PluginError{Error: err.Error()})
+	}
+
+	for _, manifest := range enabled {
+		path := filepath.Join(manifest.Location, manifest.EntryPoint)
+		info := ExternalPluginInfo{
+			Name:        manifest.Name,
+			Path:        path,
+			Description: manifest.Description,
+			Version:     manifest.Version,
+			Compatible:  pi.loader.IsLoaded(manifest.Name),
+		}
+		if info.Compatible {
+			pi.entryPoints[path] = true
+		} else {
+			info.Error = "failed to load"
+		}
+		result.ExternalPlugins = append(result.ExternalPlugins, info)
+	}
+}
+
 // discoverPluginsInPath searches for plugins in a specific directory
 func (pi *PluginIntegrator) discoverPluginsInPath(ctx context.Context, searchPath string, result *PluginDiscoveryResult) error {
 	// Check if path exists
@@ -132,8 +236,8 @@ func (pi *PluginIntegrator) discoverPluginsInPath(ctx context.Context, searchPat
 			return nil // Continue walking despite errors
 		}
 		
-		// Skip non-plugin files
-		if !pi.isPluginFile(path, info) {
+		// Skip non-plugin files, and plugins already loaded from their manifest
+		if !pi.isPluginFile(path, info) || pi.entryPoints[path] {
 			return nil
 		}
 		
@@ -281,6 +385,11 @@ func (pi *PluginIntegrator) isPluginEnabled(name string) bool {
 	return true
 }
 
+// pluginSetting returns a value from plugins.configurations.<name>.settings, or nil
+func (pi *PluginIntegrator) pluginSetting(name, key string) interface{} {
+	return pi.config.Plugins.Configurations[name].Settings[key]
+}
+
 // loadExternalPlugin loads an external plugin
 func (pi *PluginIntegrator) loadExternalPlugin(ctx context.Context, path, name string) error {
 	pi.logger.Info("Loading external plugin", "name", name, "path", path)
@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/config"
+	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/internal/storage"
+)
+
+const poemManifest = `name: poem
+version: 0.1.0
+type: external
+domains: [fiction]
+phases:
+  - name: draft
+entry_point: poem
+binary: true
+protocol: jsonrpc
+`
+
+// poetAgent answers every prompt with a poem about it
+type poetAgent struct{}
+
+func (poetAgent) Execute(ctx context.Context, prompt string, input any) (string, error) {
+	return "a poem: " + prompt, nil
+}
+
+func (poetAgent) ExecuteJSON(ctx context.Context, prompt string, input any) (string, error) {
+	return `{"poem": "` + prompt + `"}`, nil
+}
+
+// buildPoemPlugin compiles testdata/poem, a plugin written with the SDK, into a
+// plugin directory along with its manifest
+func buildPoemPlugin(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "poem")
+	cmd := exec.Command("go", "build", "-o", filepath.Join(dir, "poem"), "./testdata/poem")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building the test plugin: %v\n%s", err, output)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(poemManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Dir(dir)
+}
+
+func TestIntegratorLoadsSDKPlugin(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Plugins: config.PluginsConfig{
+		DiscoveryPaths: []string{buildPoemPlugin(t)},
+		Settings:       config.PluginSettings{AutoDiscovery: true, MaxExternalPlugins: 5},
+	}}
+	outputDir := t.TempDir()
+
+	pi := NewPluginIntegrator(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := pi.InitializeBuiltinPlugins(poetAgent{}, storage.NewFileSystem(outputDir), t.TempDir(), agent.NewMockClient()); err != nil {
+		t.Fatalf("InitializeBuiltinPlugins() error = %v", err)
+	}
+	result, err := pi.DiscoverExternalPlugins(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverExternalPlugins() error = %v", err)
+	}
+	if len(result.Errors) != 0 || len(result.ExternalPlugins) != 1 || !result.ExternalPlugins[0].Compatible {
+		t.Fatalf("discovery = %+v", result)
+	}
+
+	p, err := pi.GetDomainRegistry().Get("poem")
+	if err != nil {
+		t.Fatalf("plugin isn't registered: %v", err)
+	}
+	t.Cleanup(func() {
+		if closer, ok := p.(io.Closer); ok {
+			closer.Close()
+		}
+	})
+	phases := p.GetPhases()
+	if len(phases) != 1 || phases[0].Name() != "draft" {
+		t.Fatalf("phases = %v", phases)
+	}
+
+	output, err := phases[0].Execute(ctx, domain.PhaseInput{
+		Request:  "the sea",
+		Metadata: map[string]interface{}{"session_id": "s1"},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if output.Data != "a poem: write about the sea" {
+		t.Errorf("output = %v", output.Data)
+	}
+	saved, err := os.ReadFile(filepath.Join(outputDir, "s1", "poem.md"))
+	if err != nil || string(saved) != "a poem: write about the sea" {
+		t.Errorf("saved poem = %q, %v", saved, err)
+	}
+}
@@ -0,0 +1,48 @@
+// Command poem is a binary plugin built with the plugin SDK, for the integrator's
+// end-to-end test
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dotcommander/orc/pkg/orc"
+	sdk "github.com/dotcommander/orc/pkg/plugin-sdk"
+)
+
+type poemPlugin struct {
+	sdk.BasePlugin
+}
+
+func (p *poemPlugin) CreatePhases() ([]orc.Phase, error) {
+	return []orc.Phase{&draftPhase{BasePhase: sdk.NewBasePhase("draft", time.Minute), plugin: p}}, nil
+}
+
+type draftPhase struct {
+	sdk.BasePhase
+	plugin *poemPlugin
+}
+
+func (ph *draftPhase) Execute(ctx context.Context, input orc.PhaseInput) (orc.PhaseOutput, error) {
+	if ph.plugin.Agents() == nil || ph.plugin.Storage() == nil {
+		return orc.PhaseOutput{}, fmt.Errorf("host services weren't injected")
+	}
+	poem, err := ph.plugin.Agents().CreateAgent("poet", "").Execute(ctx, "write about "+input.Request, nil)
+	if err != nil {
+		return orc.PhaseOutput{}, err
+	}
+	if err := ph.plugin.Storage().SaveOutput(input.SessionID, "poem.md", []byte(poem)); err != nil {
+		return orc.PhaseOutput{}, err
+	}
+	return orc.PhaseOutput{Data: poem}, nil
+}
+
+func main() {
+	p := &poemPlugin{BasePlugin: sdk.NewBasePlugin("poem", "0.1.0", "Writes poems", "test", []string{"fiction"})}
+	if err := sdk.ServeBinaryPlugin(p); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// Walker is implemented by stores that can enumerate every path they hold
+type Walker interface {
+	Walk(ctx context.Context, fn func(path string) error) error
+}
+
+// Cached writes through to a remote store and keeps a local copy, so phases that
+// read back their own output don't wait on the network. The remote store is the
+// source of truth: listings come from it, and reads the local copy misses are
+// fetched from it and kept.
+type Cached struct {
+	remote Storage
+	local  *FileSystem
+}
+
+// NewCached fronts remote with a local copy in local
+func NewCached(remote Storage, local *FileSystem) *Cached {
+	return &Cached{remote: remote, local: local}
+}
+
+// BaseDir returns the directory holding the local copy
+func (c *Cached) BaseDir() string {
+	return c.local.BaseDir()
+}
+
+// transactor matches core.Transactor, which storage can't import
+type transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// cachedTxKey finds the writes a transaction on a Cached store has pending
+type cachedTxKey struct {
+	store *Cached
+}
+
+// pendingFile is a write to the local copy held back until its transaction commits;
+// nil data marks a deletion
+type pendingFile struct {
+	data []byte
+}
+
+// InTx runs fn in a transaction on the remote store, when it supports them. Writes
+// reach the local copy only once the transaction commits, so a rollback can't
+// leave the copy ahead of the remote store.
+func (c *Cached) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	remote, ok := c.remote.(transactor)
+	if !ok {
+		return fn(ctx)
+	}
+	if _, ok := ctx.Value(cachedTxKey{c}).(map[string]pendingFile); ok {
+		return remote.InTx(ctx, fn)
+	}
+
+	pending := make(map[string]pendingFile)
+	err := remote.InTx(context.WithValue(ctx, cachedTxKey{c}, pending), fn)
+	if err != nil {
+		return err
+	}
+	for path, file := range pending {
+		if file.data == nil {
+			err = c.local.Delete(ctx, path)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = c.local.Save(ctx, path, file.data)
+		}
+		if err != nil {
+			// Leave no stale copy behind; the next read fetches the remote one
+			_ = c.local.Delete(ctx, path)
+			return fmt.Errorf("updating local copy: %w", err)
+		}
+	}
+	return nil
+}
+
+// pending returns the writes held back by the transaction on ctx, if any
+func (c *Cached) pending(ctx context.Context) (map[string]pendingFile, bool) {
+	pending, ok := ctx.Value(cachedTxKey{c}).(map[string]pendingFile)
+	return pending, ok
+}
+
+// Remote returns the store writes go through to
+func (c *Cached) Remote() Storage {
+	return c.remote
+}
+
+func (c *Cached) Save(ctx context.Context, path string, data []byte) error {
+	if err := c.remote.Save(ctx, path, data); err != nil {
+		return err
+	}
+	if pending, ok := c.pending(ctx); ok {
+		pending[path] = pendingFile{data: append([]byte{}, data...)}
+		return nil
+	}
+	if err := c.local.Save(ctx, path, data); err != nil {
+		return fmt.Errorf("updating local copy: %w", err)
+	}
+	return nil
+}
+
+func (c *Cached) Load(ctx context.Context, path string) ([]byte, error) {
+	if pending, ok := c.pending(ctx); ok {
+		// The local copy doesn't have the transaction's writes yet
+		if _, written := pending[path]; written {
+			return c.remote.Load(ctx, path)
+		}
+	}
+	if data, err := c.local.Load(ctx, path); err == nil {
+		return data, nil
+	}
+	data, err := c.remote.Load(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	// A failed local write only costs the next read another fetch
+	_ = c.local.Save(ctx, path, data)
+	return data, nil
+}
+
+func (c *Cached) List(ctx context.Context, pattern string) ([]string, error) {
+	return c.remote.List(ctx, pattern)
+}
+
+func (c *Cached) Exists(ctx context.Context, path string) bool {
+	if pending, ok := c.pending(ctx); ok {
+		if _, written := pending[path]; written {
+			return c.remote.Exists(ctx, path)
+		}
+	}
+	return c.local.Exists(ctx, path) || c.remote.Exists(ctx, path)
+}
+
+func (c *Cached) Delete(ctx context.Context, path string) error {
+	if err := c.remote.Delete(ctx, path); err != nil {
+		return err
+	}
+	if pending, ok := c.pending(ctx); ok {
+		pending[path] = pendingFile{}
+		return nil
+	}
+	if err := c.local.Delete(ctx, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("updating local copy: %w", err)
+	}
+	return nil
+}
+
+// Pull copies every remote file missing from the local copy and returns how many it
+// fetched. The remote store must implement Walker.
+func (c *Cached) Pull(ctx context.Context) (int, error) {
+	walker, ok := c.remote.(Walker)
+	if !ok {
+		return 0, fmt.Errorf("remote storage can't list its contents")
+	}
+	pulled := 0
+	err := walker.Walk(ctx, func(path string) error {
+		if c.local.Exists(ctx, path) {
+			return nil
+		}
+		data, err := c.remote.Load(ctx, path)
+		if err != nil {
+			return err
+		}
+		if err := c.local.Save(ctx, path, data); err != nil {
+			return err
+		}
+		pulled++
+		return nil
+	})
+	return pulled, err
+}
+
+// Push uploads every file in the local copy and returns how many it sent, for
+// output written to the local directory directly
+func (c *Cached) Push(ctx context.Context) (int, error) {
+	pushed := 0
+	err := c.local.Walk(ctx, func(path string) error {
+		data, err := c.local.Load(ctx, path)
+		if err != nil {
+			return err
+		}
+		if err := c.remote.Save(ctx, path, data); err != nil {
+			return err
+		}
+		pushed++
+		return nil
+	})
+	return pushed, err
+}
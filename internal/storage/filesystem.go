@@ -18,6 +18,11 @@ func NewFileSystem(baseDir string) *FileSystem {
 	}
 }
 
+// BaseDir returns the directory all paths are resolved against
+func (fs *FileSystem) BaseDir() string {
+	return fs.baseDir
+}
+
 // sanitizePath validates and cleans the path to prevent directory traversal
 func (fs *FileSystem) sanitizePath(path string) (string, error) {
 	// Clean the path to resolve . and .. elements
@@ -62,13 +67,60 @@ func (fs *FileSystem) Save(ctx context.Context, path string, data []byte) error
 		mode = 0600 // Owner read/write only for config files
 	}
 	
-	if err := os.WriteFile(fullPath, data, mode); err != nil {
+	if err := writeFileAtomic(fullPath, data, mode); err != nil {
 		return fmt.Errorf("writing file: %w", err)
 	}
 	
 	return nil
 }
 
+// internalPrefix starts the names of the lock and temporary files the store keeps
+// beside session data; List and Walk leave them out
+const internalPrefix = ".orc"
+
+func isInternal(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), internalPrefix)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory, syncs it and
+// renames it over path, so a crash leaves either the old contents or the new
+func writeFileAtomic(path string, data []byte, mode os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, internalPrefix+"-tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	
+	// Persist the rename itself; not every platform can sync a directory
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
 func (fs *FileSystem) Load(ctx context.Context, path string) ([]byte, error) {
 	fullPath, err := fs.sanitizePath(path)
 	if err != nil {
@@ -107,6 +159,9 @@ func (fs *FileSystem) List(ctx context.Context, pattern string) ([]string, error
 		if !strings.HasPrefix(match, fs.baseDir+string(filepath.Separator)) && match != fs.baseDir {
 			continue
 		}
+		if isInternal(match) {
+			continue
+		}
 		
 		rel, err := filepath.Rel(fs.baseDir, match)
 		if err != nil {
@@ -139,4 +194,22 @@ func (fs *FileSystem) Delete(ctx context.Context, path string) error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// Walk calls fn with the path of every file under the base directory
+func (fs *FileSystem) Walk(ctx context.Context, fn func(path string) error) error {
+	err := filepath.WalkDir(fs.baseDir, func(full string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isInternal(full) {
+			return err
+		}
+		rel, err := filepath.Rel(fs.baseDir, full)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
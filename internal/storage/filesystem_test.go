@@ -146,4 +146,30 @@ func TestSanitizePath(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestFileSystemSaveReplacesAtomically(t *testing.T) {
+	tempDir := t.TempDir()
+	fs := NewFileSystem(tempDir)
+	ctx := context.Background()
+	
+	for _, data := range []string{`{"phase":1}`, `{"phase":2}`} {
+		if err := fs.Save(ctx, "checkpoints/session.json", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := fs.Load(ctx, "checkpoints/session.json")
+	if err != nil || string(data) != `{"phase":2}` {
+		t.Errorf("Load() = %q, %v", data, err)
+	}
+	
+	entries, err := os.ReadDir(filepath.Join(tempDir, "checkpoints"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("checkpoints holds %d files, want no temporary files left behind", len(entries))
+	}
+	if info, err := os.Stat(filepath.Join(tempDir, "checkpoints", "session.json")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("file mode = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+}
@@ -6,4 +6,6 @@ type Storage interface {
 	Save(ctx context.Context, path string, data []byte) error
 	Load(ctx context.Context, path string) ([]byte, error)
 	List(ctx context.Context, pattern string) ([]string, error)
+	Exists(ctx context.Context, path string) bool
+	Delete(ctx context.Context, path string) error
 }
\ No newline at end of file
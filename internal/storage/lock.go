@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LockFile is the advisory lock a running session holds in its directory
+const LockFile = internalPrefix + ".lock"
+
+// DefaultStaleLock is how long a lock can go without a heartbeat before it is
+// considered abandoned
+const DefaultStaleLock = 2 * time.Minute
+
+// ErrLocked is returned when another live process holds a directory's lock
+var ErrLocked = errors.New("locked by another process")
+
+// LockInfo is what a lock file records about its holder
+type LockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Acquired  time.Time `json:"acquired"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+func (i LockInfo) String() string {
+	return fmt.Sprintf("process %d on %s since %s", i.PID, i.Host, i.Acquired.Format("2006-01-02 15:04:05"))
+}
+
+// Lock is an advisory lock on a directory, shared by cooperating orc processes. The
+// holder refreshes the lock's heartbeat until Unlock.
+type Lock struct {
+	path string
+	info LockInfo
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// LockDir takes the lock on dir. A lock whose holder has exited, or whose heartbeat
+// is older than staleAfter, is taken over; any other held lock fails with an error
+// wrapping ErrLocked that names the holder. Zero staleAfter uses DefaultStaleLock.
+func LockDir(dir string, staleAfter time.Duration) (*Lock, error) {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleLock
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating directory: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	now := time.Now()
+	l := &Lock{
+		path: filepath.Join(dir, LockFile),
+		info: LockInfo{PID: os.Getpid(), Host: host, Acquired: now, Heartbeat: now},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	err := l.create()
+	if errors.Is(err, os.ErrExist) {
+		err = l.takeOver(dir, staleAfter)
+	} else if err != nil {
+		err = fmt.Errorf("creating lock: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go l.heartbeat(staleAfter / 3)
+	return l, nil
+}
+
+// takeOver replaces the lock file if it is stale. Judging and replacing it happen
+// under the guard, so two processes can't both take over the same stale lock.
+func (l *Lock) takeOver(dir string, staleAfter time.Duration) error {
+	release, err := guard(l.path)
+	if err != nil {
+		return fmt.Errorf("guarding lock: %w", err)
+	}
+	defer release()
+
+	holder, stale := readLock(l.path, staleAfter)
+	if stale {
+		if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing stale lock: %w", err)
+		}
+		err := l.create()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("creating lock: %w", err)
+		}
+		// Created by a process that found no lock at all
+		holder, _ = readLock(l.path, staleAfter)
+	}
+	if holder == nil {
+		return fmt.Errorf("%s: %w", dir, ErrLocked)
+	}
+	return fmt.Errorf("%s: %w: %s", dir, ErrLocked, holder)
+}
+
+// guardFile is the name of the file beside a lock that its guard locks. It is
+// never removed, so every process locks the same file.
+func guardFile(lockPath string) string {
+	return lockPath + "-guard"
+}
+
+// create writes the lock file, failing with os.ErrExist if it is already there
+func (l *Lock) create() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	data, _ := json.Marshal(l.info)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	return f.Close()
+}
+
+// readLock returns the lock's holder, if the file can be read, and whether the lock
+// is stale
+func readLock(path string, staleAfter time.Duration) (*LockInfo, bool) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, true // Released while we looked
+	}
+	data, err := os.ReadFile(path)
+	var info LockInfo
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		// Possibly being written; judge it by age alone
+		return nil, time.Since(stat.ModTime()) > staleAfter
+	}
+
+	host, _ := os.Hostname()
+	if info.Host == host && !processAlive(info.PID) {
+		return &info, true
+	}
+	return &info, time.Since(info.Heartbeat) > staleAfter
+}
+
+// heartbeat refreshes the lock so other hosts sharing the directory can tell it
+// is still held
+func (l *Lock) heartbeat(every time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			if !l.refresh(now) {
+				return // Taken over; don't clobber the new holder
+			}
+		}
+	}
+}
+
+// refresh writes a new heartbeat if the lock is still this lock's, and reports
+// whether it was
+func (l *Lock) refresh(now time.Time) bool {
+	release, err := guard(l.path)
+	if err != nil {
+		return true // Try again on the next tick
+	}
+	defer release()
+
+	if !l.owned() {
+		return false
+	}
+	l.info.Heartbeat = now
+	data, _ := json.Marshal(l.info)
+	writeFileAtomic(l.path, data, 0644)
+	return true
+}
+
+// owned reports whether the lock file still belongs to this lock
+func (l *Lock) owned() bool {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return false
+	}
+	var info LockInfo
+	if json.Unmarshal(data, &info) != nil {
+		return false
+	}
+	return info.PID == l.info.PID && info.Host == l.info.Host && info.Acquired.Equal(l.info.Acquired)
+}
+
+// Unlock releases the lock. It is safe to call more than once.
+func (l *Lock) Unlock() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+		release, guardErr := guard(l.path)
+		if guardErr != nil {
+			err = fmt.Errorf("guarding lock: %w", guardErr)
+			return
+		}
+		defer release()
+		if l.owned() {
+			if removeErr := os.Remove(l.path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+				err = fmt.Errorf("removing lock: %w", removeErr)
+			}
+		}
+	})
+	return err
+}
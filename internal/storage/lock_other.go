@@ -0,0 +1,15 @@
+//go:build !unix
+
+package storage
+
+// processAlive can't check other processes here, so a lock is only taken over once
+// its heartbeat goes stale
+func processAlive(pid int) bool {
+	return true
+}
+
+// guard can't lock files here, so stale takeovers aren't serialized between
+// processes
+func guard(lockPath string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockDir(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := LockDir(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockDir(dir, time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("second LockDir() error = %v, want ErrLocked", err)
+	}
+	if names, _ := NewFileSystem(dir).List(context.Background(), "*"); len(names) != 0 {
+		t.Errorf("List() = %v, want the lock file hidden", names)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("lock file left after Unlock: %v", err)
+	}
+	relock, err := LockDir(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("LockDir() after Unlock error = %v", err)
+	}
+	relock.Unlock()
+}
+
+func TestLockDirTakesOverStaleLock(t *testing.T) {
+	dir := t.TempDir()
+
+	// A holder on another host that stopped sending heartbeats
+	abandoned, _ := json.Marshal(LockInfo{
+		PID:       1,
+		Host:      "ci-worker-7",
+		Acquired:  time.Now().Add(-time.Hour),
+		Heartbeat: time.Now().Add(-time.Hour),
+	})
+	if err := os.WriteFile(filepath.Join(dir, LockFile), abandoned, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LockDir(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("LockDir() error = %v, want the stale lock taken over", err)
+	}
+	defer lock.Unlock()
+
+	if _, err := LockDir(dir, time.Minute); err == nil || !errors.Is(err, ErrLocked) {
+		t.Errorf("LockDir() error = %v, want ErrLocked naming this process", err)
+	}
+}
+
+func TestLockDirConcurrentTakeover(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		dir := t.TempDir()
+		abandoned, _ := json.Marshal(LockInfo{
+			PID:       1,
+			Host:      "ci-worker-7",
+			Acquired:  time.Now().Add(-time.Hour),
+			Heartbeat: time.Now().Add(-time.Hour),
+		})
+		if err := os.WriteFile(filepath.Join(dir, LockFile), abandoned, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Every contender sees the same stale lock; only one may take it over
+		const contenders = 8
+		var wg sync.WaitGroup
+		locks := make(chan *Lock, contenders)
+		start := make(chan struct{})
+		for i := 0; i < contenders; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				lock, err := LockDir(dir, time.Minute)
+				if err == nil {
+					locks <- lock
+				} else if !errors.Is(err, ErrLocked) {
+					t.Errorf("LockDir() error = %v", err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(locks)
+
+		held := 0
+		for lock := range locks {
+			held++
+			lock.Unlock()
+		}
+		if held != 1 {
+			t.Fatalf("round %d: %d contenders hold the lock, want 1", round, held)
+		}
+	}
+}
+
+func TestLockDirJudgesStaleLockUnderGuard(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, LockFile)
+	abandoned, _ := json.Marshal(LockInfo{
+		PID:       1,
+		Host:      "ci-worker-7",
+		Acquired:  time.Now().Add(-time.Hour),
+		Heartbeat: time.Now().Add(-time.Hour),
+	})
+	if err := os.WriteFile(path, abandoned, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process is in the middle of taking the stale lock over
+	release, err := guard(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		lock, err := LockDir(dir, time.Minute)
+		if err == nil {
+			lock.Unlock()
+		}
+		result <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	fresh, _ := json.Marshal(LockInfo{
+		PID:       1,
+		Host:      "ci-worker-8",
+		Acquired:  time.Now(),
+		Heartbeat: time.Now(),
+	})
+	if err := os.WriteFile(path, fresh, 0644); err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	if err := <-result; !errors.Is(err, ErrLocked) {
+		t.Errorf("LockDir() error = %v, want ErrLocked by the process that took over", err)
+	}
+}
@@ -0,0 +1,441 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionPlaceholder marks where the session ID goes in an S3 prefix
+const SessionPlaceholder = "{session}"
+
+// S3Options locates a bucket on AWS S3 or an S3-compatible service such as MinIO
+type S3Options struct {
+	// Endpoint is the service URL; empty uses https://s3.<region>.amazonaws.com
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// PathStyle addresses the bucket as <endpoint>/<bucket>, as MinIO expects,
+	// rather than <bucket>.<endpoint>
+	PathStyle bool
+
+	// HTTPClient sends the requests; nil uses a client with a one minute timeout
+	HTTPClient *http.Client
+}
+
+// S3 keeps data as objects in an S3 bucket under a key prefix. Paths follow the same
+// rules as FileSystem and List takes the same glob patterns.
+type S3 struct {
+	opts    S3Options
+	base    *url.URL
+	prefix  string
+	session string // Set on stores returned by Session
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewS3 stores objects under prefix in the bucket. A prefix containing {session}
+// is a template: use Session to get the store for one session.
+func NewS3(opts S3Options, prefix string) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket is required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", endpoint)
+	}
+	if !opts.PathStyle {
+		base.Host = opts.Bucket + "." + base.Host
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	return &S3{
+		opts:   opts,
+		base:   base,
+		prefix: strings.Trim(prefix, "/"),
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// Session returns a store rooted at the prefix for sessionID
+func (s *S3) Session(sessionID string) *S3 {
+	session := *s
+	session.prefix = s.sessionPrefix(sessionID)
+	session.session = sessionID
+	return &session
+}
+
+// sessionPrefix fills in the prefix template, or nests the session under a plain prefix
+func (s *S3) sessionPrefix(sessionID string) string {
+	if strings.Contains(s.prefix, SessionPlaceholder) {
+		return strings.Replace(s.prefix, SessionPlaceholder, sessionID, 1)
+	}
+	return strings.TrimPrefix(s.prefix+"/"+sessionID, "/")
+}
+
+// FindSessions returns the IDs of stored sessions starting with idPrefix, sorted
+func (s *S3) FindSessions(ctx context.Context, idPrefix string) ([]string, error) {
+	template := s.prefix
+	if !strings.Contains(template, SessionPlaceholder) {
+		template = strings.TrimPrefix(template+"/"+SessionPlaceholder, "/")
+	}
+	before, after, _ := strings.Cut(template, SessionPlaceholder)
+
+	keys, err := s.listKeys(ctx, before+idPrefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, key := range keys {
+		id, rest, found := strings.Cut(strings.TrimPrefix(key, before), "/")
+		if !found || seen[id] || !strings.HasPrefix("/"+rest, strings.TrimSuffix(after, "/")+"/") {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *S3) objectKey(p string) (string, error) {
+	key, err := cleanKey(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if s.prefix == "" {
+		return key, nil
+	}
+	return s.prefix + "/" + key, nil
+}
+
+func (s *S3) Save(ctx context.Context, path string, data []byte) error {
+	key, err := s.objectKey(path)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SaveOutput stores a file in a session's output. A store from Session already is
+// that output, so it only accepts its own session's files.
+func (s *S3) SaveOutput(sessionID, filename string, data []byte) error {
+	if s.session == "" {
+		return s.Session(sessionID).Save(context.Background(), filename, data)
+	}
+	if sessionID != s.session {
+		return fmt.Errorf("s3: store belongs to session %s, not %s", s.session, sessionID)
+	}
+	return s.Save(context.Background(), filename, data)
+}
+
+func (s *S3) Load(ctx context.Context, path string) ([]byte, error) {
+	key, err := s.objectKey(path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// List returns the paths matching a glob pattern, in which * and ? don't match a
+// slash, sorted like filepath.Glob
+func (s *S3) List(ctx context.Context, pattern string) ([]string, error) {
+	cleaned, prefix, err := cleanPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Narrow the listing to the pattern's literal prefix, then match in Go
+	root := ""
+	if s.prefix != "" {
+		root = s.prefix + "/"
+	}
+	keys, err := s.listKeys(ctx, root+prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+
+	var results []string
+	for _, key := range keys {
+		p := strings.TrimPrefix(key, root)
+		if ok, _ := path.Match(cleaned, p); ok {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
+
+// Walk calls fn with every path in the store
+func (s *S3) Walk(ctx context.Context, fn func(path string) error) error {
+	root := ""
+	if s.prefix != "" {
+		root = s.prefix + "/"
+	}
+	keys, err := s.listKeys(ctx, root)
+	if err != nil {
+		return fmt.Errorf("listing files: %w", err)
+	}
+	for _, key := range keys {
+		if err := fn(strings.TrimPrefix(key, root)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3) Exists(ctx context.Context, path string) bool {
+	key, err := s.objectKey(path)
+	if err != nil {
+		return false
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// Delete removes an object. S3 doesn't report whether a deleted key existed, so
+// Delete checks first to return fs.ErrNotExist like the other stores.
+func (s *S3) Delete(ctx context.Context, path string) error {
+	key, err := s.objectKey(path)
+	if err != nil {
+		return err
+	}
+	if !s.Exists(ctx, path) {
+		return fmt.Errorf("deleting %s: %w", key, fs.ErrNotExist)
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response the store reads
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listKeys returns every key starting with prefix, following continuation tokens
+func (s *S3) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing object list: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			keys = append(keys, obj.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// do sends a signed request for key, or for the bucket when key is empty. Responses
+// other than 2xx are returned as errors; 404 wraps fs.ErrNotExist.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	req, err := s.newRequest(ctx, method, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fs.ErrNotExist
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %s: %s: %s", method, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u := *s.base
+	u.Path = "/" + key
+	if s.opts.PathStyle {
+		u.Path = strings.TrimSuffix("/"+s.opts.Bucket+u.Path, "/")
+	}
+	// Send the path exactly as it is signed
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body)
+	return req, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.opts.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.opts.SessionToken)
+	}
+
+	signed := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" {
+			signed = append(signed, lower)
+		}
+	}
+	sort.Strings(signed)
+
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by key, as both the URL and the signature need
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and slashes
+// unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SQLiteDriver is the database/sql driver name OpenSQLite uses, registered by the
+// pure-Go modernc.org/sqlite driver
+const SQLiteDriver = "sqlite"
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS objects (
+	path       TEXT PRIMARY KEY,
+	data       BLOB NOT NULL,
+	updated_at INTEGER NOT NULL
+)`
+
+// SQLite keeps data as rows in a SQLite database. Paths follow the same rules as
+// FileSystem and List takes the same glob patterns, so the two are interchangeable.
+// It implements InTx, so writes made through core.WithinTx commit together.
+type SQLite struct {
+	db *sql.DB
+	// root prefixes every path when the store is scoped to one session
+	root string
+}
+
+// OpenSQLite opens or creates the database file at path
+func OpenSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating directory: %w", err)
+	}
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite database: %w", err)
+	}
+	s, err := NewSQLite(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLite stores data in db, creating its table if needed
+func NewSQLite(db *sql.DB) (*SQLite, error) {
+	// SQLite allows a single writer; one connection serializes writers instead of
+	// failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("creating SQLite schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Close closes the database
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// sqliteSessionsDir holds each session's paths in a database shared by sessions
+const sqliteSessionsDir = "sessions"
+
+// Session returns a store holding sessionID's paths, in the same database
+func (s *SQLite) Session(sessionID string) *SQLite {
+	session := *s
+	session.root = path.Join(sqliteSessionsDir, sessionID)
+	return &session
+}
+
+// FindSessions returns the IDs of stored sessions starting with idPrefix, sorted
+func (s *SQLite) FindSessions(ctx context.Context, idPrefix string) ([]string, error) {
+	before := sqliteSessionsDir + "/"
+	paths, err := s.keysWithPrefix(ctx, before+idPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	var ids []string
+	for _, p := range paths {
+		id, _, found := strings.Cut(strings.TrimPrefix(p, before), "/")
+		if found && (len(ids) == 0 || ids[len(ids)-1] != id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// key returns the row key for a path in this store
+func (s *SQLite) key(p string) (string, error) {
+	key, err := cleanKey(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if s.root == "" {
+		return key, nil
+	}
+	return s.root + "/" + key, nil
+}
+
+// rootDir is the prefix of every row key in this store
+func (s *SQLite) rootDir() string {
+	if s.root == "" {
+		return ""
+	}
+	return s.root + "/"
+}
+
+// keysWithPrefix returns the row keys starting with prefix, sorted
+func (s *SQLite) keysWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT path FROM objects WHERE substr(path, 1, ?) = ? ORDER BY path`, len(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// sqliteTxKey scopes a transaction on a context to the database it was begun on,
+// so every session store sharing the database joins it
+type sqliteTxKey struct {
+	db *sql.DB
+}
+
+// queryer is the part of *sql.DB and *sql.Tx the store uses
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction on ctx, if this store began one, or the database
+func (s *SQLite) conn(ctx context.Context) queryer {
+	if tx, ok := ctx.Value(sqliteTxKey{s.db}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+// InTx runs fn in a transaction, or in the caller's when ctx already carries one
+func (s *SQLite) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(sqliteTxKey{s.db}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, sqliteTxKey{s.db}, tx)); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// cleanKey applies FileSystem's path rules and normalizes separators
+func cleanKey(p string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(p))
+	if strings.Contains(cleaned, "..") {
+		return "", fmt.Errorf("invalid path: contains parent directory reference")
+	}
+	if path.IsAbs(cleaned) || filepath.IsAbs(p) {
+		return "", fmt.Errorf("invalid path: absolute paths not allowed")
+	}
+	if cleaned == "." {
+		return "", fmt.Errorf("invalid path: empty")
+	}
+	return cleaned, nil
+}
+
+// cleanPattern applies cleanKey's rules to a glob pattern and returns it with the
+// literal prefix every match must start with
+func cleanPattern(pattern string) (cleaned, prefix string, err error) {
+	cleaned = path.Clean(filepath.ToSlash(pattern))
+	if strings.Contains(cleaned, "..") {
+		return "", "", fmt.Errorf("invalid pattern: contains parent directory reference")
+	}
+	if path.IsAbs(cleaned) {
+		return "", "", fmt.Errorf("invalid pattern: absolute paths not allowed")
+	}
+	if _, err := path.Match(cleaned, ""); err != nil {
+		return "", "", fmt.Errorf("listing files: %w", err)
+	}
+
+	prefix = cleaned
+	if i := strings.IndexAny(cleaned, `*?[\`); i >= 0 {
+		prefix = cleaned[:i]
+	}
+	return cleaned, prefix, nil
+}
+
+func (s *SQLite) Save(ctx context.Context, path string, data []byte) error {
+	key, err := s.key(path)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+
+	_, err = s.conn(ctx).ExecContext(ctx,
+		`INSERT INTO objects (path, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		key, data, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *SQLite) Load(ctx context.Context, path string) ([]byte, error) {
+	key, err := s.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = s.conn(ctx).QueryRowContext(ctx, `SELECT data FROM objects WHERE path = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("reading %s: %w", key, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// List returns the paths matching a glob pattern, in which * and ? don't match a
+// slash, sorted like filepath.Glob
+func (s *SQLite) List(ctx context.Context, pattern string) ([]string, error) {
+	cleaned, prefix, err := cleanPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Narrow the scan to the pattern's literal prefix, then match in Go
+	root := s.rootDir()
+	keys, err := s.keysWithPrefix(ctx, root+prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+
+	var results []string
+	for _, key := range keys {
+		p := strings.TrimPrefix(key, root)
+		if ok, _ := path.Match(cleaned, p); ok {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
+
+// Walk calls fn with every path in the store
+func (s *SQLite) Walk(ctx context.Context, fn func(path string) error) error {
+	root := s.rootDir()
+	keys, err := s.keysWithPrefix(ctx, root)
+	if err != nil {
+		return fmt.Errorf("listing files: %w", err)
+	}
+	for _, key := range keys {
+		if err := fn(strings.TrimPrefix(key, root)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) Exists(ctx context.Context, path string) bool {
+	key, err := s.key(path)
+	if err != nil {
+		return false
+	}
+	var one int
+	return s.conn(ctx).QueryRowContext(ctx, `SELECT 1 FROM objects WHERE path = ?`, key).Scan(&one) == nil
+}
+
+func (s *SQLite) Delete(ctx context.Context, path string) error {
+	key, err := s.key(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM objects WHERE path = ?`, key)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("deleting %s: %w", key, fs.ErrNotExist)
+	}
+	return nil
+}
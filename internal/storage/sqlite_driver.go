@@ -0,0 +1,4 @@
+package storage
+
+// Registers the pure-Go SQLite driver under SQLiteDriver
+import _ "modernc.org/sqlite"
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "orc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"scenes/chapter_1_scene_1.txt", "scenes/chapter_1_scene_2.txt", "scenes/notes.md", "progress.json"} {
+		if err := s.Save(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("Save(%q) error = %v", key, err)
+		}
+	}
+	if err := s.Save(ctx, "../escape.txt", []byte("x")); err == nil {
+		t.Error("Save() accepted a path outside the store")
+	}
+
+	data, err := s.Load(ctx, "progress.json")
+	if err != nil || string(data) != "progress.json" {
+		t.Errorf("Load() = %q, %v", data, err)
+	}
+	if _, err := s.Load(ctx, "missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load() of a missing key error = %v, want fs.ErrNotExist", err)
+	}
+
+	got, err := s.List(ctx, "scenes/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"scenes/chapter_1_scene_1.txt", "scenes/chapter_1_scene_2.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	if err := s.Delete(ctx, "progress.json"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "progress.json") {
+		t.Error("Exists() = true after Delete")
+	}
+	if err := s.Delete(ctx, "progress.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second Delete() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestSQLiteInTxRollsBack(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "orc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	failed := errors.New("checkpoint failed")
+	err = s.InTx(ctx, func(ctx context.Context) error {
+		if err := s.Save(ctx, "scenes/chapter_1_scene_1.txt", []byte("scene")); err != nil {
+			return err
+		}
+		// Nested calls join the outer transaction
+		return s.InTx(ctx, func(ctx context.Context) error {
+			if err := s.Save(ctx, "progress.json", []byte("{}")); err != nil {
+				return err
+			}
+			return failed
+		})
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("InTx() error = %v, want %v", err, failed)
+	}
+	if s.Exists(ctx, "scenes/chapter_1_scene_1.txt") || s.Exists(ctx, "progress.json") {
+		t.Error("writes from a failed transaction were kept")
+	}
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "orc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []string{"abc123", "abd456", "xyz789"} {
+		if err := db.Session(id).Save(ctx, "checkpoints/000001.json", []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	abc := db.Session("abc123")
+	if err := abc.Save(ctx, "plan.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := abc.List(ctx, "*.json"); err != nil || !reflect.DeepEqual(got, []string{"plan.json"}) {
+		t.Errorf("List() = %v, %v", got, err)
+	}
+	var walked []string
+	abc.Walk(ctx, func(p string) error {
+		walked = append(walked, p)
+		return nil
+	})
+	if want := []string{"checkpoints/000001.json", "plan.json"}; !reflect.DeepEqual(walked, want) {
+		t.Errorf("Walk() = %v, want %v", walked, want)
+	}
+	if data, err := db.Load(ctx, "sessions/xyz789/checkpoints/000001.json"); err != nil || string(data) != "xyz789" {
+		t.Errorf("Load() through the shared store = %q, %v", data, err)
+	}
+
+	if ids, err := db.FindSessions(ctx, "ab"); err != nil || !reflect.DeepEqual(ids, []string{"abc123", "abd456"}) {
+		t.Errorf("FindSessions(ab) = %v, %v", ids, err)
+	}
+	if ids, _ := db.FindSessions(ctx, "nope"); len(ids) != 0 {
+		t.Errorf("FindSessions(nope) = %v", ids)
+	}
+}
+
+func TestCachedInTx(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "orc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	store := NewCached(db.Session("abc123"), NewFileSystem(t.TempDir()))
+
+	failed := errors.New("checkpoint failed")
+	err = store.InTx(ctx, func(ctx context.Context) error {
+		if err := store.Save(ctx, "scenes/chapter_1_scene_1.txt", []byte("scene")); err != nil {
+			return err
+		}
+		if data, err := store.Load(ctx, "scenes/chapter_1_scene_1.txt"); err != nil || string(data) != "scene" {
+			t.Errorf("Load() inside the transaction = %q, %v", data, err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("InTx() error = %v, want %v", err, failed)
+	}
+	if store.Exists(ctx, "scenes/chapter_1_scene_1.txt") {
+		t.Error("a rolled back write reached the local copy or the database")
+	}
+
+	err = store.InTx(ctx, func(ctx context.Context) error {
+		return store.Save(ctx, "scenes/chapter_1_scene_1.txt", []byte("scene"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.local.Load(ctx, "scenes/chapter_1_scene_1.txt"); err != nil || string(data) != "scene" {
+		t.Errorf("local copy after commit = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,12 @@
+package orc
+
+import orcerrors "github.com/dotcommander/orc/pkg/orc/errors"
+
+// Errors plugins commonly return, also available from package errors
+var (
+	ErrPhaseTimeout  = orcerrors.ErrPhaseTimeout
+	ErrInvalidInput  = orcerrors.ErrInvalidInput
+	ErrInvalidOutput = orcerrors.ErrInvalidOutput
+	ErrAPILimit      = orcerrors.ErrAPILimit
+	ErrNoRetry       = orcerrors.ErrNoRetry
+)
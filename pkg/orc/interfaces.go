@@ -148,4 +148,15 @@ type OutputSpec struct {
 type AgentFactory interface {
 	// CreateAgent creates an agent with a specific role and prompt file
 	CreateAgent(role, promptPath string) Agent
-}
\ No newline at end of file
+}
+// AgentFactoryAware is implemented by plugins that use AI agents. The host calls
+// SetAgentFactory before CreatePhases.
+type AgentFactoryAware interface {
+	SetAgentFactory(factory AgentFactory)
+}
+
+// StorageAware is implemented by plugins that store data. The host calls
+// SetStorage before CreatePhases.
+type StorageAware interface {
+	SetStorage(storage Storage)
+}
@@ -67,6 +67,8 @@ type BasePlugin struct {
 	domains     []string
 	phases      []orc.Phase
 	logger      *slog.Logger
+	agents      orc.AgentFactory
+	storage     orc.Storage
 }
 
 // NewBasePlugin creates a new base plugin
@@ -103,6 +105,26 @@ func (p *BasePlugin) SetPhases(phases []orc.Phase) {
 	p.phases = phases
 }
 
+// SetAgentFactory receives the host's agent factory before CreatePhases
+func (p *BasePlugin) SetAgentFactory(factory orc.AgentFactory) {
+	p.agents = factory
+}
+
+// Agents returns the host's agent factory, or nil outside a host
+func (p *BasePlugin) Agents() orc.AgentFactory {
+	return p.agents
+}
+
+// SetStorage receives the host's storage before CreatePhases
+func (p *BasePlugin) SetStorage(storage orc.Storage) {
+	p.storage = storage
+}
+
+// Storage returns the host's storage, or nil outside a host
+func (p *BasePlugin) Storage() orc.Storage {
+	return p.storage
+}
+
 // ValidateRequest provides default request validation
 func (p *BasePlugin) ValidateRequest(request string) error {
 	if len(request) < 10 {
@@ -111,6 +133,11 @@ func (p *BasePlugin) ValidateRequest(request string) error {
 	return nil
 }
 
+// GetOutputSpec returns an empty output spec; plugins that write files override it
+func (p *BasePlugin) GetOutputSpec() orc.OutputSpec {
+	return orc.OutputSpec{}
+}
+
 // GetPhaseTimeouts returns default timeouts
 func (p *BasePlugin) GetPhaseTimeouts() map[string]time.Duration {
 	timeouts := make(map[string]time.Duration)
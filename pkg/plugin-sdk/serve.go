@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/dotcommander/orc/pkg/orc"
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+// ServeBinaryPlugin runs p as a binary plugin. orc starts the executable with
+// "serve" when the manifest sets protocol: jsonrpc, and with "execute <phase>"
+// otherwise. Agents and storage call back into orc, so plugins only get them over
+// the JSON-RPC protocol and only as far as their security policy allows.
+func ServeBinaryPlugin(p orc.Plugin) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := os.Args[1:]
+	switch {
+	case len(args) == 1 && args[0] == "serve":
+		return serve(ctx, p, os.Stdin, os.Stdout)
+	case len(args) == 2 && args[0] == "execute":
+		return executeOnce(ctx, p, args[1], os.Stdin, os.Stdout)
+	}
+	return fmt.Errorf("usage: %s serve | execute <phase>", filepath.Base(os.Args[0]))
+}
+
+// serve speaks the JSON-RPC protocol on r and w. The plugin's phases are created
+// on the first phase call, once the host has said which services it grants.
+func serve(ctx context.Context, p orc.Plugin, r io.Reader, w io.Writer) error {
+	var (
+		once    sync.Once
+		phases  map[string]orc.Phase
+		initErr error
+	)
+	execute := func(ctx context.Context, host *rpc.HostClient, phase string, input json.RawMessage) (interface{}, error) {
+		once.Do(func() {
+			if aware, ok := p.(orc.AgentFactoryAware); ok && host.Granted(rpc.MethodAgentExecute) {
+				aware.SetAgentFactory(hostAgentFactory{host: host})
+			}
+			if aware, ok := p.(orc.StorageAware); ok && host.Granted(rpc.MethodStorageSave) {
+				aware.SetStorage(hostStorage{host: host})
+			}
+			phases, initErr = phasesByName(p)
+		})
+		if initErr != nil {
+			return nil, initErr
+		}
+		return runPhase(ctx, phases, phase, input)
+	}
+
+	info := p.GetInfo()
+	return rpc.Serve(ctx, r, w, rpc.PluginInfo{Name: info.Name, Version: info.Version}, execute, nil)
+}
+
+// executeOnce runs a single phase with its input read from r and its output
+// written to w, for hosts using the exec protocol
+func executeOnce(ctx context.Context, p orc.Plugin, phase string, r io.Reader, w io.Writer) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading phase input: %w", err)
+	}
+	phases, err := phasesByName(p)
+	if err != nil {
+		return err
+	}
+	output, err := runPhase(ctx, phases, phase, input)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(output)
+}
+
+func phasesByName(p orc.Plugin) (map[string]orc.Phase, error) {
+	phases, err := p.CreatePhases()
+	if err != nil {
+		return nil, fmt.Errorf("creating phases: %w", err)
+	}
+	byName := make(map[string]orc.Phase, len(phases))
+	for _, phase := range phases {
+		byName[phase.Name()] = phase
+	}
+	return byName, nil
+}
+
+// phaseResult is a phase output as the host decodes it; errors travel separately
+type phaseResult struct {
+	Data     interface{}            `json:"Data"`
+	Metadata map[string]interface{} `json:"Metadata,omitempty"`
+}
+
+func runPhase(ctx context.Context, phases map[string]orc.Phase, name string, raw []byte) (interface{}, error) {
+	phase, ok := phases[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown phase %s", name)
+	}
+
+	var input orc.PhaseInput
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &input); err != nil {
+			return nil, fmt.Errorf("decoding phase input: %w", err)
+		}
+	}
+	if input.SessionID == "" {
+		input.SessionID, _ = input.Metadata["session_id"].(string)
+	}
+
+	if err := phase.ValidateInput(ctx, input); err != nil {
+		return nil, err
+	}
+	output, err := phase.Execute(ctx, input)
+	if err == nil {
+		err = output.Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return phaseResult{Data: output.Data, Metadata: output.Metadata}, nil
+}
+
+// hostAgentFactory creates agents that send their prompts to orc
+type hostAgentFactory struct {
+	host *rpc.HostClient
+}
+
+func (f hostAgentFactory) CreateAgent(role, promptPath string) orc.Agent {
+	return hostAgent{host: f.host, promptPath: promptPath}
+}
+
+// hostAgent prefixes each prompt with its prompt file, read from the plugin's
+// directory, and has orc's agent answer it
+type hostAgent struct {
+	host       *rpc.HostClient
+	promptPath string
+}
+
+func (a hostAgent) Execute(ctx context.Context, prompt string, input interface{}) (string, error) {
+	full, err := a.prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	return a.host.Execute(ctx, full, input)
+}
+
+func (a hostAgent) ExecuteJSON(ctx context.Context, prompt string, input interface{}) (string, error) {
+	full, err := a.prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	return a.host.ExecuteJSON(ctx, full, input)
+}
+
+func (a hostAgent) prompt(prompt string) (string, error) {
+	if a.promptPath == "" {
+		return prompt, nil
+	}
+	data, err := os.ReadFile(a.promptPath)
+	if err != nil {
+		return "", fmt.Errorf("loading prompt: %w", err)
+	}
+	return string(data) + "\n\n" + prompt, nil
+}
+
+// hostStorage stores data in orc's session storage. The protocol only offers
+// save and load, so Delete and List are unavailable.
+type hostStorage struct {
+	host *rpc.HostClient
+}
+
+func (s hostStorage) Save(ctx context.Context, key string, data []byte) error {
+	return s.host.Save(ctx, key, data)
+}
+
+func (s hostStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	return s.host.Load(ctx, key)
+}
+
+func (s hostStorage) Exists(ctx context.Context, key string) bool {
+	_, err := s.host.Load(ctx, key)
+	return err == nil
+}
+
+func (s hostStorage) Delete(ctx context.Context, key string) error {
+	return fmt.Errorf("delete isn't available to binary plugins")
+}
+
+func (s hostStorage) List(ctx context.Context, pattern string) ([]string, error) {
+	return nil, fmt.Errorf("list isn't available to binary plugins")
+}
+
+func (s hostStorage) SaveOutput(sessionID, filename string, data []byte) error {
+	return s.host.Save(context.Background(), path.Join(sessionID, filename), data)
+}
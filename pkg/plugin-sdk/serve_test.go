@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/pkg/orc"
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+// poemPlugin is a plugin as the orc-plugin scaffold writes them
+type poemPlugin struct {
+	BasePlugin
+}
+
+func newPoemPlugin() *poemPlugin {
+	return &poemPlugin{BasePlugin: NewBasePlugin("poem", "0.1.0", "Writes poems", "test", []string{"fiction"})}
+}
+
+func (p *poemPlugin) CreatePhases() ([]orc.Phase, error) {
+	return []orc.Phase{&poemPhase{BasePhase: NewBasePhase("draft", time.Minute), plugin: p}}, nil
+}
+
+type poemPhase struct {
+	BasePhase
+	plugin *poemPlugin
+}
+
+func (ph *poemPhase) Execute(ctx context.Context, input orc.PhaseInput) (orc.PhaseOutput, error) {
+	if ph.plugin.Agents() == nil || ph.plugin.Storage() == nil {
+		return orc.PhaseOutput{}, fmt.Errorf("host services weren't injected")
+	}
+	poem, err := ph.plugin.Agents().CreateAgent("poet", "").Execute(ctx, "write about "+input.Request, nil)
+	if err != nil {
+		return orc.PhaseOutput{}, err
+	}
+	if err := ph.plugin.Storage().SaveOutput(input.SessionID, "poem.md", []byte(poem)); err != nil {
+		return orc.PhaseOutput{}, err
+	}
+	return orc.PhaseOutput{Data: poem}, nil
+}
+
+type fakeHost struct {
+	mu    sync.Mutex
+	saved map[string]string
+}
+
+func (h *fakeHost) ExecuteAgent(ctx context.Context, p rpc.AgentExecuteParams) (string, error) {
+	return "a poem " + p.Prompt, nil
+}
+
+func (h *fakeHost) Save(ctx context.Context, key string, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.saved[key] = string(data)
+	return nil
+}
+
+func (h *fakeHost) Load(ctx context.Context, key string) ([]byte, error) {
+	return nil, fmt.Errorf("%s not found", key)
+}
+
+func (h *fakeHost) Publish(ctx context.Context, event rpc.EventParams) error {
+	return nil
+}
+
+func TestServeInjectsHostServices(t *testing.T) {
+	hostR, pluginW := io.Pipe()
+	pluginR, hostW := io.Pipe()
+	go func() {
+		serve(context.Background(), newPoemPlugin(), pluginR, pluginW)
+		pluginW.Close()
+	}()
+
+	services := &fakeHost{saved: make(map[string]string)}
+	host, err := rpc.Connect(context.Background(), hostR, hostW, rpc.HostConfig{Plugin: "poem", Services: services})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer host.Close(time.Second)
+
+	input := map[string]interface{}{
+		"Request":  "the sea",
+		"Metadata": map[string]interface{}{"session_id": "s1"},
+	}
+	raw, err := host.Execute(context.Background(), "draft", "s1", input)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var output struct{ Data string }
+	if err := json.Unmarshal(raw, &output); err != nil || output.Data != "a poem write about the sea" {
+		t.Errorf("Execute() = %s, %v", raw, err)
+	}
+	if got := services.saved["s1/poem.md"]; got != output.Data {
+		t.Errorf("saved poem = %q, want %q", got, output.Data)
+	}
+}
+
+func TestExecuteOnceWithoutHostServices(t *testing.T) {
+	var out strings.Builder
+	err := executeOnce(context.Background(), newPoemPlugin(), "draft", strings.NewReader(`{"Request":"the sea"}`), &out)
+	if err == nil || !strings.Contains(err.Error(), "weren't injected") {
+		t.Errorf("executeOnce() error = %v, want services missing", err)
+	}
+	if err := executeOnce(context.Background(), newPoemPlugin(), "missing", strings.NewReader(`{}`), &out); err == nil {
+		t.Error("executeOnce() with an unknown phase succeeded")
+	}
+}
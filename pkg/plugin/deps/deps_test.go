@@ -0,0 +1,244 @@
+package deps
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "3.1.4", true},
+		{"*", "0.0.1", true},
+		{"1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.4", false},
+		{"1.2", "1.2.9", true},
+		{"1.x", "2.0.0", false},
+		{">=1.2, <2", "1.9.9", true},
+		{">= 1.2 < 2", "2.0.0", false},
+		{">1.2", "1.2.9", false},
+		{"<=1.2", "1.2.9", true},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.2.2", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{"^1.0 || ^3.0", "3.1.0", true},
+		{"^1.0 || ^3.0", "2.1.0", false},
+		{"<2.0.0", "2.0.0-rc.1", true},
+		{">=1.0.0", "v1.0.0", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) error = %v", tt.constraint, err)
+			continue
+		}
+		if got := c.Check(mustVersion(t, tt.version)); got != tt.want {
+			t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{">=", "1.2.3.4", "^x1", "=>1", "1.02"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// In ascending order, as in the semver spec
+	versions := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+	for i := 1; i < len(versions); i++ {
+		a, b := mustVersion(t, versions[i-1]), mustVersion(t, versions[i])
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("%s isn't older than %s", a, b)
+		}
+	}
+	if mustVersion(t, "1.0.0+build.5").Compare(mustVersion(t, "1.0.0")) != 0 {
+		t.Error("build metadata changed the order")
+	}
+}
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		spec, name, constraint string
+	}{
+		{"base-fiction", "base-fiction", "*"},
+		{"base-fiction ^1.2", "base-fiction", "^1.2"},
+		{"ai-client>=0.3, <0.5", "ai-client", ">=0.3, <0.5"},
+	}
+	for _, tt := range tests {
+		r, err := ParseDependency(tt.spec)
+		if err != nil || r.Name != tt.name || r.Constraint.String() != tt.constraint {
+			t.Errorf("ParseDependency(%q) = %s %s, %v", tt.spec, r.Name, r.Constraint, err)
+		}
+	}
+	if _, err := ParseDependency(">=1.0"); err == nil {
+		t.Error("ParseDependency accepted a spec without a name")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	plugin := func(name, version string, deps ...string) Plugin {
+		return Plugin{Name: name, Version: version, Dependencies: deps}
+	}
+
+	t.Run("topological order", func(t *testing.T) {
+		g := Resolve([]Plugin{
+			plugin("app", "1.0.0", "writer ^2", "base"),
+			plugin("writer", "2.1.0", "base >=1.0"),
+			plugin("base", "1.4.0"),
+			plugin("alone", "0.1.0"),
+		}, "1.0.0")
+		if err := g.Err(); err != nil {
+			t.Fatalf("Err() = %v", err)
+		}
+		want := []string{"alone", "base", "writer", "app"}
+		if !reflect.DeepEqual(g.Order, want) {
+			t.Errorf("Order = %v, want %v", g.Order, want)
+		}
+		if got := g.Dependents("base"); !reflect.DeepEqual(got, []string{"app", "writer"}) {
+			t.Errorf("Dependents(base) = %v", got)
+		}
+	})
+
+	t.Run("missing dependency", func(t *testing.T) {
+		g := Resolve([]Plugin{
+			plugin("app", "1.0.0", "writer"),
+			plugin("writer", "1.0.0", "base ^1"),
+		}, "1.0.0")
+		var missing *MissingError
+		if !errors.As(g.Problems["writer"], &missing) || missing.Requirement.Name != "base" {
+			t.Errorf("writer problem = %v, want base missing", g.Problems["writer"])
+		}
+		if !errors.As(g.Problems["app"], &missing) {
+			t.Errorf("app problem = %v, want its dependency's problem", g.Problems["app"])
+		}
+		if len(g.Order) != 0 {
+			t.Errorf("Order = %v, want nothing loadable", g.Order)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		g := Resolve([]Plugin{
+			plugin("a", "1.0.0", "b"),
+			plugin("b", "1.0.0", "c"),
+			plugin("c", "1.0.0", "a"),
+			plugin("d", "1.0.0", "a"),
+			plugin("self", "1.0.0", "self"),
+			plugin("ok", "1.0.0"),
+		}, "1.0.0")
+		var cycle *CycleError
+		for _, name := range []string{"a", "b", "c", "d", "self"} {
+			if !errors.As(g.Problems[name], &cycle) {
+				t.Errorf("%s problem = %v, want a cycle", name, g.Problems[name])
+			}
+		}
+		if msg := g.Problems["a"].Error(); msg != "dependency cycle: a -> b -> c -> a" {
+			t.Errorf("a problem = %q", msg)
+		}
+		if !reflect.DeepEqual(g.Order, []string{"ok"}) {
+			t.Errorf("Order = %v, want [ok]", g.Order)
+		}
+	})
+
+	t.Run("incompatible versions", func(t *testing.T) {
+		g := Resolve([]Plugin{
+			plugin("base", "1.4.0"),
+			plugin("old", "1.0.0", "base ~1.4"),
+			plugin("new", "1.0.0", "base ^2.0"),
+		}, "1.0.0")
+		var conflict *ConflictError
+		if !errors.As(g.Problems["new"], &conflict) {
+			t.Fatalf("new problem = %v, want a conflict", g.Problems["new"])
+		}
+		want := "plugin new needs base ^2.0, but base 1.4.0 is installed (old needs ~1.4)"
+		if conflict.Error() != want {
+			t.Errorf("conflict = %q, want %q", conflict.Error(), want)
+		}
+		if !reflect.DeepEqual(g.Order, []string{"base", "old"}) {
+			t.Errorf("Order = %v", g.Order)
+		}
+	})
+
+	t.Run("host version", func(t *testing.T) {
+		plugins := []Plugin{
+			{Name: "ranged", Version: "1.0.0", MinVersion: "1.0.0", MaxVersion: "2.0.0"},
+			plugin("user", "1.0.0", "ranged"),
+		}
+		if g := Resolve(plugins, "2.0.0"); g.Err() != nil {
+			t.Errorf("2.0.0 is in range: %v", g.Err())
+		}
+		if g := Resolve(plugins, "dev"); g.Err() != nil {
+			t.Errorf("development builds run everything: %v", g.Err())
+		}
+		g := Resolve(plugins, "2.1.0")
+		var host *HostVersionError
+		if !errors.As(g.Problems["ranged"], &host) || !errors.As(g.Problems["user"], &host) {
+			t.Errorf("problems = %v, want both to fail on the host version", g.Problems)
+		}
+		if !strings.Contains(g.Err().Error(), "supports orc >=1.0.0, <=2.0.0, not 2.1.0") {
+			t.Errorf("Err() = %v", g.Err())
+		}
+	})
+
+	t.Run("invalid specs", func(t *testing.T) {
+		g := Resolve([]Plugin{plugin("bad", "one"), plugin("spec", "1.0.0", "base >>1")}, "1.0.0")
+		if len(g.Problems) != 2 || len(g.Order) != 0 {
+			t.Errorf("problems = %v, order = %v", g.Problems, g.Order)
+		}
+	})
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"writer/plugin.yaml": "name: writer\nversion: 2.1.0\ndependencies:\n  - base ^1.2\n",
+		"base/manifest.json": `{"name": "base", "version": "1.4.0", "max_version": "2.0.0"}`,
+		"broken/plugin.yml":  "version: 1.0.0\n",
+		"writer/notes.yaml":  "name: not-a-plugin\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plugins, errs := Scan([]string{dir, filepath.Join(dir, "missing")})
+	if len(errs) != 1 {
+		t.Errorf("errors = %v, want the manifest without a name", errs)
+	}
+	g := Resolve(plugins, "1.0.0")
+	if !reflect.DeepEqual(g.Order, []string{"base", "writer"}) || g.Err() != nil {
+		t.Errorf("Order = %v, Err() = %v", g.Order, g.Err())
+	}
+	if p, _ := g.Plugin("base"); p.MaxVersion != "2.0.0" || p.Location != filepath.Join(dir, "base") {
+		t.Errorf("base = %+v", p)
+	}
+}
+
+func mustVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q) error = %v", s, err)
+	}
+	return v
+}
@@ -0,0 +1,418 @@
+// Package deps resolves the dependencies between plugins. Plugins name the
+// plugins they need, optionally with a semver constraint on their version, and
+// the orc versions they run on. Resolve works out which plugins can load and the
+// order to load them in, so that every plugin loads after its dependencies.
+package deps
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plugin is what resolving needs to know about an installed plugin
+type Plugin struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// MinVersion and MaxVersion bound the orc versions the plugin runs on;
+	// both are inclusive
+	MinVersion string `yaml:"min_version"`
+	MaxVersion string `yaml:"max_version"`
+	// Dependencies are specs such as "base-fiction" or "base-fiction ^1.2"
+	Dependencies []string `yaml:"dependencies"`
+	// Location is the directory the plugin's manifest is in
+	Location string `yaml:"-"`
+}
+
+// Requirement is one plugin's need for another
+type Requirement struct {
+	Name       string
+	Constraint Constraint
+}
+
+// ParseDependency parses a dependency spec: a plugin name, optionally followed
+// by a version constraint, as in "ai-client >=0.3, <0.5" or "base-fiction^1.2"
+func ParseDependency(spec string) (Requirement, error) {
+	spec = strings.TrimSpace(spec)
+	end := strings.IndexAny(spec, " \t<>=^~")
+	if end < 0 {
+		end = len(spec)
+	}
+	r := Requirement{Name: spec[:end]}
+	if r.Name == "" {
+		return Requirement{}, fmt.Errorf("invalid dependency %q: no plugin name", spec)
+	}
+	c, err := ParseConstraint(spec[end:])
+	if err != nil {
+		return Requirement{}, fmt.Errorf("invalid dependency %q: %w", spec, err)
+	}
+	r.Constraint = c
+	return r, nil
+}
+
+func (r Requirement) String() string {
+	if r.Constraint.raw == "" {
+		return r.Name
+	}
+	return r.Name + " " + r.Constraint.raw
+}
+
+// CheckHost returns a HostVersionError if an orc version is outside the range a
+// plugin supports. Versions that aren't semver, such as development builds, run
+// everything.
+func CheckHost(plugin, minVersion, maxVersion, hostVersion string) error {
+	host, err := ParseVersion(hostVersion)
+	if err != nil {
+		return nil
+	}
+	if minVersion != "" {
+		min, err := ParseVersion(minVersion)
+		if err != nil {
+			return fmt.Errorf("plugin %s: invalid min_version: %w", plugin, err)
+		}
+		if host.Compare(min) < 0 {
+			return &HostVersionError{Plugin: plugin, Host: hostVersion, Min: minVersion, Max: maxVersion}
+		}
+	}
+	if maxVersion != "" {
+		max, err := ParseVersion(maxVersion)
+		if err != nil {
+			return fmt.Errorf("plugin %s: invalid max_version: %w", plugin, err)
+		}
+		if host.Compare(max) > 0 {
+			return &HostVersionError{Plugin: plugin, Host: hostVersion, Min: minVersion, Max: maxVersion}
+		}
+	}
+	return nil
+}
+
+// HostVersionError means a plugin doesn't run on this version of orc
+type HostVersionError struct {
+	Plugin, Host, Min, Max string
+}
+
+func (e *HostVersionError) Error() string {
+	var supported []string
+	if e.Min != "" {
+		supported = append(supported, ">="+e.Min)
+	}
+	if e.Max != "" {
+		supported = append(supported, "<="+e.Max)
+	}
+	return fmt.Sprintf("plugin %s supports orc %s, not %s", e.Plugin, strings.Join(supported, ", "), e.Host)
+}
+
+// MissingError means a plugin needs a plugin that isn't installed
+type MissingError struct {
+	Plugin      string
+	Requirement Requirement
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("plugin %s needs %s, which isn't installed", e.Plugin, e.Requirement)
+}
+
+// ConflictError means the installed version of a dependency doesn't satisfy a
+// plugin. Others holds what the other plugins using it need, so that plugins
+// needing incompatible versions can be told apart from a plain outdated install.
+type ConflictError struct {
+	Plugin      string
+	Requirement Requirement
+	Installed   string
+	Others      map[string]Constraint
+}
+
+func (e *ConflictError) Error() string {
+	msg := fmt.Sprintf("plugin %s needs %s, but %s %s is installed",
+		e.Plugin, e.Requirement, e.Requirement.Name, e.Installed)
+	others := make([]string, 0, len(e.Others))
+	for name, c := range e.Others {
+		others = append(others, fmt.Sprintf("%s needs %s", name, c))
+	}
+	sort.Strings(others)
+	if len(others) > 0 {
+		msg += " (" + strings.Join(others, ", ") + ")"
+	}
+	return msg
+}
+
+// CycleError means plugins depend on each other in a loop
+type CycleError struct {
+	// Cycle starts and ends with the same plugin
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// DependencyError means a plugin can't load because a plugin it needs can't
+type DependencyError struct {
+	Plugin     string
+	Dependency string
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("plugin %s needs %s, which can't load: %v", e.Plugin, e.Dependency, e.Err)
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// Graph is the result of resolving a set of plugins
+type Graph struct {
+	// Order lists the plugins that can load, each after its dependencies.
+	// Plugins that don't depend on each other are in name order.
+	Order []string
+	// Problems holds why each of the other plugins can't load
+	Problems map[string]error
+
+	nodes map[string]*node
+}
+
+type node struct {
+	plugin   Plugin
+	version  Version
+	requires []Requirement
+	parsed   bool
+}
+
+// Resolve builds the dependency graph of plugins for an orc version. When two
+// plugins share a name, the first is used.
+func Resolve(plugins []Plugin, hostVersion string) *Graph {
+	g := &Graph{Problems: make(map[string]error), nodes: make(map[string]*node)}
+	var names []string
+	for _, p := range plugins {
+		if _, exists := g.nodes[p.Name]; exists {
+			continue
+		}
+		n := &node{plugin: p}
+		g.nodes[p.Name] = n
+		names = append(names, p.Name)
+		if err := n.parse(); err != nil {
+			g.Problems[p.Name] = err
+		} else if err := CheckHost(p.Name, p.MinVersion, p.MaxVersion, hostVersion); err != nil {
+			g.Problems[p.Name] = err
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if g.Problems[name] == nil {
+			g.Problems[name] = g.check(name)
+		}
+	}
+	g.findCycles(names)
+
+	// Plugins can't load without their dependencies
+	for changed := true; changed; {
+		changed = false
+		for _, name := range names {
+			if g.Problems[name] != nil {
+				continue
+			}
+			for _, r := range g.nodes[name].requires {
+				if err := g.Problems[r.Name]; err != nil {
+					g.Problems[name] = &DependencyError{Plugin: name, Dependency: r.Name, Err: err}
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, name := range names {
+		if g.Problems[name] == nil {
+			delete(g.Problems, name)
+		}
+	}
+
+	g.Order = g.sort(names)
+	return g
+}
+
+// parse reads the plugin's version and dependencies
+func (n *node) parse() error {
+	v, err := ParseVersion(n.plugin.Version)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", n.plugin.Name, err)
+	}
+	n.version = v
+	for _, spec := range n.plugin.Dependencies {
+		r, err := ParseDependency(spec)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", n.plugin.Name, err)
+		}
+		n.requires = append(n.requires, r)
+	}
+	n.parsed = true
+	return nil
+}
+
+// check checks that a plugin's dependencies are installed in versions it accepts
+func (g *Graph) check(name string) error {
+	for _, r := range g.nodes[name].requires {
+		if r.Name == name {
+			return &CycleError{Cycle: []string{name, name}}
+		}
+		dep, ok := g.nodes[r.Name]
+		if !ok {
+			return &MissingError{Plugin: name, Requirement: r}
+		}
+		if !dep.parsed {
+			continue // Its own problem is reported once it is propagated
+		}
+		if !r.Constraint.Check(dep.version) {
+			return &ConflictError{
+				Plugin:      name,
+				Requirement: r,
+				Installed:   dep.plugin.Version,
+				Others:      g.othersNeeding(r.Name, name),
+			}
+		}
+	}
+	return nil
+}
+
+// othersNeeding returns the constraints plugins other than except have on a plugin
+func (g *Graph) othersNeeding(dependency, except string) map[string]Constraint {
+	others := make(map[string]Constraint)
+	for name, n := range g.nodes {
+		if name == except {
+			continue
+		}
+		for _, r := range n.requires {
+			if r.Name == dependency {
+				others[name] = r.Constraint
+			}
+		}
+	}
+	return others
+}
+
+// findCycles marks the plugins in dependency cycles
+func (g *Graph) findCycles(names []string) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, r := range g.nodes[name].requires {
+			if _, ok := g.nodes[r.Name]; !ok || r.Name == name {
+				continue
+			}
+			switch state[r.Name] {
+			case unvisited:
+				visit(r.Name)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != r.Name {
+					start--
+				}
+				cycle := append(append([]string(nil), stack[start:]...), r.Name)
+				for _, member := range stack[start:] {
+					if g.Problems[member] == nil {
+						g.Problems[member] = &CycleError{Cycle: cycle}
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+}
+
+// sort orders the loadable plugins so that each comes after its dependencies
+func (g *Graph) sort(names []string) []string {
+	pending := make(map[string]int)
+	for _, name := range names {
+		if g.Problems[name] == nil {
+			pending[name] = len(g.nodes[name].requires)
+		}
+	}
+
+	order := make([]string, 0, len(pending))
+	for len(pending) > 0 {
+		var ready []string
+		for name, waiting := range pending {
+			if waiting == 0 {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			break // Cycles were removed above, so this can't happen
+		}
+		sort.Strings(ready)
+		next := ready[0]
+		delete(pending, next)
+		order = append(order, next)
+		for name := range pending {
+			for _, r := range g.nodes[name].requires {
+				if r.Name == next {
+					pending[name]--
+				}
+			}
+		}
+	}
+	return order
+}
+
+// Plugin returns a plugin in the graph
+func (g *Graph) Plugin(name string) (Plugin, bool) {
+	n, ok := g.nodes[name]
+	if !ok {
+		return Plugin{}, false
+	}
+	return n.plugin, true
+}
+
+// Requires returns what a plugin needs, or nil if its dependencies are invalid
+func (g *Graph) Requires(name string) []Requirement {
+	if n, ok := g.nodes[name]; ok {
+		return n.requires
+	}
+	return nil
+}
+
+// Dependents returns the plugins that need a plugin, in name order
+func (g *Graph) Dependents(name string) []string {
+	var dependents []string
+	for other, n := range g.nodes {
+		for _, r := range n.requires {
+			if r.Name == name {
+				dependents = append(dependents, other)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// Err joins the problems, in plugin name order, or returns nil if there are none
+func (g *Graph) Err() error {
+	names := make([]string, 0, len(g.Problems))
+	for name := range g.Problems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, len(names))
+	for i, name := range names {
+		errs[i] = g.Problems[name]
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package deps
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scan reads the plugin manifests (plugin.yaml, manifest.json and so on) under
+// the given directories. Directories that don't exist are skipped; manifests
+// that can't be read are returned as errors alongside the plugins that could.
+func Scan(paths []string) ([]Plugin, []error) {
+	var plugins []Plugin
+	var errs []error
+	for _, root := range paths {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !isManifestFile(entry.Name()) {
+				return nil // Skip inaccessible directories
+			}
+			p, err := readManifest(path)
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			plugins = append(plugins, p)
+			return nil
+		})
+	}
+	return plugins, errs
+}
+
+// isManifestFile checks if a filename is a plugin manifest
+func isManifestFile(name string) bool {
+	switch strings.TrimSuffix(name, filepath.Ext(name)) {
+	case "plugin", "manifest":
+	default:
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readManifest reads the fields resolving needs from a manifest; YAML parsing
+// covers JSON manifests too
+func readManifest(path string) (Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Plugin{}, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var p Plugin
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return Plugin{}, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if p.Name == "" {
+		return Plugin{}, fmt.Errorf("manifest %s has no plugin name", path)
+	}
+	p.Location = filepath.Dir(path)
+	return p, nil
+}
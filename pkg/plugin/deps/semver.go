@@ -0,0 +1,312 @@
+package deps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. A leading "v" is accepted, and missing minor
+// and patch numbers are taken as zero.
+type Version struct {
+	Major, Minor, Patch uint64
+	Prerelease          string
+	Build               string // Ignored when comparing
+}
+
+// ParseVersion parses a semantic version such as "1.4.2", "v2.0.0-rc.1" or "1.3"
+func ParseVersion(s string) (Version, error) {
+	v, parts, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if parts == 0 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// parsePartial parses a version that may end in wildcards ("1.x", "1.2.*", "*")
+// or stop early ("1.2"), and returns how many numbers it gave
+func parsePartial(s string) (Version, int, error) {
+	var v Version
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if rest, v.Build, _ = strings.Cut(rest, "+"); v.Build != "" && !validIdentifiers(v.Build) {
+		return Version{}, 0, fmt.Errorf("invalid build metadata in version %q", s)
+	}
+	if rest, v.Prerelease, _ = strings.Cut(rest, "-"); v.Prerelease != "" && !validIdentifiers(v.Prerelease) {
+		return Version{}, 0, fmt.Errorf("invalid prerelease in version %q", s)
+	}
+
+	fields := strings.Split(rest, ".")
+	if len(fields) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	parts := 0
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			if i+1 < len(fields) || v.Prerelease != "" {
+				return Version{}, 0, fmt.Errorf("invalid version %q", s)
+			}
+			break
+		}
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil || (len(field) > 1 && field[0] == '0') {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+		parts++
+	}
+	if v.Prerelease != "" && parts < 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q: a prerelease needs a full version", s)
+	}
+	return v, parts, nil
+}
+
+func validIdentifiers(s string) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than o.
+// A prerelease is older than its release.
+func (v Version) Compare(o Version) int {
+	for _, d := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil: // Numeric identifiers sort first
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Constraint is a set of version ranges, written as in npm and Cargo:
+//
+//	1.2.3  =1.2.3     exactly 1.2.3
+//	1.2  1.2.x        any 1.2 release
+//	>1.2  >=1.2.0  <2  <=2.0.0
+//	^1.2.3            compatible with 1.2.3: >=1.2.3 <2.0.0 (>=0.2.3 <0.3.0 for 0.x)
+//	~1.2.3            patch releases of 1.2: >=1.2.3 <1.3.0
+//	*                 any version
+//
+// Ranges separated by spaces or commas must all match; alternatives are joined
+// with "||".
+type Constraint struct {
+	raw  string
+	sets [][]bound
+}
+
+// bound is one primitive comparison against a version
+type bound struct {
+	op string // =, <, <=, >, >=
+	v  Version
+}
+
+// ParseConstraint parses a version constraint; an empty one allows any version
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	for _, alternative := range strings.Split(s, "||") {
+		var set []bound
+		tokens := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// Allow a space between an operator and its version
+			if strings.Trim(token, "<>=^~") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			bounds, err := parseRange(token)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			set = append(set, bounds...)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// parseRange expands one range into the bounds it stands for
+func parseRange(token string) ([]bound, error) {
+	op := token[:len(token)-len(strings.TrimLeft(token, "<>=^~"))]
+	v, parts, err := parsePartial(token[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	// next is the first version past the given numbers: 1.2 -> 1.3.0
+	next := func(parts int) Version {
+		switch parts {
+		case 1:
+			return Version{Major: v.Major + 1}
+		case 2:
+			return Version{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	lower := bound{">=", v}
+
+	switch op {
+	case "", "=":
+		if parts == 3 {
+			return []bound{{"=", v}}, nil
+		}
+		if parts == 0 {
+			return nil, nil
+		}
+		return []bound{lower, {"<", next(parts)}}, nil
+	case "^":
+		if parts == 0 {
+			return nil, nil
+		}
+		// The first non-zero number given may not change
+		significant := 1
+		if v.Major == 0 && parts > 1 {
+			significant = 2
+			if v.Minor == 0 && parts > 2 {
+				significant = 3
+			}
+		}
+		return []bound{lower, {"<", next(significant)}}, nil
+	case "~":
+		if parts == 0 {
+			return nil, nil
+		}
+		if parts == 1 {
+			return []bound{lower, {"<", next(1)}}, nil
+		}
+		return []bound{lower, {"<", next(2)}}, nil
+	case ">=", "<":
+		if parts == 0 {
+			if op == "<" {
+				return []bound{{"<", Version{}}}, nil
+			}
+			return nil, nil
+		}
+		return []bound{{op, v}}, nil
+	case ">":
+		if parts == 0 {
+			return []bound{{"<", Version{}}}, nil
+		}
+		if parts < 3 {
+			return []bound{{">=", next(parts)}}, nil
+		}
+		return []bound{{">", v}}, nil
+	case "<=":
+		if parts == 0 {
+			return nil, nil
+		}
+		if parts < 3 {
+			return []bound{{"<", next(parts)}}, nil
+		}
+		return []bound{{"<=", v}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// Check reports whether v satisfies the constraint
+func (c Constraint) Check(v Version) bool {
+	if len(c.sets) == 0 {
+		return true
+	}
+	for _, set := range c.sets {
+		if matches(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(set []bound, v Version) bool {
+	for _, b := range set {
+		c := v.Compare(b.v)
+		ok := false
+		switch b.op {
+		case "=":
+			ok = c == 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint as it was written, or "*" if it allows anything
+func (c Constraint) String() string {
+	if c.raw == "" {
+		return "*"
+	}
+	return c.raw
+}
@@ -84,12 +84,12 @@ type EventBus struct {
 	wg           sync.WaitGroup
 	
 	// Metrics
-	metrics *EventMetrics
+	metricsMu sync.RWMutex
+	metrics   *EventMetrics
 }
 
 // EventMetrics tracks bus performance
 type EventMetrics struct {
-	mu              sync.RWMutex
 	TotalPublished  int64
 	TotalDelivered  int64
 	TotalFailed     int64
@@ -396,8 +396,8 @@ func (eb *EventBus) Stop() {
 
 // GetMetrics returns current bus metrics
 func (eb *EventBus) GetMetrics() EventMetrics {
-	eb.metrics.mu.RLock()
-	defer eb.metrics.mu.RUnlock()
+	eb.metricsMu.RLock()
+	defer eb.metricsMu.RUnlock()
 	
 	// Create a copy to avoid race conditions
 	metrics := *eb.metrics
@@ -437,8 +437,8 @@ type SubscriptionInfo struct {
 
 // updateMetrics safely updates metrics
 func (eb *EventBus) updateMetrics(updater func(*EventMetrics)) {
-	eb.metrics.mu.Lock()
-	defer eb.metrics.mu.Unlock()
+	eb.metricsMu.Lock()
+	defer eb.metricsMu.Unlock()
 	updater(eb.metrics)
 }
 
@@ -162,10 +162,16 @@ func TestPluginCompatibility(t *testing.T) {
 		MaxVersion: "2.0.0",
 	}
 
-	// Test compatibility (currently always returns true)
+	// Test compatibility
 	if !manifest.IsCompatible("1.5.0") {
 		t.Error("Expected plugin to be compatible with version 1.5.0")
 	}
+	if !manifest.IsCompatible("2.0.0") {
+		t.Error("Expected max_version to be inclusive")
+	}
+	if manifest.IsCompatible("2.1.0") || manifest.IsCompatible("0.9.0") {
+		t.Error("Expected plugin to be incompatible outside its version range")
+	}
 }
 
 func TestManifestValidation(t *testing.T) {
@@ -3,6 +3,8 @@ package plugin_test
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -111,7 +113,7 @@ func TestHealthAwarePluginManager(t *testing.T) {
 	manager := plugin.NewHealthAwarePluginManager()
 	
 	// Register plugins
-	healthyPlugin := plugin.NewExampleHealthyPlugin("healthy-plugin")
+	healthyPlugin := &TestHealthPlugin{healthy: true} // The example plugin fails checks at random
 	if err := manager.RegisterPlugin("healthy-plugin", healthyPlugin); err != nil {
 		t.Fatalf("Failed to register healthy plugin: %v", err)
 	}
@@ -216,8 +218,15 @@ func TestSpecializedHealthCheckers(t *testing.T) {
 	ctx := context.Background()
 	
 	t.Run("HTTPHealthChecker", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("User-Agent") != "HealthCheck/1.0" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer srv.Close()
+		
 		checker := &plugin.HTTPHealthChecker{
-			URL:     "https://httpbin.org/status/200",
+			URL:     srv.URL,
 			Timeout: 5 * time.Second,
 			Headers: map[string]string{
 				"User-Agent": "HealthCheck/1.0",
@@ -1,9 +1,11 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -13,8 +15,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dotcommander/orc/internal/core"
 	"github.com/dotcommander/orc/internal/domain"
 	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/pkg/orc"
+	"github.com/dotcommander/orc/pkg/plugin/deps"
 )
 
 // Loader loads and manages plugins
@@ -23,6 +28,7 @@ type Loader struct {
 	discoverer *Discoverer
 	registry   *domainPlugin.DomainRegistry
 	loaded     map[string]LoadedPlugin
+	services   HostServices
 	mu         sync.RWMutex
 }
 
@@ -45,17 +51,48 @@ func NewLoader(logger *slog.Logger, discoverer *Discoverer, registry *domainPlug
 	}
 }
 
-// LoadAll loads all discovered plugins
+// SetHostServices sets what plugins speaking the JSON-RPC protocol can call back
+// into. It applies to plugins loaded afterwards.
+func (l *Loader) SetHostServices(services HostServices) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.services = services
+}
+
+// LoadAll loads all discovered plugins, each after the plugins it depends on.
+// Plugins whose dependencies are missing, cyclic or in versions they don't accept
+// are left out.
 func (l *Loader) LoadAll() error {
 	manifests, err := l.discoverer.Discover()
 	if err != nil {
 		return fmt.Errorf("failed to discover plugins: %w", err)
 	}
+	return l.LoadManifests(manifests)
+}
+
+// LoadManifests loads the plugins manifests describe, as LoadAll does for the
+// ones it discovers
+func (l *Loader) LoadManifests(manifests []*Manifest) error {
+	byName := make(map[string]*Manifest, len(manifests))
+	infos := make([]deps.Plugin, len(manifests))
+	for i, manifest := range manifests {
+		byName[manifest.Name] = manifest
+		infos[i] = manifest.DependencyInfo()
+	}
+	l.mu.RLock()
+	hostVersion := l.services.HostVersion
+	l.mu.RUnlock()
+	graph := deps.Resolve(infos, hostVersion)
 
 	var loadErrors []error
 	for _, manifest := range manifests {
-		if err := l.Load(manifest); err != nil {
-			loadErrors = append(loadErrors, fmt.Errorf("failed to load %s: %w", manifest.Name, err))
+		if problem := graph.Problems[manifest.Name]; problem != nil {
+			loadErrors = append(loadErrors, fmt.Errorf("failed to load %s: %w", manifest.Name, problem))
+		}
+	}
+	for _, name := range graph.Order {
+		if err := l.Load(byName[name]); err != nil {
+			loadErrors = append(loadErrors, fmt.Errorf("failed to load %s: %w", name, err))
 		}
 	}
 
@@ -77,6 +114,13 @@ func (l *Loader) Load(manifest *Manifest) error {
 		return nil
 	}
 
+	if err := deps.CheckHost(manifest.Name, manifest.MinVersion, manifest.MaxVersion, l.services.HostVersion); err != nil {
+		return err
+	}
+	if err := l.checkDependencies(manifest); err != nil {
+		return err
+	}
+
 	l.logger.Info("loading plugin", "name", manifest.Name, "type", manifest.Type)
 
 	var domainPlg domainPlugin.DomainPlugin
@@ -88,9 +132,12 @@ func (l *Loader) Load(manifest *Manifest) error {
 	case PluginTypeBuiltin:
 		domainPlg, err = l.loadBuiltinPlugin(manifest)
 	case PluginTypeExternal:
-		if manifest.Binary {
+		switch {
+		case strings.HasSuffix(manifest.EntryPoint, ".wasm"):
+			domainPlg, err = l.loadWasmPlugin(manifest)
+		case manifest.Binary:
 			domainPlg, process, err = l.loadBinaryPlugin(manifest)
-		} else {
+		default:
 			domainPlg, handle, err = l.loadGoPlugin(manifest)
 		}
 	default:
@@ -120,6 +167,29 @@ func (l *Loader) Load(manifest *Manifest) error {
 	return nil
 }
 
+// checkDependencies checks that the plugins a plugin needs are loaded, in
+// versions it accepts. The caller holds l.mu.
+func (l *Loader) checkDependencies(manifest *Manifest) error {
+	for _, spec := range manifest.Dependencies {
+		req, err := deps.ParseDependency(spec)
+		if err != nil {
+			return err
+		}
+		dep, exists := l.loaded[req.Name]
+		if !exists {
+			return fmt.Errorf("dependency %s is not loaded", req)
+		}
+		version, err := deps.ParseVersion(dep.Manifest.Version)
+		if err != nil {
+			return fmt.Errorf("dependency %s: %w", req.Name, err)
+		}
+		if !req.Constraint.Check(version) {
+			return fmt.Errorf("dependency %s is loaded at version %s", req, dep.Manifest.Version)
+		}
+	}
+	return nil
+}
+
 // loadBuiltinPlugin loads a built-in plugin (already compiled into the binary)
 func (l *Loader) loadBuiltinPlugin(manifest *Manifest) (domainPlugin.DomainPlugin, error) {
 	// Built-in plugins are registered at compile time
@@ -156,18 +226,33 @@ func (l *Loader) loadGoPlugin(manifest *Manifest) (domainPlugin.DomainPlugin, in
 		return nil, nil, fmt.Errorf("plugin missing 'Plugin' symbol: %w", err)
 	}
 
-	// Assert the type
-	domainPlg, ok := symPlugin.(domainPlugin.DomainPlugin)
-	if !ok {
-		// Try pointer type
-		if ptr, ok := symPlugin.(*domainPlugin.DomainPlugin); ok {
-			domainPlg = *ptr
-		} else {
-			return nil, nil, fmt.Errorf("plugin 'Plugin' symbol has wrong type: %T", symPlugin)
-		}
-	}
+	// Plugins built against the public pkg/orc API are adapted; internal domain
+	// plugins are used as they are
+	switch sym := symPlugin.(type) {
+	case domainPlugin.DomainPlugin:
+		return sym, p, nil
+	case *domainPlugin.DomainPlugin:
+		return *sym, p, nil
+	case orc.Plugin:
+		return l.adaptOrcPlugin(sym, manifest, p)
+	case *orc.Plugin:
+		return l.adaptOrcPlugin(*sym, manifest, p)
+	case func() orc.Plugin:
+		return l.adaptOrcPlugin(sym(), manifest, p)
+	}
+	return nil, nil, fmt.Errorf("plugin 'Plugin' symbol has wrong type: %T", symPlugin)
+}
 
-	return domainPlg, p, nil
+// adaptOrcPlugin wraps a pkg/orc plugin loaded from handle for the domain registry
+func (l *Loader) adaptOrcPlugin(orcPlg orc.Plugin, manifest *Manifest, handle interface{}) (domainPlugin.DomainPlugin, interface{}, error) {
+	if orcPlg == nil {
+		return nil, nil, fmt.Errorf("plugin 'Plugin' symbol is nil")
+	}
+	adapter, err := newOrcPluginAdapter(orcPlg, manifest, l.services)
+	if err != nil {
+		return nil, nil, err
+	}
+	return adapter, handle, nil
 }
 
 // loadBinaryPlugin loads an external binary plugin (executable)
@@ -178,24 +263,44 @@ func (l *Loader) loadBinaryPlugin(manifest *Manifest) (domainPlugin.DomainPlugin
 
 	execPath := filepath.Join(manifest.Location, manifest.EntryPoint)
 
-	// Create a wrapper that communicates with the binary via JSON-RPC or similar
 	wrapper := &binaryPluginWrapper{
 		manifest: manifest,
 		execPath: execPath,
+		services: l.services,
 		logger:   l.logger,
 	}
 
-	// Start the plugin process (if needed)
-	// For now, we'll start processes on-demand in the wrapper
+	// JSON-RPC plugins run in one long-lived process, started on the first phase
+	// call; the rest start a process per phase
+	if manifest.Protocol == ProtocolJSONRPC {
+		wrapper.runner = newRPCProcess(manifest, execPath, l.services, l.logger)
+	}
 
 	return wrapper, nil, nil
 }
 
-// binaryPluginWrapper wraps an external binary plugin
+// binaryPluginWrapper wraps an external plugin whose phases are described by its
+// manifest: a binary or a WebAssembly module
 type binaryPluginWrapper struct {
 	manifest *Manifest
 	execPath string
+	services HostServices
 	logger   *slog.Logger
+	runner   phaseRunner // Set for plugins that don't start a process per phase
+}
+
+// phaseRunner runs a plugin's phases by name
+type phaseRunner interface {
+	Execute(ctx context.Context, phase string, input domain.PhaseInput) (domain.PhaseOutput, error)
+	Close() error
+}
+
+// Close stops the plugin's long-lived process or runtime, if it has one
+func (w *binaryPluginWrapper) Close() error {
+	if w.runner == nil {
+		return nil
+	}
+	return w.runner.Close()
 }
 
 // Implement DomainPlugin interface
@@ -261,6 +366,11 @@ func (w *binaryPluginWrapper) GetOutputSpec() domainPlugin.DomainOutputSpec {
 	}
 }
 
+// Schedule returns the ordering the manifest declares for the plugin's phases
+func (w *binaryPluginWrapper) Schedule() []core.PhaseSchedule {
+	return w.manifest.PhaseSchedule()
+}
+
 func (w *binaryPluginWrapper) GetDomainValidator() domain.DomainValidator {
 	// Return a basic validator
 	return &basicDomainValidator{
@@ -279,8 +389,9 @@ func (p *binaryPhaseWrapper) Name() string {
 }
 
 func (p *binaryPhaseWrapper) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
-	// Execute the binary with phase name and input
-	cmd := exec.CommandContext(ctx, p.wrapper.execPath, "execute", p.definition.Name)
+	if p.wrapper.runner != nil {
+		return p.wrapper.runner.Execute(ctx, p.definition.Name, input)
+	}
 
 	// Pass input as JSON via stdin
 	inputJSON, err := json.Marshal(input)
@@ -288,22 +399,47 @@ func (p *binaryPhaseWrapper) Execute(ctx context.Context, input domain.PhaseInpu
 		return domain.PhaseOutput{}, fmt.Errorf("failed to marshal input: %w", err)
 	}
 
-	cmd.Stdin = strings.NewReader(string(inputJSON))
-
-	// Set environment variables
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PLUGIN_NAME=%s", p.wrapper.manifest.Name))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PHASE_NAME=%s", p.definition.Name))
+	// Execute the binary with phase name and input, in the plugin's sandbox
+	sb := p.wrapper.services.sandboxFor(p.wrapper.manifest, p.wrapper.logger)
+	var (
+		cmd    *exec.Cmd
+		output bytes.Buffer
+	)
+	for {
+		cmd = exec.CommandContext(ctx, p.wrapper.execPath, "execute", p.definition.Name)
+		cmd.Stdin = bytes.NewReader(inputJSON)
+		cmd.Stdout = &output
+		err := sb.prepare(cmd,
+			fmt.Sprintf("PLUGIN_NAME=%s", p.wrapper.manifest.Name),
+			fmt.Sprintf("PHASE_NAME=%s", p.definition.Name))
+		if err != nil {
+			return domain.PhaseOutput{}, fmt.Errorf("failed to sandbox plugin: %w", err)
+		}
+		if err = cmd.Start(); err == nil {
+			break
+		}
+		if !sb.retry(err) {
+			return domain.PhaseOutput{}, fmt.Errorf("phase execution failed: %w", err)
+		}
+	}
+	if err := sb.started(cmd.Process); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return domain.PhaseOutput{}, fmt.Errorf("failed to sandbox plugin: %w", err)
+	}
 
-	// Execute and capture output
-	output, err := cmd.Output()
+	err = cmd.Wait()
+	sb.exited(cmd.ProcessState)
+	if violations := sb.violations(); len(violations) > 0 {
+		return domain.PhaseOutput{}, fmt.Errorf("security violations: %v", violations)
+	}
 	if err != nil {
 		return domain.PhaseOutput{}, fmt.Errorf("phase execution failed: %w", err)
 	}
 
 	// Parse output as JSON
 	var phaseOutput domain.PhaseOutput
-	if err := json.Unmarshal(output, &phaseOutput); err != nil {
+	if err := json.Unmarshal(output.Bytes(), &phaseOutput); err != nil {
 		return domain.PhaseOutput{}, fmt.Errorf("failed to parse phase output: %w", err)
 	}
 
@@ -358,7 +494,11 @@ func (v *basicDomainValidator) ValidatePhaseTransition(from, to string, data int
 func (l *Loader) Unload(name string) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	return l.unloadLocked(name)
+}
 
+// unloadLocked unloads a plugin; l.mu must be held
+func (l *Loader) unloadLocked(name string) error {
 	loaded, exists := l.loaded[name]
 	if !exists {
 		return fmt.Errorf("plugin not loaded: %s", name)
@@ -370,6 +510,11 @@ func (l *Loader) Unload(name string) error {
 			l.logger.Warn("failed to kill plugin process", "plugin", name, "error", err)
 		}
 	}
+	if closer, ok := loaded.Plugin.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			l.logger.Warn("failed to stop plugin process", "plugin", name, "error", err)
+		}
+	}
 
 	// Remove from registry
 	// Note: registry doesn't have a Remove method in current implementation
@@ -388,7 +533,7 @@ func (l *Loader) UnloadAll() {
 	defer l.mu.Unlock()
 
 	for name := range l.loaded {
-		if err := l.Unload(name); err != nil {
+		if err := l.unloadLocked(name); err != nil {
 			l.logger.Error("failed to unload plugin", "name", name, "error", err)
 		}
 	}
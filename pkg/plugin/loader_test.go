@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dotcommander/orc/internal/core"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+)
+
+func TestManifestScheduleReachesOrchestrator(t *testing.T) {
+	manifest := &Manifest{
+		Name: "novel",
+		Phases: []PhaseDefinition{
+			{Name: "outline", Order: 1},
+			{Name: "characters", Order: 2, Parallel: true},
+			{Name: "setting", Order: 2, Parallel: true},
+			{Name: "draft", Order: 3},
+		},
+	}
+	wrapped := NewSecurePlugin(&binaryPluginWrapper{manifest: manifest}, nil, nil)
+
+	schedule := domainPlugin.PhaseSchedule(wrapped)
+	if len(schedule) != len(manifest.Phases) {
+		t.Fatalf("schedule = %+v, want the manifest's phases", schedule)
+	}
+	dag, err := core.BuildPhaseDAG(domainPlugin.CorePhases(wrapped), schedule)
+	if err != nil {
+		t.Fatalf("BuildPhaseDAG() error = %v", err)
+	}
+
+	var waves [][]string
+	for _, wave := range dag.Waves() {
+		var names []string
+		for _, p := range wave {
+			names = append(names, p.Name())
+		}
+		waves = append(waves, names)
+	}
+	if want := "[[outline] [characters setting] [draft]]"; fmt.Sprint(waves) != want {
+		t.Errorf("waves = %v, want %s", waves, want)
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/pkg/plugin/deps"
 	"gopkg.in/yaml.v3"
 )
 
@@ -47,9 +52,16 @@ type Manifest struct {
 	Location   string `json:"location" yaml:"location"`           // Directory path
 	EntryPoint string `json:"entry_point" yaml:"entry_point"`     // Main file or binary
 	Binary     bool   `json:"binary" yaml:"binary"`               // True if precompiled binary
+	Protocol   string `json:"protocol" yaml:"protocol,omitempty"` // Binary protocol: exec (default) or jsonrpc
 	Language   string `json:"language" yaml:"language,omitempty"` // Programming language
 }
 
+// Protocols a binary plugin can speak
+const (
+	ProtocolExec    = "exec"    // One process per phase, JSON on stdin and stdout
+	ProtocolJSONRPC = "jsonrpc" // One long-lived process speaking JSON-RPC, see package rpc
+)
+
 // PluginType indicates whether a plugin is built-in or external
 type PluginType string
 
@@ -65,6 +77,7 @@ type PhaseDefinition struct {
 	Order           int               `json:"order" yaml:"order"`
 	Required        bool              `json:"required" yaml:"required"`
 	Parallel        bool              `json:"parallel" yaml:"parallel"`
+	DependsOn       []string          `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
 	EstimatedTime   time.Duration     `json:"estimated_time" yaml:"estimated_time"`
 	Timeout         time.Duration     `json:"timeout" yaml:"timeout"`
 	Retryable       bool              `json:"retryable" yaml:"retryable"`
@@ -93,6 +106,26 @@ type ResourceSpec struct {
 	EnvironmentVars  []string          `json:"environment_vars,omitempty" yaml:"environment_vars,omitempty"`
 	Permissions      []string          `json:"permissions,omitempty" yaml:"permissions,omitempty"`
 	RateLimits       map[string]int    `json:"rate_limits,omitempty" yaml:"rate_limits,omitempty"`
+	MaxFuel          uint64            `json:"max_fuel,omitempty" yaml:"max_fuel,omitempty"`         // Function calls a WebAssembly phase may make
+	MaxRunTime       time.Duration     `json:"max_run_time,omitempty" yaml:"max_run_time,omitempty"` // Time a WebAssembly phase may run
+}
+
+// MaxMemoryBytes parses MaxMemory, such as "512MB" or "2GiB", into bytes. Units
+// are powers of 1024. It returns zero when MaxMemory is unset.
+func (r ResourceSpec) MaxMemoryBytes() (int64, error) {
+	size := strings.TrimSpace(r.MaxMemory)
+	if size == "" {
+		return 0, nil
+	}
+	number := strings.TrimRightFunc(size, unicode.IsLetter)
+	unit := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(size[len(number):]), "B"), "I")
+	shift := map[string]uint{"": 0, "K": 10, "M": 20, "G": 30, "T": 40}
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	bits, ok := shift[unit]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid memory size %q", r.MaxMemory)
+	}
+	return n << bits, nil
 }
 
 // LoadManifest loads a plugin manifest from a file
@@ -194,6 +227,12 @@ func (m *Manifest) Validate() error {
 		return fmt.Errorf("at least one phase is required")
 	}
 
+	switch m.Protocol {
+	case "", ProtocolExec, ProtocolJSONRPC:
+	default:
+		return fmt.Errorf("invalid protocol: %s", m.Protocol)
+	}
+
 	// Validate domain names
 	validDomains := map[string]bool{"fiction": true, "code": true, "docs": true}
 	for _, domain := range m.Domains {
@@ -214,14 +253,33 @@ func (m *Manifest) Validate() error {
 		phaseNames[phase.Name] = true
 	}
 
+	// Validate dependency specs
+	for _, spec := range m.Dependencies {
+		if _, err := deps.ParseDependency(spec); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// IsCompatible checks if the plugin is compatible with the given orchestrator version
+// IsCompatible checks if the plugin is compatible with the given orchestrator version.
+// MinVersion and MaxVersion are inclusive; versions that aren't semver, such as
+// development builds, are compatible with every plugin.
 func (m *Manifest) IsCompatible(version string) bool {
-	// TODO: Implement semantic version comparison
-	// For now, always return true
-	return true
+	return deps.CheckHost(m.Name, m.MinVersion, m.MaxVersion, version) == nil
+}
+
+// DependencyInfo returns what dependency resolution needs to know about the plugin
+func (m *Manifest) DependencyInfo() deps.Plugin {
+	return deps.Plugin{
+		Name:         m.Name,
+		Version:      m.Version,
+		MinVersion:   m.MinVersion,
+		MaxVersion:   m.MaxVersion,
+		Dependencies: m.Dependencies,
+		Location:     m.Location,
+	}
 }
 
 // GetPhase returns a phase definition by name
@@ -234,6 +292,21 @@ func (m *Manifest) GetPhase(name string) (*PhaseDefinition, bool) {
 	return nil, false
 }
 
+// PhaseSchedule converts the phase definitions into the schedule the orchestrator uses
+// to build its execution graph
+func (m *Manifest) PhaseSchedule() []core.PhaseSchedule {
+	schedule := make([]core.PhaseSchedule, len(m.Phases))
+	for i, def := range m.Phases {
+		schedule[i] = core.PhaseSchedule{
+			Name:      def.Name,
+			Order:     def.Order,
+			Parallel:  def.Parallel,
+			DependsOn: def.DependsOn,
+		}
+	}
+	return schedule
+}
+
 // GetPromptPath returns the full path to a prompt file
 func (m *Manifest) GetPromptPath(phaseName string) string {
 	if promptFile, ok := m.Prompts[phaseName]; ok {
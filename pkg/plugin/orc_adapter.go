@@ -0,0 +1,247 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dotcommander/orc/internal/agent"
+	"github.com/dotcommander/orc/internal/core"
+	"github.com/dotcommander/orc/internal/domain"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/pkg/orc"
+)
+
+// NewAgentFactory exposes orc's agent factory to plugins written against pkg/orc
+func NewAgentFactory(factory *agent.AgentFactory) orc.AgentFactory {
+	return orcAgentFactory{factory: factory}
+}
+
+type orcAgentFactory struct {
+	factory *agent.AgentFactory
+}
+
+func (f orcAgentFactory) CreateAgent(role, promptPath string) orc.Agent {
+	return f.factory.CreateAgent(role, promptPath)
+}
+
+// pluginAgentFactory resolves a plugin's relative prompt paths against its directory
+type pluginAgentFactory struct {
+	factory orc.AgentFactory
+	dir     string
+}
+
+func (f pluginAgentFactory) CreateAgent(role, promptPath string) orc.Agent {
+	if promptPath != "" && !filepath.IsAbs(promptPath) && f.dir != "" {
+		promptPath = filepath.Join(f.dir, promptPath)
+	}
+	return f.factory.CreateAgent(role, promptPath)
+}
+
+// fixedAgentFactory hands out the host's one agent for every role
+type fixedAgentFactory struct {
+	agent orc.Agent
+}
+
+func (f fixedAgentFactory) CreateAgent(role, promptPath string) orc.Agent {
+	return f.agent
+}
+
+// agentFactory returns the factory plugins create agents with, or nil if the
+// host has no AI
+func (s HostServices) agentFactory() orc.AgentFactory {
+	if s.AgentFactory != nil {
+		return s.AgentFactory
+	}
+	if s.Agent != nil {
+		return fixedAgentFactory{agent: s.Agent}
+	}
+	return nil
+}
+
+// orcStorage adds SaveOutput to domain storage, saving outputs under the session's
+// key prefix unless the storage has its own SaveOutput
+type orcStorage struct {
+	domain.Storage
+}
+
+func (s orcStorage) SaveOutput(sessionID, filename string, data []byte) error {
+	if saver, ok := s.Storage.(interface {
+		SaveOutput(sessionID, filename string, data []byte) error
+	}); ok {
+		return saver.SaveOutput(sessionID, filename, data)
+	}
+	return s.Storage.Save(context.Background(), path.Join(sessionID, filename), data)
+}
+
+// orcPluginAdapter runs a plugin written against the public pkg/orc API as a
+// domain plugin
+type orcPluginAdapter struct {
+	plugin   orc.Plugin
+	info     orc.PluginInfo
+	manifest *Manifest
+	phases   []domain.Phase
+}
+
+// newOrcPluginAdapter hands the plugin the host services its security policy
+// allows, then creates its phases. Without a security manager the plugin, which
+// runs in orc's process anyway, gets everything the host has.
+func newOrcPluginAdapter(p orc.Plugin, manifest *Manifest, services HostServices) (*orcPluginAdapter, error) {
+	allowed := func(capability Capability) bool {
+		return services.Security == nil || services.Security.CheckCapability(manifest.Name, capability) == nil
+	}
+
+	if aware, ok := p.(orc.AgentFactoryAware); ok && allowed(CapabilityAI) {
+		if factory := services.agentFactory(); factory != nil {
+			aware.SetAgentFactory(pluginAgentFactory{factory: factory, dir: manifest.Location})
+		}
+	}
+	if aware, ok := p.(orc.StorageAware); ok && allowed(CapabilityStorage) && services.Storage != nil {
+		aware.SetStorage(orcStorage{Storage: services.Storage})
+	}
+
+	orcPhases, err := p.CreatePhases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create phases: %w", err)
+	}
+	phases := make([]domain.Phase, len(orcPhases))
+	for i, phase := range orcPhases {
+		phases[i] = &orcPhaseAdapter{phase: phase}
+	}
+
+	return &orcPluginAdapter{
+		plugin:   p,
+		info:     p.GetInfo(),
+		manifest: manifest,
+		phases:   phases,
+	}, nil
+}
+
+// Name returns the manifest's name, which the loader and registry key plugins by
+func (a *orcPluginAdapter) Name() string {
+	return a.manifest.Name
+}
+
+func (a *orcPluginAdapter) Description() string {
+	if a.info.Description != "" {
+		return a.info.Description
+	}
+	return a.manifest.Description
+}
+
+func (a *orcPluginAdapter) GetPhases() []domain.Phase {
+	return a.phases
+}
+
+func (a *orcPluginAdapter) GetDefaultConfig() domainPlugin.DomainPluginConfig {
+	timeouts := a.plugin.GetPhaseTimeouts()
+	if timeouts == nil {
+		timeouts = make(map[string]time.Duration)
+	}
+	for _, phase := range a.manifest.Phases {
+		if _, ok := timeouts[phase.Name]; !ok && phase.Timeout > 0 {
+			timeouts[phase.Name] = phase.Timeout
+		}
+	}
+
+	return domainPlugin.DomainPluginConfig{
+		Prompts: a.manifest.Prompts,
+		Limits: domainPlugin.DomainPluginLimits{
+			MaxConcurrentPhases: 1,
+			PhaseTimeouts:       timeouts,
+			MaxRetries:          3,
+			TotalTimeout:        30 * time.Minute,
+		},
+		Metadata: a.plugin.GetDefaultConfig(),
+	}
+}
+
+func (a *orcPluginAdapter) ValidateRequest(request string) error {
+	return a.plugin.ValidateRequest(request)
+}
+
+func (a *orcPluginAdapter) GetOutputSpec() domainPlugin.DomainOutputSpec {
+	spec := a.plugin.GetOutputSpec()
+	return domainPlugin.DomainOutputSpec{
+		PrimaryOutput:    spec.PrimaryOutput,
+		SecondaryOutputs: spec.SecondaryOutputs,
+		Descriptions:     a.manifest.OutputSpec.Descriptions,
+	}
+}
+
+// Schedule returns the ordering the manifest declares for the plugin's phases
+func (a *orcPluginAdapter) Schedule() []core.PhaseSchedule {
+	return a.manifest.PhaseSchedule()
+}
+
+func (a *orcPluginAdapter) GetDomainValidator() domain.DomainValidator {
+	return &orcDomainValidator{plugin: a.plugin}
+}
+
+// orcDomainValidator validates requests with the plugin's own ValidateRequest
+type orcDomainValidator struct {
+	plugin orc.Plugin
+}
+
+func (v *orcDomainValidator) ValidateRequest(request string) error {
+	return v.plugin.ValidateRequest(request)
+}
+
+func (v *orcDomainValidator) ValidatePhaseTransition(from, to string, data interface{}) error {
+	return nil
+}
+
+// orcPhaseAdapter runs a pkg/orc phase as a domain phase
+type orcPhaseAdapter struct {
+	phase orc.Phase
+}
+
+func (p *orcPhaseAdapter) Name() string {
+	return p.phase.Name()
+}
+
+func (p *orcPhaseAdapter) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	output, err := p.phase.Execute(ctx, toOrcInput(input))
+	return fromOrcOutput(output), err
+}
+
+func (p *orcPhaseAdapter) ValidateInput(ctx context.Context, input domain.PhaseInput) error {
+	return p.phase.ValidateInput(ctx, toOrcInput(input))
+}
+
+func (p *orcPhaseAdapter) ValidateOutput(ctx context.Context, output domain.PhaseOutput) error {
+	return p.phase.ValidateOutput(ctx, orc.PhaseOutput{
+		Data:     output.Data,
+		Error:    output.Error,
+		Metadata: output.Metadata,
+	})
+}
+
+func (p *orcPhaseAdapter) EstimatedDuration() time.Duration {
+	return p.phase.EstimatedDuration()
+}
+
+func (p *orcPhaseAdapter) CanRetry(err error) bool {
+	return p.phase.CanRetry(err)
+}
+
+// toOrcInput takes the session ID from metadata, where domain phases carry it
+func toOrcInput(input domain.PhaseInput) orc.PhaseInput {
+	sessionID, _ := input.Metadata["session_id"].(string)
+	return orc.PhaseInput{
+		Request:   input.Request,
+		Data:      input.Data,
+		Metadata:  input.Metadata,
+		SessionID: sessionID,
+	}
+}
+
+func fromOrcOutput(output orc.PhaseOutput) domain.PhaseOutput {
+	return domain.PhaseOutput{
+		Data:     output.Data,
+		Error:    output.Error,
+		Metadata: output.Metadata,
+	}
+}
@@ -6,11 +6,10 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/dotcommander/orc/internal/domain"
 )
 
 // CircuitBreakerState represents the current state of a circuit breaker
@@ -37,512 +36,518 @@ func (s CircuitBreakerState) String() string {
 
 // CircuitBreakerConfig defines configuration for a circuit breaker
 type CircuitBreakerConfig struct {
-	// FailureThreshold is the number of failures to trigger opening
-	FailureThreshold int
-	
-	// SuccessThreshold is the number of successes needed to close from half-open
-	SuccessThreshold int
-	
-	// Timeout is how long to wait before trying half-open
+	// Name identifies the breaker in errors, logs and OnStateChange
+	Name string
+
+	// MaxFailures is the number of failures in a row that opens the circuit
+	MaxFailures int
+
+	// Timeout is how long the circuit stays open before letting requests test it
 	Timeout time.Duration
-	
-	// MaxRequests is the maximum number of requests allowed in half-open
-	MaxRequests int
-	
-	// OnStateChange callback when circuit breaker state changes
+
+	// MaxConcurrentRequests caps the requests in flight while half-open
+	MaxConcurrentRequests int
+
+	// SuccessThreshold is the number of successes that closes a half-open circuit
+	SuccessThreshold int
+
+	// OnStateChange is called after the circuit changes state
 	OnStateChange func(name string, from, to CircuitBreakerState)
 }
 
 // DefaultCircuitBreakerConfig returns sensible defaults
-func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+func DefaultCircuitBreakerConfig(name string) CircuitBreakerConfig {
 	return CircuitBreakerConfig{
-		FailureThreshold: 5,
-		SuccessThreshold: 3,
-		Timeout:          60 * time.Second,
-		MaxRequests:      3,
-		OnStateChange:    func(string, CircuitBreakerState, CircuitBreakerState) {},
+		Name:                  name,
+		MaxFailures:           5,
+		Timeout:               60 * time.Second,
+		MaxConcurrentRequests: 1,
+		SuccessThreshold:      2,
 	}
 }
 
-// CircuitBreaker implements the circuit breaker pattern
+// CircuitBreakerError is returned instead of running a request the circuit refuses
+type CircuitBreakerError struct {
+	Name  string
+	State CircuitBreakerState
+}
+
+func (e *CircuitBreakerError) Error() string {
+	if e.State == CircuitBreakerHalfOpen {
+		return fmt.Sprintf("circuit breaker %s is half-open and testing the service", e.Name)
+	}
+	return fmt.Sprintf("circuit breaker %s is open", e.Name)
+}
+
+// IsCircuitBreakerError reports whether err is a circuit breaker refusing a request
+func IsCircuitBreakerError(err error) bool {
+	var cbErr *CircuitBreakerError
+	return errors.As(err, &cbErr)
+}
+
+// CircuitBreaker stops calling a failing service until it has had time to recover
 type CircuitBreaker struct {
-	name   string
 	config CircuitBreakerConfig
-	logger *slog.Logger
 
-	mu              sync.RWMutex
-	state           CircuitBreakerState
-	generation      uint64
-	failures        int64
-	successes       int64
-	requests        int64
-	expiry          time.Time
-	lastFailureTime time.Time
+	mu        sync.Mutex
+	state     CircuitBreakerState
+	failures  int
+	successes int
+	inFlight  int
+	openedAt  time.Time
+
+	requests    atomic.Int64
+	totalFails  atomic.Int64
+	lastFailure atomic.Int64 // Unix nanoseconds
 }
 
-// NewCircuitBreaker creates a new circuit breaker
-func NewCircuitBreaker(name string, config CircuitBreakerConfig, logger *slog.Logger) *CircuitBreaker {
-	if logger == nil {
-		logger = slog.Default()
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 1
+	}
+	if config.MaxConcurrentRequests <= 0 {
+		config.MaxConcurrentRequests = 1
 	}
-	
-	return &CircuitBreaker{
-		name:   name,
-		config: config,
-		logger: logger,
-		state:  CircuitBreakerClosed,
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
 	}
+	return &CircuitBreaker{config: config}
 }
 
-// Execute runs the function if the circuit breaker allows it
+// Execute runs fn if the circuit allows it. A refused request returns a
+// CircuitBreakerError without calling fn.
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
-	generation, err := cb.beforeRequest()
-	if err != nil {
+	if err := cb.before(); err != nil {
 		return err
 	}
+	cb.requests.Add(1)
 
+	succeeded := false
 	defer func() {
-		if r := recover(); r != nil {
-			cb.onFailure(generation)
-			panic(r)
-		}
+		cb.after(succeeded)
 	}()
-
-	err = fn()
-	if err != nil {
-		cb.onFailure(generation)
+	if err := fn(); err != nil {
 		return err
 	}
-
-	cb.onSuccess(generation)
+	succeeded = true
 	return nil
 }
 
-// State returns the current state of the circuit breaker
-func (cb *CircuitBreaker) State() CircuitBreakerState {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-	return cb.state
-}
-
-// Metrics returns current circuit breaker metrics
-func (cb *CircuitBreaker) Metrics() CircuitBreakerMetrics {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-	
-	return CircuitBreakerMetrics{
-		Name:            cb.name,
-		State:           cb.state,
-		Failures:        atomic.LoadInt64(&cb.failures),
-		Successes:       atomic.LoadInt64(&cb.successes),
-		Requests:        atomic.LoadInt64(&cb.requests),
-		LastFailureTime: cb.lastFailureTime,
+// GetState returns the circuit's state, moving an open circuit whose timeout has
+// passed to half-open
+func (cb *CircuitBreaker) GetState() CircuitBreakerState {
+	cb.mu.Lock()
+	notify := cb.advance()
+	state := cb.state
+	cb.mu.Unlock()
+	notify()
+	return state
+}
+
+// Stats returns the circuit's state and counters
+func (cb *CircuitBreaker) Stats() CircuitBreakerStats {
+	stats := CircuitBreakerStats{
+		Name:     cb.config.Name,
+		State:    cb.GetState(),
+		Failures: cb.totalFails.Load(),
+		Requests: cb.requests.Load(),
 	}
+	if last := cb.lastFailure.Load(); last != 0 {
+		stats.LastFailure = time.Unix(0, last)
+	}
+	return stats
 }
 
-type CircuitBreakerMetrics struct {
-	Name            string
-	State           CircuitBreakerState
-	Failures        int64
-	Successes       int64
-	Requests        int64
-	LastFailureTime time.Time
+// CircuitBreakerStats is a snapshot of a circuit breaker
+type CircuitBreakerStats struct {
+	Name        string
+	State       CircuitBreakerState
+	Failures    int64 // Failed requests since the breaker was created
+	Requests    int64 // Requests the breaker let through
+	LastFailure time.Time
 }
 
-func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
+// before admits a request or returns why it is refused
+func (cb *CircuitBreaker) before() error {
 	cb.mu.Lock()
+	notify := cb.advance()
+	defer notify()
 	defer cb.mu.Unlock()
 
-	atomic.AddInt64(&cb.requests, 1)
-
 	switch cb.state {
-	case CircuitBreakerClosed:
-		return cb.generation, nil
 	case CircuitBreakerOpen:
-		if time.Now().After(cb.expiry) {
-			cb.toHalfOpen()
-			return cb.generation, nil
-		}
-		return 0, fmt.Errorf("circuit breaker %s is open", cb.name)
+		return &CircuitBreakerError{Name: cb.config.Name, State: CircuitBreakerOpen}
 	case CircuitBreakerHalfOpen:
-		if cb.requests <= int64(cb.config.MaxRequests) {
-			return cb.generation, nil
+		if cb.inFlight >= cb.config.MaxConcurrentRequests {
+			return &CircuitBreakerError{Name: cb.config.Name, State: CircuitBreakerHalfOpen}
 		}
-		return 0, fmt.Errorf("circuit breaker %s half-open max requests exceeded", cb.name)
-	default:
-		return 0, fmt.Errorf("circuit breaker %s unknown state: %v", cb.name, cb.state)
 	}
+	cb.inFlight++
+	return nil
 }
 
-func (cb *CircuitBreaker) onSuccess(generation uint64) {
+// after records the outcome of an admitted request
+func (cb *CircuitBreaker) after(succeeded bool) {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	cb.inFlight--
+	notify := func() {}
+	if succeeded {
+		cb.failures = 0
+		if cb.state == CircuitBreakerHalfOpen {
+			cb.successes++
+			if cb.successes >= cb.config.SuccessThreshold {
+				notify = cb.setState(CircuitBreakerClosed)
+			}
+		}
+	} else {
+		cb.totalFails.Add(1)
+		cb.lastFailure.Store(time.Now().UnixNano())
+		cb.failures++
+		if cb.state == CircuitBreakerHalfOpen || cb.failures >= cb.config.MaxFailures {
+			notify = cb.setState(CircuitBreakerOpen)
+		}
+	}
+	cb.mu.Unlock()
+	notify()
+}
 
-	if generation != cb.generation {
-		return
+// advance moves an open circuit whose timeout has passed to half-open. The caller
+// holds cb.mu and calls the returned function once it has released it.
+func (cb *CircuitBreaker) advance() func() {
+	if cb.state == CircuitBreakerOpen && time.Since(cb.openedAt) >= cb.config.Timeout {
+		return cb.setState(CircuitBreakerHalfOpen)
 	}
+	return func() {}
+}
 
-	atomic.AddInt64(&cb.successes, 1)
+// setState changes the state with cb.mu held and returns the function reporting
+// the change, to be called without it
+func (cb *CircuitBreaker) setState(state CircuitBreakerState) func() {
+	from := cb.state
+	if from == state {
+		return func() {}
+	}
+	cb.state = state
+	cb.failures = 0
+	cb.successes = 0
+	if state == CircuitBreakerOpen {
+		cb.openedAt = time.Now()
+	}
 
-	switch cb.state {
-	case CircuitBreakerHalfOpen:
-		if atomic.LoadInt64(&cb.successes) >= int64(cb.config.SuccessThreshold) {
-			cb.toClosed()
+	return func() {
+		slog.Info("circuit breaker state change", "name", cb.config.Name, "from", from.String(), "to", state.String())
+		if cb.config.OnStateChange != nil {
+			cb.config.OnStateChange(cb.config.Name, from, state)
 		}
 	}
 }
 
-func (cb *CircuitBreaker) onFailure(generation uint64) {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+// RetryPolicy defines how retries should be handled
+type RetryPolicy struct {
+	// MaxAttempts is the number of retries after the first attempt
+	MaxAttempts int
 
-	if generation != cb.generation {
-		return
-	}
+	// InitialInterval is the wait before the first retry; each later wait is
+	// Multiplier times longer, up to MaxInterval
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
 
-	atomic.AddInt64(&cb.failures, 1)
-	cb.lastFailureTime = time.Now()
+	// Jitter varies each wait by up to 10% so that callers don't retry in step
+	Jitter bool
 
-	switch cb.state {
-	case CircuitBreakerClosed:
-		if atomic.LoadInt64(&cb.failures) >= int64(cb.config.FailureThreshold) {
-			cb.toOpen()
-		}
-	case CircuitBreakerHalfOpen:
-		cb.toOpen()
+	// RetryableErrors reports whether an error is worth retrying; nil retries all
+	RetryableErrors func(error) bool
+}
+
+// DefaultRetryPolicy retries three times, starting after 100ms. Cancellations and
+// refusals from an open circuit aren't retried.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2.0,
+		Jitter:          true,
+		RetryableErrors: func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+				!IsCircuitBreakerError(err)
+		},
 	}
 }
 
-func (cb *CircuitBreaker) toOpen() {
-	cb.setState(CircuitBreakerOpen)
-	cb.expiry = time.Now().Add(cb.config.Timeout)
-	cb.generation++
-	atomic.StoreInt64(&cb.failures, 0)
-	atomic.StoreInt64(&cb.successes, 0)
+// RetryExecutor runs functions again when they fail, backing off between attempts
+type RetryExecutor struct {
+	policy RetryPolicy
 }
 
-func (cb *CircuitBreaker) toHalfOpen() {
-	cb.setState(CircuitBreakerHalfOpen)
-	cb.generation++
-	atomic.StoreInt64(&cb.requests, 0)
-	atomic.StoreInt64(&cb.successes, 0)
+// NewRetryExecutor creates an executor following policy
+func NewRetryExecutor(policy RetryPolicy) *RetryExecutor {
+	return &RetryExecutor{policy: policy}
 }
 
-func (cb *CircuitBreaker) toClosed() {
-	cb.setState(CircuitBreakerClosed)
-	cb.generation++
-	atomic.StoreInt64(&cb.failures, 0)
-	atomic.StoreInt64(&cb.successes, 0)
+// Execute calls fn until it succeeds, fails with an error that isn't retryable,
+// or has used its retries. If ctx ends while waiting, its error is returned.
+func (r *RetryExecutor) Execute(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= r.policy.MaxAttempts || (r.policy.RetryableErrors != nil && !r.policy.RetryableErrors(err)) {
+			return err
+		}
+
+		timer := time.NewTimer(r.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
 }
 
-func (cb *CircuitBreaker) setState(state CircuitBreakerState) {
-	if cb.state == state {
-		return
+// delay returns the wait before retry number attempt+1
+func (r *RetryExecutor) delay(attempt int) time.Duration {
+	delay := float64(r.policy.InitialInterval)
+	for i := 0; i < attempt; i++ {
+		delay *= r.policy.Multiplier
 	}
+	if r.policy.MaxInterval > 0 && delay > float64(r.policy.MaxInterval) {
+		delay = float64(r.policy.MaxInterval)
+	}
+	if r.policy.Jitter {
+		delay += 0.1 * delay * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
 
-	prev := cb.state
-	cb.state = state
+// FallbackHandler produces a substitute result for an operation that failed
+type FallbackHandler interface {
+	// CanHandle reports whether the handler can stand in for this failure
+	CanHandle(operation string, err error) bool
 
-	cb.logger.Info("circuit breaker state change",
-		"name", cb.name,
-		"from", prev.String(),
-		"to", state.String())
+	// Handle returns the substitute result
+	Handle(ctx context.Context, operation string, originalErr error) (interface{}, error)
 
-	cb.config.OnStateChange(cb.name, prev, state)
+	// GetQuality rates the substitute from 0 to 1; the best handler is used
+	GetQuality() float64
 }
 
-// RetryPolicy defines how retries should be handled
-type RetryPolicy struct {
-	MaxAttempts  int
-	InitialDelay time.Duration
-	MaxDelay     time.Duration
-	Multiplier   float64
-	Jitter       bool
-	
-	// ShouldRetry determines if an error is retryable
-	ShouldRetry func(error) bool
+// FallbackRegistry holds the fallback handlers for each operation
+type FallbackRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string][]FallbackHandler
 }
 
-// DefaultRetryPolicy returns sensible defaults
-func DefaultRetryPolicy() RetryPolicy {
-	return RetryPolicy{
-		MaxAttempts:  3,
-		InitialDelay: 100 * time.Millisecond,
-		MaxDelay:     30 * time.Second,
-		Multiplier:   2.0,
-		Jitter:       true,
-		ShouldRetry: func(err error) bool {
-			// Retry on temporary errors, not on validation errors
-			return !errors.Is(err, domain.ErrInvalidInput)
-		},
-	}
+// NewFallbackRegistry creates an empty registry
+func NewFallbackRegistry() *FallbackRegistry {
+	return &FallbackRegistry{handlers: make(map[string][]FallbackHandler)}
 }
 
-// FallbackHandler provides fallback behavior when all retries fail
-type FallbackHandler func(ctx context.Context, err error) error
-
-// ResilientPlugin wraps a plugin with circuit breaker and retry logic
-type ResilientPlugin struct {
-	plugin          domain.Plugin
-	circuitBreaker  *CircuitBreaker
-	retryPolicy     RetryPolicy
-	fallbackHandler FallbackHandler
-	logger          *slog.Logger
+// RegisterHandler adds a fallback for an operation
+func (r *FallbackRegistry) RegisterHandler(operation string, handler FallbackHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[operation] = append(r.handlers[operation], handler)
 }
 
-// NewResilientPlugin creates a plugin with resilience patterns
-func NewResilientPlugin(
-	plugin domain.Plugin,
-	circuitBreaker *CircuitBreaker,
-	retryPolicy RetryPolicy,
-	fallbackHandler FallbackHandler,
-	logger *slog.Logger,
-) *ResilientPlugin {
-	if logger == nil {
-		logger = slog.Default()
+// GetFallback returns the highest quality handler that can handle err, or nil
+func (r *FallbackRegistry) GetFallback(operation string, err error) FallbackHandler {
+	r.mu.RLock()
+	handlers := append([]FallbackHandler(nil), r.handlers[operation]...)
+	r.mu.RUnlock()
+
+	sort.SliceStable(handlers, func(i, j int) bool {
+		return handlers[i].GetQuality() > handlers[j].GetQuality()
+	})
+	for _, h := range handlers {
+		if h.CanHandle(operation, err) {
+			return h
+		}
 	}
+	return nil
+}
 
-	return &ResilientPlugin{
-		plugin:          plugin,
-		circuitBreaker:  circuitBreaker,
-		retryPolicy:     retryPolicy,
-		fallbackHandler: fallbackHandler,
-		logger:          logger,
+// ExecuteWithFallback runs fn and, if it fails, the best fallback for the failure.
+// Without one, fn's error is returned.
+func (r *FallbackRegistry) ExecuteWithFallback(ctx context.Context, operation string, fn func() (interface{}, error)) (interface{}, error) {
+	result, err := fn()
+	if err == nil {
+		return result, nil
+	}
+	handler := r.GetFallback(operation, err)
+	if handler == nil {
+		return nil, err
 	}
+	result, fallbackErr := handler.Handle(ctx, operation, err)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w (fallback failed: %v)", err, fallbackErr)
+	}
+	return result, nil
 }
 
-// Name implements domain.Plugin
-func (rp *ResilientPlugin) Name() string {
-	return rp.plugin.Name()
+// StaticFallbackHandler stands in for failures with a fixed result
+type StaticFallbackHandler struct {
+	result    interface{}
+	quality   float64
+	canHandle func(operation string, err error) bool
 }
 
-// Domain implements domain.Plugin
-func (rp *ResilientPlugin) Domain() string {
-	return rp.plugin.Domain()
+// NewStaticFallbackHandler returns result for the failures canHandle accepts
+func NewStaticFallbackHandler(result interface{}, quality float64, canHandle func(operation string, err error) bool) *StaticFallbackHandler {
+	return &StaticFallbackHandler{result: result, quality: quality, canHandle: canHandle}
 }
 
-// GetPhases implements domain.Plugin
-func (rp *ResilientPlugin) GetPhases() []domain.Phase {
-	originalPhases := rp.plugin.GetPhases()
-	resilientPhases := make([]domain.Phase, len(originalPhases))
-	
-	for i, phase := range originalPhases {
-		resilientPhases[i] = &ResilientPhase{
-			phase:           phase,
-			circuitBreaker:  rp.circuitBreaker,
-			retryPolicy:     rp.retryPolicy,
-			fallbackHandler: rp.fallbackHandler,
-			logger:          rp.logger,
-		}
-	}
-	
-	return resilientPhases
+func (h *StaticFallbackHandler) CanHandle(operation string, err error) bool {
+	return h.canHandle == nil || h.canHandle(operation, err)
 }
 
-// ResilientPhase wraps a phase with resilience patterns
-type ResilientPhase struct {
-	phase           domain.Phase
-	circuitBreaker  *CircuitBreaker
-	retryPolicy     RetryPolicy
-	fallbackHandler FallbackHandler
-	logger          *slog.Logger
+func (h *StaticFallbackHandler) Handle(ctx context.Context, operation string, originalErr error) (interface{}, error) {
+	return h.result, nil
 }
 
-// Name implements domain.Phase
-func (rp *ResilientPhase) Name() string {
-	return rp.phase.Name()
+func (h *StaticFallbackHandler) GetQuality() float64 {
+	return h.quality
 }
 
-// Execute implements domain.Phase with resilience
-func (rp *ResilientPhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
-	var lastErr error
-	
-	for attempt := 1; attempt <= rp.retryPolicy.MaxAttempts; attempt++ {
-		err := rp.circuitBreaker.Execute(ctx, func() error {
-			output, err := rp.phase.Execute(ctx, input)
-			if err != nil {
-				return err
-			}
-			// Store output for successful execution
-			input.SetPreviousOutput(output)
-			return nil
+// ResilienceConfig combines the patterns a ResilientWrapper applies
+type ResilienceConfig struct {
+	CircuitBreaker CircuitBreakerConfig
+	Retry          RetryPolicy
+	// EnableFallback uses registered fallbacks once retries are exhausted or the
+	// circuit is open
+	EnableFallback bool
+}
+
+// ResilientWrapper runs operations behind a circuit breaker, retrying them and
+// falling back when they still fail. The breaker counts each operation once,
+// however many times it was retried.
+type ResilientWrapper struct {
+	config    ResilienceConfig
+	breaker   *CircuitBreaker
+	retry     *RetryExecutor
+	fallbacks *FallbackRegistry
+
+	fallbacksUsed atomic.Int64
+}
+
+// NewResilientWrapper creates a wrapper with its own circuit breaker
+func NewResilientWrapper(config ResilienceConfig) *ResilientWrapper {
+	return &ResilientWrapper{
+		config:    config,
+		breaker:   NewCircuitBreaker(config.CircuitBreaker),
+		retry:     NewRetryExecutor(config.Retry),
+		fallbacks: NewFallbackRegistry(),
+	}
+}
+
+// RegisterFallback adds a fallback for an operation
+func (w *ResilientWrapper) RegisterFallback(operation string, handler FallbackHandler) {
+	w.fallbacks.RegisterHandler(operation, handler)
+}
+
+// Execute runs fn with the circuit breaker, retries and fallbacks
+func (w *ResilientWrapper) Execute(ctx context.Context, operation string, fn func() (interface{}, error)) (interface{}, error) {
+	var result interface{}
+	err := w.breaker.Execute(ctx, func() error {
+		return w.retry.Execute(ctx, func() error {
+			var err error
+			result, err = fn()
+			return err
 		})
-		
-		if err == nil {
-			rp.logger.Debug("phase execution succeeded",
-				"phase", rp.phase.Name(),
-				"attempt", attempt)
-			return input.GetPreviousOutput().(domain.PhaseOutput), nil
-		}
-		
-		lastErr = err
-		
-		// Check if we should retry
-		if attempt >= rp.retryPolicy.MaxAttempts || !rp.retryPolicy.ShouldRetry(err) {
-			break
-		}
-		
-		// Calculate delay with exponential backoff
-		delay := rp.calculateDelay(attempt)
-		
-		rp.logger.Warn("phase execution failed, retrying",
-			"phase", rp.phase.Name(),
-			"attempt", attempt,
-			"delay", delay,
-			"error", err)
-		
-		// Wait before retry
-		select {
-		case <-time.After(delay):
-			continue
-		case <-ctx.Done():
-			return domain.PhaseOutput{}, ctx.Err()
-		}
+	})
+	if err == nil {
+		return result, nil
 	}
-	
-	// All retries failed, try fallback if available
-	if rp.fallbackHandler != nil {
-		rp.logger.Info("executing fallback handler",
-			"phase", rp.phase.Name(),
-			"error", lastErr)
-		
-		if fallbackErr := rp.fallbackHandler(ctx, lastErr); fallbackErr != nil {
-			return domain.PhaseOutput{}, fmt.Errorf("fallback failed: %w", fallbackErr)
-		}
-		
-		// Return empty output to indicate fallback was used
-		return domain.PhaseOutput{Success: false, Message: "fallback executed"}, nil
+	if !w.config.EnableFallback {
+		return nil, err
 	}
-	
-	return domain.PhaseOutput{}, fmt.Errorf("phase %s failed after %d attempts: %w", 
-		rp.phase.Name(), rp.retryPolicy.MaxAttempts, lastErr)
-}
 
-// ValidateInput implements domain.Phase
-func (rp *ResilientPhase) ValidateInput(ctx context.Context, input domain.PhaseInput) error {
-	return rp.phase.ValidateInput(ctx, input)
+	handler := w.fallbacks.GetFallback(operation, err)
+	if handler == nil {
+		return nil, err
+	}
+	fallback, fallbackErr := handler.Handle(ctx, operation, err)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w (fallback failed: %v)", err, fallbackErr)
+	}
+	w.fallbacksUsed.Add(1)
+	return fallback, nil
 }
 
-// ValidateOutput implements domain.Phase
-func (rp *ResilientPhase) ValidateOutput(ctx context.Context, output domain.PhaseOutput) error {
-	return rp.phase.ValidateOutput(ctx, output)
+// IsCircuitOpen reports whether the circuit is refusing operations
+func (w *ResilientWrapper) IsCircuitOpen() bool {
+	return w.breaker.GetState() == CircuitBreakerOpen
 }
 
-// EstimatedDuration implements domain.Phase
-func (rp *ResilientPhase) EstimatedDuration() time.Duration {
-	// Factor in potential retries
-	baseDuration := rp.phase.EstimatedDuration()
-	maxRetryDelay := rp.calculateDelay(rp.retryPolicy.MaxAttempts)
-	return baseDuration + maxRetryDelay
+// GetCircuitBreakerStats returns the circuit breaker's state and counters
+func (w *ResilientWrapper) GetCircuitBreakerStats() CircuitBreakerStats {
+	return w.breaker.Stats()
 }
 
-// CanRetry implements domain.Phase
-func (rp *ResilientPhase) CanRetry(err error) bool {
-	return rp.retryPolicy.ShouldRetry(err)
+// ResilienceStats summarizes what a ResilientWrapper has done
+type ResilienceStats struct {
+	CircuitBreaker CircuitBreakerStats
+	FallbacksUsed  int64
 }
 
-func (rp *ResilientPhase) calculateDelay(attempt int) time.Duration {
-	delay := float64(rp.retryPolicy.InitialDelay)
-	
-	// Apply exponential backoff
-	for i := 1; i < attempt; i++ {
-		delay *= rp.retryPolicy.Multiplier
+// GetStats returns the wrapper's statistics
+func (w *ResilientWrapper) GetStats() ResilienceStats {
+	return ResilienceStats{
+		CircuitBreaker: w.breaker.Stats(),
+		FallbacksUsed:  w.fallbacksUsed.Load(),
 	}
-	
-	// Apply jitter if enabled
-	if rp.retryPolicy.Jitter {
-		jitter := 0.1 * delay * (2*rand.Float64() - 1) // ±10% jitter
-		delay += jitter
+}
+
+// ResilientPluginWrapper applies a ResilientWrapper to a plugin's methods. A
+// plugin the health monitor marks critical is refused while it is unhealthy,
+// which lets its fallbacks stand in.
+type ResilientPluginWrapper struct {
+	name       string
+	plugin     interface{}
+	health     *HealthMonitor
+	resilience *ResilientWrapper
+}
+
+// NewResilientPluginWrapper wraps plugin; health may be nil
+func NewResilientPluginWrapper(name string, plugin interface{}, config ResilienceConfig, health *HealthMonitor) *ResilientPluginWrapper {
+	if config.CircuitBreaker.Name == "" {
+		config.CircuitBreaker.Name = name
 	}
-	
-	// Ensure we don't exceed max delay
-	if delay > float64(rp.retryPolicy.MaxDelay) {
-		delay = float64(rp.retryPolicy.MaxDelay)
+	return &ResilientPluginWrapper{
+		name:       name,
+		plugin:     plugin,
+		health:     health,
+		resilience: NewResilientWrapper(config),
 	}
-	
-	return time.Duration(delay)
 }
 
-// ResilienceManager manages circuit breakers for multiple plugins
-type ResilienceManager struct {
-	circuitBreakers map[string]*CircuitBreaker
-	mu              sync.RWMutex
-	logger          *slog.Logger
-}
-
-// NewResilienceManager creates a new resilience manager
-func NewResilienceManager(logger *slog.Logger) *ResilienceManager {
-	if logger == nil {
-		logger = slog.Default()
-	}
-	
-	return &ResilienceManager{
-		circuitBreakers: make(map[string]*CircuitBreaker),
-		logger:          logger,
-	}
-}
-
-// GetCircuitBreaker gets or creates a circuit breaker for a plugin
-func (rm *ResilienceManager) GetCircuitBreaker(pluginName string, config CircuitBreakerConfig) *CircuitBreaker {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
-	
-	if cb, exists := rm.circuitBreakers[pluginName]; exists {
-		return cb
-	}
-	
-	cb := NewCircuitBreaker(pluginName, config, rm.logger)
-	rm.circuitBreakers[pluginName] = cb
-	return cb
-}
-
-// GetMetrics returns metrics for all circuit breakers
-func (rm *ResilienceManager) GetMetrics() map[string]CircuitBreakerMetrics {
-	rm.mu.RLock()
-	defer rm.mu.RUnlock()
-	
-	metrics := make(map[string]CircuitBreakerMetrics)
-	for name, cb := range rm.circuitBreakers {
-		metrics[name] = cb.Metrics()
-	}
-	
-	return metrics
-}
-
-// HealthStatus returns health status based on circuit breaker states
-func (rm *ResilienceManager) HealthStatus() map[string]string {
-	rm.mu.RLock()
-	defer rm.mu.RUnlock()
-	
-	status := make(map[string]string)
-	for name, cb := range rm.circuitBreakers {
-		switch cb.State() {
-		case CircuitBreakerClosed:
-			status[name] = "healthy"
-		case CircuitBreakerHalfOpen:
-			status[name] = "degraded"
-		case CircuitBreakerOpen:
-			status[name] = "unhealthy"
+// operation names a method of the plugin for fallbacks: "<plugin>.<method>"
+func (w *ResilientPluginWrapper) operation(method string) string {
+	return w.name + "." + method
+}
+
+// RegisterFallback adds a fallback for one of the plugin's methods
+func (w *ResilientPluginWrapper) RegisterFallback(method string, handler FallbackHandler) {
+	w.resilience.RegisterFallback(w.operation(method), handler)
+}
+
+// ExecutePluginMethod runs fn, a call to one of the plugin's methods, resiliently
+func (w *ResilientPluginWrapper) ExecutePluginMethod(ctx context.Context, method string, fn func() (interface{}, error)) (interface{}, error) {
+	return w.resilience.Execute(ctx, w.operation(method), func() (interface{}, error) {
+		if w.health != nil && w.health.IsCriticalPlugin(w.name) {
+			if report, ok := w.health.GetReport(w.name); ok && report.Status == HealthStatusUnhealthy {
+				return nil, fmt.Errorf("plugin %s is unhealthy", w.name)
+			}
 		}
-	}
-	
-	return status
-}
-
-// WrapPlugin wraps a plugin with standard resilience patterns
-func (rm *ResilienceManager) WrapPlugin(
-	plugin domain.Plugin,
-	circuitBreakerConfig CircuitBreakerConfig,
-	retryPolicy RetryPolicy,
-	fallbackHandler FallbackHandler,
-) *ResilientPlugin {
-	cb := rm.GetCircuitBreaker(plugin.Name(), circuitBreakerConfig)
-	
-	return NewResilientPlugin(
-		plugin,
-		cb,
-		retryPolicy,
-		fallbackHandler,
-		rm.logger,
-	)
-}
\ No newline at end of file
+		return fn()
+	})
+}
+
+// GetStats returns the wrapper's statistics
+func (w *ResilientPluginWrapper) GetStats() ResilienceStats {
+	return w.resilience.GetStats()
+}
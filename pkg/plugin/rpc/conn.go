@@ -0,0 +1,240 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// ErrClosed is returned for calls on a connection that has shut down
+var ErrClosed = errors.New("rpc connection closed")
+
+// Handler answers a request or notification from the other side. For notifications
+// the result is discarded. ctx is cancelled if the caller gives up on the request.
+type Handler func(ctx context.Context, method string, params json.RawMessage) (interface{}, error)
+
+// Conn is one end of a JSON-RPC connection. Either end may send requests; each
+// incoming request is handled on its own goroutine.
+type Conn struct {
+	w       io.Writer
+	wmu     sync.Mutex
+	handler Handler
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[string]chan *message
+	inflight map[string]context.CancelFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewConn starts reading messages from r and passing requests to handler. Responses
+// and notifications are written to w.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	c := newConn(w, handler)
+	go c.read(r)
+	return c
+}
+
+// newConn returns a connection that isn't reading yet, so callers can finish
+// setting up whatever the handler uses before the first message arrives
+func newConn(w io.Writer, handler Handler) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		w:        w,
+		handler:  handler,
+		pending:  make(map[string]chan *message),
+		inflight: make(map[string]context.CancelFunc),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Done is closed once the connection stops reading, when the other side closes
+// its end or sends something that isn't JSON
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection stopped, once Done is closed
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Call sends a request and decodes its result into result, which may be nil. If
+// ctx ends first the other side is asked to cancel the request.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encoding %s params: %w", method, err)
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("%s: %w", method, ErrClosed)
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.FormatInt(c.nextID, 10))
+	reply := make(chan *message, 1)
+	c.pending[string(id)] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(&message{ID: id, Method: method, Params: raw}); err != nil {
+		return fmt.Errorf("sending %s: %w", method, err)
+	}
+
+	select {
+	case resp := <-reply:
+		if resp == nil {
+			return fmt.Errorf("%s: %w", method, ErrClosed)
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("decoding %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		c.Notify(MethodCancel, cancelParams{ID: id})
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification, which gets no response
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encoding %s params: %w", method, err)
+	}
+	return c.send(&message{Method: method, Params: raw})
+}
+
+func (c *Conn) send(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.w.Write(append(data, '\n'))
+	return err
+}
+
+// read dispatches incoming messages until r fails
+func (c *Conn) read(r io.Reader) {
+	dec := json.NewDecoder(r)
+	for {
+		var msg message
+		err := dec.Decode(&msg)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// Valid JSON, but not a message; the stream is still in step
+			c.send(&message{ID: json.RawMessage("null"), Error: &Error{Code: CodeInvalidRequest, Message: "invalid request"}})
+			continue
+		}
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		switch {
+		case msg.Method == MethodCancel:
+			var p cancelParams
+			if json.Unmarshal(msg.Params, &p) == nil {
+				c.mu.Lock()
+				if cancel, ok := c.inflight[string(p.ID)]; ok {
+					cancel()
+				}
+				c.mu.Unlock()
+			}
+		case msg.Method != "":
+			go c.handle(&msg)
+		default:
+			c.mu.Lock()
+			reply, ok := c.pending[string(msg.ID)]
+			c.mu.Unlock()
+			if ok {
+				reply <- &msg
+			}
+		}
+	}
+}
+
+// handle runs the handler for a request or notification and answers requests
+func (c *Conn) handle(msg *message) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	isRequest := len(msg.ID) > 0 && string(msg.ID) != "null"
+	if isRequest {
+		c.mu.Lock()
+		c.inflight[string(msg.ID)] = cancel
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, string(msg.ID))
+			c.mu.Unlock()
+		}()
+	}
+
+	result, err := c.handler(ctx, msg.Method, msg.Params)
+	if !isRequest {
+		return
+	}
+
+	resp := &message{ID: msg.ID}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		var rpcErr *Error
+		switch {
+		case errors.As(err, &rpcErr):
+			resp.Error = rpcErr
+		case ctx.Err() != nil:
+			resp.Error = &Error{Code: CodeCancelled, Message: err.Error()}
+		default:
+			resp.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Result = nil
+	}
+	c.send(resp)
+}
+
+// shutdown fails every outstanding call and cancels running handlers
+func (c *Conn) shutdown(err error) {
+	c.mu.Lock()
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	}
+	c.err = err
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	c.cancel()
+	close(c.done)
+}
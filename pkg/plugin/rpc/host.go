@@ -0,0 +1,351 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Services are what the host offers plugins that call back into it
+type Services interface {
+	ExecuteAgent(ctx context.Context, params AgentExecuteParams) (string, error)
+	Save(ctx context.Context, key string, data []byte) error
+	Load(ctx context.Context, key string) ([]byte, error)
+	Publish(ctx context.Context, event EventParams) error
+}
+
+// HostConfig configures the host's side of a plugin connection
+type HostConfig struct {
+	// Plugin is the name the plugin was loaded as
+	Plugin      string
+	HostVersion string
+	Services    Services
+	// Authorize decides whether the plugin may call a host method; nil allows all
+	Authorize func(method string) error
+	// OnProgress receives the plugin's progress notifications
+	OnProgress func(ProgressParams)
+	Logger     *slog.Logger
+	// HandshakeTimeout bounds initialize; zero uses 10 seconds
+	HandshakeTimeout time.Duration
+
+	// OnStart is called by Launch once the process has started, before the
+	// handshake; an error kills the process
+	OnStart func(process *os.Process) error
+	// OnExit is called by Launch's process once it has exited
+	OnExit func(state *os.ProcessState)
+}
+
+// Host is orc's side of a running plugin process
+type Host struct {
+	cfg  HostConfig
+	conn *Conn
+	info InitializeResult
+
+	cmd    *exec.Cmd
+	stdin  io.Closer
+	exited chan struct{}
+}
+
+// Launch starts cmd as a plugin process and performs the handshake. The process
+// outlives ctx, which only bounds the start; stop it with Close.
+func Launch(ctx context.Context, cmd *exec.Cmd, cfg HostConfig) (*Host, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting plugin: %w", err)
+	}
+	if cfg.OnStart != nil {
+		if err := cfg.OnStart(cmd.Process); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("starting plugin: %w", err)
+		}
+	}
+
+	h := newHost(stdout, stdin, cfg)
+	h.cmd = cmd
+	h.stdin = stdin
+	h.exited = make(chan struct{})
+	go h.forwardStderr(stderr)
+	go func() {
+		cmd.Wait()
+		if cfg.OnExit != nil {
+			cfg.OnExit(cmd.ProcessState)
+		}
+		close(h.exited)
+	}()
+
+	if err := h.initialize(ctx); err != nil {
+		cmd.Process.Kill()
+		<-h.exited
+		return nil, err
+	}
+	return h, nil
+}
+
+// Connect performs the handshake with a plugin already reachable through r and w
+func Connect(ctx context.Context, r io.Reader, w io.WriteCloser, cfg HostConfig) (*Host, error) {
+	h := newHost(r, w, cfg)
+	h.stdin = w
+	if err := h.initialize(ctx); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+func newHost(r io.Reader, w io.Writer, cfg HostConfig) *Host {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	cfg.Logger = cfg.Logger.With("plugin", cfg.Plugin)
+	h := &Host{cfg: cfg}
+	h.conn = newConn(w, h.handle)
+	go h.conn.read(r)
+	return h
+}
+
+func (h *Host) initialize(ctx context.Context) error {
+	timeout := h.cfg.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var granted []string
+	for _, method := range HostMethods {
+		if h.authorize(method) == nil {
+			granted = append(granted, method)
+		}
+	}
+
+	params := InitializeParams{
+		ProtocolVersion:    ProtocolVersion,
+		MinProtocolVersion: MinProtocolVersion,
+		HostVersion:        h.cfg.HostVersion,
+		Plugin:             h.cfg.Plugin,
+		Granted:            granted,
+	}
+	if err := h.conn.Call(ctx, MethodInitialize, params, &h.info); err != nil {
+		return fmt.Errorf("plugin handshake: %w", err)
+	}
+	if _, err := negotiate(ProtocolVersion, MinProtocolVersion, h.info.ProtocolVersion, h.info.ProtocolVersion); err != nil {
+		return fmt.Errorf("plugin handshake: plugin chose protocol version %d: %w", h.info.ProtocolVersion, err)
+	}
+	h.cfg.Logger.Debug("plugin connected",
+		"name", h.info.Name,
+		"version", h.info.Version,
+		"protocol_version", h.info.ProtocolVersion,
+		"features", h.info.Features)
+	return h.conn.Notify(MethodInitialized, struct{}{})
+}
+
+// Info returns what the plugin reported in the handshake
+func (h *Host) Info() InitializeResult {
+	return h.info
+}
+
+// Supports reports whether the plugin announced an optional feature
+func (h *Host) Supports(feature string) bool {
+	for _, f := range h.info.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute runs a phase in the plugin and returns its raw output
+func (h *Host) Execute(ctx context.Context, phase, sessionID string, input interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("encoding phase input: %w", err)
+	}
+	var result ExecuteResult
+	if err := h.conn.Call(ctx, MethodPhaseExecute, ExecuteParams{Phase: phase, SessionID: sessionID, Input: raw}, &result); err != nil {
+		return nil, err
+	}
+	return result.Output, nil
+}
+
+// Ping sends a heartbeat and returns the plugin's answer and the round trip time
+func (h *Host) Ping(ctx context.Context) (PingResult, time.Duration, error) {
+	start := time.Now()
+	var result PingResult
+	err := h.conn.Call(ctx, MethodHealthPing, struct{}{}, &result)
+	return result, time.Since(start), err
+}
+
+// Done is closed when the connection to the plugin is lost
+func (h *Host) Done() <-chan struct{} {
+	return h.conn.Done()
+}
+
+// Close asks the plugin to shut down, waiting up to timeout before killing it
+func (h *Host) Close(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shutdownErr := h.conn.Call(ctx, MethodShutdown, struct{}{}, nil)
+	h.conn.Notify(MethodExit, struct{}{})
+	h.stdin.Close()
+
+	if h.cmd == nil {
+		return shutdownErr
+	}
+	select {
+	case <-h.exited:
+	case <-ctx.Done():
+		h.cmd.Process.Kill()
+		<-h.exited
+		return fmt.Errorf("plugin didn't exit within %s and was killed", timeout)
+	}
+	if shutdownErr != nil && !errors.Is(shutdownErr, ErrClosed) {
+		return shutdownErr
+	}
+	return nil
+}
+
+func (h *Host) authorize(method string) error {
+	if h.cfg.Authorize == nil {
+		return nil
+	}
+	return h.cfg.Authorize(method)
+}
+
+// handle serves the plugin's calls into the host
+func (h *Host) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodLog:
+		var p LogParams
+		if err := json.Unmarshal(params, &p); err == nil {
+			h.log(p)
+		}
+		return nil, nil
+	case MethodProgress:
+		var p ProgressParams
+		if err := json.Unmarshal(params, &p); err == nil {
+			h.cfg.Logger.Debug("plugin progress", "phase", p.Phase, "percent", p.Percent, "message", p.Message)
+			if h.cfg.OnProgress != nil {
+				h.cfg.OnProgress(p)
+			}
+		}
+		return nil, nil
+	}
+	return Dispatch(ctx, h.cfg.Services, h.cfg.Authorize, method, params)
+}
+
+// Dispatch serves a plugin's call to one of the HostMethods: it checks the call
+// with authorize, which may be nil, decodes params and calls services. Other
+// transports, such as the WebAssembly runtime, use it to offer plugins the same
+// methods with the same errors.
+func Dispatch(ctx context.Context, services Services, authorize func(method string) error, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodAgentExecute, MethodStorageSave, MethodStorageLoad, MethodEventsPublish:
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + method}
+	}
+
+	if authorize != nil {
+		if err := authorize(method); err != nil {
+			return nil, &Error{Code: CodeCapabilityDenied, Message: err.Error()}
+		}
+	}
+	if services == nil {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "host services unavailable"}
+	}
+
+	switch method {
+	case MethodAgentExecute:
+		var p AgentExecuteParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		response, err := services.ExecuteAgent(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		return AgentExecuteResult{Response: response}, nil
+	case MethodStorageSave:
+		var p StorageSaveParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, services.Save(ctx, p.Key, p.Data)
+	case MethodStorageLoad:
+		var p StorageLoadParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		data, err := services.Load(ctx, p.Key)
+		if err != nil {
+			return nil, &Error{Code: CodeNotFound, Message: err.Error()}
+		}
+		return StorageLoadResult{Data: data}, nil
+	default: // MethodEventsPublish
+		var p EventParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Type == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "event type is required"}
+		}
+		return nil, services.Publish(ctx, p)
+	}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// log writes a log notification from the plugin to the host's log
+func (h *Host) log(p LogParams) {
+	Log(h.cfg.Logger, p)
+}
+
+// Log writes a plugin's log line to logger at the level it asked for
+func Log(logger *slog.Logger, p LogParams) {
+	level := slog.LevelInfo
+	switch p.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	args := make([]any, 0, 2*len(p.Attrs))
+	for k, v := range p.Attrs {
+		args = append(args, k, v)
+	}
+	logger.Log(context.Background(), level, p.Message, args...)
+}
+
+// forwardStderr logs each line the plugin writes to stderr
+func (h *Host) forwardStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		h.cfg.Logger.Info("plugin stderr", "line", scanner.Text())
+	}
+}
@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// PluginInfo describes a plugin to the host in the handshake
+type PluginInfo struct {
+	Name    string
+	Version string
+	Phases  []string
+}
+
+// PhaseFunc runs a phase inside a plugin process. host calls back into orc; the
+// returned value is sent to the host as the phase's output.
+type PhaseFunc func(ctx context.Context, host *HostClient, phase string, input json.RawMessage) (interface{}, error)
+
+// HealthFunc answers the host's heartbeats; nil always reports healthy
+type HealthFunc func(ctx context.Context) PingResult
+
+// Serve runs a plugin's side of the protocol on r and w, normally os.Stdin and
+// os.Stdout, until the host sends exit, closes the connection or ctx ends
+func Serve(ctx context.Context, r io.Reader, w io.Writer, info PluginInfo, execute PhaseFunc, health HealthFunc) error {
+	s := &server{info: info, execute: execute, health: health, exit: make(chan struct{})}
+	s.conn = newConn(w, s.handle)
+	s.host = &HostClient{conn: s.conn}
+	go s.conn.read(r)
+
+	select {
+	case <-s.exit:
+		return nil
+	case <-s.conn.Done():
+		if s.conn.Err() == ErrClosed {
+			return nil
+		}
+		return s.conn.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type server struct {
+	info    PluginInfo
+	execute PhaseFunc
+	health  HealthFunc
+	conn    *Conn
+	host    *HostClient
+	exit    chan struct{}
+	once    sync.Once
+}
+
+func (s *server) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodInitialize:
+		var p InitializeParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		version, err := negotiate(ProtocolVersion, MinProtocolVersion, p.ProtocolVersion, p.MinProtocolVersion)
+		if err != nil {
+			return nil, &Error{Code: CodeInvalidRequest, Message: err.Error()}
+		}
+		s.host.setGranted(p.Granted)
+		return InitializeResult{
+			ProtocolVersion: version,
+			Name:            s.info.Name,
+			Version:         s.info.Version,
+			Phases:          s.info.Phases,
+			Features:        []string{FeatureProgress, FeatureLogs, FeatureCancel},
+		}, nil
+	case MethodInitialized:
+		return nil, nil
+	case MethodPhaseExecute:
+		var p ExecuteParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		output, err := s.execute(ctx, s.host, p.Phase, p.Input)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(output)
+		if err != nil {
+			return nil, fmt.Errorf("encoding phase output: %w", err)
+		}
+		return ExecuteResult{Output: raw}, nil
+	case MethodHealthPing:
+		if s.health == nil {
+			return PingResult{Time: time.Now()}, nil
+		}
+		result := s.health(ctx)
+		result.Time = time.Now()
+		return result, nil
+	case MethodShutdown:
+		return nil, nil
+	case MethodExit:
+		s.once.Do(func() { close(s.exit) })
+		return nil, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + method}
+}
+
+// HostClient lets a plugin call back into orc while it runs a phase
+type HostClient struct {
+	conn    *Conn
+	mu      sync.RWMutex
+	granted []string
+}
+
+func (c *HostClient) setGranted(methods []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.granted = methods
+}
+
+// Granted reports whether the host allows the plugin to call method
+func (c *HostClient) Granted(method string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, m := range c.granted {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute sends a prompt to the host's AI agent
+func (c *HostClient) Execute(ctx context.Context, prompt string, input interface{}) (string, error) {
+	return c.executeAgent(ctx, prompt, input, false)
+}
+
+// ExecuteJSON sends a prompt to the host's AI agent and asks for a JSON response
+func (c *HostClient) ExecuteJSON(ctx context.Context, prompt string, input interface{}) (string, error) {
+	return c.executeAgent(ctx, prompt, input, true)
+}
+
+func (c *HostClient) executeAgent(ctx context.Context, prompt string, input interface{}, asJSON bool) (string, error) {
+	params := AgentExecuteParams{Prompt: prompt, JSON: asJSON}
+	if input != nil {
+		raw, err := json.Marshal(input)
+		if err != nil {
+			return "", fmt.Errorf("encoding agent input: %w", err)
+		}
+		params.Input = raw
+	}
+	var result AgentExecuteResult
+	if err := c.conn.Call(ctx, MethodAgentExecute, params, &result); err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
+
+// Save stores data in the session's storage
+func (c *HostClient) Save(ctx context.Context, key string, data []byte) error {
+	return c.conn.Call(ctx, MethodStorageSave, StorageSaveParams{Key: key, Data: data}, nil)
+}
+
+// Load reads data from the session's storage
+func (c *HostClient) Load(ctx context.Context, key string) ([]byte, error) {
+	var result StorageLoadResult
+	if err := c.conn.Call(ctx, MethodStorageLoad, StorageLoadParams{Key: key}, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// Publish sends an event to the host's event bus
+func (c *HostClient) Publish(ctx context.Context, eventType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding event data: %w", err)
+	}
+	return c.conn.Call(ctx, MethodEventsPublish, EventParams{Type: eventType, Data: raw}, nil)
+}
+
+// Log writes a line to the host's log
+func (c *HostClient) Log(level, msg string, attrs map[string]interface{}) error {
+	return c.conn.Notify(MethodLog, LogParams{Level: level, Message: msg, Attrs: attrs})
+}
+
+// Progress reports how far a phase has got, from 0 to 100
+func (c *HostClient) Progress(phase string, percent float64, msg string) error {
+	return c.conn.Notify(MethodProgress, ProgressParams{Phase: phase, Percent: percent, Message: msg})
+}
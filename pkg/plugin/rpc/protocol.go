@@ -0,0 +1,197 @@
+// Package rpc implements the protocol long-lived binary plugins speak with orc:
+// JSON-RPC 2.0 over the plugin's stdin and stdout, one message per line.
+//
+// The host starts the plugin process once and sends initialize, agreeing on a
+// protocol version and learning the plugin's phases. It then sends phase.execute for
+// each phase it runs and health.ping as a heartbeat. While a phase runs the plugin
+// may call back into the host (agent.execute, storage.save, storage.load,
+// events.publish) and send log and progress notifications. Anything the plugin
+// writes to stderr is forwarded to the host's log. shutdown followed by an exit
+// notification ends the process.
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProtocolVersion is the newest protocol version this package speaks, and
+// MinProtocolVersion the oldest
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Methods the host handles
+const (
+	MethodAgentExecute  = "agent.execute"
+	MethodStorageSave   = "storage.save"
+	MethodStorageLoad   = "storage.load"
+	MethodEventsPublish = "events.publish"
+	MethodLog           = "log"      // notification
+	MethodProgress      = "progress" // notification
+)
+
+// HostMethods are the host methods a plugin may be granted
+var HostMethods = []string{MethodAgentExecute, MethodStorageSave, MethodStorageLoad, MethodEventsPublish}
+
+// Methods the plugin handles
+const (
+	MethodInitialize   = "initialize"
+	MethodInitialized  = "initialized" // notification
+	MethodPhaseExecute = "phase.execute"
+	MethodHealthPing   = "health.ping"
+	MethodShutdown     = "shutdown"
+	MethodExit         = "exit" // notification
+)
+
+// MethodCancel asks the other side to abandon a request it is handling
+const MethodCancel = "$/cancelRequest"
+
+// Plugin features announced in the handshake
+const (
+	FeatureProgress = "progress"
+	FeatureLogs     = "logs"
+	FeatureCancel   = "cancel"
+)
+
+// JSON-RPC error codes. Those from -32000 to -32099 are orc's own.
+const (
+	CodeParseError       = -32700
+	CodeInvalidRequest   = -32600
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeCapabilityDenied = -32001
+	CodeCancelled        = -32002
+	CodeNotFound         = -32003
+)
+
+// Error is a JSON-RPC error object. Handlers may return one to choose the code;
+// any other error is sent as an internal error.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// message is any JSON-RPC request, notification or response
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// InitializeParams open the handshake
+type InitializeParams struct {
+	ProtocolVersion    int    `json:"protocol_version"`
+	MinProtocolVersion int    `json:"min_protocol_version"`
+	HostVersion        string `json:"host_version,omitempty"`
+	// Plugin is the name the host loaded the plugin as
+	Plugin string `json:"plugin"`
+	// Granted lists the host methods the plugin is allowed to call
+	Granted []string `json:"granted"`
+}
+
+// InitializeResult is the plugin's side of the handshake
+type InitializeResult struct {
+	// ProtocolVersion is the version the plugin chose from the host's range
+	ProtocolVersion int      `json:"protocol_version"`
+	Name            string   `json:"name"`
+	Version         string   `json:"version,omitempty"`
+	Phases          []string `json:"phases,omitempty"`
+	// Features lists the optional behaviour the plugin supports (progress, logs, cancel)
+	Features []string `json:"features,omitempty"`
+}
+
+// ExecuteParams ask the plugin to run one phase
+type ExecuteParams struct {
+	Phase     string          `json:"phase"`
+	SessionID string          `json:"session_id,omitempty"`
+	Input     json.RawMessage `json:"input"`
+}
+
+// ExecuteResult carries the phase's output
+type ExecuteResult struct {
+	Output json.RawMessage `json:"output"`
+}
+
+// PingResult answers a heartbeat
+type PingResult struct {
+	Time time.Time `json:"time"`
+	// Status is healthy, degraded or unhealthy; empty means healthy
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// AgentExecuteParams ask the host to send a prompt to the AI
+type AgentExecuteParams struct {
+	Prompt string          `json:"prompt"`
+	Input  json.RawMessage `json:"input,omitempty"`
+	// JSON asks for a JSON response
+	JSON bool `json:"json,omitempty"`
+}
+
+// AgentExecuteResult is the AI's response
+type AgentExecuteResult struct {
+	Response string `json:"response"`
+}
+
+// StorageSaveParams store data under a key in the session's storage
+type StorageSaveParams struct {
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+}
+
+// StorageLoadParams read a key from the session's storage
+type StorageLoadParams struct {
+	Key string `json:"key"`
+}
+
+// StorageLoadResult holds the stored data
+type StorageLoadResult struct {
+	Data []byte `json:"data"`
+}
+
+// EventParams publish an event on the host's event bus
+type EventParams struct {
+	Type     string                 `json:"type"`
+	Data     json.RawMessage        `json:"data,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// LogParams carry a log line from the plugin
+type LogParams struct {
+	Level   string                 `json:"level"` // debug, info, warn or error
+	Message string                 `json:"message"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// ProgressParams report how far a phase has got
+type ProgressParams struct {
+	Phase   string  `json:"phase"`
+	Percent float64 `json:"percent"`
+	Message string  `json:"message,omitempty"`
+}
+
+// cancelParams name the request to abandon
+type cancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+// negotiate picks the newest version both sides speak
+func negotiate(ours, oursMin, theirs, theirsMin int) (int, error) {
+	version := min(ours, theirs)
+	if version < max(oursMin, theirsMin) {
+		return 0, fmt.Errorf("no common protocol version: we speak %d-%d, the other side %d-%d", oursMin, ours, theirsMin, theirs)
+	}
+	return version, nil
+}
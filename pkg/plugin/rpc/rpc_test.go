@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServices records what a plugin asked the host for
+type fakeServices struct {
+	mu     sync.Mutex
+	stored map[string][]byte
+	events []string
+}
+
+func (f *fakeServices) ExecuteAgent(ctx context.Context, p AgentExecuteParams) (string, error) {
+	return "echo: " + p.Prompt, nil
+}
+
+func (f *fakeServices) Save(ctx context.Context, key string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stored[key] = data
+	return nil
+}
+
+func (f *fakeServices) Load(ctx context.Context, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.stored[key]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", key)
+	}
+	return data, nil
+}
+
+func (f *fakeServices) Publish(ctx context.Context, event EventParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event.Type)
+	return nil
+}
+
+// connectPlugin runs Serve against a host over in-memory pipes
+func connectPlugin(t *testing.T, cfg HostConfig, execute PhaseFunc) (*Host, chan error) {
+	t.Helper()
+	hostR, pluginW := io.Pipe()
+	pluginR, hostW := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(context.Background(), pluginR, pluginW, PluginInfo{Name: "outline", Version: "1.2.0", Phases: []string{"outline"}}, execute, nil)
+		pluginW.Close()
+	}()
+
+	host, err := Connect(context.Background(), hostR, hostW, cfg)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return host, served
+}
+
+func TestHostRunsPhaseWithCallbacks(t *testing.T) {
+	services := &fakeServices{stored: make(map[string][]byte)}
+	progress := make(chan float64, 1)
+	cfg := HostConfig{
+		Plugin:   "outline",
+		Services: services,
+		Authorize: func(method string) error {
+			if method == MethodEventsPublish {
+				return errors.New("plugin outline lacks capability: plugin:comm")
+			}
+			return nil
+		},
+		OnProgress: func(p ProgressParams) { progress <- p.Percent },
+	}
+
+	host, served := connectPlugin(t, cfg, func(ctx context.Context, h *HostClient, phase string, input json.RawMessage) (interface{}, error) {
+		if h.Granted(MethodEventsPublish) {
+			return nil, errors.New("events.publish shouldn't be granted")
+		}
+		var request string
+		if err := json.Unmarshal(input, &request); err != nil {
+			return nil, err
+		}
+		response, err := h.Execute(ctx, "outline "+request, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.Save(ctx, "outline.md", []byte(response)); err != nil {
+			return nil, err
+		}
+		saved, err := h.Load(ctx, "outline.md")
+		if err != nil {
+			return nil, err
+		}
+		h.Progress(phase, 50, "saved")
+		var rpcErr *Error
+		if err := h.Publish(ctx, "outline.done", nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeCapabilityDenied {
+			return nil, fmt.Errorf("Publish() error = %v, want capability denied", err)
+		}
+		return map[string]string{"outline": string(saved)}, nil
+	})
+
+	info := host.Info()
+	if info.Name != "outline" || info.ProtocolVersion != ProtocolVersion || !host.Supports(FeatureProgress) {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	output, err := host.Execute(context.Background(), "outline", "session-1", "a heist")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(output, &got); err != nil || got["outline"] != "echo: outline a heist" {
+		t.Errorf("Execute() = %s, %v", output, err)
+	}
+
+	if _, rtt, err := host.Ping(context.Background()); err != nil || rtt <= 0 {
+		t.Errorf("Ping() = %v, %v", rtt, err)
+	}
+	select {
+	case percent := <-progress:
+		if percent != 50 {
+			t.Errorf("progress = %v, want 50", percent)
+		}
+	case <-time.After(time.Second):
+		t.Error("progress notification never arrived")
+	}
+
+	if err := host.Close(time.Second); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestHostCancelsPhase(t *testing.T) {
+	cancelled := make(chan struct{})
+	host, _ := connectPlugin(t, HostConfig{Plugin: "slow"}, func(ctx context.Context, h *HostClient, phase string, input json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	defer host.Close(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := host.Execute(ctx, "slow", "", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Execute() error = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("plugin wasn't told to cancel the phase")
+	}
+}
+
+func TestNegotiateRejectsDisjointVersions(t *testing.T) {
+	if v, err := negotiate(3, 1, 2, 2); err != nil || v != 2 {
+		t.Errorf("negotiate() = %d, %v; want 2", v, err)
+	}
+	if _, err := negotiate(1, 1, 3, 2); err == nil || !strings.Contains(err.Error(), "no common protocol version") {
+		t.Errorf("negotiate() error = %v, want no common version", err)
+	}
+}
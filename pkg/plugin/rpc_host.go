@@ -0,0 +1,330 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/dotcommander/orc/internal/domain"
+	"github.com/dotcommander/orc/pkg/orc"
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+// HostServices are what orc offers plugins: binary plugins speaking the JSON-RPC
+// protocol, WebAssembly plugins and pkg/orc plugins.
+// Each host method is only granted to plugins whose security policy allows it.
+type HostServices struct {
+	Agent domain.Agent
+	// AgentFactory creates the agents handed to pkg/orc plugins; without one they
+	// all share Agent
+	AgentFactory orc.AgentFactory
+	Storage      domain.Storage
+	Events       *EventBus
+	Security     *SecurityManager
+	// Health receives a report for every heartbeat sent to a running plugin
+	Health *HealthMonitor
+	// HeartbeatInterval is how often running plugins are pinged; zero uses 30 seconds
+	HeartbeatInterval time.Duration
+	HostVersion       string
+}
+
+// methodCapabilities maps each host method to the capability it requires
+var methodCapabilities = map[string]Capability{
+	rpc.MethodAgentExecute:  CapabilityAI,
+	rpc.MethodStorageSave:   CapabilityStorage,
+	rpc.MethodStorageLoad:   CapabilityStorage,
+	rpc.MethodEventsPublish: CapabilityPluginComm,
+}
+
+// authorize checks a plugin's call into the host against its security policy.
+// Without a security manager no host methods are granted.
+func (s HostServices) authorize(pluginName, method string) error {
+	capability, ok := methodCapabilities[method]
+	if !ok {
+		return fmt.Errorf("unknown host method %s", method)
+	}
+	if s.Security == nil {
+		return fmt.Errorf("plugin %s lacks capability: %s", pluginName, capability)
+	}
+	return s.Security.CheckCapability(pluginName, capability)
+}
+
+// rpcServices adapts HostServices to the calls a single plugin makes
+type rpcServices struct {
+	services HostServices
+	plugin   string
+}
+
+func (s *rpcServices) ExecuteAgent(ctx context.Context, params rpc.AgentExecuteParams) (string, error) {
+	var agent orc.Agent = s.services.Agent
+	if agent == nil && s.services.AgentFactory != nil {
+		agent = s.services.AgentFactory.CreateAgent(s.plugin, "")
+	}
+	if agent == nil {
+		return "", fmt.Errorf("no AI agent available")
+	}
+	var input any
+	if len(params.Input) > 0 {
+		if err := json.Unmarshal(params.Input, &input); err != nil {
+			return "", fmt.Errorf("invalid agent input: %w", err)
+		}
+	}
+	if params.JSON {
+		return agent.ExecuteJSON(ctx, params.Prompt, input)
+	}
+	return agent.Execute(ctx, params.Prompt, input)
+}
+
+func (s *rpcServices) Save(ctx context.Context, key string, data []byte) error {
+	if s.services.Storage == nil {
+		return fmt.Errorf("no storage available")
+	}
+	return s.services.Storage.Save(ctx, key, data)
+}
+
+func (s *rpcServices) Load(ctx context.Context, key string) ([]byte, error) {
+	if s.services.Storage == nil {
+		return nil, fmt.Errorf("no storage available")
+	}
+	return s.services.Storage.Load(ctx, key)
+}
+
+func (s *rpcServices) Publish(ctx context.Context, params rpc.EventParams) error {
+	if s.services.Events == nil {
+		return fmt.Errorf("no event bus available")
+	}
+	var data interface{}
+	if len(params.Data) > 0 {
+		if err := json.Unmarshal(params.Data, &data); err != nil {
+			return fmt.Errorf("invalid event data: %w", err)
+		}
+	}
+	return s.services.Events.Publish(ctx, Event{
+		ID:        generateShortID(),
+		Type:      params.Type,
+		Source:    fmt.Sprintf("plugin.%s", s.plugin),
+		Timestamp: time.Now(),
+		Data:      data,
+		Metadata:  params.Metadata,
+	})
+}
+
+// rpcProcess is the long-lived process behind a plugin using the JSON-RPC protocol.
+// It is started on the first phase call and restarted if it dies.
+type rpcProcess struct {
+	manifest *Manifest
+	execPath string
+	services HostServices
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	host    *rpc.Host
+	sandbox *pluginSandbox
+	stop    chan struct{}
+}
+
+func newRPCProcess(manifest *Manifest, execPath string, services HostServices, logger *slog.Logger) *rpcProcess {
+	return &rpcProcess{
+		manifest: manifest,
+		execPath: execPath,
+		services: services,
+		logger:   logger.With("plugin", manifest.Name),
+	}
+}
+
+// Execute runs a phase in the plugin process, starting it if needed. A process
+// that has gone over its sandbox's limits fails the phase and is stopped, so the
+// next phase starts a fresh one.
+func (p *rpcProcess) Execute(ctx context.Context, phase string, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	host, sb, err := p.running(ctx)
+	if err != nil {
+		return domain.PhaseOutput{}, err
+	}
+
+	sessionID, _ := input.Metadata["session_id"].(string)
+	raw, err := host.Execute(ctx, phase, sessionID, input)
+	if violations := sb.violations(); len(violations) > 0 {
+		p.mu.Lock()
+		if p.host == host {
+			p.stopLocked()
+		}
+		p.mu.Unlock()
+		return domain.PhaseOutput{}, fmt.Errorf("security violations: %v", violations)
+	}
+	if err != nil {
+		return domain.PhaseOutput{}, fmt.Errorf("phase execution failed: %w", err)
+	}
+
+	var output domain.PhaseOutput
+	if err := json.Unmarshal(raw, &output); err != nil {
+		return domain.PhaseOutput{}, fmt.Errorf("failed to parse phase output: %w", err)
+	}
+	return output, nil
+}
+
+// running returns the plugin's connection and sandbox, launching the process if
+// it isn't running
+func (p *rpcProcess) running(ctx context.Context) (*rpc.Host, *pluginSandbox, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.host != nil {
+		select {
+		case <-p.host.Done():
+			p.logger.Warn("plugin process exited, restarting")
+			p.stopLocked()
+		default:
+			return p.host, p.sandbox, nil
+		}
+	}
+
+	name := p.manifest.Name
+	sb := p.services.sandboxFor(p.manifest, p.logger)
+	var host *rpc.Host
+	for {
+		cmd := exec.Command(p.execPath, "serve")
+		cmd.Dir = p.manifest.Location
+		err := sb.prepare(cmd,
+			fmt.Sprintf("PLUGIN_NAME=%s", name),
+			fmt.Sprintf("ORC_PLUGIN_PROTOCOL=%s", ProtocolJSONRPC))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to sandbox plugin %s: %w", name, err)
+		}
+
+		host, err = rpc.Launch(ctx, cmd, rpc.HostConfig{
+			Plugin:      name,
+			HostVersion: p.services.HostVersion,
+			Services:    &rpcServices{services: p.services, plugin: name},
+			Authorize: func(method string) error {
+				return p.services.authorize(name, method)
+			},
+			Logger:  p.logger,
+			OnStart: sb.started,
+			OnExit:  sb.exited,
+		})
+		if err == nil {
+			break
+		}
+		if !sb.retry(err) {
+			return nil, nil, fmt.Errorf("failed to start plugin %s: %w", name, err)
+		}
+	}
+	p.host = host
+	p.sandbox = sb
+	p.stop = make(chan struct{})
+
+	if p.services.Health != nil {
+		if err := p.services.Health.RegisterPlugin(name, p); err != nil {
+			p.logger.Debug("plugin already registered for health checks", "error", err)
+		}
+		go p.heartbeat(host, p.stop)
+	}
+	return host, sb, nil
+}
+
+// heartbeat pings the plugin and records the result with the health monitor
+// until the process is stopped or exits
+func (p *rpcProcess) heartbeat(host *rpc.Host, stop <-chan struct{}) {
+	ticker := time.NewTicker(p.GetHealthCheckInterval())
+	defer ticker.Stop()
+
+	check := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), p.GetHealthCheckInterval())
+		defer cancel()
+		if _, err := p.services.Health.CheckNow(ctx, p.manifest.Name); err != nil {
+			p.logger.Debug("plugin health check failed", "error", err)
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-host.Done():
+			check() // Record that the process has gone
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// HealthCheck pings the plugin process
+func (p *rpcProcess) HealthCheck(ctx context.Context) (*HealthReport, error) {
+	p.mu.Lock()
+	host := p.host
+	p.mu.Unlock()
+
+	start := time.Now()
+	check := HealthCheck{Name: "heartbeat", Timestamp: start}
+	if host == nil {
+		check.Status = HealthStatusUnknown
+		check.Message = "plugin process not started"
+	} else {
+		result, rtt, err := host.Ping(ctx)
+		check.Duration = rtt
+		switch {
+		case err != nil:
+			check.Status = HealthStatusUnhealthy
+			check.Message = err.Error()
+		case result.Status == "":
+			check.Status = HealthStatusHealthy
+		default:
+			check.Status = HealthStatus(result.Status)
+			check.Message = result.Message
+		}
+	}
+
+	return &HealthReport{
+		Plugin:        p.manifest.Name,
+		Status:        check.Status,
+		Checks:        []HealthCheck{check},
+		Timestamp:     start,
+		TotalDuration: time.Since(start),
+	}, nil
+}
+
+// GetHealthCheckInterval returns how often the plugin is pinged
+func (p *rpcProcess) GetHealthCheckInterval() time.Duration {
+	if p.services.HeartbeatInterval > 0 {
+		return p.services.HeartbeatInterval
+	}
+	return 30 * time.Second
+}
+
+// IsHealthCheckCritical returns false; a dead process is restarted on the next phase
+func (p *rpcProcess) IsHealthCheckCritical() bool {
+	return false
+}
+
+// Close shuts the plugin process down
+func (p *rpcProcess) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.host == nil {
+		return nil
+	}
+	err := p.stopLocked()
+	if p.services.Health != nil {
+		p.services.Health.UnregisterPlugin(p.manifest.Name)
+	}
+	return err
+}
+
+// stopLocked stops the heartbeat and the process; p.mu must be held
+func (p *rpcProcess) stopLocked() error {
+	close(p.stop)
+	err := p.host.Close(5 * time.Second)
+	p.host = nil
+	p.sandbox = nil
+	if errors.Is(err, rpc.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/internal/domain"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+)
+
+type echoAgent struct{}
+
+func (echoAgent) Execute(ctx context.Context, prompt string, input any) (string, error) {
+	return "echo " + prompt, nil
+}
+
+func (echoAgent) ExecuteJSON(ctx context.Context, prompt string, input any) (string, error) {
+	return `"echo ` + prompt + `"`, nil
+}
+
+type memoryStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memoryStorage) Save(ctx context.Context, path string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+	return nil
+}
+
+func (s *memoryStorage) Load(ctx context.Context, path string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", path)
+	}
+	return data, nil
+}
+
+func (s *memoryStorage) Exists(ctx context.Context, path string) bool {
+	_, err := s.Load(ctx, path)
+	return err == nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+	return nil
+}
+
+func (s *memoryStorage) List(ctx context.Context, pattern string) ([]string, error) {
+	return nil, nil
+}
+
+// loadRPCPlugin builds testdata/rpcplugin and loads it with services, granting
+// it capabilities
+func loadRPCPlugin(t *testing.T, services HostServices, capabilities ...Capability) map[string]domain.Phase {
+	t.Helper()
+	dir := t.TempDir()
+	cmd := exec.Command("go", "build", "-o", filepath.Join(dir, "rpcplugin"), "./testdata/rpcplugin")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building the test plugin: %v\n%s", err, output)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy := DefaultSecurityPolicy()
+	policy.Capabilities = make(map[Capability]bool)
+	for _, capability := range capabilities {
+		policy.Capabilities[capability] = true
+	}
+	policy.AllowUnisolated = true
+	services.Security = NewSecurityManager(logger)
+	services.Security.SetPolicy("rpc-test", policy)
+
+	registry := domainPlugin.NewDomainRegistry()
+	loader := NewLoader(logger, NewDiscoverer(logger), registry)
+	loader.SetHostServices(services)
+	manifest := &Manifest{
+		Name:       "rpc-test",
+		Version:    "1.0.0",
+		Type:       PluginTypeExternal,
+		Domains:    []string{"fiction"},
+		Phases:     []PhaseDefinition{{Name: "ask"}, {Name: "save"}, {Name: "exit"}},
+		Location:   dir,
+		EntryPoint: "rpcplugin",
+		Binary:     true,
+		Protocol:   ProtocolJSONRPC,
+	}
+	if err := loader.Load(manifest); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	t.Cleanup(loader.UnloadAll)
+
+	p, err := registry.Get("rpc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases := make(map[string]domain.Phase)
+	for _, phase := range p.GetPhases() {
+		phases[phase.Name()] = phase
+	}
+	return phases
+}
+
+func runRPCPhase(t *testing.T, phase domain.Phase) string {
+	t.Helper()
+	output, err := phase.Execute(context.Background(), domain.PhaseInput{Request: "test"})
+	if err != nil {
+		t.Fatalf("%s: Execute() error = %v", phase.Name(), err)
+	}
+	result, _ := output.Data.(string)
+	return result
+}
+
+func TestRPCHostRefusesDeniedCapability(t *testing.T) {
+	store := &memoryStorage{files: make(map[string][]byte)}
+	phases := loadRPCPlugin(t, HostServices{Agent: echoAgent{}, Storage: store}, CapabilityAI)
+
+	if got := runRPCPhase(t, phases["ask"]); got != "ok" {
+		t.Errorf("agent call with CapabilityAI = %q, want ok", got)
+	}
+	if got := runRPCPhase(t, phases["save"]); got != "error -32001" {
+		t.Errorf("storage call without CapabilityStorage = %q, want error -32001", got)
+	}
+	if store.Exists(context.Background(), "note.txt") {
+		t.Error("denied storage call saved its data")
+	}
+}
+
+func TestRPCHostHeartbeats(t *testing.T) {
+	monitor := NewHealthMonitor()
+	phases := loadRPCPlugin(t, HostServices{Health: monitor, HeartbeatInterval: 20 * time.Millisecond})
+
+	waitFor := func(status HealthStatus) *HealthReport {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if report, ok := monitor.GetReport("rpc-test"); ok && report.Status == status {
+				return report
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		report, _ := monitor.GetReport("rpc-test")
+		t.Fatalf("no %s heartbeat recorded, last report %+v", status, report)
+		return nil
+	}
+
+	if _, ok := monitor.GetReport("rpc-test"); ok {
+		t.Error("heartbeat recorded before the process started")
+	}
+	runRPCPhase(t, phases["ask"]) // Starts the process; the call itself is denied
+	report := waitFor(HealthStatusDegraded)
+	if len(report.Checks) != 1 || report.Checks[0].Message != "warming up" {
+		t.Errorf("heartbeat checks = %+v, want the plugin's answer", report.Checks)
+	}
+
+	// A process that dies is recorded as unhealthy
+	phases["exit"].Execute(context.Background(), domain.PhaseInput{Request: "test"})
+	waitFor(HealthStatusUnhealthy)
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"github.com/dotcommander/orc/pkg/plugin/sandbox"
+)
+
+// pluginSandbox confines one binary plugin process and records the limits it
+// goes over. A nil sandbox leaves the process unconfined with orc's environment.
+type pluginSandbox struct {
+	cfg     sandbox.Config
+	monitor *ResourceMonitor
+	logger  *slog.Logger
+	// unisolated allows retry to drop namespaces and chroot the kernel refuses
+	unisolated bool
+}
+
+// sandboxFor returns the sandbox for a new process of the plugin, or nil when
+// there is no security manager
+func (s HostServices) sandboxFor(manifest *Manifest, logger *slog.Logger) *pluginSandbox {
+	if s.Security == nil {
+		return nil
+	}
+	policy, _ := s.Security.GetPolicy(manifest.Name)
+	return &pluginSandbox{
+		cfg:        s.Security.SandboxConfig(manifest),
+		monitor:    s.Security.StartMonitoring(manifest.Name),
+		logger:     logger,
+		unisolated: policy.AllowUnisolated,
+	}
+}
+
+// prepare sets cmd up to start in the sandbox with extra added to its environment
+func (sb *pluginSandbox) prepare(cmd *exec.Cmd, extra ...string) error {
+	if sb == nil {
+		cmd.Env = append(os.Environ(), extra...)
+		return nil
+	}
+	cfg := sb.cfg
+	cfg.Extra = append(append([]string(nil), cfg.Extra...), extra...)
+	return sandbox.Apply(cmd, cfg)
+}
+
+// started watches a process started from a prepared command
+func (sb *pluginSandbox) started(process *os.Process) error {
+	if sb == nil {
+		return nil
+	}
+	return sb.monitor.Watch(process.Pid, sb.cfg)
+}
+
+// exited records the limit that ended the process, if any, and stops watching it
+func (sb *pluginSandbox) exited(state *os.ProcessState) {
+	if sb == nil {
+		return
+	}
+	sb.monitor.Exited(state)
+	sb.monitor.Stop()
+}
+
+// violations returns the limits the process has gone over
+func (sb *pluginSandbox) violations() []string {
+	if sb == nil {
+		return nil
+	}
+	return sb.monitor.GetViolations()
+}
+
+// retry reports whether a process failed to start because the kernel refused
+// its namespaces or chroot and the plugin's policy allows running it without
+// them. If so they are dropped, and the caller should start the process again
+// from a newly prepared command. Otherwise the plugin doesn't start.
+func (sb *pluginSandbox) retry(err error) bool {
+	if sb == nil || (!sb.cfg.IsolateNetwork && sb.cfg.Root == "") || !sandbox.NamespaceError(err) {
+		return false
+	}
+	if !sb.unisolated {
+		sb.logger.Error("plugin sandbox namespaces unavailable; set AllowUnisolated in the plugin's security policy to run it without isolation", "error", err)
+		return false
+	}
+	sb.logger.Warn("plugin sandbox namespaces unavailable, starting without network and filesystem isolation", "error", err)
+	sb.cfg.IsolateNetwork = false
+	sb.cfg.Root = ""
+	return true
+}
@@ -0,0 +1,139 @@
+// Package sandbox confines plugin processes. On Linux a sandboxed process gets
+// resource limits (memory, open files, CPU time), an environment holding only
+// the variables it is allowed, optionally its own empty network namespace, and
+// optionally its own directory as its filesystem root. Watch samples the running
+// process and reports when it goes over its limits. Elsewhere only the
+// environment is restricted.
+//
+// Limits and the root are set before the plugin runs by a shim: the program
+// calling Apply is started again, and this package's init sets them up and
+// execs the plugin in its place.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Config describes the sandbox for one plugin process. Zero limits are unlimited.
+type Config struct {
+	// MaxMemory caps the process's data memory (heap and other private writable
+	// mappings, RLIMIT_DATA), in bytes. Address space isn't limited because the Go
+	// runtime reserves far more of it than it uses.
+	MaxMemory int64
+	// MaxOpenFiles caps the number of file descriptors the process can hold
+	MaxOpenFiles int
+	// MaxCPUTime is the CPU time after which the kernel kills the process
+	MaxCPUTime time.Duration
+	// MaxCPUPercent is the share of one CPU the process may use; going over is
+	// reported by Watch, not prevented
+	MaxCPUPercent int
+
+	// Env names the variables passed through from orc's environment; Extra is
+	// added as it is
+	Env   []string
+	Extra []string
+
+	// IsolateNetwork runs the process in a network namespace with no interfaces
+	IsolateNetwork bool
+	// Root, when set, becomes the process's filesystem root. The executable and
+	// working directory must be inside it.
+	Root string
+
+	// SampleInterval is how often Watch samples the process; zero uses one second
+	SampleInterval time.Duration
+}
+
+// Environ returns the variables from environ named in allowed, followed by extra
+func Environ(environ, allowed, extra []string) []string {
+	keep := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		keep[name] = true
+	}
+	var env []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if keep[name] {
+			env = append(env, kv)
+		}
+	}
+	return append(env, extra...)
+}
+
+// Apply configures cmd to start inside the sandbox. On Linux a command with
+// limits or a root starts the current executable, which sets them up and execs
+// cmd's executable before any of the plugin's code runs.
+func Apply(cmd *exec.Cmd, cfg Config) error {
+	cmd.Env = Environ(os.Environ(), cfg.Env, cfg.Extra)
+	if cmd.Env == nil {
+		cmd.Env = []string{} // A nil Env would pass orc's whole environment
+	}
+	return applyPlatform(cmd, cfg)
+}
+
+// ExitViolation describes the limit a process that has exited ran into, if any
+func ExitViolation(state *os.ProcessState, cfg Config) string {
+	if state == nil || cfg.MaxCPUTime <= 0 {
+		return ""
+	}
+	if used := state.UserTime() + state.SystemTime(); used >= cfg.MaxCPUTime {
+		return fmt.Sprintf("CPU time %s reached limit %s", used.Round(time.Millisecond), cfg.MaxCPUTime)
+	}
+	return ""
+}
+
+// Usage is one sample of a process's resource use
+type Usage struct {
+	Memory    int64 // Resident set size in bytes
+	OpenFiles int
+	CPUTime   time.Duration // User and system time so far
+}
+
+// Watch samples the process every cfg.SampleInterval until done is closed or the
+// process goes away, calling violation once for each limit it goes over
+func Watch(pid int, cfg Config, done <-chan struct{}, violation func(string)) {
+	interval := cfg.SampleInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := make(map[string]bool)
+	report := func(kind, msg string) {
+		if !reported[kind] {
+			reported[kind] = true
+			violation(msg)
+		}
+	}
+
+	last, _ := Sample(pid)
+	lastAt := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			usage, err := Sample(pid)
+			if err != nil {
+				return // Exited
+			}
+			if cfg.MaxMemory > 0 && usage.Memory > cfg.MaxMemory {
+				report("memory", fmt.Sprintf("memory use %d bytes exceeds limit %d", usage.Memory, cfg.MaxMemory))
+			}
+			if cfg.MaxOpenFiles > 0 && usage.OpenFiles >= cfg.MaxOpenFiles {
+				report("files", fmt.Sprintf("%d open files reaches limit %d", usage.OpenFiles, cfg.MaxOpenFiles))
+			}
+			if cfg.MaxCPUPercent > 0 && now.After(lastAt) {
+				percent := int(100 * (usage.CPUTime - last.CPUTime) / now.Sub(lastAt))
+				if percent > cfg.MaxCPUPercent {
+					report("cpu", fmt.Sprintf("CPU use %d%% exceeds limit %d%%", percent, cfg.MaxCPUPercent))
+				}
+			}
+			last, lastAt = usage, now
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc, which is 100 on every
+// architecture Go supports
+const clockTicks = 100
+
+// shimEnv holds the shimSpec of a process started through the shim. Its
+// presence makes the sandbox package's init run the shim instead of the program.
+const shimEnv = "ORC_SANDBOX_SHIM"
+
+// shimSpec is what the shim does before it execs the plugin
+type shimSpec struct {
+	Path         string `json:"path"`
+	Root         string `json:"root,omitempty"`
+	Dir          string `json:"dir,omitempty"`
+	MaxMemory    int64  `json:"max_memory,omitempty"`
+	MaxOpenFiles int    `json:"max_open_files,omitempty"`
+	MaxCPUTime   int64  `json:"max_cpu_seconds,omitempty"`
+}
+
+func init() {
+	if spec, ok := os.LookupEnv(shimEnv); ok {
+		runShim(spec)
+	}
+}
+
+// applyPlatform starts cmd through the shim when it has limits or a root: the
+// current executable is run again and, from the sandbox package's init, sets the
+// limits and chroots before it execs the plugin. Namespaces are set up by the
+// kernel as the shim is cloned.
+func applyPlatform(cmd *exec.Cmd, cfg Config) error {
+	spec := shimSpec{
+		MaxMemory:    cfg.MaxMemory,
+		MaxOpenFiles: cfg.MaxOpenFiles,
+	}
+	if cfg.MaxCPUTime > 0 {
+		spec.MaxCPUTime = int64((cfg.MaxCPUTime + time.Second - 1) / time.Second)
+	}
+	if cfg.Root != "" {
+		root, err := filepath.Abs(cfg.Root)
+		if err != nil {
+			return err
+		}
+		if err := rebase(cmd, root); err != nil {
+			return err
+		}
+		spec.Root, spec.Dir = root, cmd.Dir
+	}
+	if spec != (shimSpec{}) {
+		if err := shim(cmd, spec); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.IsolateNetwork && cfg.Root == "" {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	if cfg.IsolateNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	// Unprivileged, namespaces and chroot need a user namespace. Inside it the
+	// process is root only if it has to chroot.
+	if os.Geteuid() != 0 {
+		uid, gid := os.Getuid(), os.Getgid()
+		inner := uid
+		if cfg.Root != "" {
+			inner = 0
+			attr.Cloneflags |= syscall.CLONE_NEWNS
+		}
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: inner, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: inner, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+	return nil
+}
+
+// shim makes cmd run the shim, which execs cmd's executable as spec says. The
+// shim's directory is left to the shim when it chroots.
+func shim(cmd *exec.Cmd, spec shimSpec) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding the sandbox shim: %w", err)
+	}
+	spec.Path = cmd.Path
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	cmd.Path = self
+	if spec.Root != "" {
+		cmd.Dir = ""
+	}
+	cmd.Env = append(cmd.Env, shimEnv+"="+string(data))
+	return nil
+}
+
+// runShim applies the shimSpec in spec to this process and execs the plugin in
+// its place. It only returns by exiting.
+func runShim(spec string) {
+	if err := execShim(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(127)
+	}
+}
+
+func execShim(data string) error {
+	var spec shimSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return fmt.Errorf("invalid shim spec: %w", err)
+	}
+	if spec.Root != "" {
+		if err := syscall.Chroot(spec.Root); err != nil {
+			return fmt.Errorf("chroot %s: %w", spec.Root, err)
+		}
+		if err := os.Chdir(spec.Dir); err != nil {
+			return err
+		}
+	}
+	if spec.MaxMemory > 0 {
+		if err := setrlimit(syscall.RLIMIT_DATA, uint64(spec.MaxMemory), uint64(spec.MaxMemory)); err != nil {
+			return fmt.Errorf("limiting memory: %w", err)
+		}
+	}
+	if spec.MaxOpenFiles > 0 {
+		if err := setrlimit(syscall.RLIMIT_NOFILE, uint64(spec.MaxOpenFiles), uint64(spec.MaxOpenFiles)); err != nil {
+			return fmt.Errorf("limiting open files: %w", err)
+		}
+	}
+	if spec.MaxCPUTime > 0 {
+		// SIGXCPU at the limit, SIGKILL a second later if that is ignored
+		if err := setrlimit(syscall.RLIMIT_CPU, uint64(spec.MaxCPUTime), uint64(spec.MaxCPUTime)+1); err != nil {
+			return fmt.Errorf("limiting CPU time: %w", err)
+		}
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, shimEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	return syscall.Exec(spec.Path, os.Args, env)
+}
+
+func setrlimit(resource int, soft, hard uint64) error {
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
+
+// NamespaceError reports whether a failure to start a sandboxed process is the
+// kernel refusing namespaces or chroot, in which case it can be retried without
+func NamespaceError(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EACCES)
+}
+
+// Sample reads a process's resource use from /proc
+func Sample(pid int) (Usage, error) {
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	var usage Usage
+
+	stat, err := os.ReadFile(filepath.Join(proc, "stat"))
+	if err != nil {
+		return usage, err
+	}
+	// The command name may contain spaces, so fields are counted from its end
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return usage, fmt.Errorf("malformed %s/stat", proc)
+	}
+	fields := bytes.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return usage, fmt.Errorf("malformed %s/stat", proc)
+	}
+	if string(fields[0]) == "Z" {
+		return usage, os.ErrProcessDone
+	}
+	utime, _ := strconv.ParseInt(string(fields[11]), 10, 64)
+	stime, _ := strconv.ParseInt(string(fields[12]), 10, 64)
+	usage.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+	rss, _ := strconv.ParseInt(string(fields[21]), 10, 64)
+	usage.Memory = rss * int64(os.Getpagesize())
+
+	if fds, err := os.ReadDir(filepath.Join(proc, "fd")); err == nil {
+		usage.OpenFiles = len(fds)
+	}
+	return usage, nil
+}
+
+// rebase rewrites cmd's executable and working directory relative to root, as
+// the process will see them after chroot
+func rebase(cmd *exec.Cmd, root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	inside := func(path string) (string, error) {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s is outside the sandbox root %s", path, root)
+		}
+		return string(filepath.Separator) + rel, nil
+	}
+
+	path, err := inside(cmd.Path)
+	if err != nil {
+		return err
+	}
+	dir := cmd.Dir
+	if dir == "" {
+		dir = root
+	}
+	if dir, err = inside(dir); err != nil {
+		return err
+	}
+	cmd.Path = filepath.Clean(path)
+	cmd.Dir = filepath.Clean(dir)
+	return nil
+}
@@ -0,0 +1,151 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the sandboxed child in the tests below; it reports what
+// it sees as soon as it starts
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("SANDBOX_HELPER")
+	if mode == "" {
+		return
+	}
+
+	switch mode {
+	case "limits":
+		var files, mem syscall.Rlimit
+		syscall.Getrlimit(syscall.RLIMIT_NOFILE, &files)
+		syscall.Getrlimit(syscall.RLIMIT_DATA, &mem)
+		fmt.Printf("files=%d mem=%d env=%s\n", files.Cur, mem.Cur, strings.Join(os.Environ(), ","))
+	case "network":
+		ifaces, _ := net.Interfaces()
+		var names []string
+		for _, iface := range ifaces {
+			names = append(names, iface.Name)
+		}
+		fmt.Println(strings.Join(names, ","))
+	case "spin":
+		for start := time.Now(); time.Since(start) < 5*time.Second; {
+		}
+	}
+	os.Exit(0)
+}
+
+func helper(t *testing.T, mode string, cfg Config) (*exec.Cmd, *strings.Builder) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cfg.Extra = append(cfg.Extra, "SANDBOX_HELPER="+mode)
+	if err := Apply(cmd, cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	out := &strings.Builder{}
+	cmd.Stdout = out
+	if err := cmd.Start(); err != nil {
+		if NamespaceError(err) {
+			t.Skipf("namespaces unavailable: %v", err)
+		}
+		t.Fatalf("Start() error = %v", err)
+	}
+	return cmd, out
+}
+
+func TestLimitAndEnvironment(t *testing.T) {
+	t.Setenv("SANDBOX_KEEP", "kept")
+	t.Setenv("SANDBOX_DROP", "dropped")
+
+	cmd, out := helper(t, "limits", Config{
+		MaxMemory:    1 << 30,
+		MaxOpenFiles: 64,
+		Env:          []string{"SANDBOX_KEEP"},
+	})
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"files=64", fmt.Sprintf("mem=%d", 1<<30), "SANDBOX_KEEP=kept"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("helper saw %q, want %s", got, want)
+		}
+	}
+	for _, dropped := range []string{"SANDBOX_DROP", shimEnv} {
+		if strings.Contains(got, dropped) {
+			t.Errorf("helper saw %q, %s should have been removed", got, dropped)
+		}
+	}
+}
+
+func TestCPUTimeLimit(t *testing.T) {
+	cfg := Config{MaxCPUTime: time.Second}
+	cmd, _ := helper(t, "spin", cfg)
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("helper spun for 5 seconds with a 1 second CPU limit")
+	}
+	if v := ExitViolation(cmd.ProcessState, cfg); !strings.HasPrefix(v, "CPU time") {
+		t.Errorf("ExitViolation() = %q, want CPU time", v)
+	}
+}
+
+func TestIsolateNetwork(t *testing.T) {
+	cmd, out := helper(t, "network", Config{IsolateNetwork: true})
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "lo" {
+		t.Errorf("interfaces = %q, want only lo", got)
+	}
+}
+
+func TestWatchReportsCPU(t *testing.T) {
+	cfg := Config{MaxCPUPercent: 10, SampleInterval: 50 * time.Millisecond}
+	cmd, _ := helper(t, "spin", cfg)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	violations := make(chan string, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go Watch(cmd.Process.Pid, cfg, done, func(v string) { violations <- v })
+
+	select {
+	case v := <-violations:
+		if !strings.HasPrefix(v, "CPU use") {
+			t.Errorf("violation = %q, want CPU use", v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("no violation reported for a spinning process")
+	}
+}
+
+func TestApplyChrootRebasesPaths(t *testing.T) {
+	root := t.TempDir()
+	cmd := exec.Command(filepath.Join(root, "bin", "plugin"), "serve")
+	if err := Apply(cmd, Config{Root: root}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	self, _ := os.Executable()
+	var spec shimSpec
+	for _, kv := range cmd.Env {
+		if value, ok := strings.CutPrefix(kv, shimEnv+"="); ok {
+			json.Unmarshal([]byte(value), &spec)
+		}
+	}
+	if cmd.Path != self || spec.Path != "/bin/plugin" || spec.Dir != "/" || spec.Root != root {
+		t.Errorf("Apply() = path %s, shim %+v", cmd.Path, spec)
+	}
+
+	outside := exec.Command("/usr/bin/env")
+	if err := Apply(outside, Config{Root: root}); err == nil {
+		t.Error("Apply() accepted an executable outside the root")
+	}
+}
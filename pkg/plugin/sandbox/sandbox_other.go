@@ -0,0 +1,24 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Namespaces, chroot and limits need Linux; elsewhere Apply only restricts the
+// environment
+func applyPlatform(cmd *exec.Cmd, cfg Config) error {
+	return nil
+}
+
+// NamespaceError always reports false, as namespaces are never used here
+func NamespaceError(err error) bool {
+	return false
+}
+
+// Sample isn't supported outside Linux, which stops Watch at once
+func Sample(pid int) (Usage, error) {
+	return Usage{}, errors.ErrUnsupported
+}
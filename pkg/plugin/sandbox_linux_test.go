@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"syscall"
+	"testing"
+)
+
+func TestSandboxRetryFailsClosed(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	refused := fmt.Errorf("fork/exec plugin: %w", syscall.EPERM)
+	manifest := &Manifest{Name: "isolated"}
+
+	security := NewSecurityManager(logger)
+	security.SetPolicy("isolated", DefaultSecurityPolicy())
+	sb := HostServices{Security: security}.sandboxFor(manifest, logger)
+	defer sb.monitor.Stop()
+	if sb.retry(refused) {
+		t.Error("retry() dropped isolation the policy didn't allow dropping")
+	}
+	if !sb.cfg.IsolateNetwork {
+		t.Error("retry() cleared IsolateNetwork")
+	}
+
+	policy := DefaultSecurityPolicy()
+	policy.AllowUnisolated = true
+	security.SetPolicy("isolated", policy)
+	sb = HostServices{Security: security}.sandboxFor(manifest, logger)
+	defer sb.monitor.Stop()
+	if !sb.retry(refused) || sb.cfg.IsolateNetwork {
+		t.Errorf("retry() with AllowUnisolated = false, IsolateNetwork = %v", sb.cfg.IsolateNetwork)
+	}
+	if sb.retry(refused) {
+		t.Error("retry() again after isolation was dropped")
+	}
+}
@@ -3,16 +3,17 @@ package plugin
 import (
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
-	"net"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/dotcommander/orc/internal/core"
 	"github.com/dotcommander/orc/internal/domain"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/pkg/plugin/sandbox"
 )
 
 // Capability represents a permission that a plugin can request
@@ -43,16 +44,21 @@ type SecurityPolicy struct {
 	// File system restrictions
 	AllowedReadPaths  []string // Paths the plugin can read from
 	AllowedWritePaths []string // Paths the plugin can write to
+	IsolateFilesystem bool     // Run binary plugins with their own directory as the filesystem root
+	// AllowUnisolated starts binary plugins without network and filesystem
+	// isolation when the kernel refuses namespaces or chroot, instead of failing
+	AllowUnisolated bool
 	
 	// Network restrictions
 	AllowedHosts []string // Hosts the plugin can connect to
 	AllowedPorts []int    // Ports the plugin can use
 	
 	// Resource limits
-	MaxMemory     int64 // Maximum memory in bytes
-	MaxCPU        int   // Maximum CPU percentage
-	MaxGoroutines int   // Maximum concurrent goroutines
-	MaxOpenFiles  int   // Maximum open file descriptors
+	MaxMemory     int64         // Maximum memory in bytes
+	MaxCPU        int           // Maximum CPU percentage
+	MaxGoroutines int           // Maximum concurrent goroutines
+	MaxOpenFiles  int           // Maximum open file descriptors
+	MaxCPUTime    time.Duration // CPU time after which a binary plugin is killed; zero is unlimited
 	
 	// API restrictions
 	MaxAPICallsPerMinute int
@@ -229,43 +235,33 @@ func (sm *SecurityManager) CheckNetworkAccess(pluginName string, host string, po
 
 // SecurePlugin wraps a plugin with security enforcement
 type SecurePlugin struct {
-	plugin          domain.Plugin
+	domainPlugin.DomainPlugin
 	securityManager *SecurityManager
 	logger          *slog.Logger
 }
 
 // NewSecurePlugin creates a security-enforcing plugin wrapper
-func NewSecurePlugin(plugin domain.Plugin, sm *SecurityManager, logger *slog.Logger) *SecurePlugin {
+func NewSecurePlugin(plugin domainPlugin.DomainPlugin, sm *SecurityManager, logger *slog.Logger) *SecurePlugin {
 	if logger == nil {
 		logger = slog.Default()
 	}
 	
 	return &SecurePlugin{
-		plugin:          plugin,
+		DomainPlugin:    plugin,
 		securityManager: sm,
 		logger:          logger,
 	}
 }
 
-// Name implements domain.Plugin
-func (sp *SecurePlugin) Name() string {
-	return sp.plugin.Name()
-}
-
-// Domain implements domain.Plugin
-func (sp *SecurePlugin) Domain() string {
-	return sp.plugin.Domain()
-}
-
-// GetPhases implements domain.Plugin with security wrappers
+// GetPhases implements domainPlugin.DomainPlugin with security wrappers
 func (sp *SecurePlugin) GetPhases() []domain.Phase {
-	originalPhases := sp.plugin.GetPhases()
+	originalPhases := sp.DomainPlugin.GetPhases()
 	securePhases := make([]domain.Phase, len(originalPhases))
 	
 	for i, phase := range originalPhases {
 		securePhases[i] = &SecurePhase{
 			phase:           phase,
-			pluginName:      sp.plugin.Name(),
+			pluginName:      sp.Name(),
 			securityManager: sp.securityManager,
 			logger:          sp.logger,
 		}
@@ -274,6 +270,11 @@ func (sp *SecurePlugin) GetPhases() []domain.Phase {
 	return securePhases
 }
 
+// Schedule passes on the wrapped plugin's phase schedule, if it declares one
+func (sp *SecurePlugin) Schedule() []core.PhaseSchedule {
+	return domainPlugin.PhaseSchedule(sp.DomainPlugin)
+}
+
 // SecurePhase wraps a phase with security checks
 type SecurePhase struct {
 	phase           domain.Phase
@@ -289,15 +290,12 @@ func (sp *SecurePhase) Name() string {
 
 // Execute implements domain.Phase with security enforcement
 func (sp *SecurePhase) Execute(ctx context.Context, input domain.PhaseInput) (domain.PhaseOutput, error) {
-	// Wrap the input with secure versions
-	secureInput := sp.wrapInput(input)
-	
 	// Monitor resource usage during execution
 	monitor := sp.securityManager.StartMonitoring(sp.pluginName)
 	defer monitor.Stop()
 	
 	// Execute with monitoring
-	output, err := sp.phase.Execute(ctx, secureInput)
+	output, err := sp.phase.Execute(ctx, input)
 	
 	// Check if any limits were exceeded
 	if violations := monitor.GetViolations(); len(violations) > 0 {
@@ -324,110 +322,94 @@ func (sp *SecurePhase) CanRetry(err error) bool {
 	return sp.phase.CanRetry(err)
 }
 
-// wrapInput creates secure wrappers for phase input components
-func (sp *SecurePhase) wrapInput(input domain.PhaseInput) domain.PhaseInput {
-	// Create a copy with secure wrappers
-	secureInput := input
-	
-	// Wrap storage if present
-	if input.Storage != nil {
-		secureInput.Storage = &SecureStorage{
-			storage:         input.Storage,
-			pluginName:      sp.pluginName,
-			securityManager: sp.securityManager,
-		}
-	}
-	
-	// Wrap AI agent if present
-	if input.Agent != nil {
-		secureInput.Agent = &SecureAgent{
-			agent:           input.Agent,
-			pluginName:      sp.pluginName,
-			securityManager: sp.securityManager,
-		}
-	}
-	
-	return secureInput
-}
-
-// SecureStorage wraps storage with security checks
+// SecureStorage wraps the storage handed to a plugin, refusing it without
+// CapabilityStorage and writes over its MaxDataSize
 type SecureStorage struct {
 	storage         domain.Storage
 	pluginName      string
 	securityManager *SecurityManager
 }
 
-func (ss *SecureStorage) SaveOutput(sessionID, filename string, data []byte) error {
-	// Check capability
+// NewSecureStorage wraps storage for a plugin
+func NewSecureStorage(storage domain.Storage, pluginName string, sm *SecurityManager) *SecureStorage {
+	return &SecureStorage{storage: storage, pluginName: pluginName, securityManager: sm}
+}
+
+func (ss *SecureStorage) Save(ctx context.Context, key string, data []byte) error {
 	if err := ss.securityManager.CheckCapability(ss.pluginName, CapabilityStorage); err != nil {
 		return err
 	}
 	
-	// Check file write permission
-	path := filepath.Join(sessionID, filename)
-	if err := ss.securityManager.CheckFileAccess(ss.pluginName, path, true); err != nil {
-		return err
-	}
-	
 	// Check data size limit
 	policy, _ := ss.securityManager.GetPolicy(ss.pluginName)
-	if int64(len(data)) > policy.MaxDataSize {
+	if policy.MaxDataSize > 0 && int64(len(data)) > policy.MaxDataSize {
 		return fmt.Errorf("data size %d exceeds limit %d", len(data), policy.MaxDataSize)
 	}
 	
-	return ss.storage.SaveOutput(sessionID, filename, data)
+	return ss.storage.Save(ctx, key, data)
 }
 
-func (ss *SecureStorage) LoadOutput(sessionID, filename string) ([]byte, error) {
-	// Check capability
+func (ss *SecureStorage) Load(ctx context.Context, key string) ([]byte, error) {
 	if err := ss.securityManager.CheckCapability(ss.pluginName, CapabilityStorage); err != nil {
 		return nil, err
 	}
-	
-	// Check file read permission
-	path := filepath.Join(sessionID, filename)
-	if err := ss.securityManager.CheckFileAccess(ss.pluginName, path, false); err != nil {
-		return nil, err
+	return ss.storage.Load(ctx, key)
+}
+
+func (ss *SecureStorage) Exists(ctx context.Context, key string) bool {
+	if ss.securityManager.CheckCapability(ss.pluginName, CapabilityStorage) != nil {
+		return false
 	}
-	
-	return ss.storage.LoadOutput(sessionID, filename)
+	return ss.storage.Exists(ctx, key)
 }
 
-// Other storage methods...
+func (ss *SecureStorage) Delete(ctx context.Context, key string) error {
+	if err := ss.securityManager.CheckCapability(ss.pluginName, CapabilityStorage); err != nil {
+		return err
+	}
+	return ss.storage.Delete(ctx, key)
+}
+
+func (ss *SecureStorage) List(ctx context.Context, pattern string) ([]string, error) {
+	if err := ss.securityManager.CheckCapability(ss.pluginName, CapabilityStorage); err != nil {
+		return nil, err
+	}
+	return ss.storage.List(ctx, pattern)
+}
 
-// SecureAgent wraps AI agent with security checks
+// SecureAgent wraps the AI agent handed to a plugin, refusing it without
+// CapabilityAI
 type SecureAgent struct {
 	agent           domain.Agent
 	pluginName      string
 	securityManager *SecurityManager
 }
 
-func (sa *SecureAgent) Complete(ctx context.Context, prompt string) (string, error) {
-	// Check capability
+// NewSecureAgent wraps agent for a plugin
+func NewSecureAgent(agent domain.Agent, pluginName string, sm *SecurityManager) *SecureAgent {
+	return &SecureAgent{agent: agent, pluginName: pluginName, securityManager: sm}
+}
+
+// check applies the capability and rate limit to one call
+func (sa *SecureAgent) check() error {
 	if err := sa.securityManager.CheckCapability(sa.pluginName, CapabilityAI); err != nil {
-		return "", err
-	}
-	
-	// Check API rate limits
-	if err := sa.securityManager.CheckAPIRateLimit(sa.pluginName); err != nil {
-		return "", err
+		return err
 	}
-	
-	return sa.agent.Complete(ctx, prompt)
+	return sa.securityManager.CheckAPIRateLimit(sa.pluginName)
 }
 
-func (sa *SecureAgent) CompleteWithPersona(ctx context.Context, persona, prompt string) (string, error) {
-	// Check capability
-	if err := sa.securityManager.CheckCapability(sa.pluginName, CapabilityAI); err != nil {
+func (sa *SecureAgent) Execute(ctx context.Context, prompt string, input any) (string, error) {
+	if err := sa.check(); err != nil {
 		return "", err
 	}
-	
-	// Check API rate limits
-	if err := sa.securityManager.CheckAPIRateLimit(sa.pluginName); err != nil {
+	return sa.agent.Execute(ctx, prompt, input)
+}
+
+func (sa *SecureAgent) ExecuteJSON(ctx context.Context, prompt string, input any) (string, error) {
+	if err := sa.check(); err != nil {
 		return "", err
 	}
-	
-	return sa.agent.CompleteWithPersona(ctx, persona, prompt)
+	return sa.agent.ExecuteJSON(ctx, prompt, input)
 }
 
 // ResourceMonitor tracks resource usage for security enforcement
@@ -435,17 +417,57 @@ type ResourceMonitor struct {
 	pluginName string
 	startTime  time.Time
 	violations []string
+	cfg        sandbox.Config
 	mu         sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
 }
 
+// Watch samples a plugin process started in the sandbox cfg describes in the
+// background, recording each limit it goes over, until Stop
+func (rm *ResourceMonitor) Watch(pid int, cfg sandbox.Config) error {
+	rm.mu.Lock()
+	rm.cfg = cfg
+	rm.mu.Unlock()
+
+	rm.wg.Add(1)
+	go func() {
+		defer rm.wg.Done()
+		sandbox.Watch(pid, cfg, rm.stop, rm.record)
+	}()
+	return nil
+}
+
+// Exited records the limit that ended a watched process, if any
+func (rm *ResourceMonitor) Exited(state *os.ProcessState) {
+	rm.mu.Lock()
+	cfg := rm.cfg
+	rm.mu.Unlock()
+	if violation := sandbox.ExitViolation(state, cfg); violation != "" {
+		rm.record(violation)
+	}
+}
+
+func (rm *ResourceMonitor) record(violation string) {
+	slog.Warn("plugin exceeded resource limit", "plugin", rm.pluginName, "violation", violation)
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.violations = append(rm.violations, violation)
+}
+
+// Stop ends sampling and waits for it to finish
 func (rm *ResourceMonitor) Stop() {
-	// Collect final metrics
+	rm.stopOnce.Do(func() { close(rm.stop) })
+	rm.wg.Wait()
 }
 
+// GetViolations returns the limits the plugin has gone over so far
 func (rm *ResourceMonitor) GetViolations() []string {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
-	return rm.violations
+	return append([]string(nil), rm.violations...)
 }
 
 // Additional security manager methods
@@ -460,6 +482,7 @@ func (sm *SecurityManager) StartMonitoring(pluginName string) *ResourceMonitor {
 	monitor := &ResourceMonitor{
 		pluginName: pluginName,
 		startTime:  time.Now(),
+		stop:       make(chan struct{}),
 	}
 	
 	sm.mu.Lock()
@@ -469,6 +492,30 @@ func (sm *SecurityManager) StartMonitoring(pluginName string) *ResourceMonitor {
 	return monitor
 }
 
+// SandboxConfig describes the sandbox a binary plugin runs in: its policy's
+// resource limits, only the environment variables its manifest lists, no network
+// unless it has CapabilityNetwork, and its directory as its root if the policy
+// isolates the filesystem. Plugins without a policy get DefaultSecurityPolicy.
+func (sm *SecurityManager) SandboxConfig(manifest *Manifest) sandbox.Config {
+	policy, exists := sm.GetPolicy(manifest.Name)
+	if !exists {
+		policy = DefaultSecurityPolicy()
+	}
+
+	cfg := sandbox.Config{
+		MaxMemory:      policy.MaxMemory,
+		MaxOpenFiles:   policy.MaxOpenFiles,
+		MaxCPUTime:     policy.MaxCPUTime,
+		MaxCPUPercent:  policy.MaxCPU,
+		Env:            manifest.ResourceSpec.EnvironmentVars,
+		IsolateNetwork: !policy.Capabilities[CapabilityNetwork],
+	}
+	if policy.IsolateFilesystem {
+		cfg.Root = manifest.Location
+	}
+	return cfg
+}
+
 func (sm *SecurityManager) CheckAPIRateLimit(pluginName string) error {
 	// TODO: Implement rate limiting logic
 	return nil
@@ -0,0 +1,48 @@
+// Command rpcplugin is a JSON-RPC plugin for the host tests. Its phases call back
+// into the host and report how the call went; its heartbeats answer degraded.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+func main() {
+	info := rpc.PluginInfo{Name: "rpc-test", Version: "1.0.0", Phases: []string{"ask", "save", "exit"}}
+	err := rpc.Serve(context.Background(), os.Stdin, os.Stdout, info, execute, health)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func execute(ctx context.Context, host *rpc.HostClient, phase string, input json.RawMessage) (interface{}, error) {
+	var err error
+	switch phase {
+	case "ask":
+		_, err = host.Execute(ctx, "hello", nil)
+	case "save":
+		err = host.Save(ctx, "note.txt", []byte("note"))
+	case "exit":
+		os.Exit(1)
+	default:
+		return nil, fmt.Errorf("unknown phase %s", phase)
+	}
+	result := "ok"
+	var rpcErr *rpc.Error
+	if errors.As(err, &rpcErr) {
+		result = fmt.Sprintf("error %d", rpcErr.Code)
+	} else if err != nil {
+		result = err.Error()
+	}
+	return map[string]interface{}{"Data": result}, nil
+}
+
+func health(ctx context.Context) rpc.PingResult {
+	return rpc.PingResult{Status: "degraded", Message: "warming up"}
+}
@@ -0,0 +1,89 @@
+//go:build wasip1
+
+// Command guest is the WebAssembly plugin the runtime's tests load. The tests
+// build it with GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared.
+package main
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+//go:wasmimport orc agent_execute
+func agentExecute(ptr, size uint32) uint64
+
+//go:wasmimport orc storage_save
+func storageSave(ptr, size uint32) uint64
+
+func main() {}
+
+// allocations keeps what orc_alloc hands out reachable until the instance is
+// discarded after the phase
+var allocations [][]byte
+
+//go:wasmexport orc_alloc
+func alloc(size uint32) uint32 {
+	buf := make([]byte, size)
+	allocations = append(allocations, buf)
+	return uint32(uintptr(unsafe.Pointer(unsafe.SliceData(buf))))
+}
+
+//go:wasmexport orc_execute
+func execute(phasePtr, phaseSize, inputPtr, inputSize uint32) uint64 {
+	var input struct{ Request string }
+	if err := json.Unmarshal(read(inputPtr, inputSize), &input); err != nil {
+		return result(nil, err.Error())
+	}
+
+	switch phase := string(read(phasePtr, phaseSize)); phase {
+	case "echo":
+		return result(input.Request, "")
+	case "ask":
+		var reply struct {
+			Result struct{ Response string }
+			Error  *struct{ Message string }
+		}
+		call(agentExecute, map[string]string{"prompt": input.Request}, &reply)
+		if reply.Error != nil {
+			return result(nil, reply.Error.Message)
+		}
+		return result(reply.Result.Response, "")
+	case "save":
+		var reply struct{ Error *struct{ Message string } }
+		call(storageSave, map[string]interface{}{"key": "out.txt", "data": []byte(input.Request)}, &reply)
+		if reply.Error != nil {
+			return result(nil, reply.Error.Message)
+		}
+		return result("saved", "")
+	case "spin":
+		for n := 0; ; n = step(n) {
+		}
+	case "loop":
+		for {
+		}
+	default:
+		return result(nil, "unknown phase "+phase)
+	}
+}
+
+//go:noinline
+func step(n int) int {
+	return n + 1
+}
+
+func call(fn func(ptr, size uint32) uint64, params, reply interface{}) {
+	data, _ := json.Marshal(params)
+	packed := fn(uint32(uintptr(unsafe.Pointer(unsafe.SliceData(data)))), uint32(len(data)))
+	json.Unmarshal(read(uint32(packed>>32), uint32(packed)), reply)
+}
+
+func result(output interface{}, errMsg string) uint64 {
+	data, _ := json.Marshal(map[string]interface{}{"output": map[string]interface{}{"Data": output}, "error": errMsg})
+	ptr := alloc(uint32(len(data)))
+	copy(read(ptr, uint32(len(data))), data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+func read(ptr, size uint32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size)
+}
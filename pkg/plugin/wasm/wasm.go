@@ -0,0 +1,342 @@
+// Package wasm runs plugins compiled to WebAssembly, using the pure Go wazero
+// runtime. A module can only reach the host through the functions orc gives it,
+// so it sees no files, network or environment, and its memory, the function
+// calls a phase may make and the time it may run are capped.
+//
+// A plugin module exports its memory and:
+//
+//	orc_alloc(size i32) i32
+//	orc_execute(phase_ptr, phase_len, input_ptr, input_len i32) i64
+//
+// orc_alloc returns size bytes of guest memory for orc to write into. orc_execute
+// receives the phase name and the phase input as JSON and returns a pointer to its
+// result in the high 32 bits and the result's length in the low 32 bits. The
+// result is JSON: {"output": <phase output>} or {"error": "message"}.
+//
+// The host functions live in the "orc" module. Those that call the host take a
+// pointer to and length of JSON parameters and return a packed pointer and length,
+// like orc_execute:
+//
+//	agent_execute(ptr, len i32) i64   // rpc.AgentExecuteParams
+//	storage_save(ptr, len i32) i64    // rpc.StorageSaveParams
+//	storage_load(ptr, len i32) i64    // rpc.StorageLoadParams
+//	events_publish(ptr, len i32) i64  // rpc.EventParams
+//	log(ptr, len i32)                 // rpc.LogParams
+//
+// Their reply is {"result": ...} or {"error": {"code": ..., "message": ...}}, with
+// the results and error codes of the JSON-RPC protocol. WASI is available for
+// clocks, random numbers and writing to stdout and stderr, which go to orc's log.
+//
+// Each phase runs in a fresh instance of the module, so guests needn't free
+// what orc_alloc hands out.
+package wasm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+// Exports a plugin module must provide
+const (
+	ExportAlloc   = "orc_alloc"
+	ExportExecute = "orc_execute"
+)
+
+// HostModule is the name of the module holding the host functions
+const HostModule = "orc"
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 * 1024
+
+// ErrOutOfFuel is returned when a phase makes more function calls than its fuel allows
+var ErrOutOfFuel = errors.New("plugin ran out of fuel")
+
+// ErrTimeout is returned when a phase runs longer than its timeout allows
+var ErrTimeout = errors.New("plugin ran out of time")
+
+// Config configures the runtime for one plugin
+type Config struct {
+	// Plugin is the name the plugin was loaded as
+	Plugin   string
+	Services rpc.Services
+	// Authorize decides whether the plugin may call a host method; nil allows all
+	Authorize func(method string) error
+	Logger    *slog.Logger
+
+	// MaxMemory caps the module's linear memory, in bytes; zero allows the
+	// WebAssembly maximum of 4GiB
+	MaxMemory int64
+	// MaxFuel caps the function calls one phase may make; zero is unlimited.
+	// Fuel is a call budget, not an instruction count: a loop that calls nothing
+	// burns none, so only Timeout stops it. Metered modules run in wazero's
+	// interpreter.
+	MaxFuel uint64
+	// Timeout caps the time one phase may run, including loops that make no
+	// calls; zero is unlimited
+	Timeout time.Duration
+}
+
+// Module is a compiled plugin module
+type Module struct {
+	cfg      Config
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// Load compiles a plugin module and sets up the host functions it may call
+func Load(ctx context.Context, binary []byte, cfg Config) (*Module, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	cfg.Logger = cfg.Logger.With("plugin", cfg.Plugin)
+
+	runtimeConfig := wazero.NewRuntimeConfig()
+	if cfg.MaxFuel > 0 {
+		runtimeConfig = wazero.NewRuntimeConfigInterpreter()
+		ctx = experimental.WithFunctionListenerFactory(ctx, fuelListener{})
+	}
+	runtimeConfig = runtimeConfig.WithCloseOnContextDone(true)
+	if cfg.MaxMemory > 0 {
+		pages := cfg.MaxMemory / pageSize
+		if pages < 1 {
+			pages = 1
+		}
+		if pages < 1<<16 {
+			runtimeConfig = runtimeConfig.WithMemoryLimitPages(uint32(pages))
+		}
+	}
+
+	m := &Module{cfg: cfg, runtime: wazero.NewRuntimeWithConfig(ctx, runtimeConfig)}
+	if err := m.load(ctx, binary); err != nil {
+		m.runtime.Close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Module) load(ctx context.Context, binary []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, m.runtime); err != nil {
+		return fmt.Errorf("instantiating WASI: %w", err)
+	}
+
+	host := m.runtime.NewHostModuleBuilder(HostModule)
+	for name, method := range map[string]string{
+		"agent_execute":  rpc.MethodAgentExecute,
+		"storage_save":   rpc.MethodStorageSave,
+		"storage_load":   rpc.MethodStorageLoad,
+		"events_publish": rpc.MethodEventsPublish,
+	} {
+		host.NewFunctionBuilder().WithFunc(m.hostCall(method)).Export(name)
+	}
+	host.NewFunctionBuilder().WithFunc(m.log).Export("log")
+	if _, err := host.Instantiate(ctx); err != nil {
+		return fmt.Errorf("instantiating host functions: %w", err)
+	}
+
+	compiled, err := m.runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return fmt.Errorf("compiling plugin module: %w", err)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, name := range []string{ExportAlloc, ExportExecute} {
+		if _, ok := exports[name]; !ok {
+			return fmt.Errorf("plugin module doesn't export %s", name)
+		}
+	}
+	m.compiled = compiled
+	return nil
+}
+
+// Close releases the runtime and everything compiled in it
+func (m *Module) Close(ctx context.Context) error {
+	return m.runtime.Close(ctx)
+}
+
+// phaseResult is what orc_execute returns
+type phaseResult struct {
+	Output json.RawMessage `json:"output"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Execute runs a phase in a new instance of the module and returns its raw output
+func (m *Module) Execute(ctx context.Context, phase string, input interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("encoding phase input: %w", err)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if m.cfg.Timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, m.cfg.Timeout, ErrTimeout)
+		defer stop()
+	}
+	if m.cfg.MaxFuel > 0 {
+		ctx = context.WithValue(ctx, fuelKey{}, &fuel{remaining: int64(m.cfg.MaxFuel), cancel: cancel})
+	}
+
+	out := logWriter{logger: m.cfg.Logger}
+	instance, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(out).
+		WithStderr(out).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader))
+	if err != nil {
+		return nil, m.failed(ctx, "starting plugin module", err)
+	}
+	defer instance.Close(context.Background())
+
+	phasePtr, err := write(ctx, instance, []byte(phase))
+	if err != nil {
+		return nil, m.failed(ctx, "passing phase input", err)
+	}
+	inputPtr, err := write(ctx, instance, raw)
+	if err != nil {
+		return nil, m.failed(ctx, "passing phase input", err)
+	}
+	results, err := instance.ExportedFunction(ExportExecute).Call(ctx,
+		uint64(phasePtr), uint64(len(phase)), uint64(inputPtr), uint64(len(raw)))
+	if err != nil {
+		return nil, m.failed(ctx, "phase execution failed", err)
+	}
+
+	data, ok := instance.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, fmt.Errorf("phase result is outside the module's memory")
+	}
+	var result phaseResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decoding phase result: %w", err)
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result.Output, nil
+}
+
+// failed reports an error from running the module, blaming the fuel or time
+// limit if that is what stopped it
+func (m *Module) failed(ctx context.Context, doing string, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrOutOfFuel) || errors.Is(cause, ErrTimeout) {
+		return fmt.Errorf("%s: %w", doing, cause)
+	}
+	return fmt.Errorf("%s: %w", doing, err)
+}
+
+// hostReply is what a host function returns to the module
+type hostReply struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  *rpc.Error  `json:"error,omitempty"`
+}
+
+// hostCall returns the host function for a host method. Calls are checked and
+// served as they are for JSON-RPC plugins.
+func (m *Module) hostCall(method string) func(ctx context.Context, mod api.Module, ptr, size uint32) uint64 {
+	return func(ctx context.Context, mod api.Module, ptr, size uint32) uint64 {
+		var reply hostReply
+		if params, ok := mod.Memory().Read(ptr, size); !ok {
+			reply.Error = &rpc.Error{Code: rpc.CodeInvalidParams, Message: "parameters are outside the module's memory"}
+		} else if result, err := rpc.Dispatch(ctx, m.cfg.Services, m.cfg.Authorize, method, params); err != nil {
+			var rpcErr *rpc.Error
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpc.Error{Code: rpc.CodeInternalError, Message: err.Error()}
+			}
+			reply.Error = rpcErr
+		} else {
+			reply.Result = result
+		}
+
+		data, err := json.Marshal(reply)
+		if err != nil {
+			panic(fmt.Errorf("encoding %s reply: %w", method, err))
+		}
+		replyPtr, err := write(ctx, mod, data)
+		if err != nil {
+			panic(fmt.Errorf("returning %s reply: %w", method, err))
+		}
+		return uint64(replyPtr)<<32 | uint64(len(data))
+	}
+}
+
+// log is the host function writing a log line from the module to orc's log
+func (m *Module) log(ctx context.Context, mod api.Module, ptr, size uint32) {
+	data, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	var p rpc.LogParams
+	if err := json.Unmarshal(data, &p); err == nil {
+		rpc.Log(m.cfg.Logger, p)
+	}
+}
+
+// write copies data into memory allocated by the module and returns its address
+func write(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	results, err := mod.ExportedFunction(ExportAlloc).Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("%s returned memory outside the module", ExportAlloc)
+	}
+	return ptr, nil
+}
+
+// logWriter sends what the module writes to stdout and stderr to orc's log
+type logWriter struct {
+	logger *slog.Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.logger.Info("plugin output", "line", line)
+	}
+	return len(p), nil
+}
+
+// fuelKey is the context key for the fuel of the phase running on a context
+type fuelKey struct{}
+
+// fuel counts down the function calls a phase may still make
+type fuel struct {
+	remaining int64
+	cancel    context.CancelCauseFunc
+}
+
+// fuelListener burns one unit of fuel on every function call, whatever the call
+// does; instructions aren't counted. Running out cancels the phase's context,
+// which closes the module.
+type fuelListener struct{}
+
+func (fuelListener) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return fuelListener{}
+}
+
+func (fuelListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+	if f, ok := ctx.Value(fuelKey{}).(*fuel); ok && atomic.AddInt64(&f.remaining, -1) == -1 {
+		f.cancel(ErrOutOfFuel)
+	}
+}
+
+func (fuelListener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+func (fuelListener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
@@ -0,0 +1,141 @@
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotcommander/orc/pkg/plugin/rpc"
+)
+
+// fakeServices answers agent calls and records what is saved
+type fakeServices struct {
+	saved map[string][]byte
+}
+
+func (s *fakeServices) ExecuteAgent(ctx context.Context, p rpc.AgentExecuteParams) (string, error) {
+	return "answer to " + p.Prompt, nil
+}
+
+func (s *fakeServices) Save(ctx context.Context, key string, data []byte) error {
+	s.saved[key] = data
+	return nil
+}
+
+func (s *fakeServices) Load(ctx context.Context, key string) ([]byte, error) {
+	return nil, fmt.Errorf("no data for %s", key)
+}
+
+func (s *fakeServices) Publish(ctx context.Context, event rpc.EventParams) error {
+	return nil
+}
+
+// buildGuest compiles testdata/guest, which needs a Go toolchain that can
+// export functions to WebAssembly hosts
+func buildGuest(t *testing.T) []byte {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "guest.wasm")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, "./testdata/guest")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("can't build the test guest: %v\n%s", err, output)
+	}
+	binary, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+func TestModule(t *testing.T) {
+	binary := buildGuest(t)
+	ctx := context.Background()
+
+	services := &fakeServices{saved: make(map[string][]byte)}
+	load := func(t *testing.T, cfg Config) *Module {
+		t.Helper()
+		cfg.Plugin = "guest"
+		cfg.Services = services
+		m, err := Load(ctx, binary, cfg)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		t.Cleanup(func() { m.Close(ctx) })
+		return m
+	}
+	data := func(t *testing.T, raw json.RawMessage) interface{} {
+		t.Helper()
+		var output struct{ Data interface{} }
+		if err := json.Unmarshal(raw, &output); err != nil {
+			t.Fatalf("decoding output %s: %v", raw, err)
+		}
+		return output.Data
+	}
+	input := map[string]string{"Request": "hi"}
+
+	t.Run("phases and host calls", func(t *testing.T) {
+		m := load(t, Config{})
+
+		raw, err := m.Execute(ctx, "echo", input)
+		if err != nil || data(t, raw) != "hi" {
+			t.Errorf("echo = %s, %v; want hi", raw, err)
+		}
+		raw, err = m.Execute(ctx, "ask", input)
+		if err != nil || data(t, raw) != "answer to hi" {
+			t.Errorf("ask = %s, %v; want the agent's answer", raw, err)
+		}
+		if _, err := m.Execute(ctx, "save", input); err != nil || string(services.saved["out.txt"]) != "hi" {
+			t.Errorf("save = %v, stored %q", err, services.saved["out.txt"])
+		}
+		if _, err := m.Execute(ctx, "missing", input); err == nil || !strings.Contains(err.Error(), "unknown phase") {
+			t.Errorf("missing phase error = %v", err)
+		}
+	})
+
+	t.Run("denied host call", func(t *testing.T) {
+		m := load(t, Config{Authorize: func(method string) error {
+			if method == rpc.MethodStorageSave {
+				return errors.New("plugin guest lacks capability: storage")
+			}
+			return nil
+		}})
+		if _, err := m.Execute(ctx, "save", input); err == nil || !strings.Contains(err.Error(), "lacks capability") {
+			t.Errorf("save error = %v, want the capability denial", err)
+		}
+	})
+
+	t.Run("fuel", func(t *testing.T) {
+		m := load(t, Config{MaxFuel: 2_000_000})
+		if _, err := m.Execute(ctx, "spin", input); !errors.Is(err, ErrOutOfFuel) {
+			t.Errorf("spin error = %v, want ErrOutOfFuel", err)
+		}
+	})
+
+	t.Run("call-free loop", func(t *testing.T) {
+		// Burns no fuel, so only the timeout stops it
+		m := load(t, Config{MaxFuel: 2_000_000, Timeout: 200 * time.Millisecond})
+		if _, err := m.Execute(ctx, "loop", input); !errors.Is(err, ErrTimeout) {
+			t.Errorf("loop error = %v, want ErrTimeout", err)
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		// The limit is below what the Go runtime needs, which wazero either
+		// rejects up front or the module runs into when it grows its memory
+		m, err := Load(ctx, binary, Config{Plugin: "guest", MaxMemory: 1 << 20})
+		if err != nil {
+			return
+		}
+		defer m.Close(ctx)
+		if _, err := m.Execute(ctx, "echo", input); err == nil {
+			t.Error("echo ran in 1MiB")
+		}
+	})
+}
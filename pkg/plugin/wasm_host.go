@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dotcommander/orc/internal/domain"
+	domainPlugin "github.com/dotcommander/orc/internal/domain/plugin"
+	"github.com/dotcommander/orc/pkg/plugin/wasm"
+)
+
+// defaultWasmRunTime is how long a WebAssembly phase may run when neither its
+// manifest nor its security policy say
+const defaultWasmRunTime = 10 * time.Minute
+
+// loadWasmPlugin compiles an external WebAssembly plugin. Its phases call the same
+// host methods as JSON-RPC plugins, under the same security policy. Its memory is
+// capped by the manifest's max_memory, or else by the policy's MaxMemory, and
+// each phase's function calls by max_fuel. Each phase also gets a deadline, as
+// fuel doesn't stop loops that make no calls: max_run_time, or else the policy's
+// MaxCPUTime, or else defaultWasmRunTime.
+func (l *Loader) loadWasmPlugin(manifest *Manifest) (domainPlugin.DomainPlugin, error) {
+	modulePath := filepath.Join(manifest.Location, manifest.EntryPoint)
+	binary, err := os.ReadFile(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WebAssembly module: %w", err)
+	}
+
+	maxMemory, err := manifest.ResourceSpec.MaxMemoryBytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource_spec: %w", err)
+	}
+	runTime := manifest.ResourceSpec.MaxRunTime
+	if l.services.Security != nil {
+		sandbox := l.services.Security.SandboxConfig(manifest)
+		if maxMemory == 0 {
+			maxMemory = sandbox.MaxMemory
+		}
+		if runTime == 0 {
+			runTime = sandbox.MaxCPUTime
+		}
+	}
+	if runTime == 0 {
+		runTime = defaultWasmRunTime
+	}
+
+	name := manifest.Name
+	services := l.services
+	module, err := wasm.Load(context.Background(), binary, wasm.Config{
+		Plugin:   name,
+		Services: &rpcServices{services: services, plugin: name},
+		Authorize: func(method string) error {
+			return services.authorize(name, method)
+		},
+		Logger:    l.logger,
+		MaxMemory: maxMemory,
+		MaxFuel:   manifest.ResourceSpec.MaxFuel,
+		Timeout:   runTime,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load WebAssembly plugin %s: %w", name, err)
+	}
+
+	return &binaryPluginWrapper{
+		manifest: manifest,
+		execPath: modulePath,
+		services: services,
+		logger:   l.logger,
+		runner:   &wasmRunner{module: module},
+	}, nil
+}
+
+// wasmRunner runs phases in a compiled WebAssembly plugin
+type wasmRunner struct {
+	module *wasm.Module
+}
+
+func (r *wasmRunner) Execute(ctx context.Context, phase string, input domain.PhaseInput) (domain.PhaseOutput, error) {
+	raw, err := r.module.Execute(ctx, phase, input)
+	if err != nil {
+		return domain.PhaseOutput{}, fmt.Errorf("phase execution failed: %w", err)
+	}
+
+	var output domain.PhaseOutput
+	if err := json.Unmarshal(raw, &output); err != nil {
+		return domain.PhaseOutput{}, fmt.Errorf("failed to parse phase output: %w", err)
+	}
+	return output, nil
+}
+
+// Close releases the plugin's runtime
+func (r *wasmRunner) Close() error {
+	return r.module.Close(context.Background())
+}
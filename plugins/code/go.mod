@@ -2,8 +2,21 @@ module github.com/dotcommander/orc-plugin-code
 
 go 1.21
 
+require github.com/dotcommander/orc v0.1.0
+
 require (
-	github.com/dotcommander/orc v0.1.0
+	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/go-playground/validator/v10 v10.20.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/leodido/go-urn v1.4.0 // indirect
+	golang.org/x/crypto v0.19.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
-replace github.com/dotcommander/orc => ../../
\ No newline at end of file
+replace github.com/dotcommander/orc => ../../
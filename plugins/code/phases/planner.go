@@ -118,20 +118,30 @@ func (p *Planner) executePlanningWithRetry(ctx context.Context, analysisJSON str
 	err := p.resilience.ExecuteWithRetry(ctx, func() error {
 		// Build planning prompt with analysis data
 		prompt := p.buildPlanningPrompt(analysisJSON)
-		response, err := p.agent.ExecuteJSON(ctx, prompt, nil)
-		if err != nil {
-			return &core.RetryableError{
-				Err:        err,
-				RetryAfter: 2 * time.Second,
+		if structured, ok := p.agent.(core.StructuredAgent); ok {
+			// Schema validation and repair happen inside the agent
+			if err := structured.ExecuteInto(ctx, prompt, nil, &plan); err != nil {
+				return &core.RetryableError{
+					Err:        err,
+					RetryAfter: 2 * time.Second,
+				}
 			}
-		}
-		
-		// Parse the response
-		if err := json.Unmarshal([]byte(response), &plan); err != nil {
-			p.logger.Error("Failed to parse AI planning response", "error", err, "response", response)
-			return &core.RetryableError{
-				Err:        err,
-				RetryAfter: 1 * time.Second,
+		} else {
+			response, err := p.agent.ExecuteJSON(ctx, prompt, nil)
+			if err != nil {
+				return &core.RetryableError{
+					Err:        err,
+					RetryAfter: 2 * time.Second,
+				}
+			}
+			
+			// Parse the response
+			if err := json.Unmarshal([]byte(response), &plan); err != nil {
+				p.logger.Error("Failed to parse AI planning response", "error", err, "response", response)
+				return &core.RetryableError{
+					Err:        err,
+					RetryAfter: 1 * time.Second,
+				}
 			}
 		}
 		
@@ -148,44 +148,11 @@ func (p *Planner) Execute(ctx context.Context, input core.PhaseInput) (core.Phas
 		"prompt_length", len(prompt),
 	)
 	
-	// Use template rendering with JSON enforcement
-	response, err := p.agent.ExecuteJSON(ctx, prompt, "")
+	plan, err := p.requestPlan(ctx, prompt)
 	if err != nil {
-		slog.Error("AI agent execution failed",
-			"phase", p.Name(),
-			"error", err,
-		)
-		return core.PhaseOutput{}, fmt.Errorf("calling AI: %w", err)
-	}
-	
-	slog.Debug("Received AI response",
-		"phase", p.Name(),
-		"response_length", len(response),
-		"response_preview", truncateString(response, 200),
-	)
-	
-	// Debug output
-	if len(response) < 100 && strings.Contains(response, "Hello") {
-		err := fmt.Errorf("AI returned greeting instead of JSON. Response: %s. PromptPath: %s", response, p.promptPath)
-		slog.Error("Invalid AI response",
-			"phase", p.Name(),
-			"response", response,
-			"prompt_path", p.promptPath,
-			"error", err,
-		)
 		return core.PhaseOutput{}, err
 	}
 	
-	var plan NovelPlan
-	if err := json.Unmarshal([]byte(response), &plan); err != nil {
-		slog.Error("Failed to parse plan JSON",
-			"phase", p.Name(),
-			"error", err,
-			"response_preview", truncateString(response, 500),
-		)
-		return core.PhaseOutput{}, fmt.Errorf("parsing plan: %w", err)
-	}
-	
 	slog.Info("Successfully parsed novel plan",
 		"phase", p.Name(),
 		"title", plan.Title,
@@ -226,4 +193,60 @@ func (p *Planner) Execute(ctx context.Context, input core.PhaseInput) (core.Phas
 	return core.PhaseOutput{
 		Data: plan,
 	}, nil
+}
+
+// requestPlan asks the agent for the novel plan, letting agents with structured output
+// validate it against NovelPlan and repair it before it reaches us
+func (p *Planner) requestPlan(ctx context.Context, prompt string) (NovelPlan, error) {
+	if structured, ok := p.agent.(core.StructuredAgent); ok {
+		var plan NovelPlan
+		if err := structured.ExecuteInto(ctx, prompt, "", &plan); err != nil {
+			slog.Error("AI agent execution failed",
+				"phase", p.Name(),
+				"error", err,
+			)
+			return NovelPlan{}, fmt.Errorf("calling AI: %w", err)
+		}
+		return plan, nil
+	}
+	
+	// Use template rendering with JSON enforcement
+	response, err := p.agent.ExecuteJSON(ctx, prompt, "")
+	if err != nil {
+		slog.Error("AI agent execution failed",
+			"phase", p.Name(),
+			"error", err,
+		)
+		return NovelPlan{}, fmt.Errorf("calling AI: %w", err)
+	}
+	
+	slog.Debug("Received AI response",
+		"phase", p.Name(),
+		"response_length", len(response),
+		"response_preview", truncateString(response, 200),
+	)
+	
+	// Debug output
+	if len(response) < 100 && strings.Contains(response, "Hello") {
+		err := fmt.Errorf("AI returned greeting instead of JSON. Response: %s. PromptPath: %s", response, p.promptPath)
+		slog.Error("Invalid AI response",
+			"phase", p.Name(),
+			"response", response,
+			"prompt_path", p.promptPath,
+			"error", err,
+		)
+		return NovelPlan{}, err
+	}
+	
+	var plan NovelPlan
+	if err := json.Unmarshal([]byte(response), &plan); err != nil {
+		slog.Error("Failed to parse plan JSON",
+			"phase", p.Name(),
+			"error", err,
+			"response_preview", truncateString(response, 500),
+		)
+		return NovelPlan{}, fmt.Errorf("parsing plan: %w", err)
+	}
+	
+	return plan, nil
 }
\ No newline at end of file
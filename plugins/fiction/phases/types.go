@@ -56,8 +56,8 @@ type Scene struct {
 	ChapterTitle string                 `json:"chapter_title"`
 	Title        string                 `json:"title"`
 	Summary      string                 `json:"summary"`
-	Content      string                 `json:"content"`
-	Context      map[string]interface{} `json:"context"`
+	Content      string                 `json:"content,omitempty"` // Empty until the scene is written
+	Context      map[string]interface{} `json:"context,omitempty"`
 }
 
 // ID implements WorkItem interface
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -263,7 +264,7 @@ func (w *ResilientWriter) writeScene(ctx context.Context, scene Scene) (SceneRes
 		return SceneResult{}, fmt.Errorf("loading prompt: %w", err)
 	}
 	
-	content, err := w.agent.Execute(ctx, prompt, "")
+	content, err := w.generateScene(ctx, scene, prompt)
 	if err != nil {
 		return SceneResult{}, err
 	}
@@ -275,6 +276,46 @@ func (w *ResilientWriter) writeScene(ctx context.Context, scene Scene) (SceneRes
 	}, nil
 }
 
+// partialFlushInterval bounds how often streamed scene text is written to the tracker
+const partialFlushInterval = 2 * time.Second
+
+// generateScene streams the scene when the agent supports it so that text produced before a
+// timeout is kept by the scene tracker; otherwise it falls back to a single blocking call
+func (w *ResilientWriter) generateScene(ctx context.Context, scene Scene, prompt string) (string, error) {
+	streamer, ok := w.agent.(core.StreamingAgent)
+	if !ok || w.sceneTracker == nil {
+		return w.agent.Execute(ctx, prompt, "")
+	}
+	
+	var buf strings.Builder
+	lastFlush := time.Now()
+	flush := func() {
+		// Use a fresh context so a scene timeout doesn't prevent saving what we have
+		saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := w.sceneTracker.SavePartial(saveCtx, scene.ChapterNum, scene.SceneNum, buf.String()); err != nil {
+			w.logger.Warn("Failed to save partial scene", "chapter", scene.ChapterNum, "error", err)
+		}
+		lastFlush = time.Now()
+	}
+	
+	content, err := streamer.ExecuteStream(ctx, prompt, "", func(chunk string) error {
+		buf.WriteString(chunk)
+		if time.Since(lastFlush) >= partialFlushInterval {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if buf.Len() > 0 {
+			flush()
+		}
+		return "", err
+	}
+	
+	return content, nil
+}
+
 func (w *ResilientWriter) createScenes(plan NovelPlan, arch NovelArchitecture, userRequest string) []Scene {
 	var scenes []Scene
 	